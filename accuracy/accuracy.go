@@ -0,0 +1,86 @@
+//Package accuracy scores the snapshots of the yield forecasts against the measured production
+package accuracy
+
+import (
+	"fmt"
+	"solargo/inverter"
+	"solargo/persistence"
+	"solargo/yield_forecast"
+	"strings"
+	"time"
+)
+
+//ReportDays covered by the weekly report
+const ReportDays = 7
+
+//Score compares the forecast with the hourly production, only hourly periods with measured production are scored
+func Score(forecast []yield_forecast.Data, production map[time.Time]inverter.WattHour) yield_forecast.Metrics {
+	var errors []float64
+	for _, f := range forecast {
+		if f.Period != 0 && f.Period != time.Hour {
+			continue
+		}
+		actual, ok := production[f.Date.Add(-time.Hour)]
+		if !ok {
+			continue
+		}
+		errors = append(errors, float64(f.CurrentProduction-actual))
+	}
+	return yield_forecast.Errors(errors)
+}
+
+//ScoreDay scores the snapshots of the providers for every lead time on the day and saves the scores.
+//Lead times without scored periods are skipped.
+func ScoreDay(database persistence.GenericDatabase, providers []string, day time.Time) (map[string]map[string]yield_forecast.Metrics, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+
+	stamps, err := database.GetProduction(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read persisted production: %s", err)
+	}
+	production := persistence.Hourly(stamps)
+
+	scores := map[string]map[string]yield_forecast.Metrics{}
+	for _, provider := range providers {
+		for _, lead := range yield_forecast.LeadTimes {
+			forecast, err := database.GetYieldSnapshots(provider, lead.Name, from, to)
+			if err != nil {
+				return scores, fmt.Errorf("Could not read the forecasts of %s: %s", provider, err)
+			}
+			m := Score(forecast, production)
+			if m.Samples == 0 {
+				continue
+			}
+			if scores[provider] == nil {
+				scores[provider] = map[string]yield_forecast.Metrics{}
+			}
+			scores[provider][lead.Name] = m
+			database.SendForecastAccuracy(provider, lead.Name, from, m)
+		}
+	}
+	return scores, nil
+}
+
+//Report of the saved scores of the days starting after from until to, combined per provider and lead time
+func Report(database persistence.GenericDatabase, providers []string, from, to time.Time) (string, error) {
+	var lines []string
+	for _, provider := range providers {
+		for _, lead := range yield_forecast.LeadTimes {
+			metrics, err := database.GetForecastAccuracy(provider, lead.Name, from, to)
+			if err != nil {
+				return "", fmt.Errorf("Could not read the accuracy of %s: %s", provider, err)
+			}
+			m := yield_forecast.Combine(metrics...)
+			if m.Samples == 0 {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s (%s): MAE %.0f Wh, RMSE %.0f Wh, Bias %+.0f Wh, %d h",
+				provider, lead.Name, m.MAE, m.RMSE, m.Bias, m.Samples))
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return "Prognosegenauigkeit pro Stunde:\n" + strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,125 @@
+package accuracy
+
+import (
+	"math"
+	"reflect"
+	"solargo/inverter"
+	"solargo/persistence"
+	"solargo/testutils"
+	"solargo/yield_forecast"
+	"strings"
+	"testing"
+	"time"
+)
+
+//database with the snapshots of two providers and the saved scores
+type database struct {
+	testutils.SuccessDatabase
+	production []persistence.ProductionStamps
+	snapshots  map[string][]yield_forecast.Data
+	scores     map[string][]yield_forecast.Metrics
+}
+
+func (db *database) GetProduction(from, to time.Time) ([]persistence.ProductionStamps, error) {
+	return db.production, nil
+}
+
+func (db *database) GetYieldSnapshots(provider, lead string, from, to time.Time) ([]yield_forecast.Data, error) {
+	return db.snapshots[provider+" "+lead], nil
+}
+
+func (db *database) SendForecastAccuracy(provider, lead string, day time.Time, metrics yield_forecast.Metrics) {
+	db.scores[provider+" "+lead] = append(db.scores[provider+" "+lead], metrics)
+}
+
+func (db *database) GetForecastAccuracy(provider, lead string, from, to time.Time) ([]yield_forecast.Metrics, error) {
+	return db.scores[provider+" "+lead], nil
+}
+
+//production of 1000 W between 10:00 and 12:00
+func production(day time.Time) []persistence.ProductionStamps {
+	var ps []persistence.ProductionStamps
+	for t := day.Add(10 * time.Hour); t.Before(day.Add(12 * time.Hour)); t = t.Add(5 * time.Minute) {
+		ps = append(ps, persistence.ProductionStamps{Date: t, Value: 1000})
+	}
+	return ps
+}
+
+func TestScore(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	hourly := persistence.Hourly(production(day))
+	forecast := []yield_forecast.Data{
+		{Date: day.Add(10 * time.Hour), CurrentProduction: 500},                           // Not measured
+		{Date: day.Add(11 * time.Hour), CurrentProduction: 1200},                          // +200
+		{Date: day.Add(12 * time.Hour), CurrentProduction: 900, Period: time.Hour},        // -100
+		{Date: day.Add(12 * time.Hour), CurrentProduction: 100, Period: 15 * time.Minute}, // Not hourly
+	}
+
+	actual := Score(forecast, hourly)
+	want := yield_forecast.Errors([]float64{200, -100})
+	if !reflect.DeepEqual(actual, want) {
+		t.Errorf("got %v, want %v", actual, want)
+	}
+}
+
+func TestScoreDayAndReport(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	db := &database{
+		production: production(day),
+		snapshots: map[string][]yield_forecast.Data{
+			"model 6h":          {{Date: day.Add(11 * time.Hour), CurrentProduction: 1100}, {Date: day.Add(12 * time.Hour), CurrentProduction: 1100}},
+			"model 24h":         {{Date: day.Add(11 * time.Hour), CurrentProduction: 1500}},
+			"forecast.solar 6h": {{Date: day.Add(11 * time.Hour), CurrentProduction: 800}},
+		},
+		scores: map[string][]yield_forecast.Metrics{},
+	}
+
+	scores, err := ScoreDay(db, []string{"model", "forecast.solar", "solarprognose"}, day)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	want := map[string]map[string]yield_forecast.Metrics{
+		"model":          {"6h": {Samples: 2, MAE: 100, RMSE: 100, Bias: 100}, "24h": {Samples: 1, MAE: 500, RMSE: 500, Bias: 500}},
+		"forecast.solar": {"6h": {Samples: 1, MAE: 200, RMSE: 200, Bias: -200}},
+	}
+	if !reflect.DeepEqual(scores, want) {
+		t.Errorf("got %v, want %v", scores, want)
+	}
+	if len(db.scores) != 3 {
+		t.Errorf("Should save 3 scores, got %v", db.scores)
+	}
+
+	//A second day of the model
+	db.scores["model 6h"] = append(db.scores["model 6h"], yield_forecast.Metrics{Samples: 2, MAE: 300, RMSE: 300, Bias: -300})
+	report, err := Report(db, []string{"model", "forecast.solar", "solarprognose"}, day, day.AddDate(0, 0, ReportDays))
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	wantReport := "Prognosegenauigkeit pro Stunde:\n" +
+		"model (6h): MAE 200 Wh, RMSE 224 Wh, Bias -100 Wh, 4 h\n" +
+		"model (24h): MAE 500 Wh, RMSE 500 Wh, Bias +500 Wh, 1 h\n" +
+		"forecast.solar (6h): MAE 200 Wh, RMSE 200 Wh, Bias -200 Wh, 1 h"
+	if report != wantReport {
+		t.Errorf("got %s, want %s", report, wantReport)
+	}
+	if math.Abs(yield_forecast.Combine(db.scores["model 6h"]...).RMSE-math.Sqrt(50000)) > 1e-9 {
+		t.Errorf("RMSE should be combined from the squared errors")
+	}
+
+	empty, err := Report(db, []string{"solarprognose"}, day, day.AddDate(0, 0, ReportDays))
+	if err != nil || empty != "" {
+		t.Errorf("Without scores the report should be empty, got %q %v", empty, err)
+	}
+	if strings.Contains(report, "solarprognose") {
+		t.Errorf("Providers without scores should be skipped")
+	}
+}
+
+func TestScoreWithoutProduction(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	db := &database{snapshots: map[string][]yield_forecast.Data{"model 6h": {{Date: day.Add(11 * time.Hour), CurrentProduction: inverter.WattHour(1100)}}}, scores: map[string][]yield_forecast.Metrics{}}
+	scores, err := ScoreDay(db, []string{"model"}, day)
+	if err != nil || len(scores) != 0 || len(db.scores) != 0 {
+		t.Errorf("Without production nothing should be scored, got %v %v", scores, err)
+	}
+}
@@ -0,0 +1,226 @@
+//Package analytics computes the energy weighted self-consumption and autarky of days, weeks, months and years
+package analytics
+
+import (
+	"fmt"
+	"solargo/energy"
+	"solargo/inverter"
+	"solargo/persistence"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Resolutions of the periods of a report
+const (
+	Day   = "day"
+	Week  = "week"
+	Month = "month"
+	Year  = "year"
+)
+
+//Resolutions which can be selected
+var Resolutions = []string{Day, Week, Month, Year}
+
+//names of the resolutions in the reports
+var names = map[string]string{Day: "Tag", Week: "Woche", Month: "Monat", Year: "Jahr"}
+
+//Ratios of the energy of a period
+type Ratios struct {
+	Start           time.Time
+	End             time.Time
+	Production      inverter.WattHour
+	Consumption     inverter.WattHour
+	SelfConsumption inverter.WattHour // Consumption which was not purchased
+	FeedIn          inverter.WattHour
+	Import          inverter.WattHour
+}
+
+//add the energy of the counters
+func (r *Ratios) add(c energy.Counters) {
+	r.Production += c.Production
+	r.Consumption += c.Consumption
+	r.SelfConsumption += c.SelfConsumption
+	r.FeedIn += c.FeedIn
+	r.Import += c.Import
+}
+
+//SelfConsumptionRate is the share of the production consumed on site between 0 and 1, 0 without production
+func (r Ratios) SelfConsumptionRate() float64 {
+	return share(r.SelfConsumption, r.Production)
+}
+
+//Autarky is the share of the consumption which was not purchased between 0 and 1, 0 without consumption
+func (r Ratios) Autarky() float64 {
+	return share(r.SelfConsumption, r.Consumption)
+}
+
+//share of the part of the whole, a battery may shift more energy into a period than was produced in it
+func share(part, whole inverter.WattHour) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	if s := float64(part / whole); s < 1 {
+		return s
+	}
+	return 1
+}
+
+//String of the ratios and the energy in kWh
+func (r Ratios) String() string {
+	return fmt.Sprintf("Eigenverbrauch %.0f %%, Autarkie %.0f %%, Erzeugung %.1f kWh, Verbrauch %.1f kWh, Einspeisung %.1f kWh, Netzbezug %.1f kWh",
+		r.SelfConsumptionRate()*100, r.Autarky()*100, r.Production.ToKWh(), r.Consumption.ToKWh(), r.FeedIn.ToKWh(), r.Import.ToKWh())
+}
+
+//PeriodStart of the period of the resolution containing the time in its location, weeks start on Monday
+func PeriodStart(resolution string, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch resolution {
+	case Week:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case Month:
+		return day.AddDate(0, 0, 1-day.Day())
+	case Year:
+		return day.AddDate(0, 0, 1-day.YearDay())
+	}
+	return day
+}
+
+//PeriodEnd of the period of the resolution starting at the time
+func PeriodEnd(resolution string, start time.Time) time.Time {
+	switch resolution {
+	case Week:
+		return start.AddDate(0, 0, 7)
+	case Month:
+		return start.AddDate(0, 1, 0)
+	case Year:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+//Label of the period of the resolution starting at the time, e.g. 2020-W25 for a week
+func Label(resolution string, start time.Time) string {
+	switch resolution {
+	case Week:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case Month:
+		return start.Format("2006-01")
+	case Year:
+		return start.Format("2006")
+	}
+	return start.Format("2006-01-02")
+}
+
+//Aggregate the energy of the days into the periods of the resolution in the location, sorted by their start
+func Aggregate(days []energy.Counters, resolution string, loc *time.Location) []Ratios {
+	var periods []Ratios
+	index := map[time.Time]int{}
+	for _, d := range days {
+		start := PeriodStart(resolution, d.Start.In(loc))
+		i, ok := index[start]
+		if !ok {
+			i = len(periods)
+			index[start] = i
+			periods = append(periods, Ratios{Start: start, End: PeriodEnd(resolution, start)})
+		}
+		periods[i].add(d)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	return periods
+}
+
+//ByHour breaks the energy of the hours down by the hour of the day in the location, in which they start
+func ByHour(hours []energy.Counters, loc *time.Location) [24]Ratios {
+	var byHour [24]Ratios
+	for _, h := range hours {
+		byHour[h.Start.In(loc).Hour()].add(h)
+	}
+	return byHour
+}
+
+//Total of the periods
+func Total(periods []Ratios) Ratios {
+	var total Ratios
+	for i, p := range periods {
+		if i == 0 || p.Start.Before(total.Start) {
+			total.Start = p.Start
+		}
+		if p.End.After(total.End) {
+			total.End = p.End
+		}
+		total.Production += p.Production
+		total.Consumption += p.Consumption
+		total.SelfConsumption += p.SelfConsumption
+		total.FeedIn += p.FeedIn
+		total.Import += p.Import
+	}
+	return total
+}
+
+//Report of the periods of a resolution
+type Report struct {
+	Resolution string
+	Periods    []Ratios
+	Total      Ratios
+	Hours      [24]Ratios // By the hour of the day
+}
+
+//NewReport of the accounted energy of the days starting between from and to in the location
+func NewReport(database persistence.GenericDatabase, resolution string, from, to time.Time, loc *time.Location) (Report, error) {
+	r := Report{Resolution: resolution}
+	days, err := database.GetEnergy(energy.PeriodDay, from, to)
+	if err != nil {
+		return r, fmt.Errorf("Could not read the energy of the days: %s", err)
+	}
+	hours, err := database.GetEnergy(energy.PeriodHour, from, to)
+	if err != nil {
+		return r, fmt.Errorf("Could not read the energy of the hours: %s", err)
+	}
+	r.Periods = Aggregate(days, resolution, loc)
+	r.Total = Total(r.Periods)
+	r.Hours = ByHour(hours, loc)
+	return r, nil
+}
+
+//String of the report with one line per period and the total
+func (r Report) String() string {
+	if len(r.Periods) == 0 {
+		return ""
+	}
+	lines := []string{fmt.Sprintf("Eigenverbrauch und Autarkie pro %s:", names[r.Resolution])}
+	for _, p := range r.Periods {
+		lines = append(lines, Label(r.Resolution, p.Start)+": "+p.String())
+	}
+	if len(r.Periods) > 1 {
+		lines = append(lines, "Gesamt: "+r.Total.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+//HoursString of the breakdown by the hour of the day, hours without energy are skipped
+func (r Report) HoursString() string {
+	lines := []string{"Eigenverbrauch und Autarkie nach Tageszeit:"}
+	for h, ratios := range r.Hours {
+		if ratios.Production <= 0 && ratios.Consumption <= 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%02d:00: Eigenverbrauch %.0f %%, Autarkie %.0f %%", h, ratios.SelfConsumptionRate()*100, ratios.Autarky()*100))
+	}
+	return strings.Join(lines, "\n")
+}
+
+//Today of the hours accounted since midnight of now in the location
+func Today(database persistence.GenericDatabase, now time.Time, loc *time.Location) (Ratios, error) {
+	start := PeriodStart(Day, now.In(loc))
+	hours, err := database.GetEnergy(energy.PeriodHour, start, now)
+	if err != nil {
+		return Ratios{}, fmt.Errorf("Could not read the energy of today: %s", err)
+	}
+	today := Ratios{Start: start, End: PeriodEnd(Day, start)}
+	for _, h := range hours {
+		today.add(h)
+	}
+	return today, nil
+}
@@ -0,0 +1,147 @@
+package analytics
+
+import (
+	"math"
+	"solargo/emissions"
+	"solargo/energy"
+	"solargo/inverter"
+	"solargo/testutils"
+	"strings"
+	"testing"
+	"time"
+)
+
+var vienna, _ = time.LoadLocation("Europe/Vienna")
+
+//database with the accounted energy of the days and hours and the emission factors
+type database struct {
+	testutils.SuccessDatabase
+	days, hours []energy.Counters
+	intensities []emissions.Intensity
+}
+
+func (db *database) GetEmissionFactors(from, to time.Time) ([]emissions.Intensity, error) {
+	return db.intensities, nil
+}
+
+func (db *database) GetEnergy(period string, from, to time.Time) ([]energy.Counters, error) {
+	counters := db.hours
+	if period == energy.PeriodDay {
+		counters = db.days
+	}
+	var result []energy.Counters
+	for _, c := range counters {
+		if !c.Start.Before(from) && c.Start.Before(to) {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func TestRatios(t *testing.T) {
+	tests := []struct {
+		name            string
+		ratios          Ratios
+		selfConsumption float64
+		autarky         float64
+	}{
+		{"Summer day", Ratios{Production: 20000, Consumption: 8000, SelfConsumption: 6000}, 0.3, 0.75},
+		{"Night", Ratios{Consumption: 2000}, 0, 0},
+		{"Battery", Ratios{Production: 100, Consumption: 1000, SelfConsumption: 500}, 1, 0.5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if s, a := test.ratios.SelfConsumptionRate(), test.ratios.Autarky(); math.Abs(s-test.selfConsumption) > 1e-9 || math.Abs(a-test.autarky) > 1e-9 {
+				t.Errorf("got %g and %g, want %g and %g", s, a, test.selfConsumption, test.autarky)
+			}
+		})
+	}
+}
+
+func TestPeriods(t *testing.T) {
+	//2020-06-21 is a Sunday
+	at := time.Date(2020, time.June, 21, 15, 30, 0, 0, vienna)
+	tests := []struct {
+		resolution string
+		start      time.Time
+		end        time.Time
+		label      string
+	}{
+		{Day, time.Date(2020, time.June, 21, 0, 0, 0, 0, vienna), time.Date(2020, time.June, 22, 0, 0, 0, 0, vienna), "2020-06-21"},
+		{Week, time.Date(2020, time.June, 15, 0, 0, 0, 0, vienna), time.Date(2020, time.June, 22, 0, 0, 0, 0, vienna), "2020-W25"},
+		{Month, time.Date(2020, time.June, 1, 0, 0, 0, 0, vienna), time.Date(2020, time.July, 1, 0, 0, 0, 0, vienna), "2020-06"},
+		{Year, time.Date(2020, time.January, 1, 0, 0, 0, 0, vienna), time.Date(2021, time.January, 1, 0, 0, 0, 0, vienna), "2020"},
+	}
+	for _, test := range tests {
+		t.Run(test.resolution, func(t *testing.T) {
+			start := PeriodStart(test.resolution, at)
+			if !start.Equal(test.start) || !PeriodEnd(test.resolution, start).Equal(test.end) || Label(test.resolution, start) != test.label {
+				t.Errorf("got %s - %s %s", start, PeriodEnd(test.resolution, start), Label(test.resolution, start))
+			}
+		})
+	}
+}
+
+//day of accounted energy, the days are saved at midnight in the location
+func day(year int, month time.Month, d int, production, consumption, self inverter.WattHour) energy.Counters {
+	start := time.Date(year, month, d, 0, 0, 0, 0, vienna).UTC()
+	return energy.Counters{Period: energy.PeriodDay, Start: start, End: start.AddDate(0, 0, 1), Production: production, Consumption: consumption, SelfConsumption: self}
+}
+
+func TestReport(t *testing.T) {
+	noon := time.Date(2020, time.June, 21, 12, 0, 0, 0, vienna)
+	db := &database{
+		days: []energy.Counters{
+			day(2020, time.July, 1, 10000, 10000, 5000),
+			day(2020, time.June, 30, 30000, 10000, 5000),
+			day(2020, time.June, 21, 10000, 10000, 5000),
+		},
+		hours: []energy.Counters{
+			{Period: energy.PeriodHour, Start: noon, Production: 3000, Consumption: 1000, SelfConsumption: 1000},
+			{Period: energy.PeriodHour, Start: noon.AddDate(0, 0, 1), Production: 1000, Consumption: 1000, SelfConsumption: 500},
+			{Period: energy.PeriodHour, Start: noon.Add(10 * time.Hour), Consumption: 500},
+		},
+	}
+
+	r, err := NewReport(db, Month, time.Date(2020, time.June, 1, 0, 0, 0, 0, vienna), time.Date(2020, time.August, 1, 0, 0, 0, 0, vienna), vienna)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if len(r.Periods) != 2 || !r.Periods[0].Start.Equal(time.Date(2020, time.June, 1, 0, 0, 0, 0, vienna)) || r.Periods[0].Production != 40000 || r.Periods[1].Production != 10000 {
+		t.Fatalf("got %+v", r.Periods)
+	}
+	//The ratios are weighted by the energy, not averaged over the days
+	if s := r.Periods[0].SelfConsumptionRate(); s != 0.25 {
+		t.Errorf("got self consumption %g, want 0.25", s)
+	}
+	if r.Total.Production != 50000 || r.Total.SelfConsumptionRate() != 0.3 || !r.Total.End.Equal(time.Date(2020, time.August, 1, 0, 0, 0, 0, vienna)) {
+		t.Errorf("got total %+v", r.Total)
+	}
+	if h := r.Hours[12]; h.Production != 4000 || h.SelfConsumptionRate() != 0.375 || h.Autarky() != 0.75 {
+		t.Errorf("got noon %+v", h)
+	}
+
+	text := r.String()
+	want := []string{
+		"Eigenverbrauch und Autarkie pro Monat:",
+		"2020-06: Eigenverbrauch 25 %, Autarkie 50 %, Erzeugung 40.0 kWh, Verbrauch 20.0 kWh, Einspeisung 0.0 kWh, Netzbezug 0.0 kWh",
+		"2020-07: Eigenverbrauch 50 %, Autarkie 50 %",
+		"Gesamt: Eigenverbrauch 30 %, Autarkie 50 %",
+	}
+	for _, w := range want {
+		if !strings.Contains(text, w) {
+			t.Errorf("got %q, want %q", text, w)
+		}
+	}
+	if hours := r.HoursString(); hours != "Eigenverbrauch und Autarkie nach Tageszeit:\n12:00: Eigenverbrauch 38 %, Autarkie 75 %\n22:00: Eigenverbrauch 0 %, Autarkie 0 %" {
+		t.Errorf("got %q", hours)
+	}
+	if s := (Report{}).String(); s != "" {
+		t.Errorf("An empty report should be empty, got %q", s)
+	}
+
+	today, err := Today(db, noon.Add(3*time.Hour), vienna)
+	if err != nil || today.Production != 3000 || today.Autarky() != 1 {
+		t.Errorf("got %+v %v", today, err)
+	}
+}
@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"fmt"
+	"solargo/emissions"
+	"solargo/energy"
+	"solargo/persistence"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Emissions avoided in the periods of the resolution by the hours starting between from and to in the location,
+//sorted by their start. With an hourly factor, the intensities of the hours are loaded first.
+func Emissions(database persistence.GenericDatabase, factor *emissions.Factor, resolution string, from, to time.Time, loc *time.Location) ([]emissions.Savings, error) {
+	if factor.Hourly {
+		intensities, err := database.GetEmissionFactors(from, to)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the emission factors: %s", err)
+		}
+		factor.Load(intensities)
+	}
+	hours, err := database.GetEnergy(energy.PeriodHour, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the energy of the hours: %s", err)
+	}
+
+	var periods []emissions.Savings
+	index := map[time.Time]int{}
+	for _, h := range hours {
+		start := PeriodStart(resolution, h.Start.In(loc))
+		i, ok := index[start]
+		if !ok {
+			i = len(periods)
+			index[start] = i
+			periods = append(periods, emissions.Savings{Start: start, End: PeriodEnd(resolution, start)})
+		}
+		periods[i].Add(h, factor)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	return periods, nil
+}
+
+//EmissionsString of the savings of the periods of the resolution with one line per period
+func EmissionsString(resolution string, periods []emissions.Savings) string {
+	if len(periods) == 0 {
+		return ""
+	}
+	lines := []string{fmt.Sprintf("CO2-Einsparung pro %s:", names[resolution])}
+	for _, p := range periods {
+		lines = append(lines, Label(resolution, p.Start)+": "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
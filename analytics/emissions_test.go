@@ -0,0 +1,59 @@
+package analytics
+
+import (
+	"solargo/emissions"
+	"solargo/energy"
+	"testing"
+	"time"
+)
+
+func TestEmissions(t *testing.T) {
+	noon := time.Date(2020, time.June, 30, 12, 0, 0, 0, vienna)
+	db := &database{
+		hours: []energy.Counters{
+			{Period: energy.PeriodHour, Start: noon, Production: 3000, SelfConsumption: 1000},
+			{Period: energy.PeriodHour, Start: noon.Add(time.Hour), Production: 2000, SelfConsumption: 1000},
+			{Period: energy.PeriodHour, Start: noon.AddDate(0, 0, 1), Production: 1000, SelfConsumption: 1000},
+		},
+		intensities: []emissions.Intensity{{Start: noon, Factor: 200}},
+	}
+	from, to := time.Date(2020, time.June, 1, 0, 0, 0, 0, vienna), time.Date(2020, time.August, 1, 0, 0, 0, 0, vienna)
+
+	tests := []struct {
+		testName string
+		factor   emissions.Factor
+		avoided  []float64
+	}{
+		{"Static", emissions.Factor{Static: 400}, []float64{2000, 400}},
+		{"Hourly", emissions.Factor{Static: 400, Hourly: true}, []float64{1400, 400}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			periods, err := Emissions(db, &tt.factor, Month, from, to, vienna)
+			if err != nil {
+				t.Fatalf("Should not produce Error: %s", err)
+			}
+			if len(periods) != len(tt.avoided) {
+				t.Fatalf("got %+v, want %d periods", periods, len(tt.avoided))
+			}
+			for i, p := range periods {
+				if p.Avoided != tt.avoided[i] {
+					t.Errorf("got %g g avoided in %s, want %g", p.Avoided, Label(Month, p.Start), tt.avoided[i])
+				}
+			}
+			if !periods[1].Start.Equal(time.Date(2020, time.July, 1, 0, 0, 0, 0, vienna)) {
+				t.Errorf("got start %s, want July", periods[1].Start)
+			}
+		})
+	}
+
+	periods, _ := Emissions(db, &emissions.Factor{Static: 400}, Year, from, to, vienna)
+	want := "CO2-Einsparung pro Jahr:\n2020: vermieden 2.4 kg CO2, davon 1.2 kg durch Eigenverbrauch, entspricht 16 km Autofahrt oder 0.2 Bäumen pro Jahr"
+	if ans := EmissionsString(Year, periods); ans != want {
+		t.Errorf("got %q, want %q", ans, want)
+	}
+	if ans := EmissionsString(Year, nil); ans != "" {
+		t.Errorf("Without periods the text should be empty, got %q", ans)
+	}
+}
@@ -0,0 +1,147 @@
+//Package backfill fills gaps in the persisted data from the archive of the inverter
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"solargo/inverter"
+	"solargo/persistence"
+	"sort"
+	"time"
+
+	"github.com/nathan-osman/go-sunrise"
+	log "github.com/sirupsen/logrus"
+)
+
+//Default settings if nothing is configured
+const (
+	DefaultMaxAge    = 7 * 24 * time.Hour
+	DefaultChunkSize = 24 * time.Hour
+	DefaultMinGap    = 10 * time.Minute
+)
+
+//Gap in the persisted data
+type Gap struct {
+	From time.Time
+	To   time.Time
+}
+
+//Options of a backfill run
+type Options struct {
+	Latitude  float64
+	Longitude float64
+	ChunkSize time.Duration // Range of a single archive request
+	MinGap    time.Duration // Shorter periods without data are ignored
+}
+
+//String representation of a gap
+func (g Gap) String() string {
+	return fmt.Sprintf("%s - %s", g.From.Format(time.RFC3339), g.To.Format(time.RFC3339))
+}
+
+//Chunks splits the gap into ranges not larger than size
+func (g Gap) Chunks(size time.Duration) []Gap {
+	var chunks []Gap
+	for from := g.From; from.Before(g.To); from = from.Add(size) {
+		to := from.Add(size)
+		if to.After(g.To) {
+			to = g.To
+		}
+		chunks = append(chunks, Gap{from, to})
+	}
+	return chunks
+}
+
+//FindGaps returns all periods between sunrise and sunset which are longer than minGap and contain no stamp
+func FindGaps(stamps []time.Time, from, to time.Time, minGap time.Duration, latitude, longitude float64) []Gap {
+	sorted := make([]time.Time, len(stamps))
+	copy(sorted, stamps)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	var gaps []Gap
+	year, month, day := from.Date()
+	for date := time.Date(year, month, day, 0, 0, 0, 0, from.Location()); date.Before(to); date = date.AddDate(0, 0, 1) {
+		//The inverter sleeps at night, so missing data is expected there
+		rise, set := sunrise.SunriseSunset(latitude, longitude, date.Year(), date.Month(), date.Day())
+		if rise.Before(from) {
+			rise = from
+		}
+		if set.After(to) {
+			set = to
+		}
+		if !rise.Before(set) {
+			continue
+		}
+
+		last := rise
+		for _, s := range sorted {
+			if s.Before(rise) || s.After(set) {
+				continue
+			}
+			if s.Sub(last) > minGap {
+				gaps = append(gaps, Gap{last, s})
+			}
+			last = s
+		}
+		if set.Sub(last) > minGap {
+			gaps = append(gaps, Gap{last, set})
+		}
+	}
+	return gaps
+}
+
+//Backfill fetches the gaps from the inverter archive and persists them, it returns the number of saved samples.
+//It stops between two chunks if the context is cancelled.
+func Backfill(ctx context.Context, archive inverter.ArchiveInverter, database persistence.GenericDatabase, gaps []Gap, chunkSize time.Duration) (int, error) {
+	if chunkSize <= 0 || chunkSize > inverter.MaxArchiveRange {
+		chunkSize = DefaultChunkSize
+	}
+
+	saved := 0
+	for _, gap := range gaps {
+		for _, chunk := range gap.Chunks(chunkSize) {
+			if err := ctx.Err(); err != nil {
+				return saved, fmt.Errorf("Backfill stopped before %s: %s", chunk, err)
+			}
+
+			log.Info("Backfill ", chunk)
+			data, err := archive.RetrieveArchiveData(ctx, chunk.From, chunk.To)
+			if err != nil {
+				return saved, fmt.Errorf("Could not retrieve archive data for %s: %s", chunk, err)
+			}
+
+			if err := database.SendArchiveData(data); err != nil {
+				return saved, fmt.Errorf("Could not save archive data for %s: %s", chunk, err)
+			}
+			saved += len(data)
+		}
+	}
+	return saved, nil
+}
+
+//Run detects gaps in the persisted data between from and to and backfills them
+func Run(ctx context.Context, gi inverter.GenericInverter, database persistence.GenericDatabase, from, to time.Time, options Options) (int, error) {
+	archive, ok := gi.(inverter.ArchiveInverter)
+	if !ok {
+		return 0, fmt.Errorf("Inverter does not provide an archive")
+	}
+
+	if options.MinGap <= 0 {
+		options.MinGap = DefaultMinGap
+	}
+
+	ps, err := database.GetProduction(from, to)
+	if err != nil {
+		return 0, fmt.Errorf("Could not read persisted production: %s", err)
+	}
+
+	stamps := make([]time.Time, len(ps))
+	for i, p := range ps {
+		stamps[i] = p.Date
+	}
+
+	gaps := FindGaps(stamps, from, to, options.MinGap, options.Latitude, options.Longitude)
+	log.Info("Found ", len(gaps), " gaps between ", from, " and ", to)
+
+	return Backfill(ctx, archive, database, gaps, options.ChunkSize)
+}
@@ -0,0 +1,176 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"solargo/inverter"
+	"solargo/persistence"
+	"solargo/testutils"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sunrise"
+)
+
+const (
+	latitude  = 48.2
+	longitude = 16.37
+)
+
+//archive returns one sample per minute of the requested range
+type archive struct {
+	testutils.SuccessInverter
+	requests []Gap
+	fail     bool
+}
+
+func (a *archive) RetrieveArchiveData(ctx context.Context, from, to time.Time) ([]inverter.Data, error) {
+	a.requests = append(a.requests, Gap{from, to})
+	if a.fail {
+		return nil, fmt.Errorf("Error")
+	}
+	var data []inverter.Data
+	for t := from; t.Before(to); t = t.Add(time.Minute) {
+		var d inverter.Data
+		d.Info.Date = t
+		data = append(data, d)
+	}
+	return data, nil
+}
+
+//database returns the configured stamps and counts the saved archive data
+type database struct {
+	testutils.SuccessDatabase
+	stamps []persistence.ProductionStamps
+	saved  int
+}
+
+func (db *database) GetProduction(from, to time.Time) ([]persistence.ProductionStamps, error) {
+	return db.stamps, nil
+}
+
+func (db *database) SendArchiveData(data []inverter.Data) error {
+	db.saved += len(data)
+	return nil
+}
+
+func stampsBetween(from, to time.Time) []time.Time {
+	var stamps []time.Time
+	for t := from; !t.After(to); t = t.Add(30 * time.Second) {
+		stamps = append(stamps, t)
+	}
+	return stamps
+}
+
+func TestFindGaps(t *testing.T) {
+	from := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	rise, set := sunrise.SunriseSunset(latitude, longitude, 2020, time.June, 21)
+
+	morning := stampsBetween(from.Add(6*time.Hour), from.Add(12*time.Hour))
+	afternoon := stampsBetween(from.Add(13*time.Hour), from.Add(18*time.Hour))
+	//Stamps at night never produce a gap
+	night := []time.Time{from.Add(time.Hour), from.Add(23 * time.Hour)}
+
+	var tests = []struct {
+		testName string
+		stamps   []time.Time
+		from     time.Time
+		to       time.Time
+		want     []Gap
+	}{
+		{"No data", nil, from, to, []Gap{{rise, set}}},
+		{"Night only", night, from, to, []Gap{{rise, set}}},
+		{"Gaps", append(append(afternoon, morning...), night...), from, to, []Gap{
+			{rise, from.Add(6 * time.Hour)},
+			{from.Add(12 * time.Hour), from.Add(13 * time.Hour)},
+			{from.Add(18 * time.Hour), set},
+		}},
+		{"Clipped range", morning, from.Add(7 * time.Hour), from.Add(14 * time.Hour), []Gap{
+			{from.Add(12 * time.Hour), from.Add(14 * time.Hour)},
+		}},
+		{"Full day", stampsBetween(rise, set), from, to, nil},
+		{"Two days", nil, from, to.Add(24 * time.Hour), []Gap{{rise, set}, func() Gap {
+			r, s := sunrise.SunriseSunset(latitude, longitude, 2020, time.June, 22)
+			return Gap{r, s}
+		}()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			ans := FindGaps(tt.stamps, tt.from, tt.to, 10*time.Minute, latitude, longitude)
+			if !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunks(t *testing.T) {
+	from := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	gap := Gap{from, from.Add(50 * time.Hour)}
+
+	want := []Gap{
+		{from, from.Add(24 * time.Hour)},
+		{from.Add(24 * time.Hour), from.Add(48 * time.Hour)},
+		{from.Add(48 * time.Hour), from.Add(50 * time.Hour)},
+	}
+
+	if ans := gap.Chunks(24 * time.Hour); !reflect.DeepEqual(ans, want) {
+		t.Errorf("got %v, want %v", ans, want)
+	}
+	if ans := (Gap{from, from}).Chunks(time.Hour); len(ans) != 0 {
+		t.Errorf("Empty gap should not have chunks, got %v", ans)
+	}
+}
+
+func TestRun(t *testing.T) {
+	from := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	rise, set := sunrise.SunriseSunset(latitude, longitude, 2020, time.June, 21)
+
+	var db database
+	stamps := stampsBetween(rise, from.Add(12*time.Hour))
+	for _, s := range stamps {
+		db.stamps = append(db.stamps, persistence.ProductionStamps{Date: s})
+	}
+	last := stamps[len(stamps)-1]
+
+	var a archive
+	saved, err := Run(context.Background(), &a, &db, from, to, Options{Latitude: latitude, Longitude: longitude, ChunkSize: 4 * time.Hour})
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	want := Gap{last, set}.Chunks(4 * time.Hour)
+	if !reflect.DeepEqual(a.requests, want) {
+		t.Errorf("got requests %v, want %v", a.requests, want)
+	}
+	if saved != db.saved || saved != int(set.Sub(last).Minutes())+1 {
+		t.Errorf("Saved %d samples, database received %d", saved, db.saved)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	from := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	var db database
+	var iv testutils.SuccessInverter
+	if _, err := Run(context.Background(), &iv, &db, from, to, Options{}); err == nil {
+		t.Errorf("Inverter without archive should produce an error")
+	}
+
+	a := archive{fail: true}
+	if _, err := Run(context.Background(), &a, &db, from, to, Options{}); err == nil {
+		t.Errorf("Failing archive should produce an error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a = archive{}
+	if _, err := Run(ctx, &a, &db, from, to, Options{}); err == nil || len(a.requests) != 0 {
+		t.Errorf("Cancelled backfill should stop before the first request, got %v and %v", err, a.requests)
+	}
+}
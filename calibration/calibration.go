@@ -0,0 +1,182 @@
+//Package calibration learns a per-site correction of the yield model from the measured production
+package calibration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"solargo/inverter"
+	"solargo/yield_forecast"
+	"sort"
+	"time"
+)
+
+//Default settings if nothing is configured
+const (
+	DefaultFile    = "calibration.json"
+	DefaultHistory = 90 * 24 * time.Hour
+)
+
+//Size of the bins in degrees of the sun position and percent of the cloud cover
+const (
+	ElevationStep = 10.0
+	AzimuthStep   = 30.0
+	CloudStep     = 25.0
+)
+
+//MinSamples of a bin until its correction is fully trusted, bins with fewer samples are pulled towards no correction
+const MinSamples = 10
+
+//TestDays are the most recent days of the history, which are held back to measure the error of a trained model
+const TestDays = 7
+
+//MaxFactor limits the correction of a bin, e.g. if the baseline is almost zero
+const MaxFactor = 3.0
+
+//Sample of an hour with the baseline forecast and the measured production
+type Sample struct {
+	Date         time.Time // Start of the hour
+	Sun          yield_forecast.SunPosition
+	CloudDensity float64
+	Baseline     inverter.WattHour
+	Actual       inverter.WattHour
+}
+
+//Bin of similar hours, the correction is the ratio of the sums
+type Bin struct {
+	Baseline float64 `json:"baseline"`
+	Actual   float64 `json:"actual"`
+	Samples  int     `json:"samples"`
+}
+
+//Model of the correction, which is persisted between trainings
+type Model struct {
+	TrainedAt  time.Time              `json:"trained_at"`
+	From       time.Time              `json:"from"`
+	To         time.Time              `json:"to"`
+	Bins       map[string]Bin         `json:"bins"`
+	Baseline   yield_forecast.Metrics `json:"baseline"`   // Error of the baseline on the test days
+	Calibrated yield_forecast.Metrics `json:"calibrated"` // Error of the corrected baseline on the test days
+}
+
+//binKey of the sun position and cloud cover
+func binKey(sun yield_forecast.SunPosition, cloudDensity float64) string {
+	elevation := int(math.Floor(sun.Elevation() / ElevationStep))
+	azimuth := int(math.Floor((sun.Azimuth + 180) / AzimuthStep))
+	cloud := int(math.Min(math.Floor(cloudDensity/CloudStep), 100/CloudStep-1))
+	return fmt.Sprintf("e%d/a%d/c%d", elevation, azimuth, cloud)
+}
+
+//Factor to correct the baseline at the sun position and cloud cover, 1 if the model knows nothing about it
+func (m *Model) Factor(sun yield_forecast.SunPosition, cloudDensity float64) float64 {
+	if m == nil {
+		return 1
+	}
+	b, ok := m.Bins[binKey(sun, cloudDensity)]
+	if !ok || b.Samples == 0 || b.Baseline <= 0 {
+		return 1
+	}
+	factor := math.Min(MaxFactor, b.Actual/b.Baseline)
+	weight := float64(b.Samples) / float64(b.Samples+MinSamples)
+	return weight*factor + (1 - weight)
+}
+
+//Correct the baseline of the sample
+func (m *Model) Correct(s Sample) inverter.WattHour {
+	return inverter.WattHour(m.Factor(s.Sun, s.CloudDensity)) * s.Baseline
+}
+
+//fit the bins to the samples
+func fit(samples []Sample) map[string]Bin {
+	bins := map[string]Bin{}
+	for _, s := range samples {
+		key := binKey(s.Sun, s.CloudDensity)
+		b := bins[key]
+		b.Baseline += float64(s.Baseline)
+		b.Actual += float64(s.Actual)
+		b.Samples++
+		bins[key] = b
+	}
+	return bins
+}
+
+//Evaluate the error of the forecast on the samples
+func Evaluate(samples []Sample, forecast func(Sample) inverter.WattHour) yield_forecast.Metrics {
+	errors := make([]float64, len(samples))
+	for i, s := range samples {
+		errors[i] = float64(forecast(s) - s.Actual)
+	}
+	return yield_forecast.Errors(errors)
+}
+
+//Train a model on the samples. The last TestDays days are held back to measure the error,
+//afterwards the model is fitted to all samples.
+func Train(samples []Sample, loc *time.Location) (*Model, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("No samples to train the calibration")
+	}
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	if loc == nil {
+		loc = time.Local
+	}
+	last := sorted[len(sorted)-1].Date.In(loc)
+	split := time.Date(last.Year(), last.Month(), last.Day()-TestDays+1, 0, 0, 0, 0, loc)
+	i := sort.Search(len(sorted), func(i int) bool { return !sorted[i].Date.Before(split) })
+	train, test := sorted[:i], sorted[i:]
+
+	m := &Model{TrainedAt: time.Now(), From: sorted[0].Date, To: last}
+	m.Baseline = Evaluate(test, func(s Sample) inverter.WattHour { return s.Baseline })
+	if len(train) > 0 {
+		m.Bins = fit(train)
+		m.Calibrated = Evaluate(test, m.Correct)
+	} else {
+		//Without older days the error can not be measured independently of the training
+		m.Calibrated = m.Baseline
+	}
+
+	m.Bins = fit(sorted)
+	return m, nil
+}
+
+//Load a model from the file
+func Load(path string) (*Model, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Model
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("Can not read calibration %s. Error: %s", path, err)
+	}
+	return &m, nil
+}
+
+//Save the model to the file, the file is replaced atomically
+func (m *Model) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Can not save calibration. Error: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Can not save calibration. Error: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Can not save calibration. Error: %s", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Can not save calibration. Error: %s", err)
+	}
+	return nil
+}
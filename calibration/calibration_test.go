@@ -0,0 +1,151 @@
+package calibration
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"solargo/inverter"
+	"solargo/persistence"
+	"solargo/testutils"
+	"solargo/weather"
+	"solargo/yield_forecast"
+	"testing"
+	"time"
+)
+
+//database with the production of a site, which is shaded in the morning
+type database struct {
+	testutils.SuccessDatabase
+	production []persistence.ProductionStamps
+	forecasts  []weather.Forecast
+}
+
+func (db *database) GetProduction(from, to time.Time) ([]persistence.ProductionStamps, error) {
+	var ps []persistence.ProductionStamps
+	for _, p := range db.production {
+		if !p.Date.Before(from) && p.Date.Before(to) {
+			ps = append(ps, p)
+		}
+	}
+	return ps, nil
+}
+
+func (db *database) GetWeatherForecast(from, to time.Time) ([]weather.Forecast, error) {
+	return db.forecasts, nil
+}
+
+func baseline() *yield_forecast.Model {
+	return &yield_forecast.Model{Latitude: 48.2, Longitude: 16.37, Planes: []yield_forecast.Plane{{Declination: 30, Azimuth: 0, KWP: 5}}, Losses: 14, Location: time.UTC}
+}
+
+//shadedSite polls every 5 minutes, the production is halved while the sun is in the east
+func shadedSite(m *yield_forecast.Model, from time.Time, days int) *database {
+	db := &database{}
+	for d := 0; d < days; d++ {
+		day := from.AddDate(0, 0, d)
+		cloud := float64(d%2) * 50
+		db.forecasts = append(db.forecasts, weather.Forecast{Date: day, Period: 24 * time.Hour, CloudDensity: cloud, Temperature: 20})
+		for t := day; t.Before(day.AddDate(0, 0, 1)); t = t.Add(5 * time.Minute) {
+			power := m.Power(t, &db.forecasts[d])
+			if power <= 0 {
+				continue
+			}
+			if yield_forecast.SolarPosition(t, m.Latitude, m.Longitude).Azimuth < -60 {
+				power /= 2
+			}
+			db.production = append(db.production, persistence.ProductionStamps{Date: t, Value: power})
+		}
+	}
+	return db
+}
+
+func TestFactor(t *testing.T) {
+	sun := yield_forecast.SunPosition{Zenith: 40, Azimuth: 10}
+	tests := []struct {
+		name     string
+		bin      Bin
+		expected float64
+	}{
+		{"Trusted", Bin{Baseline: 1000, Actual: 800, Samples: 990}, 0.802},
+		{"Few samples", Bin{Baseline: 1000, Actual: 800, Samples: 10}, 0.9},
+		{"Limited", Bin{Baseline: 1, Actual: 1000, Samples: 1000000}, MaxFactor},
+		{"Empty", Bin{}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := &Model{Bins: map[string]Bin{binKey(sun, 30): test.bin}}
+			if actual := m.Factor(sun, 30); math.Abs(actual-test.expected) > 0.001 {
+				t.Errorf("got %f, want %f", actual, test.expected)
+			}
+			if actual := m.Factor(sun, 80); actual != 1 {
+				t.Errorf("Unknown bin: got %f, want 1", actual)
+			}
+		})
+	}
+
+	var none *Model
+	if actual := none.Factor(sun, 30); actual != 1 {
+		t.Errorf("Without a model: got %f, want 1", actual)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	samples := []Sample{{Baseline: 100, Actual: 90}, {Baseline: 100, Actual: 130}, {Baseline: 0, Actual: 0}}
+	actual := Evaluate(samples, func(s Sample) inverter.WattHour { return s.Baseline })
+	want := yield_forecast.Metrics{Samples: 3, MAE: 40.0 / 3, RMSE: math.Sqrt(1000.0 / 3), Bias: -20.0 / 3}
+	if math.Abs(actual.MAE-want.MAE) > 1e-9 || math.Abs(actual.RMSE-want.RMSE) > 1e-9 || math.Abs(actual.Bias-want.Bias) > 1e-9 || actual.Samples != want.Samples {
+		t.Errorf("got %v, want %v", actual, want)
+	}
+}
+
+func TestRetrain(t *testing.T) {
+	m := baseline()
+	from := time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC)
+	db := shadedSite(m, from, 56)
+	path := filepath.Join(t.TempDir(), "calibration.json")
+
+	trained, err := Retrain(db, m, from, from.AddDate(0, 0, 56), path)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if trained.Baseline.Samples == 0 || trained.Baseline.Samples != trained.Calibrated.Samples {
+		t.Errorf("Should evaluate on the test days, got %v and %v", trained.Baseline, trained.Calibrated)
+	}
+	if trained.Baseline.Bias <= 0 {
+		t.Errorf("The baseline should overestimate the shaded site, got %v", trained.Baseline)
+	}
+	if trained.Calibrated.RMSE >= trained.Baseline.RMSE*0.6 {
+		t.Errorf("The calibration should reduce the error, got %v and baseline %v", trained.Calibrated, trained.Baseline)
+	}
+
+	morning := yield_forecast.SunPosition{Zenith: 70, Azimuth: -100}
+	noon := yield_forecast.SunPosition{Zenith: 30, Azimuth: 0}
+	if factor := trained.Factor(morning, 0); factor > 0.7 {
+		t.Errorf("Morning should be corrected, got %f", factor)
+	}
+	if factor := trained.Factor(noon, 0); math.Abs(factor-1) > 0.01 {
+		t.Errorf("Noon should not be corrected, got %f", factor)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if !reflect.DeepEqual(loaded.Bins, trained.Bins) || loaded.Calibrated != trained.Calibrated {
+		t.Errorf("Loaded calibration differs from the saved one")
+	}
+}
+
+func TestTrainErrors(t *testing.T) {
+	if _, err := Train(nil, time.UTC); err == nil {
+		t.Errorf("Should produce an error without samples")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("Should report a missing file, got %v", err)
+	}
+	if err := (&Model{}).Save(filepath.Join(t.TempDir(), "missing", "calibration.json")); err == nil {
+		t.Errorf("Should produce an error if the directory does not exist")
+	}
+}
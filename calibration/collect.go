@@ -0,0 +1,61 @@
+package calibration
+
+import (
+	"fmt"
+	"solargo/persistence"
+	"solargo/weather"
+	"solargo/yield_forecast"
+	"time"
+)
+
+//Collect the samples between from and to, the baseline is computed with the persisted weather forecast
+func Collect(database persistence.GenericDatabase, baseline *yield_forecast.Model, from, to time.Time) ([]Sample, error) {
+	production, err := database.GetProduction(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read persisted production: %s", err)
+	}
+
+	//Periods may start up to a few hours before from
+	forecasts, err := database.GetWeatherForecast(from.Add(-6*time.Hour), to)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read persisted weather forecast: %s", err)
+	}
+
+	var samples []Sample
+	for start, actual := range persistence.Hourly(production) {
+		s := sampleAt(baseline, start, forecasts)
+		if s.Baseline <= 0 {
+			continue
+		}
+		s.Actual = actual
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+//sampleAt computes the baseline of the hour starting at start
+func sampleAt(baseline *yield_forecast.Model, start time.Time, forecasts []weather.Forecast) Sample {
+	middle := start.Add(30 * time.Minute)
+	s := Sample{
+		Date:     start,
+		Sun:      yield_forecast.SolarPosition(middle, baseline.Latitude, baseline.Longitude),
+		Baseline: baseline.Energy(start, forecasts),
+	}
+	if f := yield_forecast.FindForecast(forecasts, middle); f != nil {
+		s.CloudDensity = f.CloudDensity
+	}
+	return s
+}
+
+//Retrain the model on the history between from and to and save it to the file
+func Retrain(database persistence.GenericDatabase, baseline *yield_forecast.Model, from, to time.Time, path string) (*Model, error) {
+	samples, err := Collect(database, baseline, from, to)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Train(samples, baseline.Location)
+	if err != nil {
+		return nil, err
+	}
+	return m, m.Save(path)
+}
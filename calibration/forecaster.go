@@ -0,0 +1,89 @@
+package calibration
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"solargo/inverter"
+	"solargo/weather"
+	"solargo/yield_forecast"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//Forecaster implementation of the GenericYieldForecast interface, which corrects the forecast of the model
+//with the calibration in the file. Without a calibration the forecast of the model is returned.
+type Forecaster struct {
+	Baseline *yield_forecast.Model
+	File     string
+}
+
+//RetrieveForecast of the model corrected by the calibration
+func (f *Forecaster) RetrieveForecast(ctx context.Context) ([]yield_forecast.Data, error) {
+	if len(f.Baseline.Planes) == 0 {
+		return nil, fmt.Errorf("Error while computing yield forecast: no planes configured")
+	}
+
+	var forecasts []weather.Forecast
+	if f.Baseline.Weather != nil {
+		var err error
+		forecasts, err = f.Baseline.Weather.RetrieveHourlyForecast(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error while computing yield forecast: %s", err)
+		}
+	}
+
+	m, err := Load(f.File)
+	if os.IsNotExist(err) {
+		log.Info("No calibration in ", f.File, " yet, the yield forecast is not calibrated")
+	} else if err != nil {
+		return nil, err
+	}
+	return f.correct(m, f.Baseline.Forecast(time.Now(), forecasts), forecasts), nil
+}
+
+//correct the forecast with the model, the cummulated production is summed up again
+func (f *Forecaster) correct(m *Model, data []yield_forecast.Data, forecasts []weather.Forecast) []yield_forecast.Data {
+	loc := f.Baseline.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	corrected := make([]yield_forecast.Data, len(data))
+	var cummulated inverter.WattHour
+	var day time.Time
+	for i, d := range data {
+		s := sampleAt(f.Baseline, d.Date.Add(-time.Hour), forecasts)
+		period := d.Period
+		if period <= 0 {
+			period = time.Hour
+		}
+		d.CurrentProduction = f.limit(inverter.Watt(m.Factor(s.Sun, s.CloudDensity)) * d.MeanPower()).Over(period)
+
+		cloud := 0.0
+		if w := yield_forecast.FindForecast(forecasts, d.Date); w != nil {
+			cloud = w.CloudDensity
+		}
+		sun := yield_forecast.SolarPosition(d.Date, f.Baseline.Latitude, f.Baseline.Longitude)
+		d.Power = f.limit(inverter.Watt(m.Factor(sun, cloud)) * d.Power)
+
+		start := s.Date.In(loc)
+		if start.YearDay() != day.YearDay() || start.Year() != day.Year() {
+			day, cummulated = start, 0
+		}
+		cummulated += d.CurrentProduction
+		d.CummulatedProduction = cummulated
+		corrected[i] = d
+	}
+	return corrected
+}
+
+//limit the power to the inverter
+func (f *Forecaster) limit(w inverter.Watt) inverter.Watt {
+	if f.Baseline.InverterLimit > 0 {
+		return inverter.Watt(math.Min(float64(w), float64(f.Baseline.InverterLimit)))
+	}
+	return w
+}
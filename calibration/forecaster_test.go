@@ -0,0 +1,67 @@
+package calibration
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"solargo/inverter"
+	"solargo/yield_forecast"
+	"testing"
+	"time"
+)
+
+func TestForecasterCorrect(t *testing.T) {
+	m := baseline()
+	m.InverterLimit = 3000
+	f := Forecaster{Baseline: m}
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	data := m.Forecast(day, nil)
+
+	//Halve the production in every bin
+	bins := map[string]Bin{}
+	for t := day; t.Before(day.AddDate(0, 0, 2)); t = t.Add(5 * time.Minute) {
+		bins[binKey(yield_forecast.SolarPosition(t, m.Latitude, m.Longitude), 0)] = Bin{Baseline: 2, Actual: 1, Samples: 1000000}
+	}
+	corrected := f.correct(&Model{Bins: bins}, data, nil)
+
+	if len(corrected) != len(data) {
+		t.Fatalf("got %d values, want %d", len(corrected), len(data))
+	}
+	var cummulated inverter.WattHour
+	for i, d := range corrected {
+		if math.Abs(float64(d.CurrentProduction-data[i].CurrentProduction/2)) > 1 || math.Abs(float64(d.Power-data[i].Power/2)) > 1 {
+			t.Errorf("%d: should be halved, got %v from %v", i, d, data[i])
+		}
+		if i > 0 && d.Date.Day() != corrected[i-1].Date.Day() {
+			cummulated = 0
+		}
+		cummulated += d.CurrentProduction
+		if d.CummulatedProduction != cummulated {
+			t.Errorf("%d: got cummulated %f, want %f", i, d.CummulatedProduction, cummulated)
+		}
+	}
+
+	//Without a calibration the forecast is unchanged
+	unchanged := f.correct(nil, data, nil)
+	for i := range data {
+		if unchanged[i] != data[i] {
+			t.Errorf("%d: got %v, want %v", i, unchanged[i], data[i])
+		}
+	}
+}
+
+func TestForecasterWithoutCalibration(t *testing.T) {
+	f := Forecaster{Baseline: baseline(), File: filepath.Join(t.TempDir(), "calibration.json")}
+	data, err := f.RetrieveForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if len(data) == 0 {
+		t.Errorf("Should forecast the uncalibrated model")
+	}
+
+	f.Baseline.Planes = nil
+	if _, err := f.RetrieveForecast(context.Background()); err == nil {
+		t.Errorf("Should produce an error without planes")
+	}
+}
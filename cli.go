@@ -0,0 +1,713 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"solargo/accuracy"
+	"solargo/analytics"
+	"solargo/config"
+	"solargo/emissions"
+	"solargo/financials"
+	"solargo/inverter"
+	"solargo/summary"
+	"solargo/tariff"
+	"solargo/yield_forecast"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Exit codes of the command line interface
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+//version of SolarGo, set at compile time
+var version = "dev"
+
+//configPath of the config file given with -config, the daemon watches it for changes
+var configPath string
+
+//command of the command line interface
+type command struct {
+	description string
+	needsConfig bool
+	logging     bool
+	run         func(config *config.Config, args []string, stdout, stderr io.Writer) int
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"run":             {"Start the SolarGo daemon (default)", true, true, runDaemon},
+		"validate-config": {"Check the config file and exit", true, false, runValidateConfig},
+		"validate":        {"Alias of validate-config", true, false, runValidateConfig},
+		"poll-once":       {"Read the inverter once and print the data as JSON", true, true, runPollOnce},
+		"send-summary":    {"Send the daily summary now", true, true, runSendSummary},
+		"backfill":        {"Fill gaps in the persisted data from the inverter archive", true, true, runBackfill},
+		"export":          {"Export the persisted production as CSV or JSON", true, true, runExport},
+		"accuracy":        {"Print the accuracy of the yield forecasts", true, true, runAccuracy},
+		"analytics":       {"Print the self-consumption and autarky per day to year", true, true, runAnalytics},
+		"outlook":         {"Print the expected yield and the best window for appliances", true, false, runOutlook},
+		"calibrate":       {"Calibrate the yield model and print its error", true, true, runCalibrate},
+		"discover":        {"Search the local network for Fronius inverters", false, false, runDiscover},
+		"version":         {"Print the version of SolarGo", false, false, runVersion},
+	}
+}
+
+//runCLI parses the global flags, executes the command and returns the exit code
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("solargo", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&configPath, "config", "config.yaml", "Path to the config file")
+	flags.Usage = func() { printUsage(flags, stderr) }
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	//Without a command, SolarGo runs as daemon
+	name, commandArgs := "run", flags.Args()
+	if len(commandArgs) > 0 {
+		name, commandArgs = commandArgs[0], commandArgs[1:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n", name)
+		printUsage(flags, stderr)
+		return exitUsage
+	}
+
+	var c config.Config
+	if cmd.needsConfig {
+		var err error
+		c, err = loadConfig(configPath, stderr)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+	}
+	if cmd.logging {
+		if err := initializeLogger(c); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+	}
+
+	return cmd.run(&c, commandArgs, stdout, stderr)
+}
+
+//unexpectedArguments reports the positional arguments, which no command takes
+func unexpectedArguments(args []string, stderr io.Writer) bool {
+	if len(args) == 0 {
+		return false
+	}
+	fmt.Fprintf(stderr, "Unexpected arguments %q\n", args)
+	return true
+}
+
+//loadConfig reads and validates the config file, all problems are reported
+func loadConfig(path string, stderr io.Writer) (config.Config, error) {
+	c, err := config.ReadConfig(path)
+	if err != nil {
+		return c, err
+	}
+
+	problems := c.Validate()
+	for _, p := range problems {
+		fmt.Fprintln(stderr, p)
+	}
+	if problems.HasErrors() {
+		return c, fmt.Errorf("Config %s is invalid", path)
+	}
+	return c, nil
+}
+
+func printUsage(flags *flag.FlagSet, stderr io.Writer) {
+	fmt.Fprintln(stderr, "Usage: solargo [-config config.yaml] <command> [arguments]")
+	fmt.Fprintln(stderr, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(stderr, "  %-16s %s\n", name, commands[name].description)
+	}
+
+	fmt.Fprintln(stderr, "\nFlags:")
+	flags.PrintDefaults()
+}
+
+//parseDay parses a YYYY-MM-DD date in the timezone, an empty value returns the fallback
+func parseDay(value string, fallback time.Time, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+//parseRange parses the -from and -to flags in the timezone, the last day is included completely
+func parseRange(from, to string, maxAge time.Duration, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+	start, err := parseDay(from, now.Add(-maxAge), loc)
+	if err != nil {
+		return start, now, fmt.Errorf("Invalid -from date: %s", err)
+	}
+	end := now
+	if to != "" {
+		end, err = parseDay(to, now, loc)
+		if err != nil {
+			return start, end, fmt.Errorf("Invalid -to date: %s", err)
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, nil
+}
+
+func runValidateConfig(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	if unexpectedArguments(args, stderr) {
+		return exitUsage
+	}
+	fmt.Fprintln(stdout, "Config is valid")
+	return exitOK
+}
+
+func runPollOnce(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("poll-once", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	persist := flags.Bool("persist", false, "Also save the data in the database")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if unexpectedArguments(flags.Args(), stderr) {
+		return exitUsage
+	}
+
+	data, err := config.GetInverter().RetrieveData(context.Background())
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot read inverter data:", err)
+		return exitFailure
+	}
+
+	if *persist {
+		config.GetDatabase().SendData(data)
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		fmt.Fprintln(stderr, "Cannot encode inverter data:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func runSendSummary(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	if unexpectedArguments(args, stderr) {
+		return exitUsage
+	}
+	if !config.Summary.SendStatistics {
+		fmt.Fprintln(stderr, "Sending the summary is disabled by summary.send_statistics")
+		return exitFailure
+	}
+	summary.SendSummary(context.Background(), config, config.GetInverter(), config.GetDatabase())
+	fmt.Fprintln(stdout, "Summary sent")
+	return exitOK
+}
+
+func runBackfill(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	from := flags.String("from", "", "First day to backfill (YYYY-MM-DD), defaults to now minus backfill.max_age")
+	to := flags.String("to", "", "Last day to backfill (YYYY-MM-DD), defaults to now")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if unexpectedArguments(flags.Args(), stderr) {
+		return exitUsage
+	}
+
+	start, end, err := parseRange(*from, *to, backfillMaxAge(config), config.Location())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	saved, err := backfillGaps(context.Background(), config, start, end)
+	fmt.Fprintf(stdout, "Backfilled %d samples\n", saved)
+	if err != nil {
+		fmt.Fprintln(stderr, "Backfill failed:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func runAccuracy(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("accuracy", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	from := flags.String("from", "", "First day of the report (YYYY-MM-DD), defaults to a week ago")
+	to := flags.String("to", "", "Last day of the report (YYYY-MM-DD), defaults to yesterday")
+	score := flags.Bool("score", false, "Score the forecasts of the days again before the report")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if unexpectedArguments(flags.Args(), stderr) {
+		return exitUsage
+	}
+
+	loc := config.Location()
+	yesterday := time.Now().In(loc).AddDate(0, 0, -1)
+	start, err := parseDay(*from, yesterday.AddDate(0, 0, 1-accuracy.ReportDays), loc)
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid -from date:", err)
+		return exitUsage
+	}
+	end, err := parseDay(*to, yesterday, loc)
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid -to date:", err)
+		return exitUsage
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+
+	database := config.GetDatabase()
+	providers := config.TrackedYieldProviders()
+	if *score {
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			if _, err := accuracy.ScoreDay(database, providers, day); err != nil {
+				fmt.Fprintln(stderr, "Scoring failed:", err)
+				return exitFailure
+			}
+		}
+		if err := database.Flush(); err != nil {
+			fmt.Fprintln(stderr, "Saving the scores failed:", err)
+			return exitFailure
+		}
+	}
+
+	//The scores are saved at the start of the day, the report includes the days after its start
+	report, err := accuracy.Report(database, providers, start.Add(-time.Nanosecond), end)
+	if err != nil {
+		fmt.Fprintln(stderr, "Report failed:", err)
+		return exitFailure
+	}
+	if report == "" {
+		report = "No forecast accuracy between " + start.Format("2006-01-02") + " and " + end.Format("2006-01-02")
+	}
+	fmt.Fprintln(stdout, report)
+	return exitOK
+}
+
+func runCalibrate(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if unexpectedArguments(flags.Args(), stderr) {
+		return exitUsage
+	}
+	if config.Yield.Provider != yield_forecast.ProviderModel {
+		fmt.Fprintf(stderr, "The calibration requires yield_forecast.provider %q\n", yield_forecast.ProviderModel)
+		return exitFailure
+	}
+
+	m, err := calibrate(config, config.GetDatabase())
+	if err != nil {
+		fmt.Fprintln(stderr, "Calibration failed:", err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "Calibrated %d bins on %s - %s, saved to %s\n", len(m.Bins), m.From.Format("2006-01-02"), m.To.Format("2006-01-02"), config.CalibrationFile())
+	fmt.Fprintf(stdout, "Baseline:   %s\n", m.Baseline)
+	fmt.Fprintf(stdout, "Calibrated: %s\n", m.Calibrated)
+	return exitOK
+}
+
+func runAnalytics(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("analytics", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	from := flags.String("from", "", "First day (YYYY-MM-DD), defaults to the start of the year")
+	to := flags.String("to", "", "Last day (YYYY-MM-DD), defaults to today")
+	period := flags.String("period", analytics.Month, "Either day, week, month or year")
+	hours := flags.Bool("hours", false, "Also print the breakdown by the hour of the day")
+	format := flags.String("format", "text", "Either text or json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if unexpectedArguments(flags.Args(), stderr) {
+		return exitUsage
+	}
+
+	valid := false
+	for _, r := range analytics.Resolutions {
+		valid = valid || r == *period
+	}
+	if !valid {
+		fmt.Fprintf(stderr, "Unknown period %q\n", *period)
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "Unknown format %q\n", *format)
+		return exitUsage
+	}
+
+	loc := config.Location()
+	now := time.Now().In(loc)
+	start, end, err := parseRange(*from, *to, now.Sub(analytics.PeriodStart(analytics.Year, now)), loc)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	start = analytics.PeriodStart(analytics.Day, start)
+
+	database := config.GetDatabase()
+	report, err := analytics.NewReport(database, *period, start, end, loc)
+	if err != nil {
+		fmt.Fprintln(stderr, "Report failed:", err)
+		return exitFailure
+	}
+	var savings []emissions.Savings
+	if factor := config.GetEmissionFactor(); factor != nil {
+		savings, err = analytics.Emissions(database, factor, *period, start, end, loc)
+		if err != nil {
+			fmt.Fprintln(stderr, "Report failed:", err)
+			return exitFailure
+		}
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(analyticsJSON(report, savings, *hours)); err != nil {
+			fmt.Fprintln(stderr, "Cannot write the report:", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	if len(report.Periods) == 0 {
+		fmt.Fprintln(stdout, "No accounted energy between", start.Format("2006-01-02"), "and", end.Format("2006-01-02"))
+		return exitOK
+	}
+	fmt.Fprintln(stdout, report)
+	if *hours {
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, report.HoursString())
+	}
+	if len(savings) > 0 {
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, analytics.EmissionsString(*period, savings))
+	}
+	return exitOK
+}
+
+//ratiosJSON for other tools, the energy is in Wh and the ratios between 0 and 1
+type ratiosJSON struct {
+	Production          float64 `json:"production"`
+	Consumption         float64 `json:"consumption"`
+	SelfConsumption     float64 `json:"self_consumption"`
+	FeedIn              float64 `json:"feed_in"`
+	Import              float64 `json:"import"`
+	SelfConsumptionRate float64 `json:"self_consumption_rate"`
+	Autarky             float64 `json:"autarky"`
+}
+
+//periodJSON with its ratios
+type periodJSON struct {
+	Period string    `json:"period,omitempty"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	ratiosJSON
+}
+
+//hourJSON of the day with its ratios
+type hourJSON struct {
+	Hour int `json:"hour"`
+	ratiosJSON
+}
+
+func newRatiosJSON(r analytics.Ratios) ratiosJSON {
+	return ratiosJSON{
+		Production:          float64(r.Production),
+		Consumption:         float64(r.Consumption),
+		SelfConsumption:     float64(r.SelfConsumption),
+		FeedIn:              float64(r.FeedIn),
+		Import:              float64(r.Import),
+		SelfConsumptionRate: r.SelfConsumptionRate(),
+		Autarky:             r.Autarky(),
+	}
+}
+
+//emissionsJSON of a period, the CO2 is in g
+type emissionsJSON struct {
+	Period      string    `json:"period"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Avoided     float64   `json:"avoided"`
+	AvoidedSelf float64   `json:"avoided_self_consumption"`
+	CarKm       float64   `json:"car_km"`
+	Trees       float64   `json:"trees"`
+}
+
+//analyticsJSON of the report and the avoided emissions, the hours are only included if requested
+func analyticsJSON(report analytics.Report, savings []emissions.Savings, hours bool) interface{} {
+	type result struct {
+		Periods   []periodJSON    `json:"periods"`
+		Total     periodJSON      `json:"total"`
+		Hours     []hourJSON      `json:"hours,omitempty"`
+		Emissions []emissionsJSON `json:"emissions,omitempty"`
+	}
+	r := result{
+		Periods: []periodJSON{},
+		Total:   periodJSON{Start: report.Total.Start, End: report.Total.End, ratiosJSON: newRatiosJSON(report.Total)},
+	}
+	for _, p := range report.Periods {
+		r.Periods = append(r.Periods, periodJSON{analytics.Label(report.Resolution, p.Start), p.Start, p.End, newRatiosJSON(p)})
+	}
+	if hours {
+		for h, ratios := range report.Hours {
+			r.Hours = append(r.Hours, hourJSON{h, newRatiosJSON(ratios)})
+		}
+	}
+	for _, e := range savings {
+		r.Emissions = append(r.Emissions, emissionsJSON{analytics.Label(report.Resolution, e.Start), e.Start, e.End, e.Avoided, e.AvoidedSelf, e.CarKm(), e.Trees()})
+	}
+	return r
+}
+
+func runOutlook(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("outlook", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	power := flags.Float64("power", 0, "Power of the appliance in W, prints the best window to run it")
+	duration := flags.Duration("duration", time.Hour, "Duration of the appliance run")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if unexpectedArguments(flags.Args(), stderr) {
+		return exitUsage
+	}
+	if *power < 0 || *duration <= 0 {
+		fmt.Fprintln(stderr, "The power and the duration of the appliance must be positive")
+		return exitUsage
+	}
+	if !config.Yield.Enabled {
+		fmt.Fprintln(stderr, "The yield forecast is disabled")
+		return exitFailure
+	}
+
+	loc := config.Location()
+	now := time.Now()
+	data, err := config.GetYieldForecastService().RetrieveForecast(context.Background())
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot read the yield forecast:", err)
+		return exitFailure
+	}
+
+	o := yield_forecast.NewOutlook(data, now, loc)
+	fmt.Fprintf(stdout, "Today:        %.1f kWh, %.1f kWh remaining%s\n", o.Today/1000, o.RemainingToday/1000, peak(o.PeakToday, loc))
+	fmt.Fprintf(stdout, "Tomorrow:     %.1f kWh%s\n", o.Tomorrow/1000, peak(o.PeakTomorrow, loc))
+	fmt.Fprintf(stdout, "Next %d days:  %.1f kWh until %s\n", yield_forecast.OutlookDays, o.Week/1000, o.Until.In(loc).Format("Mon 15:04"))
+
+	if *power > 0 {
+		appliance := yield_forecast.Appliance{Power: inverter.Watt(*power), Duration: *duration}
+		if w, ok := yield_forecast.BestWindow(data, appliance, now, o.Until); ok {
+			fmt.Fprintf(stdout, "Best window:  %s - %s, %.1f of %.1f kWh from the PV array (%.0f%%)\n",
+				w.Start.In(loc).Format("Mon 15:04"), w.End.In(loc).Format("15:04"), w.Solar/1000, w.Need/1000, w.Coverage()*100)
+		} else {
+			fmt.Fprintln(stdout, "Best window:  no production expected")
+		}
+		if config.Tariff.Enabled {
+			return printCheapest(config, data, appliance, now, o.Until, stdout, stderr)
+		}
+	}
+	return exitOK
+}
+
+//printCheapest window to run the appliance by the tariff, dynamic tariffs are limited to the known market prices
+func printCheapest(config *config.Config, data []yield_forecast.Data, appliance yield_forecast.Appliance, now, until time.Time, stdout, stderr io.Writer) int {
+	t := config.GetTariff()
+	if dynamic, ok := t.Import.(*tariff.Dynamic); ok {
+		if err := financials.LoadPrices(config.GetDatabase(), t, now.Add(-time.Hour), until); err != nil {
+			fmt.Fprintln(stderr, "Cannot read the market prices:", err)
+			return exitFailure
+		}
+		if known := dynamic.Until(); known.After(now) && known.Before(until) {
+			until = known
+		}
+	}
+
+	w, ok := yield_forecast.CheapestWindow(data, t, appliance, now, until)
+	if !ok {
+		fmt.Fprintln(stdout, "Cheapest:     the appliance does not fit before", until.In(config.Location()).Format("Mon 15:04"))
+		return exitOK
+	}
+	loc := config.Location()
+	fmt.Fprintf(stdout, "Cheapest:     %s - %s, %s with %.1f kWh from the PV array\n",
+		w.Start.In(loc).Format("Mon 15:04"), w.End.In(loc).Format("15:04"), t.Format(w.Cost), w.Solar/1000)
+	return exitOK
+}
+
+//peak hour of a day, empty without production
+func peak(h yield_forecast.Hour, loc *time.Location) string {
+	if h.Energy <= 0 {
+		return ""
+	}
+	start := h.Start.In(loc)
+	return fmt.Sprintf(", peak %s - %s with %.1f kWh", start.Format("15:04"), start.Add(time.Hour).Format("15:04"), h.Energy/1000)
+}
+
+func runExport(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	from := flags.String("from", "", "First day to export (YYYY-MM-DD), defaults to yesterday")
+	to := flags.String("to", "", "Last day to export (YYYY-MM-DD), defaults to now")
+	format := flags.String("format", "csv", "Either csv or json")
+	output := flags.String("output", "", "File to write, defaults to stdout")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if unexpectedArguments(flags.Args(), stderr) {
+		return exitUsage
+	}
+
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintf(stderr, "Unknown format %q\n", *format)
+		return exitUsage
+	}
+
+	start, end, err := parseRange(*from, *to, 24*time.Hour, config.Location())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	ps, err := config.GetDatabase().GetProduction(start, end)
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot read the production:", err)
+		return exitFailure
+	}
+
+	out := stdout
+	var file *os.File
+	if *output != "" {
+		file, err = os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(stderr, "Cannot create output file:", err)
+			return exitFailure
+		}
+		out = file
+	}
+
+	type row struct {
+		Time  time.Time `json:"time"`
+		Power float64   `json:"ac_power"`
+	}
+	rows := make([]row, len(ps))
+	for i, p := range ps {
+		rows[i] = row{p.Date, float64(p.Value)}
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(rows)
+	} else {
+		w := csv.NewWriter(out)
+		_ = w.Write([]string{"time", "ac_power"})
+		for _, r := range rows {
+			_ = w.Write([]string{r.Time.Format(time.RFC3339), strconv.FormatFloat(r.Power, 'f', -1, 64)})
+		}
+		w.Flush()
+		err = w.Error()
+	}
+	//The data may only be written when the file is closed
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot write the export:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func runDiscover(_ *config.Config, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("discover", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	networks := flags.String("network", "", "Comma separated networks to scan (e.g. 192.168.1.0/24), defaults to all local networks")
+	port := flags.Uint("port", 80, "Port of the Fronius Solar API")
+	timeout := flags.Duration("timeout", 2*time.Second, "Timeout of a single probe")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if unexpectedArguments(flags.Args(), stderr) {
+		return exitUsage
+	}
+	if *port > math.MaxUint16 {
+		fmt.Fprintf(stderr, "Invalid port %d\n", *port)
+		return exitUsage
+	}
+
+	var scan []*net.IPNet
+	if *networks == "" {
+		local, err := inverter.LocalNetworks()
+		if err != nil {
+			fmt.Fprintln(stderr, "Cannot determine local networks:", err)
+			return exitFailure
+		}
+		scan = local
+	} else {
+		for _, n := range strings.Split(*networks, ",") {
+			_, network, err := net.ParseCIDR(strings.TrimSpace(n))
+			if err != nil {
+				fmt.Fprintln(stderr, "Invalid network:", err)
+				return exitUsage
+			}
+			scan = append(scan, network)
+		}
+	}
+
+	found := 0
+	for _, network := range scan {
+		fmt.Fprintln(stderr, "Scanning", network)
+		devices, err := inverter.DiscoverFronius(network, uint16(*port), *timeout)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			continue
+		}
+		for _, d := range devices {
+			fmt.Fprintf(stdout, "%s:%d Fronius Solar API version %d (%s)\n", d.IP, d.Port, d.APIVersion, d.BaseURL)
+			found++
+		}
+	}
+
+	if found == 0 {
+		fmt.Fprintln(stderr, "No inverter found")
+		return exitFailure
+	}
+	return exitOK
+}
+
+func runVersion(_ *config.Config, args []string, stdout, stderr io.Writer) int {
+	if unexpectedArguments(args, stderr) {
+		return exitUsage
+	}
+	fmt.Fprintf(stdout, "SolarGo %s\n", version)
+	return exitOK
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const validConfig = `latitude: 48.2
+longitude: 16.37
+inverter:
+  ip: "192.168.1.2"
+  port: 80
+  device_id: "1"
+logging:
+  enabled: false
+persistence:
+  url: "INFLUX"
+  database_name: "solar"
+`
+
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "solargo")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Could not write config: %s", err)
+	}
+	return path
+}
+
+func TestRunCLI(t *testing.T) {
+	valid := writeConfig(t, strings.Replace(validConfig, "INFLUX", "http://127.0.0.1:8086", 1))
+	invalid := writeConfig(t, "inverter:\n  port: 1\n")
+
+	var tests = []struct {
+		testName string
+		args     []string
+		code     int
+		stdout   string
+		stderr   string
+	}{
+		{"Version", []string{"version"}, exitOK, "SolarGo dev\n", ""},
+		{"Unknown command", []string{"unknown"}, exitUsage, "", "Unknown command \"unknown\""},
+		{"Unknown flag", []string{"-unknown", "version"}, exitUsage, "", "flag provided but not defined"},
+		{"Missing config", []string{"-config", "not_there.yaml", "validate-config"}, exitFailure, "", "Can not read config file"},
+		{"Valid config", []string{"--config", valid, "validate-config"}, exitOK, "Config is valid\n", ""},
+		{"Invalid config", []string{"--config", invalid, "validate"}, exitFailure, "", "error: inverter.port: 1 is reserved for other services"},
+		{"Summary disabled", []string{"-config", valid, "send-summary"}, exitFailure, "", "disabled"},
+		{"Export format", []string{"-config", valid, "export", "-format", "xml"}, exitUsage, "", "Unknown format"},
+		{"Export date", []string{"-config", valid, "export", "-from", "yesterday"}, exitUsage, "", "Invalid -from date"},
+		{"Accuracy date", []string{"-config", valid, "accuracy", "-to", "last week"}, exitUsage, "", "Invalid -to date"},
+		{"Analytics period", []string{"-config", valid, "analytics", "-period", "quarter"}, exitUsage, "", "Unknown period"},
+		{"Analytics format", []string{"-config", valid, "analytics", "-format", "csv"}, exitUsage, "", "Unknown format"},
+		{"Outlook disabled", []string{"-config", valid, "outlook"}, exitFailure, "", "The yield forecast is disabled"},
+		{"Outlook power", []string{"-config", valid, "outlook", "-power", "-100"}, exitUsage, "", "must be positive"},
+		{"Calibrate without model", []string{"-config", valid, "calibrate"}, exitFailure, "", "requires yield_forecast.provider \"model\""},
+		{"Discover network", []string{"discover", "-network", "no network"}, exitUsage, "", "Invalid network"},
+		{"Discover port", []string{"discover", "-port", "70000"}, exitUsage, "", "Invalid port 70000"},
+		{"Run arguments", []string{"-config", valid, "run", "now"}, exitUsage, "", "Unexpected arguments [\"now\"]"},
+		{"Export arguments", []string{"-config", valid, "export", "-format", "csv", "out.csv"}, exitUsage, "", "Unexpected arguments"},
+		{"Version arguments", []string{"version", "--short"}, exitUsage, "", "Unexpected arguments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCLI(tt.args, &stdout, &stderr)
+			if code != tt.code {
+				t.Errorf("got exit code %d, want %d (stderr: %s)", code, tt.code, stderr.String())
+			}
+			if stdout.String() != tt.stdout {
+				t.Errorf("got stdout %q, want %q", stdout.String(), tt.stdout)
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("got stderr %q, want it to contain %q", stderr.String(), tt.stderr)
+			}
+		})
+	}
+}
+
+func TestRunCLIExport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"results":[{"statement_id":0,"series":[{"name":"AC","columns":["time","Power"],"values":[["2020-11-21T12:32:00Z",3.3],["2020-11-21T12:33:00Z",4.5]]}]}]}`)
+	}))
+	defer ts.Close()
+
+	path := writeConfig(t, strings.Replace(validConfig, "INFLUX", ts.URL, 1))
+
+	var tests = []struct {
+		format string
+		want   string
+	}{
+		{"csv", "time,ac_power\n2020-11-21T12:32:00Z,3.3\n2020-11-21T12:33:00Z,4.5\n"},
+		{"json", "[\n  {\n    \"time\": \"2020-11-21T12:32:00Z\",\n    \"ac_power\": 3.3\n  },\n  {\n    \"time\": \"2020-11-21T12:33:00Z\",\n    \"ac_power\": 4.5\n  }\n]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runCLI([]string{"-config", path, "export", "-format", tt.format, "-from", "2020-11-21", "-to", "2020-11-21"}, &stdout, &stderr); code != exitOK {
+				t.Fatalf("got exit code %d (stderr: %s)", code, stderr.String())
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("got %q, want %q", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCLIOutlook(t *testing.T) {
+	path := writeConfig(t, strings.Replace(validConfig, "INFLUX", "http://127.0.0.1:8086", 1)+`yield_forecast:
+  enabled: true
+  provider: "model"
+  planes:
+    - {declination: 30, azimuth: 0, kwp: 5}
+`)
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"-config", path, "outlook", "-power", "1000", "-duration", "2h"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("got exit code %d (stderr: %s)", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	prefixes := []string{"Today:", "Tomorrow:", "Next 7 days:", "Best window:"}
+	if len(lines) != len(prefixes) {
+		t.Fatalf("got %q", stdout.String())
+	}
+	for i, prefix := range prefixes {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("got %q, want prefix %q", lines[i], prefix)
+		}
+	}
+	if !strings.Contains(lines[3], "of 2.0 kWh from the PV array") {
+		t.Errorf("got %q", lines[3])
+	}
+}
+
+func TestRunCLIOutlookCheapest(t *testing.T) {
+	path := writeConfig(t, strings.Replace(validConfig, "INFLUX", "http://127.0.0.1:8086", 1)+`yield_forecast:
+  enabled: true
+  provider: "model"
+  planes:
+    - {declination: 30, azimuth: 0, kwp: 5}
+tariff:
+  enabled: true
+  import:
+    price: 0.30
+  feed_in: [{price: 0.05}]
+`)
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"-config", path, "outlook", "-power", "1000", "-duration", "2h"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("got exit code %d (stderr: %s)", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[4], "Cheapest:") || !strings.Contains(lines[4], " EUR with ") {
+		t.Errorf("got %q", stdout.String())
+	}
+}
@@ -4,10 +4,18 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"os"
+	"solargo/calibration"
+	"solargo/emissions"
+	"solargo/energy"
 	"solargo/inverter"
 	"solargo/persistence"
+	"solargo/price"
+	"solargo/tariff"
 	"solargo/weather"
 	"solargo/yield_forecast"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -17,11 +25,20 @@ type Config struct {
 	Debug     bool    `yaml:"debug"`
 	Latitude  float64 `yaml:"latitude"`
 	Longitude float64 `yaml:"longitude"`
+	Timezone  string  `yaml:"timezone"`
 	Summary   struct {
 		TelegramURL    string `yaml:"telegram_url"`
 		BotToken       string `yaml:"bot_token"`
 		ChatID         string `yaml:"chat_id"`
 		SendStatistics bool   `yaml:"send_statistics"`
+		AccuracyReport bool   `yaml:"accuracy_report"`
+		EnergyReport   bool   `yaml:"energy_report"`
+		Chart          struct {
+			Width  int    `yaml:"width"`
+			Height int    `yaml:"height"`
+			Theme  string `yaml:"theme"`
+			Format string `yaml:"format"`
+		} `yaml:"chart"`
 	} `yaml:"summary"`
 	Inverter struct {
 		IP       net.IP `yaml:"ip"`
@@ -38,33 +55,140 @@ type Config struct {
 		User         string `yaml:"user"`
 		Password     string `yaml:"password"`
 	} `yaml:"persistence"`
+	Schedule struct {
+		Poll               string `yaml:"poll"`
+		PollFrom           string `yaml:"poll_from"`
+		PollUntil          string `yaml:"poll_until"`
+		NightPoll          string `yaml:"night_poll"`
+		Weather            string `yaml:"weather"`
+		WeatherFrom        string `yaml:"weather_from"`
+		WeatherUntil       string `yaml:"weather_until"`
+		Summary            string `yaml:"summary"`
+		YieldForecast      string `yaml:"yield_forecast"`
+		YieldForecastFrom  string `yaml:"yield_forecast_from"`
+		YieldForecastUntil string `yaml:"yield_forecast_until"`
+		Calibration        string `yaml:"calibration"`
+		Accuracy           string `yaml:"accuracy"`
+		AccuracyReport     string `yaml:"accuracy_report"`
+		Financials         string `yaml:"financials"`
+		Prices             string `yaml:"prices"`
+		EnergyReport       string `yaml:"energy_report"`
+	} `yaml:"schedule"`
+	Energy struct {
+		Enabled bool          `yaml:"enabled"`
+		File    string        `yaml:"file"`
+		MaxGap  time.Duration `yaml:"max_gap"`
+	} `yaml:"energy"`
+	Emissions struct {
+		Enabled bool    `yaml:"enabled"`
+		Factor  float64 `yaml:"factor"` // g CO2 per kWh of the grid
+		Hourly  bool    `yaml:"hourly"`
+	} `yaml:"emissions"`
+	Backfill struct {
+		OnStartup bool          `yaml:"on_startup"`
+		MaxAge    time.Duration `yaml:"max_age"`
+		ChunkSize time.Duration `yaml:"chunk_size"`
+		MinGap    time.Duration `yaml:"min_gap"`
+	} `yaml:"backfill"`
 	Weather struct {
 		Enabled      bool   `yaml:"enabled"`
+		Provider     string `yaml:"provider"`
+		Station      string `yaml:"station"`
 		Token        string `yaml:"api_token"`
 		City         string `yaml:"city_code"`
 		LanguageCode string `yaml:"language_code"`
+		OneCall      bool   `yaml:"one_call"`
 	} `yaml:"weather"`
 	Yield struct {
-		Enabled   bool   `yaml:"enabled"`
-		Token     string `yaml:"api_token"`
-		Type      string `yaml:"type"`
-		ID        string `yaml:"id"`
-		Algorithm string `yaml:"algorithm"`
+		Enabled       bool     `yaml:"enabled"`
+		Provider      string   `yaml:"provider"`
+		Token         string   `yaml:"api_token"`
+		Type          string   `yaml:"type"`
+		ID            string   `yaml:"id"`
+		Algorithm     string   `yaml:"algorithm"`
+		Planes        []Plane  `yaml:"planes"`
+		Losses        *float64 `yaml:"losses"`
+		InverterLimit float64  `yaml:"inverter_limit"`
+		Calibration   struct {
+			Enabled bool          `yaml:"enabled"`
+			File    string        `yaml:"file"`
+			History time.Duration `yaml:"history"`
+		} `yaml:"calibration"`
+		Compare []string `yaml:"compare"`
 	} `yaml:"yield_forecast"`
+	Tariff struct {
+		Enabled  bool   `yaml:"enabled"`
+		Currency string `yaml:"currency"`
+		Import   struct {
+			Price   float64       `yaml:"price"`
+			Periods []PricePeriod `yaml:"periods"`
+			Dynamic bool          `yaml:"dynamic"`
+			Markup  float64       `yaml:"markup"`
+		} `yaml:"import"`
+		Prices struct {
+			Provider string `yaml:"provider"`
+			Country  string `yaml:"country"`
+			Token    string `yaml:"api_token"`
+			Home     string `yaml:"home"`
+			Area     string `yaml:"area"`
+		} `yaml:"prices"`
+		FeedIn       []FeedIn `yaml:"feed_in"`
+		FixedCosts   float64  `yaml:"fixed_costs"`
+		Installation struct {
+			Cost float64 `yaml:"cost"`
+			Date string  `yaml:"date"`
+		} `yaml:"installation"`
+	} `yaml:"tariff"`
+}
+
+//Plane of the PV array, see yield_forecast.Plane
+type Plane struct {
+	Declination float64 `yaml:"declination"`
+	Azimuth     float64 `yaml:"azimuth"`
+	KWP         float64 `yaml:"kwp"`
+}
+
+//PricePeriod of the day with its own import price, see tariff.Window
+type PricePeriod struct {
+	From  string   `yaml:"from"`
+	Until string   `yaml:"until"`
+	Days  []string `yaml:"days"`
+	Price float64  `yaml:"price"`
+}
+
+//FeedIn compensation with its validity, see tariff.FeedIn
+type FeedIn struct {
+	From  string  `yaml:"from"`
+	Until string  `yaml:"until"`
+	Price float64 `yaml:"price"`
 }
 
-//ReadConfig reads the provided config yaml
-func ReadConfig(path string) Config {
+//ReadConfig reads the provided config yaml and applies the environment overrides, use Validate to check the values
+func ReadConfig(path string) (Config, error) {
 	config := Config{}
 	dat, err := ioutil.ReadFile(path)
 	if err != nil {
-		panic(fmt.Sprintf("Can not read config file. Error: %s", err))
+		return config, fmt.Errorf("Can not read config file. Error: %s", err)
 	}
 	err = yaml.UnmarshalStrict([]byte(dat), &config)
 	if err != nil {
-		panic(fmt.Sprintf("Can not read config file. Error %s", err))
+		return config, fmt.Errorf("Can not read config file. Error: %s", err)
+	}
+	err = config.ApplyEnvironment(os.LookupEnv)
+	return config, err
+}
+
+//Location of the solar power plant, day boundaries are computed in this timezone.
+//Without a timezone the one of the system is used.
+func (config *Config) Location() *time.Location {
+	if config.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return time.Local
 	}
-	return config
+	return loc
 }
 
 //GetInverter from a config
@@ -73,6 +197,7 @@ func (config *Config) GetInverter() inverter.GenericInverter {
 	inverter.IP = config.Inverter.IP
 	inverter.Port = config.Inverter.Port
 	inverter.DeviceID = config.Inverter.DeviceID
+	inverter.Location = config.Location()
 	return &inverter
 }
 
@@ -83,21 +208,60 @@ func (config *Config) GetDatabase() persistence.GenericDatabase {
 	database.DatabaseName = config.Persistence.DatabaseName
 	database.User = config.Persistence.User
 	database.Password = config.Persistence.Password
+	database.Location = config.Location()
 	return &database
 }
 
-//GetWeatherService from a config
+//GetWeatherService from a config, OpenWeatherMap is used if no provider is set
 func (config *Config) GetWeatherService() weather.GenericWeather {
+	switch config.Weather.Provider {
+	case weather.ProviderOpenMeteo:
+		var m weather.OpenMeteo
+		m.Latitude = config.Latitude
+		m.Longitude = config.Longitude
+		m.URL = weather.OpenMeteoURL
+		return &m
+	case weather.ProviderDWDMosmix:
+		var d weather.DWDMosmix
+		d.Latitude = config.Latitude
+		d.Longitude = config.Longitude
+		d.Station = config.Weather.Station
+		d.URL = weather.DWDURL
+		d.StationsURL = weather.DWDStationsURL
+		return &d
+	}
+
 	var w weather.OpenWeather
 	w.Token = config.Weather.Token
 	w.LanguageCode = config.Weather.LanguageCode
 	w.City = config.Weather.City
+	w.Latitude = config.Latitude
+	w.Longitude = config.Longitude
+	w.OneCall = config.Weather.OneCall
 	w.URL = weather.OpenWeatherURL
 	return &w
 }
 
-//GetYieldForecastService from a config
+//GetYieldForecastService from a config, solarprognose.de is used if no provider is set.
+//The model is corrected by its calibration if enabled.
 func (config *Config) GetYieldForecastService() yield_forecast.GenericYieldForecast {
+	switch config.Yield.Provider {
+	case yield_forecast.ProviderModel:
+		if config.Yield.Calibration.Enabled {
+			return &calibration.Forecaster{Baseline: config.GetYieldModel(), File: config.CalibrationFile()}
+		}
+		return config.GetYieldModel()
+	case yield_forecast.ProviderForecastSolar:
+		var f yield_forecast.ForecastSolar
+		f.Token = config.Yield.Token
+		f.Latitude = config.Latitude
+		f.Longitude = config.Longitude
+		f.Planes = config.planes()
+		f.URL = yield_forecast.ForecastSolarURL
+		f.Location = config.Location()
+		return &f
+	}
+
 	var s yield_forecast.SolarPrognose
 	s.Token = config.Yield.Token
 	s.Type = config.Yield.Type
@@ -106,3 +270,208 @@ func (config *Config) GetYieldForecastService() yield_forecast.GenericYieldForec
 	s.URL = yield_forecast.SolarPrognoseURL
 	return &s
 }
+
+//YieldProviderName of the yield forecast including the solarprognose.de algorithm or the calibration of the model,
+//e.g. solarprognose/mosmix or model/calibrated
+func (config *Config) YieldProviderName() string {
+	y := config.Yield
+	switch y.Provider {
+	case "", yield_forecast.ProviderSolarPrognose:
+		if y.Algorithm != "" {
+			return yield_forecast.ProviderSolarPrognose + "/" + y.Algorithm
+		}
+		return yield_forecast.ProviderSolarPrognose
+	case yield_forecast.ProviderModel:
+		if y.Calibration.Enabled {
+			return yield_forecast.ProviderModel + "/" + calibratedVariant
+		}
+	}
+	return y.Provider
+}
+
+//calibratedVariant of the model in a provider name
+const calibratedVariant = "calibrated"
+
+//withYieldProvider returns a copy of the config, which uses the provider name for the yield forecast
+func (config *Config) withYieldProvider(name string) Config {
+	c := *config
+	provider, variant := name, ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		provider, variant = name[:i], name[i+1:]
+	}
+	c.Yield.Provider = provider
+	c.Yield.Algorithm = ""
+	c.Yield.Calibration.Enabled = false
+	switch provider {
+	case yield_forecast.ProviderSolarPrognose:
+		c.Yield.Algorithm = variant
+	case yield_forecast.ProviderModel:
+		c.Yield.Calibration.Enabled = variant == calibratedVariant
+	}
+	return c
+}
+
+//GetComparedYieldForecasts from a config, their forecasts are only tracked to compare their accuracy
+func (config *Config) GetComparedYieldForecasts() map[string]yield_forecast.GenericYieldForecast {
+	compared := map[string]yield_forecast.GenericYieldForecast{}
+	for _, name := range config.Yield.Compare {
+		c := config.withYieldProvider(name)
+		compared[name] = c.GetYieldForecastService()
+	}
+	return compared
+}
+
+//TrackedYieldProviders are the provider names of the yield forecast and the compared ones
+func (config *Config) TrackedYieldProviders() []string {
+	providers := []string{config.YieldProviderName()}
+	for _, name := range config.Yield.Compare {
+		if name != providers[0] {
+			providers = append(providers, name)
+		}
+	}
+	return providers
+}
+
+//GetYieldModel from a config, the offline model uses the weather forecast for the cloud cover if the weather is enabled
+func (config *Config) GetYieldModel() *yield_forecast.Model {
+	var m yield_forecast.Model
+	m.Latitude = config.Latitude
+	m.Longitude = config.Longitude
+	m.Planes = config.planes()
+	m.Losses = yield_forecast.DefaultLosses
+	if config.Yield.Losses != nil {
+		m.Losses = *config.Yield.Losses
+	}
+	m.InverterLimit = inverter.Watt(config.Yield.InverterLimit)
+	m.Location = config.Location()
+	if config.Weather.Enabled {
+		if w, ok := config.GetWeatherService().(weather.ForecastWeather); ok {
+			m.Weather = w
+		}
+	}
+	return &m
+}
+
+//CalibrationFile in which the calibration of the yield model is saved
+func (config *Config) CalibrationFile() string {
+	if config.Yield.Calibration.File == "" {
+		return calibration.DefaultFile
+	}
+	return config.Yield.Calibration.File
+}
+
+func (config *Config) planes() []yield_forecast.Plane {
+	planes := make([]yield_forecast.Plane, len(config.Yield.Planes))
+	for i, p := range config.Yield.Planes {
+		planes[i] = yield_forecast.Plane{Declination: p.Declination, Azimuth: p.Azimuth, KWP: p.KWP}
+	}
+	return planes
+}
+
+//GetEnergyAccounting of the polled power flow, nil if disabled
+func (config *Config) GetEnergyAccounting() *energy.Accounting {
+	if !config.Energy.Enabled {
+		return nil
+	}
+	return &energy.Accounting{File: config.Energy.File, MaxGap: config.Energy.MaxGap, Location: config.Location()}
+}
+
+//GetEmissionFactor of the grid, nil if disabled
+func (config *Config) GetEmissionFactor() *emissions.Factor {
+	if !config.Emissions.Enabled {
+		return nil
+	}
+	return &emissions.Factor{Static: config.Emissions.Factor, Hourly: config.Emissions.Hourly}
+}
+
+//DefaultCurrency of the tariff
+const DefaultCurrency = "EUR"
+
+//GetTariff from a config, invalid times and dates are reported by the validation and skipped
+func (config *Config) GetTariff() *tariff.Tariff {
+	c := config.Tariff
+	loc := config.Location()
+	t := tariff.Tariff{
+		Currency:         c.Currency,
+		FixedCosts:       c.FixedCosts,
+		InstallationCost: c.Installation.Cost,
+		Location:         loc,
+	}
+	if t.Currency == "" {
+		t.Currency = DefaultCurrency
+	}
+	t.InstallationDate, _ = tariff.ParseDate(c.Installation.Date, loc)
+
+	var price tariff.ImportPrice = tariff.Flat(c.Import.Price)
+	if len(c.Import.Periods) > 0 {
+		tou := &tariff.TimeOfUse{Default: c.Import.Price, Location: loc}
+		for _, p := range c.Import.Periods {
+			if w, err := p.window(); err == nil {
+				tou.Windows = append(tou.Windows, w)
+			}
+		}
+		price = tou
+	}
+	if c.Import.Dynamic {
+		price = &tariff.Dynamic{Markup: c.Import.Markup, Fallback: price}
+	}
+	t.Import = price
+
+	for _, f := range c.FeedIn {
+		from, errFrom := tariff.ParseDate(f.From, loc)
+		until, errUntil := tariff.ParseDate(f.Until, loc)
+		if errFrom == nil && errUntil == nil {
+			t.FeedIn = append(t.FeedIn, tariff.FeedIn{From: from, Until: until, Price: f.Price})
+		}
+	}
+	return &t
+}
+
+//GetPriceService of the market prices from a config, nil if no provider is set and the prices are saved by another tool
+func (config *Config) GetPriceService() price.GenericPrice {
+	c := config.Tariff.Prices
+	switch c.Provider {
+	case price.ProviderAWATTar:
+		var a price.AWATTar
+		a.URL = price.AWATTarAustriaURL
+		if strings.EqualFold(c.Country, "de") {
+			a.URL = price.AWATTarGermanyURL
+		}
+		a.Location = config.Location()
+		return &a
+	case price.ProviderTibber:
+		var t price.Tibber
+		t.URL = price.TibberURL
+		t.Token = c.Token
+		t.Home = c.Home
+		return &t
+	case price.ProviderENTSOE:
+		var e price.ENTSOE
+		e.URL = price.ENTSOEURL
+		e.Token = c.Token
+		e.Area = c.Area
+		e.Location = config.Location()
+		return &e
+	}
+	return nil
+}
+
+//window of the price period
+func (p PricePeriod) window() (tariff.Window, error) {
+	var err error
+	w := tariff.Window{Price: p.Price}
+	if w.From, err = tariff.ParseClock(p.From); err != nil {
+		return w, err
+	}
+	if w.Until, err = tariff.ParseClock(p.Until); err != nil {
+		return w, err
+	}
+	for _, name := range p.Days {
+		day, err := tariff.ParseWeekday(name)
+		if err != nil {
+			return w, err
+		}
+		w.Days = append(w.Days, day)
+	}
+	return w, nil
+}
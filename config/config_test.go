@@ -3,12 +3,18 @@ package config
 import (
 	"net"
 	"reflect"
+	"solargo/calibration"
+	"solargo/emissions"
+	"solargo/energy"
 	"solargo/inverter"
 	"solargo/persistence"
-	"solargo/testutils"
+	"solargo/price"
+	"solargo/tariff"
 	"solargo/weather"
 	"solargo/yield_forecast"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestReadConfig(t *testing.T) {
@@ -28,10 +34,15 @@ func TestReadConfig(t *testing.T) {
 	for _, tt := range tests {
 		testname := tt.path
 		t.Run(testname, func(t *testing.T) {
+			ans, err := ReadConfig(tt.path)
 			if tt.errors {
-				testutils.AssertPanic(t, func() { ReadConfig(tt.path) })
+				if err == nil || !strings.HasPrefix(err.Error(), "Can not read config file. Error: ") {
+					t.Errorf("Expected error, got %v", err)
+				}
 			} else {
-				ans := ReadConfig(tt.path)
+				if err != nil {
+					t.Errorf("Should not produce Error: %s", err)
+				}
 				if reflect.DeepEqual(ans, tt.want) {
 					t.Errorf("got %v, want %v", ans, tt.want)
 				}
@@ -50,6 +61,7 @@ func TestGetInverter(t *testing.T) {
 	fronius.IP = net.IPv4(1, 2, 3, 4)
 	fronius.Port = 5678
 	fronius.DeviceID = "9"
+	fronius.Location = time.Local
 
 	var tests = []struct {
 		inverterName string
@@ -82,6 +94,7 @@ func TestGetDatabase(t *testing.T) {
 	influx.DatabaseName = "dbname"
 	influx.User = "user"
 	influx.Password = "pw"
+	influx.Location = time.Local
 
 	var tests = []struct {
 		databaseName string
@@ -108,19 +121,33 @@ func TestGetWeatherService(t *testing.T) {
 	config.Weather.Token = "token"
 	config.Weather.City = "123"
 	config.Weather.LanguageCode = "de"
+	config.Weather.OneCall = true
+	config.Latitude, config.Longitude = 48.2, 16.37
 
 	var w weather.OpenWeather
 	w.Token = "token"
 	w.City = "123"
 	w.LanguageCode = "de"
+	w.Latitude, w.Longitude = 48.2, 16.37
+	w.OneCall = true
 	w.URL = weather.OpenWeatherURL
 
+	var openMeteo Config
+	openMeteo.Weather.Provider = weather.ProviderOpenMeteo
+	openMeteo.Latitude, openMeteo.Longitude = 48.2, 16.37
+
+	dwd := openMeteo
+	dwd.Weather.Provider = weather.ProviderDWDMosmix
+	dwd.Weather.Station = "11035"
+
 	var tests = []struct {
 		weatherService string
 		config         Config
 		want           weather.GenericWeather
 	}{
 		{"OpenWeather", config, &w},
+		{"OpenMeteo", openMeteo, &weather.OpenMeteo{Latitude: 48.2, Longitude: 16.37, URL: weather.OpenMeteoURL}},
+		{"DWDMosmix", dwd, &weather.DWDMosmix{Latitude: 48.2, Longitude: 16.37, Station: "11035", URL: weather.DWDURL, StationsURL: weather.DWDStationsURL}},
 	}
 
 	for _, tt := range tests {
@@ -149,12 +176,51 @@ func TestGetYieldService(t *testing.T) {
 	s.Algorithm = "algo"
 	s.URL = yield_forecast.SolarPrognoseURL
 
+	var forecastSolar Config
+	forecastSolar.Latitude, forecastSolar.Longitude = 48.2, 16.37
+	forecastSolar.Yield.Provider = yield_forecast.ProviderForecastSolar
+	forecastSolar.Yield.Planes = []Plane{{Declination: 30, Azimuth: -90, KWP: 4.5}}
+	forecastSolar.Timezone = "UTC"
+
+	model := forecastSolar
+	model.Yield.Provider = yield_forecast.ProviderModel
+	model.Yield.InverterLimit = 4000
+
+	losses := 10.0
+	modelWithWeather := model
+	modelWithWeather.Yield.Losses = &losses
+	modelWithWeather.Weather.Enabled = true
+	modelWithWeather.Weather.Provider = weather.ProviderOpenMeteo
+
+	calibrated := model
+	calibrated.Yield.Calibration.Enabled = true
+	calibrated.Yield.Calibration.File = "/var/lib/solargo/calibration.json"
+
 	var tests = []struct {
 		testName string
 		config   Config
 		want     yield_forecast.GenericYieldForecast
 	}{
 		{"Solarprognose", config, &s},
+		{"ForecastSolar", forecastSolar, &yield_forecast.ForecastSolar{
+			Latitude: 48.2, Longitude: 16.37, Planes: []yield_forecast.Plane{{Declination: 30, Azimuth: -90, KWP: 4.5}}, URL: yield_forecast.ForecastSolarURL, Location: time.UTC,
+		}},
+		{"Model", model, &yield_forecast.Model{
+			Latitude: 48.2, Longitude: 16.37, Planes: []yield_forecast.Plane{{Declination: 30, Azimuth: -90, KWP: 4.5}},
+			Losses: yield_forecast.DefaultLosses, InverterLimit: 4000, Location: time.UTC,
+		}},
+		{"Model with weather", modelWithWeather, &yield_forecast.Model{
+			Latitude: 48.2, Longitude: 16.37, Planes: []yield_forecast.Plane{{Declination: 30, Azimuth: -90, KWP: 4.5}},
+			Losses: 10, InverterLimit: 4000, Location: time.UTC,
+			Weather: &weather.OpenMeteo{Latitude: 48.2, Longitude: 16.37, URL: weather.OpenMeteoURL},
+		}},
+		{"Calibrated model", calibrated, &calibration.Forecaster{
+			Baseline: &yield_forecast.Model{
+				Latitude: 48.2, Longitude: 16.37, Planes: []yield_forecast.Plane{{Declination: 30, Azimuth: -90, KWP: 4.5}},
+				Losses: yield_forecast.DefaultLosses, InverterLimit: 4000, Location: time.UTC,
+			},
+			File: "/var/lib/solargo/calibration.json",
+		}},
 	}
 
 	for _, tt := range tests {
@@ -167,3 +233,187 @@ func TestGetYieldService(t *testing.T) {
 		})
 	}
 }
+
+func TestLocation(t *testing.T) {
+	var tests = []struct {
+		timezone string
+		want     string
+	}{
+		{"", time.Local.String()},
+		{"Europe/Vienna", "Europe/Vienna"},
+		{"UTC", "UTC"},
+		{"Not/There", time.Local.String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			config := Config{Timezone: tt.timezone}
+			if ans := config.Location().String(); ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestYieldProviders(t *testing.T) {
+	var tests = []struct {
+		testName  string
+		provider  string
+		algorithm string
+		calibrate bool
+		compare   []string
+		want      []string
+	}{
+		{"Solarprognose", "", "", false, nil, []string{"solarprognose"}},
+		{"Solarprognose algorithm", "solarprognose", "own-v1", false, []string{"model", "solarprognose/mosmix"}, []string{"solarprognose/own-v1", "model", "solarprognose/mosmix"}},
+		{"Calibrated model", "model", "", true, []string{"model", "model/calibrated"}, []string{"model/calibrated", "model"}},
+		{"Forecast.Solar", "forecast.solar", "mosmix", false, []string{"forecast.solar"}, []string{"forecast.solar"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var c Config
+			c.Yield.Provider = tt.provider
+			c.Yield.Algorithm = tt.algorithm
+			c.Yield.Calibration.Enabled = tt.calibrate
+			c.Yield.Compare = tt.compare
+			if ans := c.TrackedYieldProviders(); !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetComparedYieldForecasts(t *testing.T) {
+	var c Config
+	c.Latitude, c.Longitude = 48.2, 16.37
+	c.Timezone = "UTC"
+	c.Yield.Token = "token"
+	c.Yield.ID = "1234"
+	c.Yield.Type = "plant"
+	c.Yield.Planes = []Plane{{Declination: 30, Azimuth: 0, KWP: 5}}
+	c.Yield.Compare = []string{"solarprognose/mosmix", "model/calibrated"}
+
+	compared := c.GetComparedYieldForecasts()
+	planes := []yield_forecast.Plane{{Declination: 30, Azimuth: 0, KWP: 5}}
+	want := map[string]yield_forecast.GenericYieldForecast{
+		"solarprognose/mosmix": &yield_forecast.SolarPrognose{Token: "token", Type: "plant", ID: "1234", Algorithm: "mosmix", URL: yield_forecast.SolarPrognoseURL},
+		"model/calibrated": &calibration.Forecaster{
+			Baseline: &yield_forecast.Model{Latitude: 48.2, Longitude: 16.37, Planes: planes, Losses: yield_forecast.DefaultLosses, Location: time.UTC},
+			File:     calibration.DefaultFile,
+		},
+	}
+	if !reflect.DeepEqual(compared, want) {
+		t.Errorf("got %v, want %v", compared, want)
+	}
+	if c.Yield.Provider != "" || c.Yield.Algorithm != "" {
+		t.Errorf("The config should not be changed")
+	}
+}
+
+func TestGetTariff(t *testing.T) {
+	var c Config
+	c.Timezone = "UTC"
+	c.Tariff.Import.Price = 0.30
+	c.Tariff.Import.Periods = []PricePeriod{{From: "22:00", Until: "06:00", Days: []string{"fri", "saturday"}, Price: 0.20}, {From: "now", Until: "later"}}
+	c.Tariff.FeedIn = []FeedIn{{Until: "2033-01-01", Price: 0.08}, {From: "2033", Price: 0.05}}
+	c.Tariff.FixedCosts = 150
+	c.Tariff.Installation.Cost = 12000
+	c.Tariff.Installation.Date = "2020-06-01"
+
+	tou := &tariff.TimeOfUse{
+		Default:  0.30,
+		Windows:  []tariff.Window{{From: 22 * time.Hour, Until: 6 * time.Hour, Days: []time.Weekday{time.Friday, time.Saturday}, Price: 0.20}},
+		Location: time.UTC,
+	}
+	want := &tariff.Tariff{
+		Currency:         DefaultCurrency,
+		Import:           tou,
+		FeedIn:           []tariff.FeedIn{{Until: time.Date(2033, time.January, 1, 0, 0, 0, 0, time.UTC), Price: 0.08}},
+		FixedCosts:       150,
+		InstallationCost: 12000,
+		InstallationDate: time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC),
+		Location:         time.UTC,
+	}
+	if actual := c.GetTariff(); !reflect.DeepEqual(actual, want) {
+		t.Errorf("got %+v, want %+v", actual, want)
+	}
+
+	c.Tariff.Currency = "CHF"
+	c.Tariff.Import.Dynamic = true
+	c.Tariff.Import.Markup = 0.12
+	want.Currency = "CHF"
+	want.Import = &tariff.Dynamic{Markup: 0.12, Fallback: tou}
+	if actual := c.GetTariff(); !reflect.DeepEqual(actual, want) {
+		t.Errorf("got %+v, want %+v", actual, want)
+	}
+
+	c.Tariff.Import.Dynamic = false
+	c.Tariff.Import.Periods = nil
+	if actual := c.GetTariff().Import; actual != tariff.Flat(0.30) {
+		t.Errorf("got %v, want a flat price", actual)
+	}
+}
+
+func TestGetPriceService(t *testing.T) {
+	withPrices := func(provider, country, token, home, area string) Config {
+		var c Config
+		c.Tariff.Prices.Provider = provider
+		c.Tariff.Prices.Country = country
+		c.Tariff.Prices.Token = token
+		c.Tariff.Prices.Home = home
+		c.Tariff.Prices.Area = area
+		c.Timezone = "UTC"
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   price.GenericPrice
+	}{
+		{"None", withPrices("", "", "", "", ""), nil},
+		{"aWATTar", withPrices(price.ProviderAWATTar, "", "", "", ""), &price.AWATTar{URL: price.AWATTarAustriaURL, Location: time.UTC}},
+		{"aWATTar Germany", withPrices(price.ProviderAWATTar, "DE", "", "", ""), &price.AWATTar{URL: price.AWATTarGermanyURL, Location: time.UTC}},
+		{"Tibber", withPrices(price.ProviderTibber, "", "token", "home", ""), &price.Tibber{URL: price.TibberURL, Token: "token", Home: "home"}},
+		{"ENTSO-E", withPrices(price.ProviderENTSOE, "", "token", "", "10YAT-APG------L"), &price.ENTSOE{URL: price.ENTSOEURL, Token: "token", Area: "10YAT-APG------L", Location: time.UTC}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ans := tt.config.GetPriceService(); !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEnergyAccounting(t *testing.T) {
+	var c Config
+	if a := c.GetEnergyAccounting(); a != nil {
+		t.Errorf("A disabled accounting should be nil, got %+v", a)
+	}
+
+	c.Timezone = "UTC"
+	c.Energy.Enabled = true
+	c.Energy.File = "/var/lib/solargo/energy.json"
+	c.Energy.MaxGap = 20 * time.Minute
+	want := &energy.Accounting{File: "/var/lib/solargo/energy.json", MaxGap: 20 * time.Minute, Location: time.UTC}
+	if a := c.GetEnergyAccounting(); !reflect.DeepEqual(a, want) {
+		t.Errorf("got %+v, want %+v", a, want)
+	}
+}
+
+func TestGetEmissionFactor(t *testing.T) {
+	var c Config
+	c.Emissions.Factor = 400
+	if f := c.GetEmissionFactor(); f != nil {
+		t.Errorf("A disabled emission factor should be nil, got %+v", f)
+	}
+
+	c.Emissions.Enabled = true
+	c.Emissions.Hourly = true
+	want := &emissions.Factor{Static: 400, Hourly: true}
+	if f := c.GetEmissionFactor(); !reflect.DeepEqual(f, want) {
+		t.Errorf("got %+v, want %+v", f, want)
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//EnvPrefix of the environment variables overriding config values
+const EnvPrefix = "SOLARGO"
+
+//EnvFileSuffix of environment variables pointing to a file which contains the value, e.g. a secret
+const EnvFileSuffix = "_FILE"
+
+//EnvName returns the environment variable of a YAML path, e.g. persistence.password is SOLARGO_PERSISTENCE_PASSWORD
+func EnvName(path string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.Replace(path, ".", "_", -1))
+}
+
+//ApplyEnvironment overrides every config value for which an environment variable is set
+func (config *Config) ApplyEnvironment(lookup func(string) (string, bool)) error {
+	return applyEnvironment(reflect.ValueOf(config).Elem(), "", lookup)
+}
+
+func applyEnvironment(v reflect.Value, path string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		fieldPath := tag
+		if path != "" {
+			fieldPath = path + "." + tag
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnvironment(field, fieldPath, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok, err := lookupValue(EnvName(fieldPath), lookup)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		//Strings are taken literally, everything else is parsed like in the config file
+		if field.Kind() == reflect.String {
+			field.SetString(value)
+		} else if err := yaml.UnmarshalStrict([]byte(value), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("Can not parse %s for %s. Error: %s", EnvName(fieldPath), fieldPath, err)
+		}
+	}
+	return nil
+}
+
+//lookupValue returns the value of the environment variable or the content of the file of its _FILE variant
+func lookupValue(name string, lookup func(string) (string, bool)) (string, bool, error) {
+	value, ok := lookup(name)
+	file, fileOk := lookup(name + EnvFileSuffix)
+
+	if ok && fileOk {
+		return "", false, fmt.Errorf("Only one of %s and %s%s may be set", name, name, EnvFileSuffix)
+	}
+	if !fileOk {
+		return value, ok, nil
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", false, fmt.Errorf("Can not read %s%s. Error: %s", name, EnvFileSuffix, err)
+	}
+
+	//Secret files usually end with a newline
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	var tests = []struct {
+		path string
+		want string
+	}{
+		{"debug", "SOLARGO_DEBUG"},
+		{"persistence.password", "SOLARGO_PERSISTENCE_PASSWORD"},
+		{"summary.chart.width", "SOLARGO_SUMMARY_CHART_WIDTH"},
+		{"yield_forecast.api_token", "SOLARGO_YIELD_FORECAST_API_TOKEN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if ans := EnvName(tt.path); ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "solargo")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "influx_password")
+	if err := ioutil.WriteFile(secret, []byte("s3cr3t: #1\n"), 0600); err != nil {
+		t.Fatalf("Could not write secret: %s", err)
+	}
+
+	config := getValidConfig()
+
+	err = config.ApplyEnvironment(lookupFrom(map[string]string{
+		"SOLARGO_DEBUG":                     "true",
+		"SOLARGO_LATITUDE":                  "47.5",
+		"SOLARGO_SUMMARY_BOT_TOKEN":         "123:abc",
+		"SOLARGO_SUMMARY_CHART_WIDTH":       "800",
+		"SOLARGO_INVERTER_IP":               "10.0.0.7",
+		"SOLARGO_INVERTER_PORT":             "8080",
+		"SOLARGO_INVERTER_DEVICE_ID":        "2",
+		"SOLARGO_PERSISTENCE_PASSWORD_FILE": secret,
+		"SOLARGO_BACKFILL_MIN_GAP":          "15m",
+		"SOLARGO_YIELD_FORECAST_API_TOKEN":  "yes",
+		"SOLARGO_UNKNOWN":                   "ignored",
+	}))
+
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	want := getValidConfig()
+	want.Debug = true
+	want.Latitude = 47.5
+	want.Summary.BotToken = "123:abc"
+	want.Summary.Chart.Width = 800
+	want.Inverter.IP = net.ParseIP("10.0.0.7")
+	want.Inverter.Port = 8080
+	want.Inverter.DeviceID = "2"
+	want.Persistence.Password = "s3cr3t: #1"
+	want.Backfill.MinGap = 15 * time.Minute
+	want.Yield.Token = "yes"
+
+	if !config.Inverter.IP.Equal(want.Inverter.IP) {
+		t.Errorf("got ip %s, want %s", config.Inverter.IP, want.Inverter.IP)
+	}
+	config.Inverter.IP = want.Inverter.IP
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("got %+v, want %+v", config, want)
+	}
+}
+
+func TestApplyEnvironmentErrors(t *testing.T) {
+	var tests = []struct {
+		testName string
+		env      map[string]string
+		want     string
+	}{
+		{"Invalid number", map[string]string{"SOLARGO_INVERTER_PORT": "eighty"}, "Can not parse SOLARGO_INVERTER_PORT for inverter.port"},
+		{"Invalid duration", map[string]string{"SOLARGO_BACKFILL_MAX_AGE": "a week"}, "Can not parse SOLARGO_BACKFILL_MAX_AGE for backfill.max_age"},
+		{"Both set", map[string]string{"SOLARGO_SUMMARY_BOT_TOKEN": "a", "SOLARGO_SUMMARY_BOT_TOKEN_FILE": "b"}, "Only one of SOLARGO_SUMMARY_BOT_TOKEN and SOLARGO_SUMMARY_BOT_TOKEN_FILE may be set"},
+		{"Missing file", map[string]string{"SOLARGO_SUMMARY_BOT_TOKEN_FILE": "/not/there"}, "Can not read SOLARGO_SUMMARY_BOT_TOKEN_FILE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			config := getValidConfig()
+			if err := config.ApplyEnvironment(lookupFrom(tt.env)); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %v, want Prefix %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadConfigAppliesEnvironment(t *testing.T) {
+	os.Setenv("SOLARGO_PERSISTENCE_USER", "envuser")
+	defer os.Unsetenv("SOLARGO_PERSISTENCE_USER")
+
+	config, err := ReadConfig("../testutils/config/sample.yaml")
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if config.Persistence.User != "envuser" || config.Persistence.Password != "dbpasswd" {
+		t.Errorf("got user %s and password %s", config.Persistence.User, config.Persistence.Password)
+	}
+}
@@ -0,0 +1,468 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"solargo/inverter"
+	"solargo/price"
+	"solargo/schedule"
+	"solargo/tariff"
+	"solargo/weather"
+	"solargo/yield_forecast"
+	"strings"
+	"time"
+)
+
+//Severity of a problem in the config
+type Severity int
+
+//Severities of problems, configs with errors can not be used
+const (
+	Warning Severity = iota
+	Error
+)
+
+//Problem found while validating the config
+type Problem struct {
+	Severity Severity
+	Path     string // YAML path of the value, e.g. inverter.ip
+	Message  string
+}
+
+//Problems found while validating the config
+type Problems []Problem
+
+//String representation of a severity
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+//String representation of a problem
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Severity, p.Path, p.Message)
+}
+
+//HasErrors returns true if at least one problem is an error
+func (p Problems) HasErrors() bool {
+	for _, problem := range p {
+		if problem.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+//Error joins all problems, so that they can be returned as error
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+	for i, problem := range p {
+		lines[i] = problem.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (p *Problems) errorf(path string, format string, args ...interface{}) {
+	*p = append(*p, Problem{Error, path, fmt.Sprintf(format, args...)})
+}
+
+func (p *Problems) warnf(path string, format string, args ...interface{}) {
+	*p = append(*p, Problem{Warning, path, fmt.Sprintf(format, args...)})
+}
+
+//Validate checks the config for semantically invalid or suspicious values
+func (config *Config) Validate() Problems {
+	var p Problems
+
+	config.validateLocation(&p)
+	config.validateSummary(&p)
+	config.validateInverter(&p)
+	config.validateLogging(&p)
+	config.validatePersistence(&p)
+	config.validateSchedule(&p)
+	config.validateBackfill(&p)
+	config.validateEnergy(&p)
+	config.validateEmissions(&p)
+	config.validateWeather(&p)
+	config.validateYieldForecast(&p)
+	config.validateTariff(&p)
+
+	return p
+}
+
+func (config *Config) validateLocation(p *Problems) {
+	if config.Latitude < -90 || config.Latitude > 90 {
+		p.errorf("latitude", "%g is out of range, must be between -90 and 90", config.Latitude)
+	}
+	if config.Longitude < -180 || config.Longitude > 180 {
+		p.errorf("longitude", "%g is out of range, must be between -180 and 180", config.Longitude)
+	}
+	if config.Latitude == 0 && config.Longitude == 0 {
+		p.errorf("latitude", "latitude and longitude are 0/0, set the coordinates of the solar power plant to compute sunrise and sunset")
+	}
+	if config.Timezone != "" {
+		if _, err := time.LoadLocation(config.Timezone); err != nil {
+			p.errorf("timezone", "%q is not a known timezone like Europe/Vienna", config.Timezone)
+		}
+	}
+}
+
+func (config *Config) validateSummary(p *Problems) {
+	s := config.Summary
+	if s.SendStatistics {
+		validateURL(p, "summary.telegram_url", s.TelegramURL)
+		if s.BotToken == "" {
+			p.errorf("summary.bot_token", "must be set to send the summary")
+		}
+		if s.ChatID == "" {
+			p.errorf("summary.chat_id", "must be set to send the summary")
+		}
+	}
+
+	c := s.Chart
+	if c.Width < 0 {
+		p.errorf("summary.chart.width", "%d must not be negative", c.Width)
+	} else if c.Width > 4096 {
+		p.warnf("summary.chart.width", "%d pixels is very large, Telegram may reject the chart", c.Width)
+	}
+	if c.Height < 0 {
+		p.errorf("summary.chart.height", "%d must not be negative", c.Height)
+	} else if c.Height > 4096 {
+		p.warnf("summary.chart.height", "%d pixels is very large, Telegram may reject the chart", c.Height)
+	}
+	if s.AccuracyReport && !config.Yield.Enabled {
+		p.warnf("summary.accuracy_report", "has no effect while the yield forecast is disabled")
+	}
+	if s.EnergyReport && !config.Energy.Enabled {
+		p.warnf("summary.energy_report", "has no effect while the energy accounting is disabled")
+	}
+	validateOneOf(p, "summary.chart.theme", c.Theme, "", "light", "dark")
+	validateOneOf(p, "summary.chart.format", c.Format, "", "png", "svg")
+}
+
+func (config *Config) validateInverter(p *Problems) {
+	i := config.Inverter
+	if i.IP == nil || i.IP.IsUnspecified() {
+		p.errorf("inverter.ip", "must be set to the IP address of the inverter")
+	}
+	if i.Port == 0 {
+		p.errorf("inverter.port", "must be set, the Fronius Solar API listens on port 80 by default")
+	} else if i.Port < 80 {
+		p.errorf("inverter.port", "%d is reserved for other services, the Fronius Solar API listens on port 80 by default", i.Port)
+	} else if i.Port != 80 && i.Port != 443 && i.Port < 1024 {
+		p.warnf("inverter.port", "%d is unusual, the Fronius Solar API listens on port 80 by default", i.Port)
+	}
+	if i.DeviceID == "" {
+		p.errorf("inverter.device_id", "must be set, usually 1")
+	}
+}
+
+func (config *Config) validateLogging(p *Problems) {
+	if config.Logging.Enabled && config.Logging.Filename == "" {
+		p.errorf("logging.file_name", "must be set if logging is enabled")
+	}
+}
+
+func (config *Config) validatePersistence(p *Problems) {
+	db := config.Persistence
+	validateURL(p, "persistence.url", db.URL)
+	if db.DatabaseName == "" {
+		p.errorf("persistence.database_name", "must be set")
+	}
+	if db.User != "" && db.Password == "" {
+		p.warnf("persistence.password", "is empty although a user is set, authentication is disabled")
+	} else if db.User == "" && db.Password != "" {
+		p.warnf("persistence.user", "is empty although a password is set, authentication is disabled")
+	}
+}
+
+func (config *Config) validateSchedule(p *Problems) {
+	s := config.Schedule
+	specs := []struct{ path, value string }{
+		{"schedule.poll", s.Poll},
+		{"schedule.night_poll", s.NightPoll},
+		{"schedule.weather", s.Weather},
+		{"schedule.summary", s.Summary},
+		{"schedule.yield_forecast", s.YieldForecast},
+		{"schedule.calibration", s.Calibration},
+		{"schedule.accuracy", s.Accuracy},
+		{"schedule.accuracy_report", s.AccuracyReport},
+		{"schedule.financials", s.Financials},
+		{"schedule.prices", s.Prices},
+		{"schedule.energy_report", s.EnergyReport},
+	}
+	for _, spec := range specs {
+		if spec.value == "" || (spec.path == "schedule.night_poll" && spec.value == schedule.Off) {
+			continue
+		}
+		if _, err := schedule.Parse(spec.value, config.Latitude, config.Longitude, config.Location()); err != nil {
+			p.errorf(spec.path, "%s", err)
+		}
+	}
+
+	times := []struct{ path, value string }{
+		{"schedule.poll_from", s.PollFrom},
+		{"schedule.poll_until", s.PollUntil},
+		{"schedule.weather_from", s.WeatherFrom},
+		{"schedule.weather_until", s.WeatherUntil},
+		{"schedule.yield_forecast_from", s.YieldForecastFrom},
+		{"schedule.yield_forecast_until", s.YieldForecastUntil},
+	}
+	for _, t := range times {
+		if t.value == "" {
+			continue
+		}
+		if _, err := schedule.ParseTime(t.value); err != nil {
+			p.errorf(t.path, "%s", err)
+		}
+	}
+}
+
+func (config *Config) validateEnergy(p *Problems) {
+	e := config.Energy
+	if e.MaxGap < 0 {
+		p.errorf("energy.max_gap", "%s must not be negative", e.MaxGap)
+	}
+	if e.Enabled && config.Schedule.NightPoll == schedule.Off {
+		p.warnf("energy.enabled", "the night poll is off, the energy of the night is not accounted")
+	}
+}
+
+func (config *Config) validateEmissions(p *Problems) {
+	e := config.Emissions
+	if e.Factor < 0 {
+		p.errorf("emissions.factor", "%g must not be negative", e.Factor)
+	} else if e.Enabled && e.Factor == 0 && !e.Hourly {
+		p.errorf("emissions.factor", "must be set to the emissions of the grid in g CO2 per kWh, e.g. 400")
+	} else if e.Factor > 2000 {
+		p.warnf("emissions.factor", "%g g CO2 per kWh is more than a coal power plant emits", e.Factor)
+	}
+	if e.Enabled && !config.Energy.Enabled {
+		p.warnf("emissions.enabled", "has no effect while the energy accounting is disabled")
+	}
+}
+
+func (config *Config) validateBackfill(p *Problems) {
+	b := config.Backfill
+	if b.MaxAge < 0 {
+		p.errorf("backfill.max_age", "%s must not be negative", b.MaxAge)
+	}
+	if b.ChunkSize < 0 {
+		p.errorf("backfill.chunk_size", "%s must not be negative", b.ChunkSize)
+	} else if b.ChunkSize > inverter.MaxArchiveRange {
+		p.errorf("backfill.chunk_size", "%s exceeds the maximum archive range of %s", b.ChunkSize, inverter.MaxArchiveRange)
+	}
+	if b.MinGap < 0 {
+		p.errorf("backfill.min_gap", "%s must not be negative", b.MinGap)
+	} else if b.MinGap > 0 && b.MinGap < time.Minute {
+		p.warnf("backfill.min_gap", "%s is shorter than the archive resolution, every poll interval is treated as gap", b.MinGap)
+	}
+}
+
+func (config *Config) validateWeather(p *Problems) {
+	w := config.Weather
+	validateOneOf(p, "weather.provider", w.Provider, "", weather.ProviderOpenWeather, weather.ProviderOpenMeteo, weather.ProviderDWDMosmix)
+	if w.Station != "" && w.Provider != weather.ProviderDWDMosmix {
+		p.warnf("weather.station", "is only used by the %q provider", weather.ProviderDWDMosmix)
+	}
+	if !w.Enabled || w.Provider == weather.ProviderOpenMeteo || w.Provider == weather.ProviderDWDMosmix {
+		return
+	}
+	if w.Token == "" {
+		p.errorf("weather.api_token", "must be set if the weather is enabled")
+	}
+	if w.City != "" && w.OneCall {
+		p.warnf("weather.city_code", "is ignored by the One Call API, which uses latitude and longitude")
+	}
+	if len(w.LanguageCode) != 2 {
+		p.warnf("weather.language_code", "%q is not a two letter language code", w.LanguageCode)
+	}
+}
+
+func (config *Config) validateYieldForecast(p *Problems) {
+	y := config.Yield
+	validateOneOf(p, "yield_forecast.provider", y.Provider, "", yield_forecast.ProviderSolarPrognose, yield_forecast.ProviderForecastSolar, yield_forecast.ProviderModel)
+	for i, plane := range y.Planes {
+		path := fmt.Sprintf("yield_forecast.planes[%d]", i)
+		if plane.Declination < 0 || plane.Declination > 90 {
+			p.errorf(path+".declination", "%g is out of range, must be between 0 and 90", plane.Declination)
+		}
+		if plane.Azimuth < -180 || plane.Azimuth > 180 {
+			p.errorf(path+".azimuth", "%g is out of range, must be between -180 and 180", plane.Azimuth)
+		}
+		if plane.KWP <= 0 {
+			p.errorf(path+".kwp", "%g must be positive", plane.KWP)
+		}
+	}
+	if y.Losses != nil && (*y.Losses < 0 || *y.Losses >= 100) {
+		p.errorf("yield_forecast.losses", "%g is out of range, must be between 0 and 100 percent", *y.Losses)
+	}
+	if y.InverterLimit < 0 {
+		p.errorf("yield_forecast.inverter_limit", "%g must not be negative", y.InverterLimit)
+	}
+	if y.Calibration.History < 0 {
+		p.errorf("yield_forecast.calibration.history", "%s must not be negative", y.Calibration.History)
+	}
+	if y.Calibration.Enabled && y.Provider != yield_forecast.ProviderModel {
+		p.errorf("yield_forecast.calibration.enabled", "the calibration requires the %q provider", yield_forecast.ProviderModel)
+	}
+	for i, name := range y.Compare {
+		config.validateComparedProvider(p, fmt.Sprintf("yield_forecast.compare[%d]", i), name)
+	}
+	if !y.Enabled {
+		return
+	}
+	if y.Provider == yield_forecast.ProviderForecastSolar || y.Provider == yield_forecast.ProviderModel {
+		if len(y.Planes) == 0 {
+			p.errorf("yield_forecast.planes", "must contain at least one plane for %s", y.Provider)
+		}
+		if y.Provider == yield_forecast.ProviderModel && !config.Weather.Enabled {
+			p.warnf("yield_forecast.provider", "the model forecasts a cloudless sky if the weather is disabled")
+		}
+		return
+	}
+	if y.Token == "" {
+		p.errorf("yield_forecast.api_token", "must be set if the yield forecast is enabled")
+	}
+	if y.ID == "" {
+		p.errorf("yield_forecast.id", "must be set if the yield forecast is enabled")
+	}
+	validateOneOf(p, "yield_forecast.type", y.Type, "plant", "inverter")
+	validateOneOf(p, "yield_forecast.algorithm", y.Algorithm, "", "mosmix", "own-v1", "clearsky")
+}
+
+func (config *Config) validateComparedProvider(p *Problems, path string, name string) {
+	c := config.withYieldProvider(name)
+	y := c.Yield
+	switch y.Provider {
+	case yield_forecast.ProviderSolarPrognose:
+		if config.Yield.Token == "" || config.Yield.ID == "" {
+			p.errorf(path, "%q requires the api_token and id of solarprognose.de", name)
+		}
+		validateOneOf(p, path, y.Algorithm, "", "mosmix", "own-v1", "clearsky")
+	case yield_forecast.ProviderForecastSolar, yield_forecast.ProviderModel:
+		if len(y.Planes) == 0 {
+			p.errorf(path, "%q requires at least one plane", name)
+		}
+		if c.YieldProviderName() != name {
+			p.errorf(path, "%q is unknown, did you mean %q?", name, c.YieldProviderName())
+		}
+	default:
+		p.errorf(path, "%q is invalid, must start with one of %q, %q, %q", name,
+			yield_forecast.ProviderSolarPrognose, yield_forecast.ProviderForecastSolar, yield_forecast.ProviderModel)
+		return
+	}
+	if name == config.YieldProviderName() {
+		p.warnf(path, "%q is already the provider of the yield forecast", name)
+	}
+}
+
+func (config *Config) validateTariff(p *Problems) {
+	t := config.Tariff
+	loc := config.Location()
+	if t.Import.Price < 0 {
+		p.errorf("tariff.import.price", "%g must not be negative", t.Import.Price)
+	}
+	for i, period := range t.Import.Periods {
+		path := fmt.Sprintf("tariff.import.periods[%d]", i)
+		if _, err := period.window(); err != nil {
+			p.errorf(path, "%s", err)
+		}
+		if period.Price < 0 {
+			p.errorf(path+".price", "%g must not be negative", period.Price)
+		}
+	}
+	if t.Import.Markup != 0 && !t.Import.Dynamic {
+		p.warnf("tariff.import.markup", "is only added to dynamic prices")
+	}
+	config.validatePrices(p)
+
+	for i, f := range t.FeedIn {
+		path := fmt.Sprintf("tariff.feed_in[%d]", i)
+		from, err := tariff.ParseDate(f.From, loc)
+		if err != nil {
+			p.errorf(path+".from", "%s", err)
+		}
+		until, err := tariff.ParseDate(f.Until, loc)
+		if err != nil {
+			p.errorf(path+".until", "%s", err)
+		}
+		if !from.IsZero() && !until.IsZero() && !until.After(from) {
+			p.errorf(path+".until", "%s must be after %s", f.Until, f.From)
+		}
+		if f.Price < 0 {
+			p.errorf(path+".price", "%g must not be negative", f.Price)
+		}
+	}
+
+	if t.FixedCosts < 0 {
+		p.errorf("tariff.fixed_costs", "%g must not be negative", t.FixedCosts)
+	}
+	if t.Installation.Cost < 0 {
+		p.errorf("tariff.installation.cost", "%g must not be negative", t.Installation.Cost)
+	}
+	if _, err := tariff.ParseDate(t.Installation.Date, loc); err != nil {
+		p.errorf("tariff.installation.date", "%s", err)
+	}
+	if t.Enabled && t.Import.Price == 0 && len(t.Import.Periods) == 0 && !t.Import.Dynamic {
+		p.warnf("tariff.import.price", "is 0, the self consumption saves nothing")
+	}
+}
+
+func (config *Config) validatePrices(p *Problems) {
+	c := config.Tariff.Prices
+	validateOneOf(p, "tariff.prices.provider", c.Provider, "", price.ProviderAWATTar, price.ProviderTibber, price.ProviderENTSOE)
+	if c.Provider != "" && !config.Tariff.Import.Dynamic {
+		p.warnf("tariff.prices.provider", "the market prices are only used by dynamic prices, set tariff.import.dynamic")
+	}
+	switch c.Provider {
+	case price.ProviderAWATTar:
+		if c.Country != "" && !strings.EqualFold(c.Country, "at") && !strings.EqualFold(c.Country, "de") {
+			p.errorf("tariff.prices.country", "%q is not supported by aWATTar, must be at or de", c.Country)
+		}
+	case price.ProviderTibber, price.ProviderENTSOE:
+		if config.Tariff.Enabled && c.Token == "" {
+			p.errorf("tariff.prices.api_token", "must be set for %s", c.Provider)
+		}
+	}
+	if c.Provider == price.ProviderENTSOE && c.Area == "" {
+		p.errorf("tariff.prices.area", "must be set to the bidding zone for %s, e.g. 10YAT-APG------L", c.Provider)
+	}
+	if c.Home != "" && c.Provider != price.ProviderTibber {
+		p.warnf("tariff.prices.home", "is only used by the %q provider", price.ProviderTibber)
+	}
+	if c.Area != "" && c.Provider != price.ProviderENTSOE {
+		p.warnf("tariff.prices.area", "is only used by the %q provider", price.ProviderENTSOE)
+	}
+}
+
+func validateURL(p *Problems, path string, value string) {
+	if value == "" {
+		p.errorf(path, "must be set")
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		p.errorf(path, "%q is not a valid URL: %s", value, err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		p.errorf(path, "%q must start with http:// or https://", value)
+	} else if u.Host == "" {
+		p.errorf(path, "%q does not contain a host", value)
+	}
+}
+
+func validateOneOf(p *Problems, path string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	quoted := make([]string, len(allowed))
+	for i, a := range allowed {
+		quoted[i] = fmt.Sprintf("%q", a)
+	}
+	p.errorf(path, "%q is invalid, must be one of %s", value, strings.Join(quoted, ", "))
+}
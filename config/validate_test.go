@@ -0,0 +1,325 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"solargo/price"
+	"testing"
+	"time"
+)
+
+func getValidConfig() Config {
+	var config Config
+	config.Latitude = 48.2
+	config.Longitude = 16.37
+	config.Inverter.IP = net.IPv4(192, 168, 1, 2)
+	config.Inverter.Port = 80
+	config.Inverter.DeviceID = "1"
+	config.Logging.Enabled = true
+	config.Logging.Filename = "logs/log.log"
+	config.Persistence.URL = "http://127.0.0.1:8086"
+	config.Persistence.DatabaseName = "solar"
+	return config
+}
+
+func TestValidateValidConfig(t *testing.T) {
+	config := getValidConfig()
+	if problems := config.Validate(); len(problems) != 0 {
+		t.Errorf("Valid config should not have problems, got %v", problems)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		testName string
+		modify   func(c *Config)
+		want     Problems
+	}{
+		{"Coordinates 0/0", func(c *Config) { c.Latitude, c.Longitude = 0, 0 }, Problems{
+			{Error, "latitude", "latitude and longitude are 0/0, set the coordinates of the solar power plant to compute sunrise and sunset"},
+		}},
+		{"Coordinates out of range", func(c *Config) { c.Latitude, c.Longitude = 91, -181 }, Problems{
+			{Error, "latitude", "91 is out of range, must be between -90 and 90"},
+			{Error, "longitude", "-181 is out of range, must be between -180 and 180"},
+		}},
+		{"Timezone", func(c *Config) { c.Timezone = "Europe/Graz" }, Problems{
+			{Error, "timezone", `"Europe/Graz" is not a known timezone like Europe/Vienna`},
+		}},
+		{"Timezone valid", func(c *Config) { c.Timezone = "Europe/Vienna" }, nil},
+		{"Summary", func(c *Config) {
+			c.Summary.SendStatistics = true
+			c.Summary.TelegramURL = "api.telegram.org/bot"
+			c.Summary.Chart.Width = -1
+			c.Summary.Chart.Height = 5000
+			c.Summary.Chart.Theme = "blue"
+			c.Summary.Chart.Format = "svg"
+		}, Problems{
+			{Error, "summary.telegram_url", `"api.telegram.org/bot" must start with http:// or https://`},
+			{Error, "summary.bot_token", "must be set to send the summary"},
+			{Error, "summary.chat_id", "must be set to send the summary"},
+			{Error, "summary.chart.width", "-1 must not be negative"},
+			{Warning, "summary.chart.height", "5000 pixels is very large, Telegram may reject the chart"},
+			{Error, "summary.chart.theme", `"blue" is invalid, must be one of "", "light", "dark"`},
+		}},
+		{"Empty inverter", func(c *Config) { c.Inverter.IP, c.Inverter.Port, c.Inverter.DeviceID = nil, 0, "" }, Problems{
+			{Error, "inverter.ip", "must be set to the IP address of the inverter"},
+			{Error, "inverter.port", "must be set, the Fronius Solar API listens on port 80 by default"},
+			{Error, "inverter.device_id", "must be set, usually 1"},
+		}},
+		{"Inverter port 1", func(c *Config) { c.Inverter.Port = 1 }, Problems{
+			{Error, "inverter.port", "1 is reserved for other services, the Fronius Solar API listens on port 80 by default"},
+		}},
+		{"Inverter port unusual", func(c *Config) { c.Inverter.Port = 81 }, Problems{
+			{Warning, "inverter.port", "81 is unusual, the Fronius Solar API listens on port 80 by default"},
+		}},
+		{"Inverter port high", func(c *Config) { c.Inverter.Port = 8080 }, nil},
+		{"Logging", func(c *Config) { c.Logging.Filename = "" }, Problems{
+			{Error, "logging.file_name", "must be set if logging is enabled"},
+		}},
+		{"Logging disabled", func(c *Config) { c.Logging.Enabled, c.Logging.Filename = false, "" }, nil},
+		{"Persistence", func(c *Config) {
+			c.Persistence.URL = ""
+			c.Persistence.DatabaseName = ""
+			c.Persistence.User = "user"
+		}, Problems{
+			{Error, "persistence.url", "must be set"},
+			{Error, "persistence.database_name", "must be set"},
+			{Warning, "persistence.password", "is empty although a user is set, authentication is disabled"},
+		}},
+		{"Persistence URL without host", func(c *Config) { c.Persistence.URL = "http://" }, Problems{
+			{Error, "persistence.url", `"http://" does not contain a host`},
+		}},
+		{"Schedule", func(c *Config) {
+			c.Schedule.Poll = "every 30 seconds"
+			c.Schedule.NightPoll = "100ms"
+			c.Schedule.Summary = "sunset-45m"
+			c.Schedule.Weather = "0 * * * *"
+			c.Schedule.PollFrom = "dawn"
+			c.Schedule.PollUntil = "sunset1h"
+			c.Schedule.WeatherFrom = "07:30"
+		}, Problems{
+			{Error, "schedule.poll", `"every 30 seconds" is neither a time, an interval nor a cron expression: Expected 5 or 6 fields, found 3: every 30 seconds`},
+			{Error, "schedule.night_poll", `"100ms" is shorter than a second`},
+			{Error, "schedule.poll_from", `"dawn" is neither relative to sunrise or sunset nor a time like 06:30`},
+			{Error, "schedule.poll_until", `"sunset1h" must be followed by +<duration> or -<duration>`},
+		}},
+		{"Energy", func(c *Config) {
+			c.Energy.Enabled = true
+			c.Energy.MaxGap = -time.Minute
+			c.Schedule.NightPoll = "off"
+		}, Problems{
+			{Error, "energy.max_gap", "-1m0s must not be negative"},
+			{Warning, "energy.enabled", "the night poll is off, the energy of the night is not accounted"},
+		}},
+		{"Emissions", func(c *Config) {
+			c.Emissions.Enabled = true
+		}, Problems{
+			{Error, "emissions.factor", "must be set to the emissions of the grid in g CO2 per kWh, e.g. 400"},
+			{Warning, "emissions.enabled", "has no effect while the energy accounting is disabled"},
+		}},
+		{"Emission factor", func(c *Config) {
+			c.Emissions.Factor = -1
+		}, Problems{
+			{Error, "emissions.factor", "-1 must not be negative"},
+		}},
+		{"Energy report", func(c *Config) {
+			c.Summary.EnergyReport = true
+			c.Schedule.EnergyReport = "monthly"
+		}, Problems{
+			{Warning, "summary.energy_report", "has no effect while the energy accounting is disabled"},
+			{Error, "schedule.energy_report", `"monthly" is neither a time, an interval nor a cron expression: Expected 5 or 6 fields, found 1: monthly`},
+		}},
+		{"Backfill", func(c *Config) {
+			c.Backfill.MaxAge = -time.Hour
+			c.Backfill.ChunkSize = 400 * time.Hour
+			c.Backfill.MinGap = time.Second
+		}, Problems{
+			{Error, "backfill.max_age", "-1h0m0s must not be negative"},
+			{Error, "backfill.chunk_size", "400h0m0s exceeds the maximum archive range of 384h0m0s"},
+			{Warning, "backfill.min_gap", "1s is shorter than the archive resolution, every poll interval is treated as gap"},
+		}},
+		{"Weather", func(c *Config) { c.Weather.Enabled = true; c.Weather.LanguageCode = "deu" }, Problems{
+			{Error, "weather.api_token", "must be set if the weather is enabled"},
+			{Warning, "weather.language_code", `"deu" is not a two letter language code`},
+		}},
+		{"Weather disabled", func(c *Config) { c.Weather.LanguageCode = "deu" }, nil},
+		{"One Call", func(c *Config) {
+			c.Weather.Enabled = true
+			c.Weather.Token = "token"
+			c.Weather.City = "123"
+			c.Weather.LanguageCode = "de"
+			c.Weather.OneCall = true
+		}, Problems{
+			{Warning, "weather.city_code", "is ignored by the One Call API, which uses latitude and longitude"},
+		}},
+		{"Open-Meteo", func(c *Config) { c.Weather.Enabled = true; c.Weather.Provider = "open-meteo" }, nil},
+		{"Weather provider", func(c *Config) { c.Weather.Provider = "yr" }, Problems{
+			{Error, "weather.provider", `"yr" is invalid, must be one of "", "openweathermap", "open-meteo", "dwd-mosmix"`},
+		}},
+		{"DWD MOSMIX", func(c *Config) {
+			c.Weather.Enabled = true
+			c.Weather.Provider = "dwd-mosmix"
+			c.Weather.Station = "10382"
+		}, nil},
+		{"Station without DWD", func(c *Config) { c.Weather.Station = "10382" }, Problems{
+			{Warning, "weather.station", `is only used by the "dwd-mosmix" provider`},
+		}},
+		{"Yield forecast", func(c *Config) {
+			c.Yield.Enabled = true
+			c.Yield.Type = "roof"
+			c.Yield.Algorithm = "magic"
+		}, Problems{
+			{Error, "yield_forecast.api_token", "must be set if the yield forecast is enabled"},
+			{Error, "yield_forecast.id", "must be set if the yield forecast is enabled"},
+			{Error, "yield_forecast.type", `"roof" is invalid, must be one of "plant", "inverter"`},
+			{Error, "yield_forecast.algorithm", `"magic" is invalid, must be one of "", "mosmix", "own-v1", "clearsky"`},
+		}},
+		{"Forecast.Solar", func(c *Config) {
+			c.Yield.Enabled = true
+			c.Yield.Provider = "forecast.solar"
+			c.Yield.Planes = []Plane{{30, -90, 4.5}, {95, 200, 0}}
+		}, Problems{
+			{Error, "yield_forecast.planes[1].declination", "95 is out of range, must be between 0 and 90"},
+			{Error, "yield_forecast.planes[1].azimuth", "200 is out of range, must be between -180 and 180"},
+			{Error, "yield_forecast.planes[1].kwp", "0 must be positive"},
+		}},
+		{"Forecast.Solar without planes", func(c *Config) { c.Yield.Enabled = true; c.Yield.Provider = "forecast.solar" }, Problems{
+			{Error, "yield_forecast.planes", "must contain at least one plane for forecast.solar"},
+		}},
+		{"Calibration", func(c *Config) {
+			c.Yield.Calibration.Enabled = true
+			c.Yield.Calibration.History = -time.Hour
+			c.Schedule.Calibration = "03:61"
+		}, Problems{
+			{Error, "schedule.calibration", `"03:61" is neither a time, an interval nor a cron expression: Expected 5 or 6 fields, found 1: 03:61`},
+			{Error, "yield_forecast.calibration.history", "-1h0m0s must not be negative"},
+			{Error, "yield_forecast.calibration.enabled", `the calibration requires the "model" provider`},
+		}},
+		{"Compare", func(c *Config) {
+			c.Yield.Provider = "model"
+			c.Yield.Planes = []Plane{{30, 0, 5}}
+			c.Yield.Compare = []string{"model", "model/tuned", "solarprognose/magic", "pvgis", "forecast.solar"}
+			c.Summary.AccuracyReport = true
+		}, Problems{
+			{Warning, "summary.accuracy_report", "has no effect while the yield forecast is disabled"},
+			{Warning, "yield_forecast.compare[0]", `"model" is already the provider of the yield forecast`},
+			{Error, "yield_forecast.compare[1]", `"model/tuned" is unknown, did you mean "model"?`},
+			{Error, "yield_forecast.compare[2]", `"solarprognose/magic" requires the api_token and id of solarprognose.de`},
+			{Error, "yield_forecast.compare[2]", `"magic" is invalid, must be one of "", "mosmix", "own-v1", "clearsky"`},
+			{Error, "yield_forecast.compare[3]", `"pvgis" is invalid, must start with one of "solarprognose", "forecast.solar", "model"`},
+		}},
+		{"Model", func(c *Config) {
+			losses := 100.0
+			c.Yield.Enabled = true
+			c.Yield.Provider = "model"
+			c.Yield.Losses = &losses
+			c.Yield.InverterLimit = -1
+		}, Problems{
+			{Error, "yield_forecast.losses", "100 is out of range, must be between 0 and 100 percent"},
+			{Error, "yield_forecast.inverter_limit", "-1 must not be negative"},
+			{Error, "yield_forecast.planes", "must contain at least one plane for model"},
+			{Warning, "yield_forecast.provider", "the model forecasts a cloudless sky if the weather is disabled"},
+		}},
+		{"Tariff", func(c *Config) {
+			c.Tariff.Enabled = true
+			c.Tariff.Import.Price = -0.3
+			c.Tariff.Import.Periods = []PricePeriod{{From: "22:00", Until: "25:00", Price: 0.2}, {From: "00:00", Until: "24:00", Days: []string{"weekend"}, Price: -1}}
+			c.Tariff.Import.Markup = 0.1
+			c.Tariff.FeedIn = []FeedIn{{From: "2021-01-01", Until: "2020-12-31", Price: -0.08}, {From: "01.01.2021"}}
+			c.Tariff.FixedCosts = -1
+			c.Tariff.Installation.Cost = -1
+			c.Tariff.Installation.Date = "June 2020"
+			c.Schedule.Financials = "midnight"
+		}, Problems{
+			{Error, "schedule.financials", `"midnight" is neither a time, an interval nor a cron expression: Expected 5 or 6 fields, found 1: midnight`},
+			{Error, "tariff.import.price", "-0.3 must not be negative"},
+			{Error, "tariff.import.periods[0]", `"25:00" is not a time like 06:30`},
+			{Error, "tariff.import.periods[1]", `"weekend" is not a weekday like monday`},
+			{Error, "tariff.import.periods[1].price", "-1 must not be negative"},
+			{Warning, "tariff.import.markup", "is only added to dynamic prices"},
+			{Error, "tariff.feed_in[0].until", "2020-12-31 must be after 2021-01-01"},
+			{Error, "tariff.feed_in[0].price", "-0.08 must not be negative"},
+			{Error, "tariff.feed_in[1].from", `"01.01.2021" is not a date like 2020-06-01`},
+			{Error, "tariff.fixed_costs", "-1 must not be negative"},
+			{Error, "tariff.installation.cost", "-1 must not be negative"},
+			{Error, "tariff.installation.date", `"June 2020" is not a date like 2020-06-01`},
+		}},
+		{"Tariff without price", func(c *Config) { c.Tariff.Enabled = true }, Problems{
+			{Warning, "tariff.import.price", "is 0, the self consumption saves nothing"},
+		}},
+		{"Tariff dynamic", func(c *Config) {
+			c.Tariff.Enabled = true
+			c.Tariff.Import.Dynamic = true
+			c.Tariff.Import.Markup = 0.12
+		}, nil},
+		{"Tariff prices", func(c *Config) {
+			c.Tariff.Enabled = true
+			c.Tariff.Import.Price = 0.3
+			c.Tariff.Prices.Provider = price.ProviderENTSOE
+			c.Tariff.Prices.Home = "home"
+			c.Schedule.Prices = "hourly"
+		}, Problems{
+			{Error, "schedule.prices", `"hourly" is neither a time, an interval nor a cron expression: Expected 5 or 6 fields, found 1: hourly`},
+			{Warning, "tariff.prices.provider", "the market prices are only used by dynamic prices, set tariff.import.dynamic"},
+			{Error, "tariff.prices.api_token", "must be set for entsoe"},
+			{Error, "tariff.prices.area", "must be set to the bidding zone for entsoe, e.g. 10YAT-APG------L"},
+			{Warning, "tariff.prices.home", `is only used by the "tibber" provider`},
+		}},
+		{"Tariff aWATTar", func(c *Config) {
+			c.Tariff.Enabled = true
+			c.Tariff.Import.Dynamic = true
+			c.Tariff.Prices.Provider = price.ProviderAWATTar
+			c.Tariff.Prices.Country = "ch"
+		}, Problems{
+			{Error, "tariff.prices.country", `"ch" is not supported by aWATTar, must be at or de`},
+		}},
+		{"Tariff unknown prices", func(c *Config) {
+			c.Tariff.Import.Dynamic = true
+			c.Tariff.Prices.Provider = "epex"
+		}, Problems{
+			{Error, "tariff.prices.provider", `"epex" is invalid, must be one of "", "awattar", "tibber", "entsoe"`},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			config := getValidConfig()
+			tt.modify(&config)
+			ans := config.Validate()
+			if !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEmptyConfig(t *testing.T) {
+	config, err := ReadConfig("../config_empty.yaml")
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	want := Problems{
+		{Error, "latitude", "latitude and longitude are 0/0, set the coordinates of the solar power plant to compute sunrise and sunset"},
+		{Error, "inverter.ip", "must be set to the IP address of the inverter"},
+		{Error, "persistence.database_name", "must be set"},
+	}
+	if ans := config.Validate(); !reflect.DeepEqual(ans, want) {
+		t.Errorf("got %v, want %v", ans, want)
+	}
+}
+
+func TestProblems(t *testing.T) {
+	warnings := Problems{{Warning, "inverter.port", "81 is unusual"}}
+	errors := append(warnings, Problem{Error, "inverter.ip", "must be set"})
+
+	if warnings.HasErrors() {
+		t.Errorf("Warnings should not be errors")
+	}
+	if !errors.HasErrors() {
+		t.Errorf("Expected errors")
+	}
+	if want := "warning: inverter.port: 81 is unusual\nerror: inverter.ip: must be set"; errors.Error() != want {
+		t.Errorf("got %s, want %s", errors.Error(), want)
+	}
+}
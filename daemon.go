@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"solargo/config"
+	"solargo/energy"
+	"solargo/inverter"
+	"solargo/persistence"
+	"solargo/price"
+	"solargo/schedule"
+	"solargo/supervisor"
+	"solargo/weather"
+	"solargo/yield_forecast"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//configWatchInterval in which the config file is checked for changes
+const configWatchInterval = 10 * time.Second
+
+//shutdownTimeout for running jobs to return after SIGTERM or a reload
+const shutdownTimeout = 20 * time.Second
+
+//services used by the jobs, they are swapped as a whole on reload and only recreated if their config changed
+type services struct {
+	config   *config.Config
+	inverter inverter.GenericInverter
+	database persistence.GenericDatabase
+	weather  weather.GenericWeather
+	yield    yield_forecast.GenericYieldForecast
+	compared map[string]yield_forecast.GenericYieldForecast // Only tracked to compare the accuracy
+	prices   price.GenericPrice                             // Nil if the market prices are saved by another tool
+	energy   *energy.Accounting                             // Nil if the energy is not accounted
+	jobs     []job
+	sleep    *sleepState
+}
+
+//newServices of the config. The services of the old config are kept if their part of the config did not change,
+//so that their state survives a reload, e.g. the energy of the current hour or the next request of a forecast.
+func newServices(c *config.Config, old *services) *services {
+	changed := func(name string) bool {
+		return old == nil || !reflect.DeepEqual(sections(old.config)[name], sections(c)[name])
+	}
+
+	s := &services{config: c, jobs: jobs(c)}
+	if changed("inverter") {
+		s.inverter, s.sleep = c.GetInverter(), &sleepState{}
+	} else {
+		s.inverter, s.sleep = old.inverter, old.sleep
+	}
+	if changed("database") {
+		s.database = c.GetDatabase()
+	} else {
+		s.database = old.database
+	}
+	if changed("weather") {
+		s.weather = c.GetWeatherService()
+	} else {
+		s.weather = old.weather
+	}
+	if changed("yield") {
+		s.yield, s.compared = c.GetYieldForecastService(), c.GetComparedYieldForecasts()
+	} else {
+		s.yield, s.compared = old.yield, old.compared
+	}
+	if changed("prices") {
+		s.prices = c.GetPriceService()
+	} else {
+		s.prices = old.prices
+	}
+	if changed("energy") {
+		s.energy = c.GetEnergyAccounting()
+	} else {
+		s.energy = old.energy
+	}
+	return s
+}
+
+//sections of the config on which the services depend
+func sections(c *config.Config) map[string]interface{} {
+	site := []interface{}{c.Latitude, c.Longitude, c.Timezone}
+	return map[string]interface{}{
+		"inverter": []interface{}{c.Inverter, c.Timezone},
+		"database": []interface{}{c.Persistence, c.Timezone},
+		"weather":  []interface{}{c.Weather, site},
+		"yield":    []interface{}{c.Yield, c.Weather, site},
+		"prices":   []interface{}{c.Tariff.Prices, c.Timezone},
+		"energy":   []interface{}{c.Energy, c.Timezone},
+	}
+}
+
+//job of the daemon with its schedule
+type job struct {
+	name      string
+	spec      string
+	window    *schedule.Window // Only run within this window, nil runs always
+	outside   bool             // Run outside of the window instead
+	onStartup bool
+	run       func(ctx context.Context, s *services)
+}
+
+//jobs of the config, empty schedules use the defaults
+func jobs(config *config.Config) []job {
+	sc := config.Schedule
+	poll := window(sc.PollFrom, schedule.DefaultPollFrom, sc.PollUntil, schedule.DefaultPollUntil)
+	weather := window(sc.WeatherFrom, schedule.DefaultWeatherFrom, sc.WeatherUntil, schedule.DefaultWeatherUntil)
+	yield := window(sc.YieldForecastFrom, schedule.DefaultYieldForecastFrom, sc.YieldForecastUntil, schedule.DefaultYieldForecastUntil)
+
+	jobs := []job{
+		{"inverter", schedule.Or(sc.Poll, schedule.DefaultPoll), poll, false, true, readController},
+		{"weather", schedule.Or(sc.Weather, schedule.DefaultWeather), weather, false, true, updateWeather},
+		{"summary", schedule.Or(sc.Summary, schedule.DefaultSummary), nil, false, false, sendSummary},
+		{"yield_forecast", schedule.Or(sc.YieldForecast, schedule.DefaultYieldForecast), yield, false, true, updateYieldForecast},
+		{"calibration", schedule.Or(sc.Calibration, schedule.DefaultCalibration), nil, false, false, retrainCalibration},
+		{"accuracy", schedule.Or(sc.Accuracy, schedule.DefaultAccuracy), nil, false, false, scoreAccuracy},
+		{"accuracy_report", schedule.Or(sc.AccuracyReport, schedule.DefaultAccuracyReport), nil, false, false, sendAccuracyReport},
+		{"financials", schedule.Or(sc.Financials, schedule.DefaultFinancials), nil, false, false, trackFinancials},
+		{"prices", schedule.Or(sc.Prices, schedule.DefaultPrices), nil, false, true, updatePrices},
+		{"energy_report", schedule.Or(sc.EnergyReport, schedule.DefaultEnergyReport), nil, false, false, sendEnergyReport},
+	}
+
+	//The night poll shares the name, so that it never overlaps with the day poll
+	if night := schedule.Or(sc.NightPoll, schedule.DefaultNightPoll); night != schedule.Off {
+		jobs = append(jobs, job{"inverter", night, poll, true, true, readController})
+	}
+	return jobs
+}
+
+//window parses the window, an invalid window is reported by the validation and replaced by the default
+func window(from, defaultFrom, until, defaultUntil string) *schedule.Window {
+	w, err := schedule.ParseWindow(schedule.Or(from, defaultFrom), schedule.Or(until, defaultUntil))
+	if err != nil {
+		log.Error("Invalid window, using ", defaultFrom, " to ", defaultUntil, ": ", err)
+		w, _ = schedule.ParseWindow(defaultFrom, defaultUntil)
+	}
+	return &w
+}
+
+//active returns true if the job should run at the time
+func (j job) active(t time.Time, config *config.Config) bool {
+	if j.window == nil {
+		return true
+	}
+	return j.window.Contains(t, config.Latitude, config.Longitude, config.Location()) != j.outside
+}
+
+//daemon runs the jobs of the current config and replaces them if the config file changes
+type daemon struct {
+	path       string
+	mu         sync.Mutex
+	services   *services
+	supervisor *supervisor.Supervisor
+	modTime    time.Time
+	size       int64
+}
+
+func newDaemon(path string, config *config.Config) *daemon {
+	d := &daemon{path: path, services: newServices(config, nil)}
+	d.modTime, d.size = d.stat()
+	return d
+}
+
+//supervise creates the supervisor of the jobs for the services, the jobs never see another config
+func supervise(s *services) *supervisor.Supervisor {
+	sv := supervisor.New()
+	for _, j := range s.jobs {
+		j := j
+		sched, err := schedule.Parse(j.spec, s.config.Latitude, s.config.Longitude, s.config.Location())
+		if err != nil {
+			log.Error("Can not schedule job ", j.name, ": ", err)
+			continue
+		}
+		sv.Schedule(j.name, sched, func(ctx context.Context) {
+			if j.active(time.Now(), s.config) {
+				j.run(ctx, s)
+			}
+		})
+	}
+	return sv
+}
+
+//current services of the daemon
+func (d *daemon) current() *services {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.services
+}
+
+//start the jobs of the current config
+func (d *daemon) start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.supervisor = supervise(d.services)
+	d.supervisor.Start()
+}
+
+//runStartupJobs runs every startup job once, which is within its window
+func (d *daemon) runStartupJobs() {
+	d.mu.Lock()
+	sv, s := d.supervisor, d.services
+	d.mu.Unlock()
+
+	now := time.Now()
+	for _, j := range s.jobs {
+		if j.onStartup && j.active(now, s.config) {
+			sv.Run(j.name, func(ctx context.Context) { j.run(ctx, s) })
+		}
+	}
+}
+
+//goRun runs a job once in the background with the current services
+func (d *daemon) goRun(name string, job func(ctx context.Context, s *services)) {
+	d.mu.Lock()
+	sv, s := d.supervisor, d.services
+	d.mu.Unlock()
+	sv.Go(name, func(ctx context.Context) { job(ctx, s) })
+}
+
+//stop all jobs and flush the pending writes
+func (d *daemon) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.supervisor != nil {
+		shutdown(d.supervisor, d.services)
+		d.supervisor = nil
+	}
+}
+
+//shutdown waits for the running jobs and saves what is still pending
+func shutdown(sv *supervisor.Supervisor, s *services) {
+	if err := sv.Stop(shutdownTimeout); err != nil {
+		log.Error("Could not stop jobs: ", err)
+	}
+	if err := s.database.Flush(); err != nil {
+		log.Error("Could not save pending data: ", err)
+	}
+}
+
+//reload reads and validates the config file, the old config is kept if the new one is invalid
+func (d *daemon) reload() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.modTime, d.size = d.stat()
+
+	c, err := config.ReadConfig(d.path)
+	if err != nil {
+		return err
+	}
+	problems := c.Validate()
+	for _, p := range problems {
+		log.Warn("Config ", d.path, ": ", p)
+	}
+	if problems.HasErrors() {
+		return fmt.Errorf("Config %s is invalid, keeping the old config", d.path)
+	}
+
+	old := d.services.config
+	if !reflect.DeepEqual(c.Logging, old.Logging) || c.Debug != old.Debug {
+		if err := initializeLogger(c); err != nil {
+			return fmt.Errorf("%s, keeping the old config", err)
+		}
+	}
+
+	//Stop the old jobs before the new ones start, so that a job never runs twice at a time
+	if d.supervisor != nil {
+		shutdown(d.supervisor, d.services)
+	}
+	d.services = newServices(&c, d.services)
+	d.supervisor = supervise(d.services)
+	d.supervisor.Start()
+	log.Info("Reloaded config ", d.path)
+	return nil
+}
+
+//changed returns true if the config file was modified since the last reload
+func (d *daemon) changed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	modTime, size := d.stat()
+	return !modTime.Equal(d.modTime) || size != d.size
+}
+
+func (d *daemon) stat() (time.Time, int64) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+//watch reloads the config if the file changes or a signal is received, until done is closed
+func (d *daemon) watch(interval time.Duration, signals <-chan os.Signal, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case sig := <-signals:
+			log.Info("Received ", sig, ", reloading config ", d.path)
+		case <-ticker.C:
+			if !d.changed() {
+				continue
+			}
+			log.Info("Config ", d.path, " changed, reloading")
+		}
+
+		if err := d.reload(); err != nil {
+			log.Error("Could not reload config: ", err)
+		}
+	}
+}
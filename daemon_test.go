@@ -0,0 +1,236 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"solargo/config"
+	"solargo/price"
+	"solargo/schedule"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDaemonReload(t *testing.T) {
+	path := writeConfig(t, strings.Replace(validConfig, "INFLUX", "http://127.0.0.1:8086", 1))
+	c, err := loadConfig(path, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	d := newDaemon(path, &c)
+	d.start()
+	defer d.stop()
+	first := d.current()
+
+	if d.changed() {
+		t.Errorf("Config should not have changed")
+	}
+
+	//An invalid config is rejected and the old services stay active
+	if err := ioutil.WriteFile(path, []byte("inverter:\n  port: 1\n"), 0644); err != nil {
+		t.Fatalf("Could not write config: %s", err)
+	}
+	if err := d.reload(); err == nil || !strings.Contains(err.Error(), "keeping the old config") {
+		t.Errorf("Expected invalid config error, got %v", err)
+	}
+	if d.current() != first {
+		t.Errorf("Invalid config should not replace the services")
+	}
+
+	//A log file which can not be opened rejects the config
+	logToDir := strings.Replace(validConfig, "INFLUX", "http://127.0.0.1:8086", 1)
+	logToDir = strings.Replace(logToDir, "  enabled: false\n", "  enabled: true\n  file_name: \""+filepath.Dir(path)+"\"\n", 1)
+	if err := ioutil.WriteFile(path, []byte(logToDir), 0644); err != nil {
+		t.Fatalf("Could not write config: %s", err)
+	}
+	if err := d.reload(); err == nil || !strings.Contains(err.Error(), "keeping the old config") {
+		t.Errorf("Expected log file error, got %v", err)
+	}
+	if d.current() != first {
+		t.Errorf("A config with an invalid log file should not replace the services")
+	}
+
+	//A valid config replaces the services
+	changed := strings.Replace(validConfig, "INFLUX", "http://127.0.0.1:9999", 1)
+	changed = strings.Replace(changed, "192.168.1.2", "192.168.1.3", 1)
+	if err := ioutil.WriteFile(path, []byte(changed), 0644); err != nil {
+		t.Fatalf("Could not write config: %s", err)
+	}
+	if err := d.reload(); err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	s := d.current()
+	if s == first || s.config.Persistence.URL != "http://127.0.0.1:9999" || s.config.Inverter.IP.String() != "192.168.1.3" {
+		t.Errorf("Services were not replaced, got %+v", s.config)
+	}
+	if d.changed() {
+		t.Errorf("Reload should remember the state of the file")
+	}
+
+	//Services with an unchanged config keep their state
+	if s.inverter == first.inverter || s.sleep == first.sleep || s.database == first.database {
+		t.Errorf("The inverter and the database of a changed config should be recreated")
+	}
+	if s.weather != first.weather || s.yield != first.yield {
+		t.Errorf("Services of an unchanged config should be kept")
+	}
+	s.sleep.set(true)
+	if err := ioutil.WriteFile(path, []byte(strings.Replace(changed, "solar", "other", 1)), 0644); err != nil {
+		t.Fatalf("Could not write config: %s", err)
+	}
+	if err := d.reload(); err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if next := d.current(); next.inverter != s.inverter || !next.sleep.get() || next.database == s.database {
+		t.Errorf("Only the database should be recreated, got %+v", next)
+	}
+}
+
+func TestNewServicesTimezone(t *testing.T) {
+	var c config.Config
+	c.Timezone = "Europe/Vienna"
+	c.Tariff.Prices.Provider = price.ProviderAWATTar
+	first := newServices(&c, nil)
+
+	//The days of the prices start at the midnight of the site
+	next := c
+	next.Timezone = "UTC"
+	s := newServices(&next, first)
+	if a, ok := s.prices.(*price.AWATTar); !ok || s.prices == first.prices || a.Location != time.UTC {
+		t.Errorf("The prices should be requested in the new timezone, got %+v", s.prices)
+	}
+}
+
+func TestDaemonWatch(t *testing.T) {
+	path := writeConfig(t, strings.Replace(validConfig, "INFLUX", "http://127.0.0.1:8086", 1))
+	c, err := loadConfig(path, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	d := newDaemon(path, &c)
+	d.start()
+	defer d.stop()
+
+	signals := make(chan os.Signal)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		d.watch(time.Millisecond, signals, done)
+		close(stopped)
+	}()
+
+	waitFor := func(want string) {
+		deadline := time.Now().Add(2 * time.Second)
+		for d.current().config.Persistence.DatabaseName != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Config was not reloaded, want database %s", want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	//Changes of the file are detected
+	changed := strings.Replace(validConfig, "INFLUX", "http://127.0.0.1:8086", 1)
+	if err := ioutil.WriteFile(path, []byte(strings.Replace(changed, "solar", "file", 1)+"debug: false\n"), 0644); err != nil {
+		t.Fatalf("Could not write config: %s", err)
+	}
+	waitFor("file")
+
+	//SIGHUP reloads, even if the file looks unchanged
+	os.Setenv("SOLARGO_PERSISTENCE_DATABASE_NAME", "signal")
+	defer os.Unsetenv("SOLARGO_PERSISTENCE_DATABASE_NAME")
+	signals <- syscall.SIGHUP
+	waitFor("signal")
+
+	close(done)
+	<-stopped
+}
+
+func TestDaemonStopCancelsJobs(t *testing.T) {
+	requested := make(chan struct{}, 10)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	path := writeConfig(t, strings.Replace(validConfig, "INFLUX", "http://127.0.0.1:8086", 1))
+	c, err := loadConfig(path, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Could not parse httptest URL")
+	}
+	port, _ := strconv.Atoi(u.Port())
+	c.Inverter.IP = net.ParseIP(u.Hostname())
+	c.Inverter.Port = uint16(port)
+
+	d := newDaemon(path, &c)
+	d.start()
+	d.goRun("inverter", readController)
+	select {
+	case <-requested:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("The inverter was not polled")
+	}
+
+	//The blocked poll is cancelled instead of waiting for the shutdown timeout
+	start := time.Now()
+	d.stop()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Stopping took %s while a poll was blocked", elapsed)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	var c config.Config
+	c.Latitude, c.Longitude = 48.2, 16.37
+	noon := time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC)
+	midnight := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+
+	active := func(jobs []job, t time.Time) []string {
+		var names []string
+		for _, j := range jobs {
+			if j.active(t, &c) {
+				names = append(names, j.name+" "+j.spec)
+			}
+		}
+		return names
+	}
+
+	defaults := jobs(&c)
+	if ans, want := active(defaults, noon), []string{"inverter 30s", "weather 15,45 * * * *", "summary sunset-30m", "yield_forecast 30m", "calibration 03:00", "accuracy 00:30", "accuracy_report 0 19 * * 0", "financials 00:15", "prices 5 * * * *", "energy_report 0 8 1 * *"}; !reflect.DeepEqual(ans, want) {
+		t.Errorf("got %v, want %v", ans, want)
+	}
+	if ans, want := active(defaults, midnight), []string{"summary sunset-30m", "calibration 03:00", "accuracy 00:30", "accuracy_report 0 19 * * 0", "financials 00:15", "prices 5 * * * *", "energy_report 0 8 1 * *", "inverter 5m"}; !reflect.DeepEqual(ans, want) {
+		t.Errorf("got %v, want %v", ans, want)
+	}
+
+	c.Schedule.Poll = "10s"
+	c.Schedule.NightPoll = schedule.Off
+	c.Schedule.WeatherFrom = "dusk"
+	night := jobs(&c)
+	if ans, want := active(night, midnight), []string{"summary sunset-30m", "calibration 03:00", "accuracy 00:30", "accuracy_report 0 19 * * 0", "financials 00:15", "prices 5 * * * *", "energy_report 0 8 1 * *"}; !reflect.DeepEqual(ans, want) {
+		t.Errorf("got %v, want %v", ans, want)
+	}
+	if ans, want := active(night, noon), []string{"inverter 10s", "weather 15,45 * * * *", "summary sunset-30m", "yield_forecast 30m", "calibration 03:00", "accuracy 00:30", "accuracy_report 0 19 * * 0", "financials 00:15", "prices 5 * * * *", "energy_report 0 8 1 * *"}; !reflect.DeepEqual(ans, want) {
+		t.Errorf("Invalid window should fall back to the default, got %v, want %v", ans, want)
+	}
+}
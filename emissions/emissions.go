@@ -0,0 +1,81 @@
+//Package emissions computes the CO2 emissions of the grid, which are avoided by the energy of the PV array
+package emissions
+
+import (
+	"fmt"
+	"solargo/energy"
+	"solargo/inverter"
+	"time"
+)
+
+//TreeKgPerYear is the CO2 in kg which a tree binds in a year
+const TreeKgPerYear = 12.5
+
+//CarGramsPerKm is the CO2 in g which an average car emits per km
+const CarGramsPerKm = 150.0
+
+//Intensity of the grid emissions of the hour starting at Start in g CO2 per kWh
+type Intensity struct {
+	Start  time.Time
+	Factor float64
+}
+
+//Factor of the grid emissions in g CO2 per kWh, hours without a loaded intensity use the static factor
+type Factor struct {
+	Static float64
+	Hourly bool // Load the intensity of every hour from the database
+	hours  map[time.Time]float64
+}
+
+//Load the intensities, which replace the loaded intensities of the same hours
+func (f *Factor) Load(intensities []Intensity) {
+	if f.hours == nil {
+		f.hours = map[time.Time]float64{}
+	}
+	for _, i := range intensities {
+		f.hours[i.Start.Truncate(time.Hour).UTC()] = i.Factor
+	}
+}
+
+//At returns the factor of the hour containing the time
+func (f *Factor) At(t time.Time) float64 {
+	if factor, ok := f.hours[t.Truncate(time.Hour).UTC()]; ok {
+		return factor
+	}
+	return f.Static
+}
+
+//Savings of the emissions of a period
+type Savings struct {
+	Start           time.Time
+	End             time.Time
+	Production      inverter.WattHour
+	SelfConsumption inverter.WattHour
+	Avoided         float64 // g CO2 of the production, which the grid would have emitted
+	AvoidedSelf     float64 // g CO2 of the self consumption, which was not purchased from the grid
+}
+
+//Add the energy of the hour at its factor
+func (s *Savings) Add(hour energy.Counters, f *Factor) {
+	factor := f.At(hour.Start)
+	s.Production += hour.Production
+	s.SelfConsumption += hour.SelfConsumption
+	s.Avoided += float64(hour.Production.ToKWh()) * factor
+	s.AvoidedSelf += float64(hour.SelfConsumption.ToKWh()) * factor
+}
+
+//Trees which bind the avoided CO2 in a year
+func (s Savings) Trees() float64 {
+	return s.Avoided / 1000 / TreeKgPerYear
+}
+
+//CarKm which emit the avoided CO2
+func (s Savings) CarKm() float64 {
+	return s.Avoided / CarGramsPerKm
+}
+
+//String of the avoided CO2 in kg and its equivalents
+func (s Savings) String() string {
+	return fmt.Sprintf("vermieden %.1f kg CO2, davon %.1f kg durch Eigenverbrauch, entspricht %.0f km Autofahrt oder %.1f Bäumen pro Jahr",
+		s.Avoided/1000, s.AvoidedSelf/1000, s.CarKm(), s.Trees())
+}
@@ -0,0 +1,57 @@
+package emissions
+
+import (
+	"math"
+	"solargo/energy"
+	"testing"
+	"time"
+)
+
+func TestFactor(t *testing.T) {
+	noon := time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC)
+	f := Factor{Static: 400, Hourly: true}
+	f.Load([]Intensity{{Start: noon, Factor: 150}, {Start: noon.Add(time.Hour), Factor: 200}})
+	f.Load([]Intensity{{Start: noon.Add(time.Hour), Factor: 250}})
+
+	tests := []struct {
+		testName string
+		t        time.Time
+		want     float64
+	}{
+		{"Loaded hour", noon.Add(30 * time.Minute), 150},
+		{"Replaced hour", noon.Add(time.Hour).In(time.FixedZone("CEST", 2*3600)), 250},
+		{"Static fallback", noon.Add(-time.Minute), 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if ans := f.At(tt.t); ans != tt.want {
+				t.Errorf("got %g, want %g", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestSavings(t *testing.T) {
+	noon := time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC)
+	f := Factor{Static: 400}
+	f.Load([]Intensity{{Start: noon, Factor: 200}})
+
+	var s Savings
+	s.Add(energy.Counters{Start: noon, Production: 3000, SelfConsumption: 1000}, &f)
+	s.Add(energy.Counters{Start: noon.Add(time.Hour), Production: 2000, SelfConsumption: 2000}, &f)
+
+	if s.Production != 5000 || s.SelfConsumption != 3000 {
+		t.Errorf("got %g Wh production and %g Wh self consumption, want 5000 and 3000", s.Production, s.SelfConsumption)
+	}
+	if s.Avoided != 1400 || s.AvoidedSelf != 1000 {
+		t.Errorf("got %g g and %g g avoided, want 1400 and 1000", s.Avoided, s.AvoidedSelf)
+	}
+	if math.Abs(s.CarKm()-9.333) > 0.001 || math.Abs(s.Trees()-0.112) > 0.001 {
+		t.Errorf("got %g km and %g trees", s.CarKm(), s.Trees())
+	}
+	want := "vermieden 1.4 kg CO2, davon 1.0 kg durch Eigenverbrauch, entspricht 9 km Autofahrt oder 0.1 Bäumen pro Jahr"
+	if ans := s.String(); ans != want {
+		t.Errorf("got %q, want %q", ans, want)
+	}
+}
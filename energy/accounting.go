@@ -0,0 +1,141 @@
+package energy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+//DefaultFile in which the state of the accounting is saved
+const DefaultFile = "energy.json"
+
+//state of the accounting, which is continued after a restart
+type state struct {
+	Last *Sample   `json:"last,omitempty"` // Latest integrated sample
+	Hour *Counters `json:"hour,omitempty"` // Energy of the current hour so far
+	Day  *Counters `json:"day,omitempty"`  // Energy of the current day so far
+}
+
+//Accounting integrates the samples of the polls into the energy of the current hour and day. Its state is saved
+//after every sample, so that a restart continues the integration if the gap is not too large.
+type Accounting struct {
+	File     string
+	MaxGap   time.Duration
+	Location *time.Location // Of the days, the local time zone if nil
+	state    *state
+}
+
+//Add the sample and return the hours and days which are completed by it
+func (a *Accounting) Add(s Sample) ([]Counters, error) {
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	last := a.state.Last
+	if last != nil && !s.Date.After(last.Date) {
+		return nil, nil
+	}
+
+	var closed []Counters
+	if last != nil && s.Date.Sub(last.Date) <= a.maxGap() {
+		split(*last, s, func(from, to Sample) {
+			closed = append(closed, a.close(from.Date)...)
+			a.state.Hour.trapezoid(from, to)
+			a.state.Day.trapezoid(from, to)
+		})
+	}
+	closed = append(closed, a.close(s.Date)...)
+	a.state.Last = &s
+	return closed, a.save()
+}
+
+//close the hour and the day which end before the time, new ones are started for the time
+func (a *Accounting) close(t time.Time) []Counters {
+	var closed []Counters
+	if a.state.Hour != nil && !t.Before(a.state.Hour.End) {
+		closed = append(closed, *a.state.Hour)
+		a.state.Hour = nil
+	}
+	if a.state.Day != nil && !t.Before(a.state.Day.End) {
+		closed = append(closed, *a.state.Day)
+		a.state.Day = nil
+	}
+	if a.state.Hour == nil {
+		h := hour(t)
+		a.state.Hour = &h
+	}
+	if a.state.Day == nil {
+		d := day(t, a.location())
+		a.state.Day = &d
+	}
+	return closed
+}
+
+func (a *Accounting) maxGap() time.Duration {
+	if a.MaxGap <= 0 {
+		return DefaultMaxGap
+	}
+	return a.MaxGap
+}
+
+func (a *Accounting) location() *time.Location {
+	if a.Location == nil {
+		return time.Local
+	}
+	return a.Location
+}
+
+func (a *Accounting) file() string {
+	if a.File == "" {
+		return DefaultFile
+	}
+	return a.File
+}
+
+//load the state once, a missing file starts a new accounting
+func (a *Accounting) load() error {
+	if a.state != nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(a.file())
+	if os.IsNotExist(err) {
+		a.state = &state{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Can not read energy accounting %s. Error: %s", a.file(), err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("Can not read energy accounting %s. Error: %s", a.file(), err)
+	}
+	a.state = &s
+	return nil
+}
+
+//save the state, the file is replaced atomically
+func (a *Accounting) save() error {
+	data, err := json.MarshalIndent(a.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := a.file()
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Can not save energy accounting. Error: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Can not save energy accounting. Error: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Can not save energy accounting. Error: %s", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Can not save energy accounting. Error: %s", err)
+	}
+	return nil
+}
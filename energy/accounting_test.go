@@ -0,0 +1,71 @@
+package energy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAccounting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "energy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "energy.json")
+
+	vienna, _ := time.LoadLocation("Europe/Vienna")
+	midnight := time.Date(2020, time.June, 22, 0, 0, 0, 0, vienna)
+	sample := func(minutes int) Sample {
+		return Sample{Date: midnight.Add(time.Duration(minutes) * time.Minute), Production: 600, Consumption: 300, FeedIn: 300}
+	}
+
+	a := &Accounting{File: file, Location: vienna}
+	for _, minutes := range []int{-20, -10} {
+		if closed, err := a.Add(sample(minutes)); err != nil || len(closed) != 0 {
+			t.Fatalf("got %v %v", closed, err)
+		}
+	}
+	if h, d := a.state.Hour, a.state.Day; !near(h.Production, 100) || !near(d.Production, 100) || !d.Start.Equal(midnight.AddDate(0, 0, -1)) {
+		t.Errorf("got %+v %+v", h, d)
+	}
+
+	//Midnight completes the last hour and the day
+	closed, err := a.Add(sample(0))
+	if err != nil || len(closed) != 2 {
+		t.Fatalf("got %v %v", closed, err)
+	}
+	if h := closed[0]; h.Period != PeriodHour || !h.End.Equal(midnight) || !near(h.Production, 200) || !near(h.SelfConsumption, 100) || h.Covered != 20*time.Minute {
+		t.Errorf("got hour %+v", h)
+	}
+	if d := closed[1]; d.Period != PeriodDay || !d.Start.Equal(midnight.AddDate(0, 0, -1)) || !near(d.FeedIn, 100) || d.Complete() {
+		t.Errorf("got day %+v", d)
+	}
+
+	//A restart continues with the saved state
+	a = &Accounting{File: file, Location: vienna}
+	if closed, err := a.Add(sample(10)); err != nil || len(closed) != 0 {
+		t.Fatalf("got %v %v", closed, err)
+	}
+	if h := a.state.Hour; !h.Start.Equal(midnight) || !near(h.Production, 100) {
+		t.Errorf("The integration should continue after a restart, got %+v", h)
+	}
+
+	//Older samples are ignored and the energy of a large gap is unknown
+	if closed, err := a.Add(sample(5)); err != nil || len(closed) != 0 {
+		t.Errorf("got %v %v", closed, err)
+	}
+	closed, err = a.Add(sample(70))
+	if err != nil || len(closed) != 1 || !near(closed[0].Production, 100) || closed[0].Covered != 10*time.Minute {
+		t.Errorf("got %+v %v", closed, err)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Accounting{File: file}).Add(sample(80)); err == nil {
+		t.Errorf("A broken state should produce an Error")
+	}
+}
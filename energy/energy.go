@@ -0,0 +1,122 @@
+//Package energy integrates the power samples of the site into the energy of every hour and day
+package energy
+
+import (
+	"solargo/inverter"
+	"time"
+)
+
+//DefaultMaxGap between two samples, which are still integrated
+const DefaultMaxGap = 15 * time.Minute
+
+//Periods of the counters, which are the tag of the persisted energy
+const (
+	PeriodHour = "hour"
+	PeriodDay  = "day"
+)
+
+//Sample of the power flow of the site at a time, all values are positive
+type Sample struct {
+	Date        time.Time
+	Production  inverter.Watt // Of the PV array
+	Consumption inverter.Watt // Used by the household
+	FeedIn      inverter.Watt // Fed into the grid
+	Import      inverter.Watt // Purchased from the grid
+}
+
+//SampleOf the power flow and the meter of the inverter data
+func SampleOf(data inverter.Data) Sample {
+	production := data.Sums.SumPowerPv
+	if production < 0 {
+		production = 0
+	}
+	return Sample{
+		Date:        data.Info.Date,
+		Production:  production,
+		Consumption: data.Meter.Used,
+		FeedIn:      data.Meter.Feed,
+		Import:      data.Meter.Purchased,
+	}
+}
+
+//selfConsumption is the consumption, which is not purchased
+func (s Sample) selfConsumption() inverter.Watt {
+	if self := s.Consumption - s.Import; self > 0 {
+		return self
+	}
+	return 0
+}
+
+//at interpolates the sample linearly between s and next at the time
+func (s Sample) at(next Sample, t time.Time) Sample {
+	total := next.Date.Sub(s.Date)
+	if total <= 0 {
+		return s
+	}
+	f := inverter.Watt(float64(t.Sub(s.Date)) / float64(total))
+	return Sample{
+		Date:        t,
+		Production:  s.Production + (next.Production-s.Production)*f,
+		Consumption: s.Consumption + (next.Consumption-s.Consumption)*f,
+		FeedIn:      s.FeedIn + (next.FeedIn-s.FeedIn)*f,
+		Import:      s.Import + (next.Import-s.Import)*f,
+	}
+}
+
+//Counters of the energy of a period
+type Counters struct {
+	Period          string // PeriodHour or PeriodDay
+	Start           time.Time
+	End             time.Time
+	Production      inverter.WattHour
+	Consumption     inverter.WattHour
+	SelfConsumption inverter.WattHour // Consumption which was not purchased
+	FeedIn          inverter.WattHour
+	Import          inverter.WattHour
+	Covered         time.Duration // Integrated time of the period, less than its length if samples are missing
+}
+
+//trapezoid adds the energy between the samples to the counters
+func (c *Counters) trapezoid(a, b Sample) {
+	d := b.Date.Sub(a.Date)
+	mean := func(x, y inverter.Watt) inverter.WattHour { return ((x + y) / 2).Over(d) }
+	c.Production += mean(a.Production, b.Production)
+	c.Consumption += mean(a.Consumption, b.Consumption)
+	c.SelfConsumption += mean(a.selfConsumption(), b.selfConsumption())
+	c.FeedIn += mean(a.FeedIn, b.FeedIn)
+	c.Import += mean(a.Import, b.Import)
+	c.Covered += d
+}
+
+//Complete returns true if the whole period is integrated
+func (c Counters) Complete() bool {
+	return c.Covered >= c.End.Sub(c.Start)
+}
+
+//hour starting at the full hour of the time
+func hour(t time.Time) Counters {
+	start := t.Truncate(time.Hour)
+	return Counters{Period: PeriodHour, Start: start, End: start.Add(time.Hour)}
+}
+
+//day starting at the midnight of the time in the location
+func day(t time.Time, loc *time.Location) Counters {
+	local := t.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return Counters{Period: PeriodDay, Start: start, End: start.AddDate(0, 0, 1)}
+}
+
+//split the segment between the samples at the full hours, the interpolated pieces are passed to add.
+//Hours start at full hours in every time zone with a full hour offset, which the days of the location rely on.
+func split(a, b Sample, add func(a, b Sample)) {
+	for a.Date.Before(b.Date) {
+		end := a.Date.Truncate(time.Hour).Add(time.Hour)
+		if !end.Before(b.Date) {
+			add(a, b)
+			return
+		}
+		middle := a.at(b, end)
+		add(a, middle)
+		a = middle
+	}
+}
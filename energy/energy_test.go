@@ -0,0 +1,57 @@
+package energy
+
+import (
+	"math"
+	"solargo/inverter"
+	"testing"
+	"time"
+)
+
+func near(a, b inverter.WattHour) bool {
+	return math.Abs(float64(a-b)) < 1e-6
+}
+
+func TestTrapezoid(t *testing.T) {
+	start := time.Date(2020, time.June, 21, 10, 50, 0, 0, time.UTC)
+	samples := []Sample{
+		{Date: start, Production: 1000, Consumption: 500, Import: 500},
+		{Date: start.Add(20 * time.Minute), Production: 2000, Consumption: 500, FeedIn: 1500},
+		{Date: start.Add(25 * time.Minute), Production: 3000, Consumption: 500, FeedIn: 2500},
+	}
+
+	hours := []Counters{hour(start), hour(start.Add(time.Hour))}
+	for i := 1; i < len(samples); i++ {
+		split(samples[i-1], samples[i], func(a, b Sample) {
+			hours[a.Date.Hour()-start.Hour()].trapezoid(a, b)
+		})
+	}
+
+	//The segment is split at 11:00, where the production is interpolated to 1500 W
+	first, second := hours[0], hours[1]
+	if !first.Start.Equal(time.Date(2020, time.June, 21, 10, 0, 0, 0, time.UTC)) || first.Covered != 10*time.Minute ||
+		!near(first.Production, 2500.0/2/6) || !near(first.Consumption, 500.0/6) || !near(first.Import, 750.0/2/6) ||
+		!near(first.FeedIn, 750.0/2/6) || !near(first.SelfConsumption, 250.0/2/6) {
+		t.Errorf("got %+v", first)
+	}
+	if !second.Start.Equal(first.End) || second.Covered != 15*time.Minute || second.Complete() ||
+		!near(second.Production, 3500.0/2/6+2500.0/12) || !near(second.SelfConsumption, 750.0/2/6+500.0/12) {
+		t.Errorf("got %+v", second)
+	}
+}
+
+func TestSampleOf(t *testing.T) {
+	var data inverter.Data
+	data.Info.Date = time.Date(2020, time.June, 21, 10, 0, 0, 0, time.UTC)
+	data.Sums.SumPowerPv = -1
+	data.Meter.Used = 400
+	data.Meter.Purchased = 300
+	data.Meter.Feed = 0
+
+	want := Sample{Date: data.Info.Date, Consumption: 400, Import: 300}
+	if s := SampleOf(data); s != want {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+	if self := want.selfConsumption(); self != 100 {
+		t.Errorf("got self consumption %g", self)
+	}
+}
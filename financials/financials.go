@@ -0,0 +1,109 @@
+//Package financials tracks the savings, the feed-in revenue and the payback of the installation
+package financials
+
+import (
+	"fmt"
+	"solargo/energy"
+	"solargo/inverter"
+	"solargo/persistence"
+	"solargo/tariff"
+	"time"
+)
+
+//ComputeDay from the energy of the hours of the day, the market prices of a dynamic tariff are read from the database.
+//With energy accounting the counters of the hours are used, otherwise the energy is averaged from the meter values.
+func ComputeDay(database persistence.GenericDatabase, t *tariff.Tariff, day time.Time, accounted bool) (tariff.Day, error) {
+	from, to := bounds(t, day)
+	if err := LoadPrices(database, t, from, to); err != nil {
+		return tariff.Day{}, err
+	}
+
+	if accounted {
+		hours, err := database.GetEnergy(energy.PeriodHour, from, to)
+		if err != nil {
+			return tariff.Day{}, fmt.Errorf("Could not read the energy of the hours: %s", err)
+		}
+		purchased := map[time.Time]inverter.WattHour{}
+		fedIn := map[time.Time]inverter.WattHour{}
+		used := map[time.Time]inverter.WattHour{}
+		for _, h := range hours {
+			purchased[h.Start] = h.Import
+			fedIn[h.Start] = h.FeedIn
+			used[h.Start] = h.Consumption
+		}
+		return t.Compute(from, purchased, fedIn, used), nil
+	}
+
+	meter, err := database.GetMeter(from, to)
+	if err != nil {
+		return tariff.Day{}, fmt.Errorf("Could not read persisted meter values: %s", err)
+	}
+	return t.Compute(from, persistence.Hourly(meter.Purchase), persistence.Hourly(meter.Feed), persistence.Hourly(meter.Usage)), nil
+}
+
+//LoadPrices between from and to into a dynamic tariff, other tariffs are left as they are
+func LoadPrices(database persistence.GenericDatabase, t *tariff.Tariff, from, to time.Time) error {
+	dynamic, ok := t.Import.(*tariff.Dynamic)
+	if !ok {
+		return nil
+	}
+	prices, err := database.GetPrices(from, to)
+	if err != nil {
+		return fmt.Errorf("Could not read the market prices: %s", err)
+	}
+	dynamic.Load(prices)
+	return nil
+}
+
+//TrackDay computes the financials of the day and saves them
+func TrackDay(database persistence.GenericDatabase, t *tariff.Tariff, day time.Time, accounted bool) (tariff.Day, error) {
+	d, err := ComputeDay(database, t, day, accounted)
+	if err != nil {
+		return d, err
+	}
+	database.SendFinancials(d)
+	return d, nil
+}
+
+//Payback of the installation by the saved days since its installation until the day and the day itself
+func Payback(database persistence.GenericDatabase, t *tariff.Tariff, day tariff.Day) (float64, error) {
+	from := t.InstallationDate
+	if from.IsZero() {
+		from = time.Unix(0, 0)
+	}
+	days, err := database.GetFinancials(from, day.Date)
+	if err != nil {
+		return 0, fmt.Errorf("Could not read persisted financials: %s", err)
+	}
+	return t.Payback(append(days, day)), nil
+}
+
+//Summary of the financials of the day so far and the payback of the installation
+func Summary(database persistence.GenericDatabase, t *tariff.Tariff, day time.Time, accounted bool) (string, error) {
+	d, err := ComputeDay(database, t, day, accounted)
+	if err != nil {
+		return "", err
+	}
+	message := fmt.Sprintf("Finanzen heute:\nErsparnis: %s\nEinspeisevergütung: %s\nNetzbezug: %s",
+		t.Format(d.Savings), t.Format(d.FeedInRevenue), t.Format(d.ImportCost))
+
+	if t.InstallationCost > 0 {
+		payback, err := Payback(database, t, d)
+		if err != nil {
+			return "", err
+		}
+		message += fmt.Sprintf("\nAmortisation: %.1f %%", payback*100)
+	}
+	return message, nil
+}
+
+//bounds of the day in the location of the tariff
+func bounds(t *tariff.Tariff, day time.Time) (time.Time, time.Time) {
+	loc := t.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	local := day.In(loc)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return from, from.AddDate(0, 0, 1)
+}
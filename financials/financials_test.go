@@ -0,0 +1,150 @@
+package financials
+
+import (
+	"math"
+	"solargo/energy"
+	"solargo/inverter"
+	"solargo/persistence"
+	"solargo/tariff"
+	"solargo/testutils"
+	"testing"
+	"time"
+)
+
+//database with the meter values of a day, the market prices and the saved days
+type database struct {
+	testutils.SuccessDatabase
+	meter  persistence.MeterFlow
+	hours  []energy.Counters
+	prices []tariff.Price
+	saved  []tariff.Day
+}
+
+func (db *database) GetMeter(from, to time.Time) (persistence.MeterFlow, error) {
+	return db.meter, nil
+}
+
+func (db *database) GetEnergy(period string, from, to time.Time) ([]energy.Counters, error) {
+	return db.hours, nil
+}
+
+func (db *database) GetPrices(from, to time.Time) ([]tariff.Price, error) {
+	return db.prices, nil
+}
+
+func (db *database) SendFinancials(day tariff.Day) {
+	db.saved = append(db.saved, day)
+}
+
+func (db *database) GetFinancials(from, to time.Time) ([]tariff.Day, error) {
+	var days []tariff.Day
+	for _, d := range db.saved {
+		if !d.Date.Before(from) && d.Date.Before(to) {
+			days = append(days, d)
+		}
+	}
+	return days, nil
+}
+
+//constant power of the meter during the hour
+func constant(start time.Time, value float64) []persistence.ProductionStamps {
+	var stamps []persistence.ProductionStamps
+	for t := start; t.Before(start.Add(time.Hour)); t = t.Add(5 * time.Minute) {
+		stamps = append(stamps, persistence.ProductionStamps{Date: t, Value: inverter.Watt(value)})
+	}
+	return stamps
+}
+
+func TestTrackDay(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	db := &database{
+		meter: persistence.MeterFlow{
+			Purchase: constant(day.Add(6*time.Hour), 500),
+			Feed:     constant(day.Add(12*time.Hour), 2000),
+			Usage:    append(constant(day.Add(6*time.Hour), 500), constant(day.Add(12*time.Hour), 1000)...),
+		},
+		prices: []tariff.Price{{Start: day.Add(6 * time.Hour), Price: 0.10}},
+	}
+	tr := &tariff.Tariff{
+		Import:   &tariff.Dynamic{Markup: 0.10, Fallback: tariff.Flat(0.30)},
+		FeedIn:   []tariff.FeedIn{{Price: 0.05}},
+		Location: time.UTC,
+	}
+
+	d, err := TrackDay(db, tr, day.Add(20*time.Hour), false)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if !d.Date.Equal(day) || d.Purchased != 500 || d.FedIn != 2000 || d.SelfConsumed != 1000 {
+		t.Errorf("got %+v", d)
+	}
+	//The purchase is priced with the market price, the self consumption at noon with the fallback
+	if math.Abs(d.ImportCost-0.5*0.20) > 1e-9 || math.Abs(d.FeedInRevenue-2*0.05) > 1e-9 || math.Abs(d.Savings-0.30) > 1e-9 {
+		t.Errorf("got %+v", d)
+	}
+	if len(db.saved) != 1 {
+		t.Errorf("The day should be saved, got %v", db.saved)
+	}
+}
+
+func TestComputeDayAccounted(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	morning, noon := day.Add(6*time.Hour), day.Add(12*time.Hour)
+	db := &database{
+		//The meter values are ignored with energy accounting
+		meter: persistence.MeterFlow{Purchase: constant(morning, 9000)},
+		hours: []energy.Counters{
+			{Period: energy.PeriodHour, Start: morning, End: morning.Add(time.Hour), Consumption: 500, Import: 500},
+			{Period: energy.PeriodHour, Start: noon, End: noon.Add(time.Hour), Production: 3000, Consumption: 1000, SelfConsumption: 1000, FeedIn: 2000},
+		},
+	}
+	tr := &tariff.Tariff{Import: tariff.Flat(0.30), FeedIn: []tariff.FeedIn{{Price: 0.05}}, Location: time.UTC}
+
+	d, err := ComputeDay(db, tr, day.Add(20*time.Hour), true)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if d.Purchased != 500 || d.FedIn != 2000 || d.SelfConsumed != 1000 {
+		t.Errorf("got %+v", d)
+	}
+	if math.Abs(d.ImportCost-0.15) > 1e-9 || math.Abs(d.FeedInRevenue-0.10) > 1e-9 || math.Abs(d.Savings-0.30) > 1e-9 {
+		t.Errorf("got %+v", d)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	db := &database{
+		meter: persistence.MeterFlow{
+			Feed:  constant(day.Add(12*time.Hour), 4000),
+			Usage: constant(day.Add(12*time.Hour), 1000),
+		},
+		saved: []tariff.Day{
+			{Date: day.AddDate(0, 0, -400), Savings: 1000},
+			{Date: day.AddDate(0, 0, -1), Savings: 998, FeedInRevenue: 1.7},
+		},
+	}
+	tr := &tariff.Tariff{
+		Currency:         "EUR",
+		Import:           tariff.Flat(0.30),
+		FeedIn:           []tariff.FeedIn{{Price: 0.05}},
+		InstallationCost: 10000,
+		InstallationDate: day.AddDate(0, 0, -100),
+		Location:         time.UTC,
+	}
+
+	message, err := Summary(db, tr, day.Add(18*time.Hour), false)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	//Only the days since the installation are paid back: 998 + 1.7 + 0.3 + 0.2 EUR
+	want := "Finanzen heute:\nErsparnis: 0.30 EUR\nEinspeisevergütung: 0.20 EUR\nNetzbezug: 0.00 EUR\nAmortisation: 10.0 %"
+	if message != want {
+		t.Errorf("got %q, want %q", message, want)
+	}
+
+	tr.InstallationCost = 0
+	if message, _ := Summary(db, tr, day.Add(18*time.Hour), false); message != "Finanzen heute:\nErsparnis: 0.30 EUR\nEinspeisevergütung: 0.20 EUR\nNetzbezug: 0.00 EUR" {
+		t.Errorf("Without installation cost the payback should be skipped, got %q", message)
+	}
+}
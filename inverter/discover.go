@@ -0,0 +1,139 @@
+package inverter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+//maxDiscoveryHosts limits the size of a network which is scanned
+const maxDiscoveryHosts = 1024
+
+//Discovered Fronius Solar API
+type Discovered struct {
+	IP         net.IP
+	Port       uint16
+	APIVersion int
+	BaseURL    string
+}
+
+//DiscoverFronius scans the network for devices providing the Fronius Solar API
+func DiscoverFronius(network *net.IPNet, port uint16, timeout time.Duration) ([]Discovered, error) {
+	hosts, err := hostsOf(network)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: timeout}
+	results := make(chan Discovered)
+	var wg sync.WaitGroup
+
+	//Limit the number of parallel requests
+	limit := make(chan struct{}, 64)
+	for _, ip := range hosts {
+		wg.Add(1)
+		go func(ip net.IP) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			if d, ok := probeFronius(client, ip, port); ok {
+				results <- d
+			}
+		}(ip)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var found []Discovered
+	for d := range results {
+		found = append(found, d)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return binary.BigEndian.Uint32(found[i].IP.To4()) < binary.BigEndian.Uint32(found[j].IP.To4())
+	})
+	return found, nil
+}
+
+//probeFronius checks if the host answers like a Fronius datalogger
+func probeFronius(client *http.Client, ip net.IP, port uint16) (Discovered, bool) {
+	d := Discovered{IP: ip, Port: port}
+	httpResult, err := client.Get(fmt.Sprintf("http://%s:%d/solar_api/GetAPIVersion.cgi", ip, port))
+	if err != nil {
+		return d, false
+	}
+
+	defer httpResult.Body.Close()
+
+	type Result struct {
+		APIVersion  int
+		BaseURL     string
+		CompatRange string
+	}
+
+	var result Result
+	if err := json.NewDecoder(httpResult.Body).Decode(&result); err != nil || result.APIVersion == 0 {
+		return d, false
+	}
+
+	d.APIVersion = result.APIVersion
+	d.BaseURL = result.BaseURL
+	return d, true
+}
+
+//hostsOf returns all host addresses of an IPv4 network
+func hostsOf(network *net.IPNet) ([]net.IP, error) {
+	ip := network.IP.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("Only IPv4 networks can be scanned, got %s", network)
+	}
+
+	ones, bits := network.Mask.Size()
+	size := 1 << uint(bits-ones)
+	if size > maxDiscoveryHosts {
+		return nil, fmt.Errorf("Network %s is too large, at most %d addresses are scanned", network, maxDiscoveryHosts)
+	}
+
+	start := binary.BigEndian.Uint32(ip.Mask(network.Mask))
+	var hosts []net.IP
+	for i := 0; i < size; i++ {
+		//Skip the network and broadcast address of real subnets
+		if size > 2 && (i == 0 || i == size-1) {
+			continue
+		}
+		host := make(net.IP, 4)
+		binary.BigEndian.PutUint32(host, start+uint32(i))
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+//LocalNetworks returns the IPv4 networks of all active interfaces, shrunk to at most /24
+func LocalNetworks() ([]*net.IPNet, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []*net.IPNet
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		mask := ipNet.Mask
+		if ones, _ := mask.Size(); ones < 24 {
+			mask = net.CIDRMask(24, 32)
+		}
+		networks = append(networks, &net.IPNet{IP: ipNet.IP.Mask(mask), Mask: mask})
+	}
+	return networks, nil
+}
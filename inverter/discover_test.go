@@ -0,0 +1,85 @@
+package inverter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoverFronius(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"APIVersion":1,"BaseURL":"/solar_api/v1/","CompatibilityRange":"1.6-3"}`)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Could not parse httptest URL")
+	}
+	inverter := inverterFromURL(u)
+
+	_, network, _ := net.ParseCIDR("127.0.0.1/32")
+	found, err := DiscoverFronius(network, inverter.Port, time.Second)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	if len(found) != 1 || !found[0].IP.Equal(inverter.IP) || found[0].APIVersion != 1 || found[0].BaseURL != "/solar_api/v1/" {
+		t.Errorf("Error actual = %v", found)
+	}
+}
+
+func TestDiscoverFroniusNoDevice(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `<html>Some router</html>`)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Could not parse httptest URL")
+	}
+	inverter := inverterFromURL(u)
+
+	_, network, _ := net.ParseCIDR("127.0.0.0/30")
+	found, err := DiscoverFronius(network, inverter.Port, time.Second)
+	if err != nil || len(found) != 0 {
+		t.Errorf("Expected no devices, got %v and error %v", found, err)
+	}
+}
+
+func TestHostsOf(t *testing.T) {
+	var tests = []struct {
+		network string
+		count   int
+		first   string
+		errors  string
+	}{
+		{"192.168.1.0/24", 254, "192.168.1.1", ""},
+		{"192.168.1.17/30", 2, "192.168.1.17", ""},
+		{"10.0.0.5/32", 1, "10.0.0.5", ""},
+		{"10.0.0.0/8", 0, "", "Network 10.0.0.0/8 is too large"},
+		{"fe80::/120", 0, "", "Only IPv4 networks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			_, network, _ := net.ParseCIDR(tt.network)
+			hosts, err := hostsOf(network)
+			if tt.errors != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.errors) {
+					t.Errorf("error = %v, want Prefix %s", err, tt.errors)
+				}
+				return
+			}
+			if err != nil || len(hosts) != tt.count || hosts[0].String() != tt.first {
+				t.Errorf("got %d hosts starting with %v and error %v", len(hosts), hosts, err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@
 package inverter
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -20,14 +21,15 @@ type FroniusSymo struct {
 	IP       net.IP
 	Port     uint16
 	DeviceID string
+	Location *time.Location // Timezone of the daily statistics, defaults to the one of the system
 }
 
 //GetInverterStatistics of the Fronius inverter
-func (f *FroniusSymo) GetInverterStatistics() (DailyStatistics, error) {
+func (f *FroniusSymo) GetInverterStatistics(ctx context.Context) (DailyStatistics, error) {
 	var statistics DailyStatistics
 
 	uri := fmt.Sprintf("http://%s:%d/solar_api/v1/GetInverterRealtimeData.cgi?Scope=Device&DeviceID=%s&DataCollection=CumulationInverterData", f.IP.String(), f.Port, f.DeviceID)
-	httpResult, err := http.Get(uri)
+	httpResult, err := get(ctx, uri)
 
 	if err != nil {
 		return statistics, err
@@ -78,18 +80,41 @@ func (f *FroniusSymo) GetInverterStatistics() (DailyStatistics, error) {
 
 }
 
-//RetrieveData of the inverter
-func (f *FroniusSymo) RetrieveData() (Data, error) {
+//get the uri, the request is cancelled with the context
+func get(ctx context.Context, uri string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(request)
+}
+
+func (f *FroniusSymo) location() *time.Location {
+	if f.Location == nil {
+		return time.Local
+	}
+	return f.Location
+}
+
+//newData with the product information and the current date in the timezone of the inverter
+func (f *FroniusSymo) newData() Data {
+	now := time.Now().In(f.location())
+
 	var data Data
 	data.Info.Product = "Fronius Symo Series"
 	data.Info.Object = "SolarGo"
-	data.Info.Date = time.Now()
-	data.Statistics.Date = time.Now()
-	_, week := time.Now().ISOWeek()
+	data.Info.Date = now
+	data.Statistics.Date = now
+	_, week := now.ISOWeek()
 	data.Statistics.Week = week
-	_, month, _ := time.Now().Date()
-	data.Statistics.Month = int(month)
-	data.Statistics.WeekDay = time.Now().Weekday().String()
+	data.Statistics.Month = int(now.Month())
+	data.Statistics.WeekDay = now.Weekday().String()
+	return data
+}
+
+//RetrieveData of the inverter
+func (f *FroniusSymo) RetrieveData(ctx context.Context) (Data, error) {
+	data := f.newData()
 
 	var wg sync.WaitGroup
 	done := make(chan bool)
@@ -101,7 +126,7 @@ func (f *FroniusSymo) RetrieveData() (Data, error) {
 	go func() {
 		defer wg.Done()
 
-		if err := f.getAPIVersion(&data); err != nil {
+		if err := f.getAPIVersion(ctx, &data); err != nil {
 			log.Info("Could not retrieve GetApiVersion", err)
 			occuredErrors <- err
 		}
@@ -114,7 +139,7 @@ func (f *FroniusSymo) RetrieveData() (Data, error) {
 	go func() {
 		defer wg.Done()
 
-		if err := f.powerFlowRealtimeData(&data); err != nil {
+		if err := f.powerFlowRealtimeData(ctx, &data); err != nil {
 			log.Info("Could not retrieve PowerflowRealtimeData", err)
 			occuredErrors <- err
 		}
@@ -124,7 +149,7 @@ func (f *FroniusSymo) RetrieveData() (Data, error) {
 		defer wg.Done()
 
 		//It is ok to have an error here -> not everyone has the right meter
-		if err := f.meterRealtimeData(&data); err != nil {
+		if err := f.meterRealtimeData(ctx, &data); err != nil {
 			log.Info("Could not retrieve PowerflowRealtimeData", err)
 		}
 	}()
@@ -132,7 +157,7 @@ func (f *FroniusSymo) RetrieveData() (Data, error) {
 	go func() {
 		defer wg.Done()
 
-		if err := f.inverterRealtimeData(&data); err != nil {
+		if err := f.inverterRealtimeData(ctx, &data); err != nil {
 			log.Info("Could not retrieve InverterRealtimeData", err)
 			occuredErrors <- err
 		}
@@ -141,7 +166,7 @@ func (f *FroniusSymo) RetrieveData() (Data, error) {
 	go func() {
 		defer wg.Done()
 
-		if err := f.inverterInfo(&data); err != nil {
+		if err := f.inverterInfo(ctx, &data); err != nil {
 			log.Info("Could not retrieve InverterRealtimeData", err)
 			occuredErrors <- err
 		}
@@ -150,7 +175,7 @@ func (f *FroniusSymo) RetrieveData() (Data, error) {
 	go func() {
 		defer wg.Done()
 
-		if err := f.inverterCommonData(&data); err != nil {
+		if err := f.inverterCommonData(ctx, &data); err != nil {
 			log.Info("Could not retrieve common inverter data", err)
 			occuredErrors <- err
 		}
@@ -159,7 +184,7 @@ func (f *FroniusSymo) RetrieveData() (Data, error) {
 	go func() {
 		defer wg.Done()
 
-		if err := f.archiveData(&data); err != nil {
+		if err := f.archiveData(ctx, &data); err != nil {
 			log.Info("Could not retrieve archive data", err)
 			occuredErrors <- err
 		}
@@ -184,12 +209,35 @@ func (f *FroniusSymo) RetrieveData() (Data, error) {
 	return data, nil
 }
 
-func (f *FroniusSymo) powerFlowRealtimeData(data *Data) error {
+//RetrieveSiteData reads the power flow and meter of the site, they are available while the inverter sleeps
+func (f *FroniusSymo) RetrieveSiteData(ctx context.Context) (Data, bool, error) {
+	data := f.newData()
+
+	asleep, err := f.powerFlow(ctx, &data)
+	if err != nil {
+		return data, false, fmt.Errorf("Fronius Symo Error: %s", err)
+	}
+
+	//It is ok to have an error here -> not everyone has the right meter
+	if err := f.meterRealtimeData(ctx, &data); err != nil {
+		log.Info("Could not retrieve MeterRealtimeData", err)
+	}
+
+	return data, asleep, nil
+}
+
+func (f *FroniusSymo) powerFlowRealtimeData(ctx context.Context, data *Data) error {
+	_, err := f.powerFlow(ctx, data)
+	return err
+}
+
+//powerFlow reads the power flow of the site and returns true if the inverter sleeps
+func (f *FroniusSymo) powerFlow(ctx context.Context, data *Data) (bool, error) {
 	uri := fmt.Sprintf("http://%s:%d/solar_api/v1/GetPowerFlowRealtimeData.fcgi", f.IP.String(), f.Port)
-	httpResult, err := http.Get(uri)
+	httpResult, err := get(ctx, uri)
 
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	defer httpResult.Body.Close()
@@ -210,6 +258,8 @@ func (f *FroniusSymo) powerFlowRealtimeData(data *Data) error {
 					Autonomy        float64 `json:"rel_Autonomy"`
 					SelfConsumption float64 `json:"rel_SelfConsumption"`
 				}
+				//Sleeping inverters are missing here
+				Inverters map[string]json.RawMessage
 			}
 		}
 		Head struct {
@@ -223,16 +273,16 @@ func (f *FroniusSymo) powerFlowRealtimeData(data *Data) error {
 	var result Result
 	err = json.NewDecoder(httpResult.Body).Decode(&result)
 	if err != nil || result.Head.Status.Code != 0 {
-		return fmt.Errorf("Error: %s, Inverter Reason: %s", err, result.Head.Status.Reason)
+		return false, fmt.Errorf("Error: %s, Inverter Reason: %s", err, result.Head.Status.Reason)
 	}
 
 	data.Sums.SumProdToday = WattHour(result.Body.Data.Site.Day)
 	data.Sums.SumProdTotal = WattHour(result.Body.Data.Site.Total)
 	data.Sums.SumProdYear = WattHour(result.Body.Data.Site.Year)
-	data.Sums.SumPowerGrid = WattHour(result.Body.Data.Site.Grid)
-	data.Sums.SumPowerLoad = WattHour(result.Body.Data.Site.Load)
-	data.Sums.SumPowerBattery = WattHour(result.Body.Data.Site.Battery)
-	data.Sums.SumPowerPv = WattHour(result.Body.Data.Site.PV)
+	data.Sums.SumPowerGrid = Watt(result.Body.Data.Site.Grid)
+	data.Sums.SumPowerLoad = Watt(result.Body.Data.Site.Load)
+	data.Sums.SumPowerBattery = Watt(result.Body.Data.Site.Battery)
+	data.Sums.SumPowerPv = Watt(result.Body.Data.Site.PV)
 
 	data.Service.MeterLocation = result.Body.Data.Site.Location
 	data.Service.Mode = result.Body.Data.Site.Mode
@@ -241,21 +291,23 @@ func (f *FroniusSymo) powerFlowRealtimeData(data *Data) error {
 	data.Service.SelfConsumption = result.Body.Data.Site.SelfConsumption
 
 	if data.Sums.SumPowerGrid < 0.0 {
-		data.Meter.Feed = WattHour(math.Abs(float64(data.Sums.SumPowerGrid)))
+		data.Meter.Feed = Watt(math.Abs(float64(data.Sums.SumPowerGrid)))
 		data.Meter.Purchased = 0.0
-		data.Meter.Used = WattHour(math.Abs(float64(data.Sums.SumPowerLoad)))
+		data.Meter.Used = Watt(math.Abs(float64(data.Sums.SumPowerLoad)))
 	} else {
 		data.Meter.Feed = 0.0
 		data.Meter.Purchased = data.Sums.SumPowerGrid
-		data.Meter.Used = WattHour(math.Abs(float64(data.Sums.SumPowerLoad)))
+		data.Meter.Used = Watt(math.Abs(float64(data.Sums.SumPowerLoad)))
 	}
 
-	return nil
+	//Older firmwares do not report the inverters at all, then we can not tell
+	asleep := result.Body.Data.Inverters != nil && len(result.Body.Data.Inverters) == 0
+	return asleep, nil
 }
 
-func (f *FroniusSymo) meterRealtimeData(data *Data) error {
+func (f *FroniusSymo) meterRealtimeData(ctx context.Context, data *Data) error {
 	uri := fmt.Sprintf("http://%s:%d/solar_api/v1/GetMeterRealtimeData.cgi?Scope=System", f.IP.String(), f.Port)
-	httpResult, err := http.Get(uri)
+	httpResult, err := get(ctx, uri)
 
 	if err != nil {
 		return err
@@ -314,8 +366,8 @@ func (f *FroniusSymo) meterRealtimeData(data *Data) error {
 	return nil
 }
 
-func (f *FroniusSymo) inverterRealtimeData(data *Data) error {
-	s, err := f.GetInverterStatistics()
+func (f *FroniusSymo) inverterRealtimeData(ctx context.Context, data *Data) error {
+	s, err := f.GetInverterStatistics(ctx)
 	if err != nil {
 		return err
 	}
@@ -328,9 +380,9 @@ func (f *FroniusSymo) inverterRealtimeData(data *Data) error {
 	return nil
 }
 
-func (f *FroniusSymo) getAPIVersion(data *Data) error {
+func (f *FroniusSymo) getAPIVersion(ctx context.Context, data *Data) error {
 	uri := fmt.Sprintf("http://%s:%d/solar_api/GetAPIVersion.cgi", f.IP.String(), f.Port)
-	httpResult, err := http.Get(uri)
+	httpResult, err := get(ctx, uri)
 
 	if err != nil {
 		return err
@@ -351,9 +403,9 @@ func (f *FroniusSymo) getAPIVersion(data *Data) error {
 	return nil
 }
 
-func (f *FroniusSymo) inverterInfo(data *Data) error {
+func (f *FroniusSymo) inverterInfo(ctx context.Context, data *Data) error {
 	uri := fmt.Sprintf("http://%s:%d/solar_api/v1/GetInverterInfo.cgi", f.IP.String(), f.Port)
-	httpResult, err := http.Get(uri)
+	httpResult, err := get(ctx, uri)
 
 	if err != nil {
 		return err
@@ -382,13 +434,13 @@ func (f *FroniusSymo) inverterInfo(data *Data) error {
 	if err != nil || result.Head.Status.Code != 0 {
 		return fmt.Errorf("Error: %s, Inverter Reason: %s", err, result.Head.Status.Reason)
 	}
-	data.Service.PVPower = WattHour(result.Body.Data.WR.PVPower)
+	data.Service.PVPower = Watt(result.Body.Data.WR.PVPower)
 	return nil
 }
 
-func (f *FroniusSymo) inverterCommonData(data *Data) error {
+func (f *FroniusSymo) inverterCommonData(ctx context.Context, data *Data) error {
 	uri := fmt.Sprintf("http://%s:%d/solar_api/v1/GetInverterRealtimeData.cgi?Scope=Device&DeviceID=%s&DataCollection=CommonInverterData", f.IP.String(), f.Port, f.DeviceID)
-	httpResult, err := http.Get(uri)
+	httpResult, err := get(ctx, uri)
 
 	if err != nil {
 		return err
@@ -435,20 +487,20 @@ func (f *FroniusSymo) inverterCommonData(data *Data) error {
 	data.AC.Voltage = result.Body.Data.UAC.Value
 	data.AC.Current = result.Body.Data.IAC.Value
 	data.AC.Frequency = result.Body.Data.FAC.Value
-	data.AC.Power = WattHour(result.Body.Data.PAC.Value)
+	data.AC.Power = Watt(result.Body.Data.PAC.Value)
 	data.PV.Voltage = result.Body.Data.UDC.Value
 	data.PV.Current = result.Body.Data.IDC.Value
-	data.PV.Power = WattHour(data.PV.Voltage * data.PV.Current)
+	data.PV.Power = Watt(data.PV.Voltage * data.PV.Current)
 
 	return nil
 }
 
-func (f *FroniusSymo) archiveData(data *Data) error {
+func (f *FroniusSymo) archiveData(ctx context.Context, data *Data) error {
 	now := time.Now()
 	//Just taken 400
 	before := now.Add(-400 * time.Second)
 	uri := fmt.Sprintf("http://%s:%d/solar_api/v1/GetArchiveData.cgi?Scope=System&StartDate=%s&EndDate=%s&Channel=Voltage_DC_String_1&Channel=Voltage_DC_String_2&Channel=Current_DC_String_1&Channel=Current_DC_String_2&Channel=Temperature_Powerstage", f.IP.String(), f.Port, before.Format(time.RFC3339), now.Format(time.RFC3339))
-	httpResult, err := http.Get(uri)
+	httpResult, err := get(ctx, uri)
 
 	if err != nil {
 		return err
@@ -0,0 +1,185 @@
+package inverter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//MaxArchiveRange the Fronius Solar API hands out with a single request
+const MaxArchiveRange = 16 * 24 * time.Hour
+
+//archiveChannels requested when retrieving historic data
+var archiveChannels = []string{
+	"PowerReal_PAC_Sum",
+	"Voltage_AC_Phase_1",
+	"Current_AC_Phase_1",
+	"Voltage_DC_String_1",
+	"Current_DC_String_1",
+	"Voltage_DC_String_2",
+	"Current_DC_String_2",
+	"Temperature_Powerstage",
+}
+
+//RetrieveArchiveData of the Fronius inverter between from and to
+func (f *FroniusSymo) RetrieveArchiveData(ctx context.Context, from, to time.Time) ([]Data, error) {
+	if to.Sub(from) > MaxArchiveRange {
+		return nil, fmt.Errorf("Archive range %s exceeds the maximum of %s", to.Sub(from), MaxArchiveRange)
+	}
+
+	query := url.Values{}
+	query.Set("Scope", "System")
+	query.Set("SeriesType", "Detail")
+	query.Set("StartDate", from.Format(time.RFC3339))
+	query.Set("EndDate", to.Format(time.RFC3339))
+	for _, c := range archiveChannels {
+		query.Add("Channel", c)
+	}
+
+	uri := fmt.Sprintf("http://%s:%d/solar_api/v1/GetArchiveData.cgi?%s", f.IP.String(), f.Port, query.Encode())
+	httpResult, err := get(ctx, uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpResult.Body.Close()
+
+	type Result struct {
+		Body struct {
+			Data map[string]struct {
+				Start string
+				Data  map[string]struct {
+					Values map[string]float64
+				}
+			}
+		}
+		Head struct {
+			Status struct {
+				Code   int
+				Reason string
+			}
+		}
+	}
+
+	var result Result
+	err = json.NewDecoder(httpResult.Body).Decode(&result)
+	if err != nil || result.Head.Status.Code != 0 {
+		return nil, fmt.Errorf("Error: %s, Inverter Reason: %s", err, result.Head.Status.Reason)
+	}
+
+	//Meters and other devices are not part of the inverter data
+	var nodes []string
+	for node := range result.Body.Data {
+		if strings.HasPrefix(node, "inverter/") {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+
+	samples := make(map[int64]*Data)
+	for _, node := range nodes {
+		device := result.Body.Data[node]
+
+		//The keys of the values are seconds since the start of the series
+		start, err := time.Parse(time.RFC3339, device.Start)
+		if err != nil {
+			start = from
+		}
+
+		//The samples of every inverter are completed before they are merged into the samples of the site
+		inverterSamples := make(map[int64]*Data)
+		for channel, series := range device.Data {
+			for offset, value := range series.Values {
+				seconds, err := strconv.ParseInt(offset, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("Error trying to convert archive data: %s", err)
+				}
+
+				date := start.Add(time.Duration(seconds) * time.Second)
+				if date.Before(from) || date.After(to) {
+					continue
+				}
+
+				sample, ok := inverterSamples[date.Unix()]
+				if !ok {
+					sample = newArchiveSample(date.In(f.location()))
+					inverterSamples[date.Unix()] = sample
+				}
+				applyArchiveChannel(sample, channel, value)
+			}
+		}
+
+		for key, sample := range inverterSamples {
+			p := &sample.PV
+			p.Voltage = p.String1.Voltage
+			p.Current = p.String1.Current + p.String2.Current
+			p.Power = Watt(p.String1.Voltage*p.String1.Current + p.String2.Voltage*p.String2.Current)
+			if site, ok := samples[key]; ok {
+				mergeArchiveSample(site, sample)
+			} else {
+				samples[key] = sample
+			}
+		}
+	}
+
+	data := make([]Data, 0, len(samples))
+	for _, sample := range samples {
+		data = append(data, *sample)
+	}
+
+	sort.Slice(data, func(i, j int) bool { return data[i].Info.Date.Before(data[j].Info.Date) })
+	return data, nil
+}
+
+//newArchiveSample creates the data of a single archived measurement
+func newArchiveSample(date time.Time) *Data {
+	var data Data
+	data.Info.Product = "Fronius Symo Series"
+	data.Info.Object = "SolarGo"
+	data.Info.Date = date
+	data.Statistics.Date = date
+	_, week := date.ISOWeek()
+	data.Statistics.Week = week
+	data.Statistics.Month = int(date.Month())
+	data.Statistics.WeekDay = date.Weekday().String()
+	return &data
+}
+
+//applyArchiveChannel sets the value of the channel of a single inverter
+func applyArchiveChannel(data *Data, channel string, value float64) {
+	switch channel {
+	case "PowerReal_PAC_Sum":
+		data.AC.Power = Watt(value)
+	case "Voltage_AC_Phase_1":
+		data.AC.Voltage = value
+	case "Current_AC_Phase_1":
+		data.AC.Current = value
+	case "Voltage_DC_String_1":
+		data.PV.String1.Voltage = value
+	case "Current_DC_String_1":
+		data.PV.String1.Current = value
+	case "Voltage_DC_String_2":
+		data.PV.String2.Voltage = value
+	case "Current_DC_String_2":
+		data.PV.String2.Current = value
+	case "Temperature_Powerstage":
+		data.Service.Temperature = value
+	}
+}
+
+//mergeArchiveSample of another inverter into the site, the powers and currents are summed up
+//while the voltages and the temperature of the first inverter are kept
+func mergeArchiveSample(site, other *Data) {
+	site.AC.Power += other.AC.Power
+	site.AC.Current += other.AC.Current
+	site.PV.String1.Current += other.PV.String1.Current
+	site.PV.String2.Current += other.PV.String2.Current
+	site.PV.Current += other.PV.Current
+	site.PV.Power += other.PV.Power
+}
@@ -0,0 +1,168 @@
+package inverter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const validArchiveRange = `{"Body":{"Data":{
+	"inverter/1":{"Start":"2020-11-21T00:00:00+01:00","Data":{
+		"PowerReal_PAC_Sum":{"Values":{"36000":1200,"36300":1500}},
+		"Voltage_AC_Phase_1":{"Values":{"36000":230,"36300":231}},
+		"Current_AC_Phase_1":{"Values":{"36000":1.5,"36300":2}},
+		"Voltage_DC_String_1":{"Values":{"36000":400,"36300":410}},
+		"Current_DC_String_1":{"Values":{"36000":2,"36300":2.5}},
+		"Voltage_DC_String_2":{"Values":{"36000":300}},
+		"Current_DC_String_2":{"Values":{"36000":1}},
+		"Temperature_Powerstage":{"Values":{"36000":40,"36300":41}}}},
+	"meter:16220112":{"Start":"2020-11-21T00:00:00+01:00","Data":{
+		"PowerReal_PAC_Sum":{"Values":{"36000":9999}}}}}},
+	"Head":{"Status":{"Code":0}}}`
+
+func TestRetrieveArchiveData(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	from := time.Date(2020, time.November, 21, 9, 0, 0, 0, loc)
+	to := time.Date(2020, time.November, 21, 12, 0, 0, 0, loc)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("StartDate") != "2020-11-21T09:00:00+01:00" || query.Get("EndDate") != "2020-11-21T12:00:00+01:00" {
+			t.Errorf("Wrong range requested: %s", r.URL.RawQuery)
+		}
+		if len(query["Channel"]) != len(archiveChannels) {
+			t.Errorf("Wrong channels requested: %v", query["Channel"])
+		}
+		fmt.Fprintln(w, validArchiveRange)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Could not parse httptest URL")
+	}
+
+	inverter := inverterFromURL(u)
+	actual, err := inverter.RetrieveArchiveData(context.Background(), from, to)
+
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	if len(actual) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(actual))
+	}
+
+	first := newArchiveSample(time.Date(2020, time.November, 21, 10, 0, 0, 0, loc))
+	first.AC.Power = 1200
+	first.AC.Voltage = 230
+	first.AC.Current = 1.5
+	first.PV.String1.Voltage = 400
+	first.PV.String1.Current = 2
+	first.PV.String2.Voltage = 300
+	first.PV.String2.Current = 1
+	first.PV.Voltage = 400
+	first.PV.Current = 3
+	first.PV.Power = 1100
+	first.Service.Temperature = 40
+
+	second := newArchiveSample(time.Date(2020, time.November, 21, 10, 5, 0, 0, loc))
+	second.AC.Power = 1500
+	second.AC.Voltage = 231
+	second.AC.Current = 2
+	second.PV.String1.Voltage = 410
+	second.PV.String1.Current = 2.5
+	second.PV.Voltage = 410
+	second.PV.Current = 2.5
+	second.PV.Power = 1025
+	second.Service.Temperature = 41
+
+	for i, expected := range []*Data{first, second} {
+		if !actual[i].Info.Date.Equal(expected.Info.Date) {
+			t.Errorf("Error actual date = %v, and expected = %v.", actual[i].Info.Date, expected.Info.Date)
+		}
+		actual[i].Info.Date = expected.Info.Date
+		actual[i].Statistics.Date = expected.Statistics.Date
+		if actual[i] != *expected {
+			t.Errorf("Error actual = %v, and expected = %v.", actual[i], *expected)
+		}
+	}
+}
+
+func TestRetrieveArchiveDataInverters(t *testing.T) {
+	const response = `{"Body":{"Data":{
+	"inverter/1":{"Start":"2020-11-21T00:00:00+01:00","Data":{
+		"PowerReal_PAC_Sum":{"Values":{"36000":1000}},
+		"Voltage_DC_String_1":{"Values":{"36000":400}},
+		"Current_DC_String_1":{"Values":{"36000":2}},
+		"Voltage_DC_String_2":{"Values":{"36000":300}},
+		"Current_DC_String_2":{"Values":{"36000":1}}}},
+	"inverter/2":{"Start":"2020-11-21T00:00:00+01:00","Data":{
+		"PowerReal_PAC_Sum":{"Values":{"36000":450}},
+		"Voltage_DC_String_1":{"Values":{"36000":500}},
+		"Current_DC_String_1":{"Values":{"36000":1}}}}}},
+	"Head":{"Status":{"Code":0}}}`
+
+	loc := time.FixedZone("CET", 3600)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, response)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Could not parse httptest URL")
+	}
+
+	inverter := inverterFromURL(u)
+	from := time.Date(2020, time.November, 21, 9, 0, 0, 0, loc)
+	actual, err := inverter.RetrieveArchiveData(context.Background(), from, from.Add(3*time.Hour))
+	if err != nil || len(actual) != 1 {
+		t.Fatalf("Expected 1 sample, got %v %v", actual, err)
+	}
+
+	//The power of the strings is computed for every inverter before the inverters are summed up
+	pv := actual[0].PV
+	if pv.Power != 1600 || pv.Current != 4 || pv.Voltage != 400 || pv.String1.Current != 3 || actual[0].AC.Power != 1450 {
+		t.Errorf("Error actual = %+v, and expected 1600 W at 4 A of both inverters", actual[0])
+	}
+}
+
+func TestRetrieveArchiveDataErrors(t *testing.T) {
+	from := time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		response    string
+		to          time.Time
+		errorPrefix string
+	}{
+		{"Status", errorStatus, from.Add(time.Hour), "Error: %!s(<nil>), Inverter Reason: TestReason"},
+		{"JSON", errorNotJSON, from.Add(time.Hour), "Error: invalid character 'n'"},
+		{"Offset", `{"Body":{"Data":{"inverter/1":{"Data":{"PowerReal_PAC_Sum":{"Values":{"x":1}}}}}},"Head":{"Status":{"Code":0}}}`, from.Add(time.Hour), "Error trying to convert archive data"},
+		{"Range", validArchiveRange, from.Add(17 * 24 * time.Hour), "Archive range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, tt.response)
+			}))
+			defer ts.Close()
+
+			u, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatalf("Could not parse httptest URL")
+			}
+
+			inverter := inverterFromURL(u)
+			if _, err := inverter.RetrieveArchiveData(context.Background(), from, tt.to); err == nil || !strings.HasPrefix(err.Error(), tt.errorPrefix) {
+				t.Errorf("FroniusSymo error = %v, want Prefix %s", err, tt.errorPrefix)
+			}
+		})
+	}
+}
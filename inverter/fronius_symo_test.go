@@ -1,6 +1,7 @@
 package inverter
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"net"
@@ -47,7 +48,7 @@ func TestInverterStatistics(t *testing.T) {
 	}
 
 	inverter := inverterFromURL(u)
-	actual, err := inverter.GetInverterStatistics()
+	actual, err := inverter.GetInverterStatistics(context.Background())
 
 	if err != nil {
 		t.Fatalf("Should not produce Error: %s", err)
@@ -75,7 +76,9 @@ func TestInverterRealtimeData(t *testing.T) {
 	expected.Service.DeviceStatus = 9
 	expected.Statistics.Production = 1.0
 
-	performTest(t, expected, validRealtimeData, func(inverter FroniusSymo, data *Data) error { return inverter.inverterRealtimeData(data) })
+	performTest(t, expected, validRealtimeData, func(inverter FroniusSymo, data *Data) error {
+		return inverter.inverterRealtimeData(context.Background(), data)
+	})
 }
 
 func TestFroniusSymoStatusError(t *testing.T) {
@@ -99,14 +102,14 @@ func TestFroniusSymoStatusError(t *testing.T) {
 		f           func(data *Data) error
 		errorPrefix string
 	}{
-		{"PowerFlowRealtimeData", func(data *Data) error { return inverter.powerFlowRealtimeData(data) }, errorInner},
-		{"MeterRealtimeData", func(data *Data) error { return inverter.meterRealtimeData(data) }, errorInner},
-		{"InverterRealtimeData", func(data *Data) error { return inverter.inverterRealtimeData(data) }, errorInner},
-		{"Inverter Info", func(data *Data) error { return inverter.inverterInfo(data) }, errorInner},
-		{"Inverter Common Data", func(data *Data) error { return inverter.inverterCommonData(data) }, errorInner},
-		{"Archive Data", func(data *Data) error { return inverter.archiveData(data) }, errorInner},
-		{"GetStatistics", func(data *Data) error { _, err := inverter.GetInverterStatistics(); return err }, errorInner},
-		{"RetrieveData", func(data *Data) error { _, err := inverter.RetrieveData(); return err }, errorGeneral},
+		{"PowerFlowRealtimeData", func(data *Data) error { return inverter.powerFlowRealtimeData(context.Background(), data) }, errorInner},
+		{"MeterRealtimeData", func(data *Data) error { return inverter.meterRealtimeData(context.Background(), data) }, errorInner},
+		{"InverterRealtimeData", func(data *Data) error { return inverter.inverterRealtimeData(context.Background(), data) }, errorInner},
+		{"Inverter Info", func(data *Data) error { return inverter.inverterInfo(context.Background(), data) }, errorInner},
+		{"Inverter Common Data", func(data *Data) error { return inverter.inverterCommonData(context.Background(), data) }, errorInner},
+		{"Archive Data", func(data *Data) error { return inverter.archiveData(context.Background(), data) }, errorInner},
+		{"GetStatistics", func(data *Data) error { _, err := inverter.GetInverterStatistics(context.Background()); return err }, errorInner},
+		{"RetrieveData", func(data *Data) error { _, err := inverter.RetrieveData(context.Background()); return err }, errorGeneral},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -135,14 +138,14 @@ func TestFroniusSymoJSONParsingError(t *testing.T) {
 		name string
 		f    func(data *Data) error
 	}{
-		{"PowerFlowRealtimeData", func(data *Data) error { return inverter.powerFlowRealtimeData(data) }},
-		{"MeterRealtimeData", func(data *Data) error { return inverter.meterRealtimeData(data) }},
-		{"InverterRealtimeData", func(data *Data) error { return inverter.inverterRealtimeData(data) }},
-		{"Inverter Info", func(data *Data) error { return inverter.inverterInfo(data) }},
-		{"Inverter Common Data", func(data *Data) error { return inverter.inverterCommonData(data) }},
-		{"Get API Version", func(data *Data) error { return inverter.getAPIVersion(data) }},
-		{"Archive Data", func(data *Data) error { return inverter.archiveData(data) }},
-		{"GetStatistics", func(data *Data) error { _, err := inverter.GetInverterStatistics(); return err }},
+		{"PowerFlowRealtimeData", func(data *Data) error { return inverter.powerFlowRealtimeData(context.Background(), data) }},
+		{"MeterRealtimeData", func(data *Data) error { return inverter.meterRealtimeData(context.Background(), data) }},
+		{"InverterRealtimeData", func(data *Data) error { return inverter.inverterRealtimeData(context.Background(), data) }},
+		{"Inverter Info", func(data *Data) error { return inverter.inverterInfo(context.Background(), data) }},
+		{"Inverter Common Data", func(data *Data) error { return inverter.inverterCommonData(context.Background(), data) }},
+		{"Get API Version", func(data *Data) error { return inverter.getAPIVersion(context.Background(), data) }},
+		{"Archive Data", func(data *Data) error { return inverter.archiveData(context.Background(), data) }},
+		{"GetStatistics", func(data *Data) error { _, err := inverter.GetInverterStatistics(context.Background()); return err }},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -174,14 +177,14 @@ func TestFroniusSymoInvalidPort(t *testing.T) {
 		name string
 		f    func(data *Data) error
 	}{
-		{"PowerFlowRealtimeData", func(data *Data) error { return inverter.powerFlowRealtimeData(data) }},
-		{"MeterRealtimeData", func(data *Data) error { return inverter.meterRealtimeData(data) }},
-		{"InverterRealtimeData", func(data *Data) error { return inverter.inverterRealtimeData(data) }},
-		{"Inverter Info", func(data *Data) error { return inverter.inverterInfo(data) }},
-		{"Inverter Common Data", func(data *Data) error { return inverter.inverterCommonData(data) }},
-		{"Archive Data", func(data *Data) error { return inverter.archiveData(data) }},
-		{"Get API Version", func(data *Data) error { return inverter.getAPIVersion(data) }},
-		{"GetStatistics", func(data *Data) error { _, err := inverter.GetInverterStatistics(); return err }},
+		{"PowerFlowRealtimeData", func(data *Data) error { return inverter.powerFlowRealtimeData(context.Background(), data) }},
+		{"MeterRealtimeData", func(data *Data) error { return inverter.meterRealtimeData(context.Background(), data) }},
+		{"InverterRealtimeData", func(data *Data) error { return inverter.inverterRealtimeData(context.Background(), data) }},
+		{"Inverter Info", func(data *Data) error { return inverter.inverterInfo(context.Background(), data) }},
+		{"Inverter Common Data", func(data *Data) error { return inverter.inverterCommonData(context.Background(), data) }},
+		{"Archive Data", func(data *Data) error { return inverter.archiveData(context.Background(), data) }},
+		{"Get API Version", func(data *Data) error { return inverter.getAPIVersion(context.Background(), data) }},
+		{"GetStatistics", func(data *Data) error { _, err := inverter.GetInverterStatistics(context.Background()); return err }},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -198,19 +201,21 @@ func TestInverterPowerFlowRealtimeDataPositiveGrid(t *testing.T) {
 	expected.Sums.SumProdToday = WattHour(10.0)
 	expected.Sums.SumProdTotal = WattHour(12.0)
 	expected.Sums.SumProdYear = WattHour(11.0)
-	expected.Sums.SumPowerGrid = WattHour(13.0)
-	expected.Sums.SumPowerLoad = WattHour(14.0)
-	expected.Sums.SumPowerBattery = WattHour(15.0)
-	expected.Sums.SumPowerPv = WattHour(16.0)
+	expected.Sums.SumPowerGrid = Watt(13.0)
+	expected.Sums.SumPowerLoad = Watt(14.0)
+	expected.Sums.SumPowerBattery = Watt(15.0)
+	expected.Sums.SumPowerPv = Watt(16.0)
 	expected.Service.MeterLocation = "location"
 	expected.Service.Mode = "mode"
 	expected.Service.Autonomy = 17.0
 	expected.Service.SelfConsumption = 18.0
 	expected.Meter.Feed = 0.0
 	expected.Meter.Purchased = expected.Sums.SumPowerGrid
-	expected.Meter.Used = WattHour(math.Abs(float64(expected.Sums.SumPowerLoad)))
+	expected.Meter.Used = Watt(math.Abs(float64(expected.Sums.SumPowerLoad)))
 
-	performTest(t, expected, validPowerFlowRealtimeData, func(inverter FroniusSymo, data *Data) error { return inverter.powerFlowRealtimeData(data) })
+	performTest(t, expected, validPowerFlowRealtimeData, func(inverter FroniusSymo, data *Data) error {
+		return inverter.powerFlowRealtimeData(context.Background(), data)
+	})
 }
 
 func TestInverterPowerFlowRealtimeDataNegativeGrid(t *testing.T) {
@@ -218,28 +223,32 @@ func TestInverterPowerFlowRealtimeDataNegativeGrid(t *testing.T) {
 	expected.Sums.SumProdToday = WattHour(10.0)
 	expected.Sums.SumProdTotal = WattHour(12.0)
 	expected.Sums.SumProdYear = WattHour(11.0)
-	expected.Sums.SumPowerGrid = WattHour(-20.0)
-	expected.Sums.SumPowerLoad = WattHour(14.0)
-	expected.Sums.SumPowerBattery = WattHour(15.0)
-	expected.Sums.SumPowerPv = WattHour(16.0)
+	expected.Sums.SumPowerGrid = Watt(-20.0)
+	expected.Sums.SumPowerLoad = Watt(14.0)
+	expected.Sums.SumPowerBattery = Watt(15.0)
+	expected.Sums.SumPowerPv = Watt(16.0)
 	expected.Service.MeterLocation = "location"
 	expected.Service.Mode = "mode"
 	expected.Service.Autonomy = 17.0
 	expected.Service.SelfConsumption = 18.0
-	expected.Meter.Feed = WattHour(math.Abs(float64(expected.Sums.SumPowerGrid)))
+	expected.Meter.Feed = Watt(math.Abs(float64(expected.Sums.SumPowerGrid)))
 	expected.Meter.Purchased = 0.0
-	expected.Meter.Used = WattHour(math.Abs(float64(expected.Sums.SumPowerLoad)))
+	expected.Meter.Used = Watt(math.Abs(float64(expected.Sums.SumPowerLoad)))
 
 	validPowerFlowRealtimeDataNegative := `{"Body":{"Data":{"Site":{"E_Day":10,"E_Year":11,"E_Total":12,"Meter_Location":"location","Mode":"mode","P_Grid":-20,"P_Load":14,"P_Akku":15,"P_PV":16,"rel_Autonomy":17,"rel_SelfConsumption":18}}},"Head":{"Status":{"Code":0}}}`
 
-	performTest(t, expected, validPowerFlowRealtimeDataNegative, func(inverter FroniusSymo, data *Data) error { return inverter.powerFlowRealtimeData(data) })
+	performTest(t, expected, validPowerFlowRealtimeDataNegative, func(inverter FroniusSymo, data *Data) error {
+		return inverter.powerFlowRealtimeData(context.Background(), data)
+	})
 }
 
 func TestInverterAPIVersion(t *testing.T) {
 	var expected Data
 	expected.Info.FirmWare = "1"
 
-	performTest(t, expected, validAPIVersion, func(inverter FroniusSymo, data *Data) error { return inverter.getAPIVersion(data) })
+	performTest(t, expected, validAPIVersion, func(inverter FroniusSymo, data *Data) error {
+		return inverter.getAPIVersion(context.Background(), data)
+	})
 }
 
 func TestInverterCommonData(t *testing.T) {
@@ -247,19 +256,21 @@ func TestInverterCommonData(t *testing.T) {
 	expected.AC.Voltage = 102.0
 	expected.AC.Current = 101.0
 	expected.AC.Frequency = 103.0
-	expected.AC.Power = WattHour(100.0)
+	expected.AC.Power = Watt(100.0)
 	expected.PV.Voltage = 105.0
 	expected.PV.Current = 104.0
-	expected.PV.Power = WattHour(expected.PV.Voltage * expected.PV.Current)
+	expected.PV.Power = Watt(expected.PV.Voltage * expected.PV.Current)
 
-	performTest(t, expected, validCommonData, func(inverter FroniusSymo, data *Data) error { return inverter.inverterCommonData(data) })
+	performTest(t, expected, validCommonData, func(inverter FroniusSymo, data *Data) error {
+		return inverter.inverterCommonData(context.Background(), data)
+	})
 }
 
 func TestInverterInfo(t *testing.T) {
 	var expected Data
 	expected.Service.PVPower = 1234.5
 
-	performTest(t, expected, validInverterInfo, func(inverter FroniusSymo, data *Data) error { return inverter.inverterInfo(data) })
+	performTest(t, expected, validInverterInfo, func(inverter FroniusSymo, data *Data) error { return inverter.inverterInfo(context.Background(), data) })
 }
 
 func TestInverterArchiveData(t *testing.T) {
@@ -270,7 +281,7 @@ func TestInverterArchiveData(t *testing.T) {
 	expected.PV.String2.Current = 295.0
 	expected.Service.Temperature = 45.5
 
-	performTest(t, expected, validArchiveData, func(inverter FroniusSymo, data *Data) error { return inverter.archiveData(data) })
+	performTest(t, expected, validArchiveData, func(inverter FroniusSymo, data *Data) error { return inverter.archiveData(context.Background(), data) })
 }
 
 func TestInverterMeterRealtimeData(t *testing.T) {
@@ -298,7 +309,9 @@ func TestInverterMeterRealtimeData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			performTest(t, tt.expected, tt.httpResult, func(inverter FroniusSymo, data *Data) error { return inverter.meterRealtimeData(data) })
+			performTest(t, tt.expected, tt.httpResult, func(inverter FroniusSymo, data *Data) error {
+				return inverter.meterRealtimeData(context.Background(), data)
+			})
 		})
 	}
 }
@@ -355,7 +368,7 @@ func TestRetrieveDataSuccess(t *testing.T) {
 	}
 
 	inverter := inverterFromURL(u)
-	actual, err := inverter.RetrieveData()
+	actual, err := inverter.RetrieveData(context.Background())
 
 	expected.Info.Date = actual.Info.Date
 	expected.Statistics.Date = actual.Statistics.Date
@@ -380,25 +393,25 @@ func TestRetrieveDataSuccess(t *testing.T) {
 	expected.AC.Voltage = 102.0
 	expected.AC.Current = 101.0
 	expected.AC.Frequency = 103.0
-	expected.AC.Power = WattHour(100.0)
+	expected.AC.Power = Watt(100.0)
 	expected.PV.Voltage = 105.0
 	expected.PV.Current = 104.0
-	expected.PV.Power = WattHour(expected.PV.Voltage * expected.PV.Current)
+	expected.PV.Power = Watt(expected.PV.Voltage * expected.PV.Current)
 	expected.Info.FirmWare = "1"
 	expected.Sums.SumProdToday = WattHour(10.0)
 	expected.Sums.SumProdTotal = WattHour(12.0)
 	expected.Sums.SumProdYear = WattHour(11.0)
-	expected.Sums.SumPowerGrid = WattHour(13.0)
-	expected.Sums.SumPowerLoad = WattHour(14.0)
-	expected.Sums.SumPowerBattery = WattHour(15.0)
-	expected.Sums.SumPowerPv = WattHour(16.0)
+	expected.Sums.SumPowerGrid = Watt(13.0)
+	expected.Sums.SumPowerLoad = Watt(14.0)
+	expected.Sums.SumPowerBattery = Watt(15.0)
+	expected.Sums.SumPowerPv = Watt(16.0)
 	expected.Service.MeterLocation = "location"
 	expected.Service.Mode = "mode"
 	expected.Service.Autonomy = 17.0
 	expected.Service.SelfConsumption = 18.0
 	expected.Meter.Feed = 0.0
 	expected.Meter.Purchased = expected.Sums.SumPowerGrid
-	expected.Meter.Used = WattHour(math.Abs(float64(expected.Sums.SumPowerLoad)))
+	expected.Meter.Used = Watt(math.Abs(float64(expected.Sums.SumPowerLoad)))
 	expected.Sums.ProductionToday = 1.0
 	expected.Sums.ProductionYear = 2.0
 	expected.Sums.ProductionTotal = 3.0
@@ -429,7 +442,7 @@ func TestRetrieveDataNotSupportedAPIVersion(t *testing.T) {
 	inverter := inverterFromURL(u)
 	errorPrefix := "Fronius Symo Error: Wrong Api-Version 2"
 
-	if _, err := inverter.RetrieveData(); err == nil || !strings.HasPrefix(err.Error(), errorPrefix) {
+	if _, err := inverter.RetrieveData(context.Background()); err == nil || !strings.HasPrefix(err.Error(), errorPrefix) {
 		t.Errorf("FroniusSymo error = %v, want Prefix %s", err, errorPrefix)
 	}
 }
@@ -464,7 +477,61 @@ func TestRetrieveDataInvalidAPIVersion(t *testing.T) {
 	inverter := inverterFromURL(u)
 	errorPrefix := "Fronius Symo Error: Error: invalid character 'n'"
 
-	if _, err := inverter.RetrieveData(); err == nil || !strings.HasPrefix(err.Error(), errorPrefix) {
+	if _, err := inverter.RetrieveData(context.Background()); err == nil || !strings.HasPrefix(err.Error(), errorPrefix) {
 		t.Errorf("FroniusSymo error = %v, want Prefix %s", err, errorPrefix)
 	}
 }
+
+func TestRetrieveSiteData(t *testing.T) {
+	site := `"Site":{"Meter_Location":"grid","P_Grid":300,"P_Load":-250,"P_Akku":-50}`
+	var tests = []struct {
+		testName  string
+		powerFlow string
+		asleep    bool
+	}{
+		{"Asleep", `{"Body":{"Data":{` + site + `,"Inverters":{}}},"Head":{"Status":{"Code":0}}}`, true},
+		{"Awake", `{"Body":{"Data":{` + site + `,"Inverters":{"1":{"DT":1,"P":10}}}},"Head":{"Status":{"Code":0}}}`, false},
+		{"Old firmware", `{"Body":{"Data":{` + site + `}},"Head":{"Status":{"Code":0}}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.RequestURI, "/solar_api/v1/GetPowerFlowRealtimeData.fcgi") {
+					fmt.Fprintln(w, tt.powerFlow)
+				} else if strings.HasPrefix(r.RequestURI, "/solar_api/v1/GetMeterRealtimeData.cgi?Scope=System") {
+					fmt.Fprintln(w, validMeterZero)
+				} else {
+					t.Errorf("Inverter-only endpoint %s must not be requested", r.RequestURI)
+				}
+			}))
+			defer ts.Close()
+
+			u, _ := url.Parse(ts.URL)
+			inverter := inverterFromURL(u)
+			data, asleep, err := inverter.RetrieveSiteData(context.Background())
+			if err != nil {
+				t.Fatalf("Should not produce Error: %s", err)
+			}
+			if asleep != tt.asleep {
+				t.Errorf("got asleep %t, want %t", asleep, tt.asleep)
+			}
+			if data.Sums.SumPowerGrid != 300 || data.Sums.SumPowerBattery != -50 || data.Meter.Purchased != 300 || data.Meter.Used != 250 || data.Meter.EnergyUsed != 5 {
+				t.Errorf("Unexpected site data %+v", data)
+			}
+		})
+	}
+}
+
+func TestRetrieveSiteDataError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, errorStatus)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	inverter := inverterFromURL(u)
+	if _, _, err := inverter.RetrieveSiteData(context.Background()); err == nil || !strings.HasPrefix(err.Error(), "Fronius Symo Error: ") {
+		t.Errorf("Expected Error, got %v", err)
+	}
+}
@@ -1,13 +1,17 @@
 package inverter
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
 
-//WattHour type
+//WattHour is an amount of energy
 type WattHour float64
 
+//Watt is an instantaneous power, integrated over time it becomes an energy in WattHour
+type Watt float64
+
 //KWh type
 type KWh float64
 
@@ -33,15 +37,15 @@ type Data struct {
 		Date     time.Time //Current date
 	}
 	AC struct {
-		Voltage   float64  //Voltage on Inverter AC side
-		Current   float64  //Current on Inverter AC side
-		Frequency float64  //Frequency on Inverter AC side
-		Power     WattHour //Power on Inverter AC side
+		Voltage   float64 //Voltage on Inverter AC side
+		Current   float64 //Current on Inverter AC side
+		Frequency float64 //Frequency on Inverter AC side
+		Power     Watt    //Power on Inverter AC side
 	}
 	PV struct {
-		Voltage float64  //Voltage on Inverter PV side
-		Current float64  //Current on Inverter PV side
-		Power   WattHour //Power on Inverter PV side
+		Voltage float64 //Voltage on Inverter PV side
+		Current float64 //Current on Inverter PV side
+		Power   Watt    //Power on Inverter PV side
 		String1 struct {
 			Voltage float64 // Voltage of that string
 			Current float64 // Current of that string
@@ -52,14 +56,14 @@ type Data struct {
 		}
 	}
 	Service struct {
-		DeviceStatus    int64    // Status of the inverter
-		Temperature     float64  // Temperature in °C
-		ErrorCode       int      // Error Code of the inverter
-		PVPower         WattHour // Photovoltaic production
-		MeterLocation   string   // Is the meter on "load" or "grid" or "unknown"
-		Mode            string   // In what mode the inverter is operated
-		Autonomy        float64  // Autonomy Degree in %
-		SelfConsumption float64  // Selfconsumption of the produced electricity in %
+		DeviceStatus    int64   // Status of the inverter
+		Temperature     float64 // Temperature in °C
+		ErrorCode       int     // Error Code of the inverter
+		PVPower         Watt    // Photovoltaic production
+		MeterLocation   string  // Is the meter on "load" or "grid" or "unknown"
+		Mode            string  // In what mode the inverter is operated
+		Autonomy        float64 // Autonomy Degree in %
+		SelfConsumption float64 // Selfconsumption of the produced electricity in %
 	}
 	Statistics struct {
 		Date       time.Time // Current Time
@@ -75,30 +79,46 @@ type Data struct {
 		SumProdToday    WattHour // Daily Production in Wh
 		SumProdTotal    WattHour // Total Production in Wh
 		SumProdYear     WattHour // Yearly Production in Wh
-		SumPowerGrid    WattHour // negative if we direct power to the grid, positive if we consume power from the grid
-		SumPowerLoad    WattHour // negative if consuming power, positive if generating
-		SumPowerBattery WattHour // negative if charging, positive if discharging
-		SumPowerPv      WattHour // electricity production
+		SumPowerGrid    Watt     // negative if we direct power to the grid, positive if we consume power from the grid
+		SumPowerLoad    Watt     // negative if consuming power, positive if generating
+		SumPowerBattery Watt     // negative if charging, positive if discharging
+		SumPowerPv      Watt     // electricity production
 	}
 	Meter struct {
-		Production       float64  // Current Production
-		ApparentPower    float64  // Apparent Power
-		BlindPower       float64  // Blind Power
-		EnergyProduction float64  // Smart-Meter energy produced
-		EnergyUsed       float64  // Smart-Meter energy used
-		Feed             WattHour // Fed into the Grid
-		Purchased        WattHour // Purchased from Grid
-		Used             WattHour // Locally used power
+		Production       float64 // Current Production
+		ApparentPower    float64 // Apparent Power
+		BlindPower       float64 // Blind Power
+		EnergyProduction float64 // Smart-Meter energy produced
+		EnergyUsed       float64 // Smart-Meter energy used
+		Feed             Watt    // Fed into the Grid
+		Purchased        Watt    // Purchased from Grid
+		Used             Watt    // Locally used power
 	}
 }
 
 //GenericInverter provides an abstraction over a specific inverter
 type GenericInverter interface {
 	//GetInverterStatistics of the inverter
-	GetInverterStatistics() (DailyStatistics, error)
+	GetInverterStatistics(ctx context.Context) (DailyStatistics, error)
 
 	//RetrieveData of the inverter
-	RetrieveData() (Data, error)
+	RetrieveData(ctx context.Context) (Data, error)
+}
+
+//ArchiveInverter is an inverter which keeps a history of its measurements
+type ArchiveInverter interface {
+	GenericInverter
+
+	//RetrieveArchiveData measured between from and to, sorted by time
+	RetrieveArchiveData(ctx context.Context, from, to time.Time) ([]Data, error)
+}
+
+//SiteInverter can read the power flow, battery and meter of the site, even if the inverter itself sleeps
+type SiteInverter interface {
+	GenericInverter
+
+	//RetrieveSiteData without the inverter-only values and true if the inverter sleeps
+	RetrieveSiteData(ctx context.Context) (Data, bool, error)
 }
 
 //ToKWh converts Wh to kWh
@@ -106,6 +126,11 @@ func (w *WattHour) ToKWh() KWh {
 	return KWh(*w / WattHour(1000.0))
 }
 
+//Over the duration the constant power produces or consumes the energy
+func (w Watt) Over(d time.Duration) WattHour {
+	return WattHour(float64(w) * d.Hours())
+}
+
 //Converts the Daily Statistics into a human readable form
 func (s *DailyStatistics) String() string {
 	return fmt.Sprintf("Daily Production: %.2f kWh\nYearly Production: %.2f kWh\nTotal Production: %.2f kWh", s.DailyProduction.ToKWh(), s.YearlyProduction.ToKWh(), s.TotalProduction.ToKWh())
@@ -1,103 +1,316 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+	_ "time/tzdata" //The timezone of the plant must be known in containers without zoneinfo
 
 	"path/filepath"
+	"solargo/accuracy"
+	"solargo/backfill"
+	"solargo/calibration"
 	"solargo/config"
+	"solargo/energy"
+	"solargo/financials"
+	"solargo/inverter"
+	"solargo/persistence"
 	"solargo/summary"
+	"solargo/supervisor"
+	"solargo/weather"
+	"solargo/yield_forecast"
 
-	"github.com/nathan-osman/go-sunrise"
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/robfig/cron.v2"
 )
 
-const thirtyMinutes = 30 * time.Minute
+//account integrates the power flow of the data into the energy of the current hour and day
+func account(s *services, data inverter.Data) {
+	if s.energy == nil {
+		return
+	}
+	closed, err := s.energy.Add(energy.SampleOf(data))
+	if err != nil {
+		log.Error("Cannot account the energy: ", err)
+	}
+	s.database.SendEnergy(closed)
+}
 
-var rise, set time.Time
+func updateWeather(ctx context.Context, s *services) {
+	if !s.config.Weather.Enabled {
+		return
+	}
 
-func updateWeather(config *config.Config) {
-	//If the time is between 30 min of sunrise and sunset, we can update the weather
-	if time.Now().Add(-thirtyMinutes).After(rise) && time.Now().Add(thirtyMinutes).Before(set) && config.Weather.Enabled {
-		log.Info("Update weather: ", time.Now().String())
-		weather := config.GetWeatherService()
-		database := config.GetDatabase()
-		data, err := weather.RetrieveForecast()
+	log.Info("Update weather: ", time.Now().String())
 
+	//Some sources provide the weather and the forecast with a single request
+	if w, ok := s.weather.(weather.UpdateWeather); ok {
+		data, forecast, err := w.RetrieveWeather(ctx)
 		if err != nil {
 			log.Error("Cannot read weather data: ", err)
 			return
 		}
+		s.database.SendWeather(data)
+		s.database.SendWeatherForecast(forecast)
+		return
+	}
+
+	data, err := s.weather.RetrieveForecast(ctx)
 
-		database.SendWeather(data)
+	if err != nil {
+		log.Error("Cannot read weather data: ", err)
+		return
 	}
-}
 
-func readController(config *config.Config) {
-	//If the time is between sunrise and sunset, we can read out the controller
-	if time.Now().After(rise) && time.Now().Before(set) {
-		log.Info("Reading the controller: ", time.Now().String())
+	s.database.SendWeather(data)
 
-		inverter := config.GetInverter()
-		database := config.GetDatabase()
+	//Not every weather source forecasts the next days
+	if w, ok := s.weather.(weather.ForecastWeather); ok {
+		forecast, err := w.RetrieveHourlyForecast(ctx)
+		if err != nil {
+			log.Error("Cannot read weather forecast: ", err)
+			return
+		}
+		s.database.SendWeatherForecast(forecast)
+	}
+}
 
-		data, err := inverter.RetrieveData()
+func readController(ctx context.Context, s *services) {
+	log.Info("Reading the controller: ", time.Now().String())
 
+	site, ok := s.inverter.(inverter.SiteInverter)
+	if !ok {
+		data, err := s.inverter.RetrieveData(ctx)
 		if err != nil {
 			log.Error("Cannot read inverter data: ", err)
 			return
 		}
+		s.database.SendData(data)
+		account(s, data)
+		return
+	}
+
+	//A sleeping inverter fails to answer, so we only read it if it was awake the last time
+	var inverterErr error
+	if !s.sleep.get() {
+		data, err := s.inverter.RetrieveData(ctx)
+		if err == nil {
+			s.database.SendData(data)
+			account(s, data)
+			return
+		}
+		inverterErr = err
+	}
+
+	data, asleep, err := site.RetrieveSiteData(ctx)
+	if err != nil {
+		if inverterErr != nil {
+			err = inverterErr
+		}
+		log.Error("Cannot read inverter data: ", err)
+		return
+	}
+
+	//The inverter woke up
+	if !asleep && inverterErr == nil {
+		full, err := s.inverter.RetrieveData(ctx)
+		if err == nil {
+			s.sleep.set(false)
+			s.database.SendData(full)
+			account(s, full)
+			return
+		}
+		inverterErr = err
+	}
 
-		database.SendData(data)
+	//Firmware without the inverters in the power flow never reports them asleep, so a failing inverter is treated as asleep
+	if inverterErr != nil && !asleep {
+		log.Warn("Cannot read inverter data, saving the site data: ", inverterErr)
 	}
+	s.sleep.set(asleep || inverterErr != nil)
+	s.database.SendSiteData(data)
+	account(s, data)
 }
 
-func sendSummary(config *config.Config) {
-	//If sunset is in less then 30 minutes, we send the summary
-	in30min := time.Now().Add(thirtyMinutes)
-	if in30min.After(set) && in30min.Before(set.Add(thirtyMinutes)) {
-		log.Info("Send summary: ", time.Now().String())
-		summary.SendSummary(config, config.GetInverter(), config.GetDatabase())
+//sleepState of the inverter as seen by the last poll
+type sleepState struct {
+	mu     sync.Mutex
+	asleep bool
+}
+
+func (st *sleepState) get() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.asleep
+}
+
+func (st *sleepState) set(asleep bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.asleep != asleep {
+		log.Info("Inverter asleep: ", asleep)
 	}
+	st.asleep = asleep
 }
 
-func updateYieldForecast(config *config.Config) {
-	//If sunrise is in less then 30 minutes and it is before sunset, we start to get the yield forecast
-	in30min := time.Now().Add(thirtyMinutes)
-	if in30min.After(rise) && in30min.Before(set) && config.Yield.Enabled {
-		log.Info("Update Yield Forecast: ", time.Now().String())
-		yield := config.GetYieldForecastService()
-		database := config.GetDatabase()
-		data, err := yield.RetrieveForecast()
+func sendSummary(ctx context.Context, s *services) {
+	log.Info("Send summary: ", time.Now().String())
+	summary.SendSummary(ctx, s.config, s.inverter, s.database)
+}
 
+func updateYieldForecast(ctx context.Context, s *services) {
+	if !s.config.Yield.Enabled {
+		return
+	}
+
+	log.Info("Update Yield Forecast: ", time.Now().String())
+	issued := time.Now()
+	name := s.config.YieldProviderName()
+	if due(name, s.yield, issued) {
+		data, err := s.yield.RetrieveForecast(ctx)
 		if err != nil {
 			log.Error("Cannot read yield forecast data: ", err)
-			return
+		} else {
+			s.database.SendYieldForecast(data)
+			s.database.SendYieldSnapshot(name, issued, data)
+		}
+	}
+
+	for name, yield := range s.compared {
+		if !due(name, yield, issued) {
+			continue
+		}
+		data, err := yield.RetrieveForecast(ctx)
+		if err != nil {
+			log.Error("Cannot read yield forecast data of ", name, ": ", err)
+			continue
+		}
+		s.database.SendYieldSnapshot(name, issued, data)
+	}
+}
+
+//due returns false if the provider suggested to request its next forecast later
+func due(name string, yield yield_forecast.GenericYieldForecast, now time.Time) bool {
+	throttled, ok := yield.(yield_forecast.Throttled)
+	if !ok || !now.Before(throttled.NextRequest()) {
+		return true
+	}
+	log.Debug("Skip yield forecast of ", name, " until ", throttled.NextRequest())
+	return false
+}
+
+//scoreAccuracy of the forecasts of yesterday
+func scoreAccuracy(ctx context.Context, s *services) {
+	if !s.config.Yield.Enabled {
+		return
+	}
+
+	yesterday := time.Now().In(s.config.Location()).AddDate(0, 0, -1)
+	scores, err := accuracy.ScoreDay(s.database, s.config.TrackedYieldProviders(), yesterday)
+	if err != nil {
+		log.Error("Cannot score the yield forecasts: ", err)
+	}
+	for provider, leads := range scores {
+		for lead, m := range leads {
+			log.Info("Accuracy of ", provider, " ", lead, " ahead: ", m)
 		}
+	}
+}
+
+//trackFinancials saves the financials of yesterday
+func trackFinancials(ctx context.Context, s *services) {
+	if !s.config.Tariff.Enabled {
+		return
+	}
+
+	yesterday := time.Now().In(s.config.Location()).AddDate(0, 0, -1)
+	t := s.config.GetTariff()
+	d, err := financials.TrackDay(s.database, t, yesterday, s.config.Energy.Enabled)
+	if err != nil {
+		log.Error("Cannot compute the financials: ", err)
+		return
+	}
+	log.Info("Financials of ", d.Date.Format("2006-01-02"), ": savings ", t.Format(d.Savings), ", feed-in ", t.Format(d.FeedInRevenue), ", import ", t.Format(d.ImportCost))
+}
+
+//updatePrices saves the market prices of today and tomorrow for a dynamic tariff
+func updatePrices(ctx context.Context, s *services) {
+	if !s.config.Tariff.Enabled || !s.config.Tariff.Import.Dynamic || s.prices == nil {
+		return
+	}
+
+	prices, err := s.prices.RetrievePrices(ctx)
+	if err != nil {
+		log.Error("Cannot read the market prices: ", err)
+		return
+	}
+	s.database.SendPrices(prices)
+	log.Debug("Saved ", len(prices), " market prices")
+}
+
+func sendAccuracyReport(ctx context.Context, s *services) {
+	summary.SendAccuracyReport(ctx, s.config, s.database)
+}
+
+func sendEnergyReport(ctx context.Context, s *services) {
+	summary.SendEnergyReport(ctx, s.config, s.database)
+}
 
-		database.SendYieldForecast(data)
+func backfillGaps(ctx context.Context, config *config.Config, from, to time.Time) (int, error) {
+	from, to = from.In(config.Location()), to.In(config.Location())
+	log.Info("Backfill gaps between ", from, " and ", to)
+	options := backfill.Options{
+		Latitude:  config.Latitude,
+		Longitude: config.Longitude,
+		ChunkSize: config.Backfill.ChunkSize,
+		MinGap:    config.Backfill.MinGap,
 	}
+	return backfill.Run(ctx, config.GetInverter(), config.GetDatabase(), from, to, options)
 }
 
-func sunriseSunset(config *config.Config) {
-	rise, set = sunrise.SunriseSunset(
-		config.Latitude, config.Longitude,
-		time.Now().Year(), time.Now().Month(), time.Now().Day(),
-	)
-	log.Info("Updated sunrise ", rise, "and sunset ", set)
+func retrainCalibration(ctx context.Context, s *services) {
+	if !s.config.Yield.Enabled || !s.config.Yield.Calibration.Enabled {
+		return
+	}
+
+	m, err := calibrate(s.config, s.database)
+	if err != nil {
+		log.Error("Cannot calibrate the yield forecast: ", err)
+		return
+	}
+	log.Info("Calibrated the yield forecast, baseline: ", m.Baseline, ", calibrated: ", m.Calibrated)
 }
 
-func initializeLogger(config config.Config) {
+//calibrate the yield model on the history and save the calibration
+func calibrate(config *config.Config, database persistence.GenericDatabase) (*calibration.Model, error) {
+	history := config.Yield.Calibration.History
+	if history <= 0 {
+		history = calibration.DefaultHistory
+	}
+	to := time.Now()
+	return calibration.Retrain(database, config.GetYieldModel(), to.Add(-history), to, config.CalibrationFile())
+}
+
+func backfillMaxAge(config *config.Config) time.Duration {
+	if config.Backfill.MaxAge > 0 {
+		return config.Backfill.MaxAge
+	}
+	return backfill.DefaultMaxAge
+}
+
+func initializeLogger(config config.Config) error {
 	if !config.Logging.Enabled {
 		log.SetOutput(ioutil.Discard)
 	} else {
 		_ = os.MkdirAll(filepath.Dir(config.Logging.Filename), os.ModePerm)
 		logFile, err := os.OpenFile(config.Logging.Filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0755)
 		if err != nil {
-			panic("Could not open log file!")
+			return fmt.Errorf("Could not open log file: %s", err)
 		}
 		if config.Debug {
 			mw := io.MultiWriter(os.Stdout, logFile)
@@ -108,44 +321,40 @@ func initializeLogger(config config.Config) {
 			log.SetLevel(log.ErrorLevel)
 		}
 	}
-
+	return nil
 }
 
-func main() {
-	//Read config
-	config := config.ReadConfig("config.yaml")
-
-	//Initialize logger
-	initializeLogger(config)
-
-	//Start cron jobs
-	c := cron.New()
-	defer c.Stop()
-
-	//At 0:10 update sunrise, sunset
-	_, _ = c.AddFunc("10 0 * * *", func() { sunriseSunset(&config) })
-
-	//Every 30 seconds read the controller
-	_, _ = c.AddFunc("@every 0h0m30s", func() { readController(&config) })
-
-	//Update the weather every half an hour
-	_, _ = c.AddFunc("15,45 * * * *", func() { updateWeather(&config) })
+//runDaemon reads the inverter, weather and yield forecast periodically until SIGINT or SIGTERM
+func runDaemon(config *config.Config, args []string, stdout, stderr io.Writer) int {
+	if unexpectedArguments(args, stderr) {
+		return exitUsage
+	}
+	d := newDaemon(configPath, config)
+	d.start()
 
-	//Send the summary always around 30 minutes before sunset
-	_, _ = c.AddFunc("@every 0h30m0s", func() { sendSummary(&config) })
+	//On startup, run every job once which is within its window
+	d.runStartupJobs()
 
-	//Update the yield forecast every half an hour
-	_, _ = c.AddFunc("@every 0h30m0s", func() { updateYieldForecast(&config) })
+	if config.Backfill.OnStartup {
+		d.goRun("backfill", func(ctx context.Context, s *services) {
+			saved, err := backfillGaps(ctx, s.config, time.Now().Add(-backfillMaxAge(s.config)), time.Now())
+			if err != nil {
+				log.Error("Could not backfill gaps: ", err)
+			}
+			log.Info("Backfilled ", saved, " samples")
+		})
+	}
 
-	c.Start()
+	//Reload the config on change or SIGHUP until the daemon is stopped
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	d.watch(configWatchInterval, hangup, supervisor.ShutdownSignal())
 
-	//On startup, run every function once
-	sunriseSunset(&config)
-	sendSummary(&config)
-	readController(&config)
-	updateWeather(&config)
-	updateYieldForecast(&config)
+	d.stop()
+	log.Info("SolarGo stopped")
+	return exitOK
+}
 
-	//Sleep forever
-	select {}
+func main() {
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
 }
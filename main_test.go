@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"solargo/config"
+	"solargo/energy"
+	"solargo/inverter"
+	"solargo/tariff"
+	"solargo/testutils"
+	"solargo/yield_forecast"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//sleepyInverter fails to read the inverter-only values while it sleeps
+type sleepyInverter struct {
+	testutils.SuccessInverter
+	asleep    bool
+	siteError bool
+	full      int
+	site      int
+}
+
+func (i *sleepyInverter) RetrieveData(ctx context.Context) (inverter.Data, error) {
+	i.full++
+	if i.asleep {
+		return inverter.Data{}, fmt.Errorf("Inverter sleeps")
+	}
+	return inverter.Data{}, nil
+}
+
+func (i *sleepyInverter) RetrieveSiteData(ctx context.Context) (inverter.Data, bool, error) {
+	i.site++
+	if i.siteError {
+		return inverter.Data{}, false, fmt.Errorf("Error")
+	}
+	return inverter.Data{}, i.asleep, nil
+}
+
+//countingDatabase counts the saved data
+type countingDatabase struct {
+	testutils.SuccessDatabase
+	data int
+	site int
+}
+
+func (db *countingDatabase) SendData(data inverter.Data) { db.data++ }
+
+func (db *countingDatabase) SendSiteData(data inverter.Data) { db.site++ }
+
+func TestReadController(t *testing.T) {
+	iv := &sleepyInverter{}
+	db := &countingDatabase{}
+	s := &services{inverter: iv, database: db, sleep: &sleepState{}}
+
+	var tests = []struct {
+		testName string
+		asleep   bool
+		full     int
+		site     int
+		data     int
+		siteData int
+	}{
+		{"Awake", false, 1, 0, 1, 0},
+		{"Falls asleep", true, 2, 1, 1, 1},
+		{"Sleeps", true, 2, 2, 1, 2},
+		{"Wakes up", false, 3, 3, 2, 2},
+		{"Awake again", false, 4, 3, 3, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			iv.asleep = tt.asleep
+			readController(context.Background(), s)
+			if iv.full != tt.full || iv.site != tt.site {
+				t.Errorf("got %d full and %d site requests, want %d and %d", iv.full, iv.site, tt.full, tt.site)
+			}
+			if db.data != tt.data || db.site != tt.siteData {
+				t.Errorf("got %d data and %d site data, want %d and %d", db.data, db.site, tt.data, tt.siteData)
+			}
+		})
+	}
+
+	//Nothing is saved if neither the inverter nor the site can be read
+	iv.asleep, iv.siteError = true, true
+	readController(context.Background(), s)
+	if db.data != 3 || db.site != 2 {
+		t.Errorf("Nothing should be saved, got %d data and %d site data", db.data, db.site)
+	}
+}
+
+func TestReadControllerWithoutInverters(t *testing.T) {
+	//Some firmware leaves out the inverters of the power flow instead of reporting them asleep
+	powerFlow := `{"Body":{"Data":{"Site":{"Meter_Location":"grid","P_Grid":300,"P_Load":-300,"P_PV":null}}},"Head":{"Status":{"Code":0}}}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.RequestURI, "/solar_api/v1/GetPowerFlowRealtimeData.fcgi") {
+			fmt.Fprintln(w, powerFlow)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Could not parse httptest URL")
+	}
+	port, _ := strconv.Atoi(u.Port())
+	db := &countingDatabase{}
+	s := &services{inverter: &inverter.FroniusSymo{IP: net.ParseIP(u.Hostname()), Port: uint16(port)}, database: db, sleep: &sleepState{}}
+
+	for i := 1; i <= 2; i++ {
+		readController(context.Background(), s)
+		if db.data != 0 || db.site != i {
+			t.Errorf("got %d data and %d site data, want 0 and %d", db.data, db.site, i)
+		}
+	}
+}
+
+func TestReadControllerWithoutSiteData(t *testing.T) {
+	db := &countingDatabase{}
+	s := &services{inverter: &testutils.SuccessInverter{}, database: db, sleep: &sleepState{}}
+	readController(context.Background(), s)
+	if db.data != 1 {
+		t.Errorf("got %d data, want 1", db.data)
+	}
+
+	s.inverter = &testutils.ErrorInverter{}
+	readController(context.Background(), s)
+	if db.data != 1 {
+		t.Errorf("got %d data, want 1", db.data)
+	}
+}
+
+//throttledYield suggests the time of the next request
+type throttledYield struct {
+	next time.Time
+}
+
+func (y *throttledYield) RetrieveForecast(ctx context.Context) ([]yield_forecast.Data, error) {
+	return nil, nil
+}
+
+func (y *throttledYield) NextRequest() time.Time { return y.next }
+
+func TestDue(t *testing.T) {
+	now := time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		testName string
+		yield    yield_forecast.GenericYieldForecast
+		due      bool
+	}{
+		{"Not throttled", &yield_forecast.Model{}, true},
+		{"No suggestion", &throttledYield{}, true},
+		{"Suggested later", &throttledYield{now.Add(39 * time.Minute)}, false},
+		{"Suggested now", &throttledYield{now}, true},
+		{"Suggested earlier", &throttledYield{now.Add(-time.Minute)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if ans := due("test", tt.yield, now); ans != tt.due {
+				t.Errorf("got %t, want %t", ans, tt.due)
+			}
+		})
+	}
+}
+
+//staticPrices returns the same prices on every request
+type staticPrices struct {
+	prices []tariff.Price
+}
+
+func (p *staticPrices) RetrievePrices(ctx context.Context) ([]tariff.Price, error) {
+	return p.prices, nil
+}
+
+//priceDatabase keeps the saved prices
+type priceDatabase struct {
+	testutils.SuccessDatabase
+	prices []tariff.Price
+}
+
+func (db *priceDatabase) SendPrices(prices []tariff.Price) { db.prices = append(db.prices, prices...) }
+
+func TestUpdatePrices(t *testing.T) {
+	prices := &staticPrices{[]tariff.Price{{Start: time.Date(2020, time.June, 21, 10, 0, 0, 0, time.UTC), Price: 0.05}}}
+	db := &priceDatabase{}
+	c := &config.Config{}
+	s := &services{config: c, database: db, prices: prices}
+
+	updatePrices(context.Background(), s)
+	if len(db.prices) != 0 {
+		t.Errorf("Prices should only be saved for a dynamic tariff, got %v", db.prices)
+	}
+
+	c.Tariff.Enabled = true
+	c.Tariff.Import.Dynamic = true
+	updatePrices(context.Background(), s)
+	if len(db.prices) != 1 {
+		t.Errorf("got %v, want the market prices", db.prices)
+	}
+
+	s.prices = nil
+	updatePrices(context.Background(), s)
+	if len(db.prices) != 1 {
+		t.Errorf("Without provider nothing should be saved, got %v", db.prices)
+	}
+}
+
+//energyDatabase keeps the saved energy
+type energyDatabase struct {
+	testutils.SuccessDatabase
+	counters []energy.Counters
+}
+
+func (db *energyDatabase) SendEnergy(counters []energy.Counters) {
+	db.counters = append(db.counters, counters...)
+}
+
+func TestAccount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "energy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := &energyDatabase{}
+	s := &services{database: db}
+	var data inverter.Data
+	data.Info.Date = time.Date(2020, time.June, 21, 10, 50, 0, 0, time.UTC)
+	data.Sums.SumPowerPv = 1200
+	account(s, data)
+
+	s.energy = &energy.Accounting{File: filepath.Join(dir, "energy.json"), Location: time.UTC}
+	for _, minutes := range []int{50, 55, 60} {
+		data.Info.Date = time.Date(2020, time.June, 21, 10, minutes, 0, 0, time.UTC)
+		account(s, data)
+	}
+	if len(db.counters) != 1 || db.counters[0].Period != energy.PeriodHour || db.counters[0].Production != 200 {
+		t.Errorf("The hour should be saved once it is completed, got %+v", db.counters)
+	}
+}
@@ -5,21 +5,54 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"solargo/emissions"
+	"solargo/energy"
 	"solargo/inverter"
+	"solargo/tariff"
 	"solargo/weather"
 	"solargo/yield_forecast"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+//MaxPendingWrites kept for a retry if the Influx Database is not reachable, one day of inverter data
+const MaxPendingWrites = 2880
+
+//client of the Influx Database, a hanging database must not block the jobs until the shutdown timeout
+var client = &http.Client{Timeout: 10 * time.Second}
+
 //Influx Database
 type Influx struct {
 	URL          string
 	DatabaseName string
 	User         string
 	Password     string
+	Location     *time.Location // Timezone of the day boundaries, defaults to the one of the system
+
+	mu      sync.Mutex
+	pending []pendingWrite
+}
+
+//pendingWrite could not be saved and is retried with the time it was created at
+type pendingWrite struct {
+	data        string
+	timestamped bool
+	at          time.Time
+}
+
+//lines of the write, lines without timestamp get the time of the write
+func (w pendingWrite) lines() string {
+	if w.timestamped {
+		return w.data
+	}
+	res := ""
+	for _, line := range strings.Split(strings.TrimRight(w.data, "\n"), "\n") {
+		res += fmt.Sprintf("%s %d\n", line, w.at.Unix())
+	}
+	return res
 }
 
 //Converts the inverter data into an Influx query
@@ -62,40 +95,266 @@ func inverterDataToInfluxData(data inverter.Data) string {
 	return res
 }
 
+//Converts the site data into an Influx query, it contains only the values available while the inverter sleeps
+func siteDataToInfluxData(data inverter.Data) string {
+	c := data.Sums
+	cums := fmt.Sprintf("Cummulations SumPowerGrid=%f,SumPowerLoad=%f,SumPowerBattery=%f,SumPowerPV=%f\n",
+		c.SumPowerGrid, c.SumPowerLoad, c.SumPowerBattery, c.SumPowerPv)
+
+	s := data.Service
+	service := fmt.Sprintf("Service MeterLocation=\"%s\",Autonomy=%f,SelfConsumption=%f\n", s.MeterLocation, s.Autonomy, s.SelfConsumption)
+
+	m := data.Meter
+	meter := fmt.Sprintf("Meter Production=%f,ApparentPower=%f,BlindPower=%f,EnergyProduction=%f,EnergyUsed=%f,Feed=%f,Purchase=%f,Usage=%f\n",
+		m.Production, m.ApparentPower, m.BlindPower, m.EnergyProduction, m.EnergyUsed, m.Feed, m.Purchased, m.Used)
+
+	res := cums + service + meter
+	log.Info("Site Data: ", res)
+	return res
+}
+
 func weatherToInfluxData(data weather.Data) string {
-	res := fmt.Sprintf("weather time=\"%s\",location=\"%s\",sunrise=\"%s\",sunset=\"%s\",humidity=%f,temperature=%f,sky_description=\"%s\",wind_speed=%f,cloud_density=%f,wind_direction=%f,rain_amount=%f,snow_amount=%f,pressure=%f\n", data.Date, data.LocationName, data.Sunrise, data.Sunset, data.Humidity, data.Temperature, data.SkyDescription, data.WindSpeed, data.CloudDensity, data.WindDirection, data.RainAmount, data.SnowAmount, data.Pressure)
+	res := fmt.Sprintf("weather time=\"%s\",location=\"%s\",sunrise=\"%s\",sunset=\"%s\",humidity=%f,temperature=%f,sky_description=\"%s\",wind_speed=%f,cloud_density=%f,wind_direction=%f,rain_amount=%f,snow_amount=%f,pressure=%f,rain_last_hour=%f,feels_like=%f,visibility=%f,uv_index=%f\n", data.Date, data.LocationName, data.Sunrise, data.Sunset, data.Humidity, data.Temperature, data.SkyDescription, data.WindSpeed, data.CloudDensity, data.WindDirection, data.RainAmount, data.SnowAmount, data.Pressure, data.RainLastHour, data.FeelsLike, data.Visibility, data.UVIndex)
 
 	log.Info("Weather Data: ", res)
 	return res
 }
 
-func yieldToInfluxData(data []yield_forecast.Data) string {
+//Converts the weather forecast into an Influx query with the start of the periods as timestamps
+func weatherForecastToInfluxData(data []weather.Forecast) string {
 	res := ""
 	for _, d := range data {
-		year, month, day := d.Date.Date()
-		res += fmt.Sprintf("yieldforecast date=\"%d.%d.%d\",current_production=%f,cummulated_production=%f %d\n", day, month, year, d.CurrentProduction, d.CummulatedProduction, d.Date.Unix())
+		res += fmt.Sprintf("weatherforecast period=%d,cloud_density=%f,temperature=%f,pressure=%f,humidity=%f,sky_description=\"%s\",wind_speed=%f,rain_amount=%f,snow_amount=%f,uv_index=%f",
+			int64(d.Period.Seconds()), d.CloudDensity, d.Temperature, d.Pressure, d.Humidity, d.SkyDescription, d.WindSpeed, d.RainAmount, d.SnowAmount, d.UVIndex)
+		if d.HasIrradiance {
+			res += fmt.Sprintf(",irradiance=%f,direct=%f,diffuse=%f", d.Irradiance, d.Direct, d.Diffuse)
+		}
+		res += fmt.Sprintf(" %d\n", d.Date.Unix())
+	}
+	log.Info("Weather Forecast: ", res)
+	return res
+}
+
+//Converts archived inverter data into an Influx query with the original timestamps
+func archiveDataToInfluxData(data []inverter.Data) string {
+	res := ""
+	for _, d := range data {
+		ts := d.Info.Date.Unix()
+		a := d.AC
+		res += fmt.Sprintf("AC Voltage=%f,Current=%f,Power=%f %d\n", a.Voltage, a.Current, a.Power, ts)
+
+		p := d.PV
+		pv := fmt.Sprintf("PV Voltage=%f,Current=%f,Power=%f", p.Voltage, p.Current, p.Power)
+		if p.String1.Voltage != 0 && p.String1.Current != 0 {
+			pv += fmt.Sprintf(",Voltage_String_1=%f,Current_String_1=%f", p.String1.Voltage, p.String1.Current)
+		}
+		if p.String2.Voltage != 0 && p.String2.Current != 0 {
+			pv += fmt.Sprintf(",Voltage_String_2=%f,Current_String_2=%f", p.String2.Voltage, p.String2.Current)
+		}
+		res += fmt.Sprintf("%s %d\n", pv, ts)
+
+		res += fmt.Sprintf("Service Temperature=%f %d\n", d.Service.Temperature, ts)
+
+		//The archive knows only the production, so the chart of the power flow shows it without load and grid
+		res += fmt.Sprintf("Cummulations SumPowerPV=%f %d\n", a.Power, ts)
+	}
+	log.Info("Archive Data: ", res)
+	return res
+}
+
+func yieldToInfluxData(data []yield_forecast.Data, loc *time.Location) string {
+	res := ""
+	for _, d := range data {
+		year, month, day := d.Date.In(loc).Date()
+		res += fmt.Sprintf("yieldforecast date=\"%d.%d.%d\",current_production=%f,cummulated_production=%f,power=%f,period=%d %d\n",
+			day, month, year, d.CurrentProduction, d.CummulatedProduction, d.Power, int64(d.Period.Seconds()), d.Date.Unix())
 	}
 	log.Info("Yield Data: ", res)
 	return res
 }
 
+//tagEscaper escapes the characters of Influx tag values
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+//Converts the forecast of the provider into an Influx query, every period is tagged with its lead time and
+//newer forecasts overwrite older ones of the same period and lead time
+func yieldSnapshotToInfluxData(provider string, issued time.Time, data []yield_forecast.Data) string {
+	res := ""
+	for _, d := range data {
+		lead, ok := yield_forecast.Lead(issued, d.Date, d.Period)
+		if !ok {
+			continue
+		}
+		res += fmt.Sprintf("yieldsnapshot,provider=%s,lead=%s current_production=%f,period=%d,issued=%d %d\n",
+			tagEscaper.Replace(provider), lead, d.CurrentProduction, int64(d.Period.Seconds()), issued.Unix(), d.Date.Unix())
+	}
+	return res
+}
+
+//Converts the accuracy of the provider into an Influx query with the start of the day as timestamp
+func forecastAccuracyToInfluxData(provider, lead string, day time.Time, m yield_forecast.Metrics) string {
+	return fmt.Sprintf("forecastaccuracy,provider=%s,lead=%s samples=%d,mae=%f,rmse=%f,bias=%f %d\n",
+		tagEscaper.Replace(provider), lead, m.Samples, m.MAE, m.RMSE, m.Bias, day.Unix())
+}
+
+//Converts the financials of the day into an Influx query with the start of the day as timestamp
+func financialsToInfluxData(d tariff.Day) string {
+	return fmt.Sprintf("financials purchased=%f,fed_in=%f,self_consumed=%f,import_cost=%f,feed_in_revenue=%f,savings=%f,fixed_costs=%f %d\n",
+		d.Purchased, d.FedIn, d.SelfConsumed, d.ImportCost, d.FeedInRevenue, d.Savings, d.FixedCosts, d.Date.Unix())
+}
+
+//Converts the energy counters into an Influx query tagged with their period, the start is the timestamp
+func energyToInfluxData(counters []energy.Counters) string {
+	res := ""
+	for _, c := range counters {
+		res += fmt.Sprintf("energy,period=%s production=%f,consumption=%f,self_consumption=%f,feed_in=%f,import=%f,covered=%f %d\n",
+			c.Period, c.Production, c.Consumption, c.SelfConsumption, c.FeedIn, c.Import, c.Covered.Seconds(), c.Start.Unix())
+	}
+	return res
+}
+
+//Converts the market prices into an Influx query
+func pricesToInfluxData(prices []tariff.Price) string {
+	res := ""
+	for _, p := range prices {
+		res += fmt.Sprintf("price price=%f %d\n", p.Price, p.Start.Unix())
+	}
+	return res
+}
+
+//timeRange is the condition of a query between from inclusive and to exclusive
+func timeRange(from, to time.Time) string {
+	return fmt.Sprintf(`time >= '%s' and time < '%s'`, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+}
+
+//between is the condition of a query for the tags of the provider and lead time between from and to
+func between(provider, lead string, from, to time.Time) string {
+	quote := strings.NewReplacer(`'`, `\'`)
+	return fmt.Sprintf(`"provider" = '%s' and "lead" = '%s' and time > '%s' and time <= '%s'`,
+		quote.Replace(provider), quote.Replace(lead), from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+}
+
 //SendData to the Influx Database
 func (db *Influx) SendData(data inverter.Data) {
-	_ = db.persist(inverterDataToInfluxData(data))
+	db.send(inverterDataToInfluxData(data), false)
+}
+
+//SendSiteData to the Influx Database
+func (db *Influx) SendSiteData(data inverter.Data) {
+	db.send(siteDataToInfluxData(data), false)
+}
+
+//SendArchiveData with their original timestamps to the Influx Database
+func (db *Influx) SendArchiveData(data []inverter.Data) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return db.persist(archiveDataToInfluxData(data))
 }
 
 //SendWeather to the Influx Database
 func (db *Influx) SendWeather(data weather.Data) {
-	_ = db.persist(weatherToInfluxData(data))
+	db.send(weatherToInfluxData(data), false)
+}
+
+//SendWeatherForecast to the Influx Database, newer forecasts overwrite older ones of the same period
+func (db *Influx) SendWeatherForecast(data []weather.Forecast) {
+	if len(data) == 0 {
+		return
+	}
+	db.send(weatherForecastToInfluxData(data), true)
 }
 
 //SendYieldForecast updates to the Influx Database
 func (db *Influx) SendYieldForecast(data []yield_forecast.Data) {
-	_ = db.persist(yieldToInfluxData(data))
+	db.send(yieldToInfluxData(data, db.location()), true)
+}
+
+//SendYieldSnapshot of the provider to the Influx Database, periods which started already are skipped
+func (db *Influx) SendYieldSnapshot(provider string, issued time.Time, data []yield_forecast.Data) {
+	if lines := yieldSnapshotToInfluxData(provider, issued, data); lines != "" {
+		db.send(lines, true)
+	}
+}
+
+//SendForecastAccuracy of the provider to the Influx Database
+func (db *Influx) SendForecastAccuracy(provider, lead string, day time.Time, metrics yield_forecast.Metrics) {
+	db.send(forecastAccuracyToInfluxData(provider, lead, day, metrics), true)
+}
+
+//SendEnergy of the completed hours and days to the Influx Database
+func (db *Influx) SendEnergy(counters []energy.Counters) {
+	if len(counters) == 0 {
+		return
+	}
+	db.send(energyToInfluxData(counters), true)
+}
+
+//SendFinancials of the day to the Influx Database
+func (db *Influx) SendFinancials(day tariff.Day) {
+	db.send(financialsToInfluxData(day), true)
+}
+
+//SendPrices of the energy market to the Influx Database
+func (db *Influx) SendPrices(prices []tariff.Price) {
+	if len(prices) > 0 {
+		db.send(pricesToInfluxData(prices), true)
+	}
+}
+
+//Flush retries all writes which could not be saved before
+func (db *Influx) Flush() error {
+	db.mu.Lock()
+	pending := db.pending
+	db.pending = nil
+	db.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	data := ""
+	for _, w := range pending {
+		data += w.lines()
+	}
+	if err := db.persist(data); err != nil {
+		//Flushed writes are older than the ones queued in the meantime
+		db.mu.Lock()
+		db.pending = append(pending, db.pending...)
+		db.limitPending()
+		db.mu.Unlock()
+		return fmt.Errorf("Could not flush %d pending writes: %s", len(pending), err)
+	}
+	log.Info("Flushed ", len(pending), " pending writes")
+	return nil
+}
+
+//send the data and queue it on failure, so that it is retried with the next write
+func (db *Influx) send(data string, timestamped bool) {
+	w := pendingWrite{data, timestamped, time.Now()}
+	if err := db.persist(data); err != nil {
+		db.queue(w)
+		return
+	}
+	_ = db.Flush()
+}
+
+func (db *Influx) queue(w pendingWrite) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.pending = append(db.pending, w)
+	db.limitPending()
+}
+
+//limitPending drops the oldest writes, the lock must be held
+func (db *Influx) limitPending() {
+	if dropped := len(db.pending) - MaxPendingWrites; dropped > 0 {
+		log.Warn("Dropped ", dropped, " pending writes")
+		db.pending = db.pending[dropped:]
+	}
 }
 
 func (db *Influx) persist(data string) error {
-	client := &http.Client{}
 	req, err := http.NewRequest("POST", fmt.Sprintf("%s/write?db=%s&precision=s", db.URL, db.DatabaseName), strings.NewReader(data))
 
 	if err != nil {
@@ -120,20 +379,281 @@ func (db *Influx) persist(data string) error {
 		log.Error("Could not save data, because Username or Password is wrong")
 		return fmt.Errorf("Could not save data, because Username or Password is wrong")
 	}
+	//Keep the data pending on every other failure, e.g. while the database restarts
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Error("Could not save data: ", resp.Status)
+		return fmt.Errorf("Could not save data: %s", resp.Status)
+	}
 	return nil
 }
 
+//GetTodaysProduction from the Influx Database
 func (db *Influx) GetTodaysProduction() ([]ProductionStamps, error) {
-	var ps []ProductionStamps
+	series, err := db.queryToday(`SELECT "Power" FROM "AC" WHERE time < now() and time >= '%s'`, 1)
+	if err != nil {
+		return nil, err
+	}
+	return series[0], nil
+}
+
+//GetProduction between from and to from the Influx Database
+func (db *Influx) GetProduction(from, to time.Time) ([]ProductionStamps, error) {
+	series, err := db.query(fmt.Sprintf(`SELECT "Power" FROM "AC" WHERE time >= '%s' and time < '%s'`, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)), 1)
+	if err != nil {
+		return nil, err
+	}
+	return series[0], nil
+}
+
+//GetTodaysPowerFlow from the Influx Database
+func (db *Influx) GetTodaysPowerFlow() (PowerFlow, error) {
+	var flow PowerFlow
+
+	series, err := db.queryToday(`SELECT "SumPowerPV", "SumPowerLoad", "SumPowerGrid", "SumPowerBattery" FROM "Cummulations" WHERE time < now() and time >= '%s'`, 4)
+	if err != nil {
+		return flow, err
+	}
+
+	flow.PV = series[0]
+	flow.Load = series[1]
+	flow.Grid = series[2]
+	flow.Battery = series[3]
+	return flow, nil
+}
+
+//GetTodaysYieldForecast from the Influx Database
+func (db *Influx) GetTodaysYieldForecast() ([]yield_forecast.Data, error) {
+	series, err := db.queryValues(fmt.Sprintf(`SELECT "current_production", "cummulated_production", "power", "period" FROM "yieldforecast" WHERE time < now() + 1d and time >= '%s'`,
+		startOfDay(time.Now(), db.location())), 4)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]yield_forecast.Data, len(series[0]))
+	for i := range data {
+		data[i].Date = series[0][i].Date
+		data[i].CurrentProduction = inverter.WattHour(series[0][i].Value)
+		data[i].CummulatedProduction = inverter.WattHour(series[1][i].Value)
+		data[i].Power = inverter.Watt(series[2][i].Value)
+		data[i].Period = time.Duration(series[3][i].Value) * time.Second
+	}
+	return data, nil
+}
+
+//GetWeatherForecast of the periods starting between from and to from the Influx Database
+func (db *Influx) GetWeatherForecast(from, to time.Time) ([]weather.Forecast, error) {
+	between := fmt.Sprintf(`FROM "weatherforecast" WHERE time >= '%s' and time < '%s'`, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	series, err := db.queryValues(`SELECT "period", "cloud_density", "temperature", "pressure", "humidity", "wind_speed", "rain_amount", "snow_amount", "uv_index" `+between, 9)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]weather.Forecast, len(series[0]))
+	index := map[time.Time]int{}
+	for i := range data {
+		data[i].Date = series[0][i].Date
+		data[i].Period = time.Duration(series[0][i].Value) * time.Second
+		data[i].CloudDensity = series[1][i].Value
+		data[i].Temperature = series[2][i].Value
+		data[i].Pressure = series[3][i].Value
+		data[i].Humidity = series[4][i].Value
+		data[i].WindSpeed = series[5][i].Value
+		data[i].RainAmount = series[6][i].Value
+		data[i].SnowAmount = series[7][i].Value
+		data[i].UVIndex = series[8][i].Value
+		index[data[i].Date] = i
+	}
+
+	//Selecting only the irradiance fields skips the periods without them
+	irradiance, err := db.queryValues(`SELECT "irradiance", "direct", "diffuse" `+between, 3)
+	if err != nil {
+		return nil, err
+	}
+	for j, v := range irradiance[0] {
+		if i, ok := index[v.Date]; ok {
+			data[i].Irradiance = v.Value
+			data[i].Direct = irradiance[1][j].Value
+			data[i].Diffuse = irradiance[2][j].Value
+			data[i].HasIrradiance = true
+		}
+	}
+	return data, nil
+}
+
+//GetYieldSnapshots of the provider and lead time with periods ending after from until to from the Influx Database
+func (db *Influx) GetYieldSnapshots(provider, lead string, from, to time.Time) ([]yield_forecast.Data, error) {
+	series, err := db.queryValues(`SELECT "current_production", "period" FROM "yieldsnapshot" WHERE `+between(provider, lead, from, to), 2)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]yield_forecast.Data, len(series[0]))
+	for i := range data {
+		data[i].Date = series[0][i].Date
+		data[i].CurrentProduction = inverter.WattHour(series[0][i].Value)
+		data[i].Period = time.Duration(series[1][i].Value) * time.Second
+	}
+	return data, nil
+}
+
+//GetForecastAccuracy of the provider and lead time of the days starting after from until to from the Influx Database
+func (db *Influx) GetForecastAccuracy(provider, lead string, from, to time.Time) ([]yield_forecast.Metrics, error) {
+	series, err := db.queryValues(`SELECT "samples", "mae", "rmse", "bias" FROM "forecastaccuracy" WHERE `+between(provider, lead, from, to), 4)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]yield_forecast.Metrics, len(series[0]))
+	for i := range metrics {
+		metrics[i].Samples = int(series[0][i].Value)
+		metrics[i].MAE = series[1][i].Value
+		metrics[i].RMSE = series[2][i].Value
+		metrics[i].Bias = series[3][i].Value
+	}
+	return metrics, nil
+}
+
+//GetMeter values between from and to from the Influx Database
+func (db *Influx) GetMeter(from, to time.Time) (MeterFlow, error) {
+	var flow MeterFlow
+	series, err := db.query(`SELECT "Feed", "Purchase", "Usage" FROM "Meter" WHERE `+timeRange(from, to), 3)
+	if err != nil {
+		return flow, err
+	}
+	flow.Feed = series[0]
+	flow.Purchase = series[1]
+	flow.Usage = series[2]
+	return flow, nil
+}
+
+//GetFinancials of the days starting between from and to from the Influx Database
+func (db *Influx) GetFinancials(from, to time.Time) ([]tariff.Day, error) {
+	series, err := db.queryValues(`SELECT "purchased", "fed_in", "self_consumed", "import_cost", "feed_in_revenue", "savings", "fixed_costs" FROM "financials" WHERE `+timeRange(from, to), 7)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]tariff.Day, len(series[0]))
+	for i := range days {
+		days[i].Date = series[0][i].Date.In(db.location())
+		days[i].Purchased = inverter.WattHour(series[0][i].Value)
+		days[i].FedIn = inverter.WattHour(series[1][i].Value)
+		days[i].SelfConsumed = inverter.WattHour(series[2][i].Value)
+		days[i].ImportCost = series[3][i].Value
+		days[i].FeedInRevenue = series[4][i].Value
+		days[i].Savings = series[5][i].Value
+		days[i].FixedCosts = series[6][i].Value
+	}
+	return days, nil
+}
+
+//GetEnergy of the hours or days starting between from and to from the Influx Database
+func (db *Influx) GetEnergy(period string, from, to time.Time) ([]energy.Counters, error) {
+	series, err := db.queryValues(fmt.Sprintf(`SELECT "production", "consumption", "self_consumption", "feed_in", "import", "covered" FROM "energy" WHERE "period" = '%s' and %s`,
+		strings.Replace(period, `'`, `\'`, -1), timeRange(from, to)), 6)
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make([]energy.Counters, len(series[0]))
+	for i := range counters {
+		c := &counters[i]
+		c.Period = period
+		c.Start = series[0][i].Date.In(db.location())
+		if period == energy.PeriodDay {
+			c.End = c.Start.AddDate(0, 0, 1)
+		} else {
+			c.End = c.Start.Add(time.Hour)
+		}
+		c.Production = inverter.WattHour(series[0][i].Value)
+		c.Consumption = inverter.WattHour(series[1][i].Value)
+		c.SelfConsumption = inverter.WattHour(series[2][i].Value)
+		c.FeedIn = inverter.WattHour(series[3][i].Value)
+		c.Import = inverter.WattHour(series[4][i].Value)
+		c.Covered = time.Duration(series[5][i].Value * float64(time.Second))
+	}
+	return counters, nil
+}
+
+//GetPrices of the energy market of the hours starting between from and to from the Influx Database
+func (db *Influx) GetPrices(from, to time.Time) ([]tariff.Price, error) {
+	series, err := db.queryValues(`SELECT "price" FROM "price" WHERE `+timeRange(from, to), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make([]tariff.Price, len(series[0]))
+	for i, v := range series[0] {
+		prices[i] = tariff.Price{Start: v.Date, Price: v.Value}
+	}
+	return prices, nil
+}
+
+//GetEmissionFactors of the grid of the hours starting between from and to from the Influx Database
+func (db *Influx) GetEmissionFactors(from, to time.Time) ([]emissions.Intensity, error) {
+	series, err := db.queryValues(`SELECT "factor" FROM "emissionfactor" WHERE `+timeRange(from, to), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	intensities := make([]emissions.Intensity, len(series[0]))
+	for i, v := range series[0] {
+		intensities[i] = emissions.Intensity{Start: v.Date, Factor: v.Value}
+	}
+	return intensities, nil
+}
+
+func (db *Influx) location() *time.Location {
+	if db.Location == nil {
+		return time.Local
+	}
+	return db.Location
+}
+
+//startOfDay returns the midnight of the day of t in the timezone as UTC timestamp for a query
+func startOfDay(t time.Time, loc *time.Location) string {
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc).UTC().Format(time.RFC3339)
+}
 
-	year, month, day := time.Now().Date()
-	query := url.QueryEscape(fmt.Sprintf(`SELECT "Power" FROM "AC" WHERE time < now() and time >= '%d-%02d-%02dT00:00:00Z'`, year, month, day))
+//queryToday runs a query whose '%s' placeholder is replaced with the start of today
+//and returns one series of stamps for every selected column
+func (db *Influx) queryToday(statement string, columns int) ([][]ProductionStamps, error) {
+	return db.query(fmt.Sprintf(statement, startOfDay(time.Now(), db.location())), columns)
+}
 
-	uri := fmt.Sprintf("%s/query?db=%s&q=%s", db.URL, db.DatabaseName, query)
-	httpResult, err := http.Get(uri)
-	print(uri)
+//query runs the provided statement and returns one series of stamps for each of the selected columns
+func (db *Influx) query(statement string, columns int) ([][]ProductionStamps, error) {
+	values, err := db.queryValues(statement, columns)
 	if err != nil {
-		return ps, err
+		return nil, err
+	}
+
+	series := make([][]ProductionStamps, columns)
+	for c, column := range values {
+		if column == nil {
+			continue
+		}
+		series[c] = make([]ProductionStamps, len(column))
+		for i, v := range column {
+			series[c][i] = ProductionStamps{Date: v.Date, Value: inverter.Watt(v.Value)}
+		}
+	}
+	return series, nil
+}
+
+//stamp of a plain value like a price or a temperature at a given time
+type stamp struct {
+	Date  time.Time
+	Value float64
+}
+
+//queryValues runs the provided statement and returns one series of plain values for each of the selected columns
+func (db *Influx) queryValues(statement string, columns int) ([][]stamp, error) {
+	uri := fmt.Sprintf("%s/query?db=%s&q=%s", db.URL, db.DatabaseName, url.QueryEscape(statement))
+	httpResult, err := client.Get(uri)
+	if err != nil {
+		return nil, err
 	}
 
 	defer httpResult.Body.Close()
@@ -141,27 +661,46 @@ func (db *Influx) GetTodaysProduction() ([]ProductionStamps, error) {
 	type Result struct {
 		Results []struct {
 			Series []struct {
-				Values [][]interface{} `json:"values"`
+				Columns []string        `json:"columns"`
+				Values  [][]interface{} `json:"values"`
 			} `json:"series"`
+			Error string `json:"error"`
 		} `json:"results"`
 	}
 
 	var result Result
 	err = json.NewDecoder(httpResult.Body).Decode(&result)
 	if err != nil {
-		return ps, fmt.Errorf("Error: %s", err)
+		return nil, fmt.Errorf("Error: %s", err)
 	}
 
-	//Create array of appropriate size
-	log.Warn("result", result)
+	if len(result.Results) > 0 && result.Results[0].Error != "" {
+		return nil, fmt.Errorf("Error: %s", result.Results[0].Error)
+	}
+
+	series := make([][]stamp, columns)
 
-	ps = make([]ProductionStamps, len(result.Results[0].Series[0].Values))
+	//An empty result means there is no data in the requested range
+	if len(result.Results) == 0 || len(result.Results[0].Series) == 0 {
+		return series, nil
+	}
+
+	values := result.Results[0].Series[0].Values
+	for c := range series {
+		series[c] = make([]stamp, len(values))
+	}
 
 	//Parse the values
-	for idx, v := range result.Results[0].Series[0].Values {
+	for idx, v := range values {
 		t, _ := time.Parse(time.RFC3339, v[0].(string))
-		ps[idx].Date = t
-		ps[idx].Value = inverter.WattHour(v[1].(float64))
+		for c := range series {
+			series[c][idx].Date = t
+			if c+1 < len(v) {
+				if f, ok := v[c+1].(float64); ok {
+					series[c][idx].Value = f
+				}
+			}
+		}
 	}
-	return ps, nil
+	return series, nil
 }
@@ -6,9 +6,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"solargo/emissions"
+	"solargo/energy"
 	"solargo/inverter"
+	"solargo/tariff"
 	"solargo/weather"
 	"solargo/yield_forecast"
+	"strings"
 	"testing"
 	"time"
 )
@@ -22,15 +26,32 @@ Cummulations ProductionToday=20.000000,ProductionTotal=21.000000,ProductionYear=
 Meter Production=30.000000,ApparentPower=32.000000,BlindPower=33.000000,EnergyProduction=34.000000,EnergyUsed=35.000000,Feed=36.000000,Purchase=37.000000,Usage=38.000000
 `
 
-var wantedWeatherString = `weather time="2009-11-10 23:00:00 +0000 UTC",location="XXX",sunrise="2010-11-10 23:00:00 +0000 UTC",sunset="2011-11-10 23:00:00 +0000 UTC",humidity=66.000000,temperature=14.170000,sky_description="Really nice sky. Color is blue?!?",wind_speed=1.350000,cloud_density=0.000000,wind_direction=3.000000,rain_amount=2.000000,snow_amount=1.000000,pressure=1024.000000
+var wantedWeatherString = `weather time="2009-11-10 23:00:00 +0000 UTC",location="XXX",sunrise="2010-11-10 23:00:00 +0000 UTC",sunset="2011-11-10 23:00:00 +0000 UTC",humidity=66.000000,temperature=14.170000,sky_description="Really nice sky. Color is blue?!?",wind_speed=1.350000,cloud_density=0.000000,wind_direction=3.000000,rain_amount=2.000000,snow_amount=1.000000,pressure=1024.000000,rain_last_hour=0.500000,feels_like=12.740000,visibility=10000.000000,uv_index=1.500000
 `
 
-var wantedYieldForecastString = `yieldforecast date="10.11.2009",current_production=0.000000,cummulated_production=1.000000 1257894000
-yieldforecast date="10.11.2010",current_production=2.000000,cummulated_production=3.000000 1289430000
+var wantedYieldForecastString = `yieldforecast date="10.11.2009",current_production=0.000000,cummulated_production=1.000000,power=0.000000,period=0 1257894000
+yieldforecast date="10.11.2010",current_production=2.000000,cummulated_production=3.000000,power=4.000000,period=900 1289430000
 `
 
 var validProduction = `{"results":[{"statement_id":0,"series":[{"name":"AC","columns":["time","cumulative_sum"],"values":[["2020-11-21T12:32:00Z",3.3],["2020-11-21T12:33:00Z",4.4],["2020-11-21T12:34:00Z",5.5],["2020-11-21T12:35:00Z",6.6]]}]}]}`
 
+var validPowerFlow = `{"results":[{"statement_id":0,"series":[{"name":"Cummulations","columns":["time","SumPowerPV","SumPowerLoad","SumPowerGrid","SumPowerBattery"],"values":[["2020-11-21T12:32:00Z",1000,-400,-600,null],["2020-11-21T12:33:00Z",1100,-300,-800,0]]}]}]}`
+
+var validYieldForecast = `{"results":[{"statement_id":0,"series":[{"name":"yieldforecast","columns":["time","current_production","cummulated_production","power","period"],"values":[["2020-11-21T12:00:00Z",500,1500,null,null],["2020-11-21T13:00:00Z",175,2200,720,900]]}]}]}`
+
+var wantedWeatherForecastString = `weatherforecast period=10800,cloud_density=90.000000,temperature=4.500000,pressure=1030.000000,humidity=80.000000,sky_description="overcast clouds",wind_speed=2.100000,rain_amount=0.500000,snow_amount=0.000000,uv_index=0.800000 1606132800
+weatherforecast period=3600,cloud_density=20.000000,temperature=3.200000,pressure=1031.000000,humidity=85.000000,sky_description="",wind_speed=1.000000,rain_amount=0.000000,snow_amount=1.500000,uv_index=0.000000,irradiance=250.000000,direct=100.000000,diffuse=150.000000 1606143600
+`
+
+var validWeatherForecast = `{"results":[{"statement_id":0,"series":[{"name":"weatherforecast","columns":["time","period","cloud_density","temperature","pressure","humidity","wind_speed","rain_amount","snow_amount","uv_index"],"values":[["2020-11-23T12:00:00Z",10800,90,4.5,1030,80,2.1,0.5,0,0.8],["2020-11-23T15:00:00Z",3600,20,3.2,1031,85,1,0,1.5,0]]}]}]}`
+
+var validIrradiance = `{"results":[{"statement_id":0,"series":[{"name":"weatherforecast","columns":["time","irradiance","direct","diffuse"],"values":[["2020-11-23T15:00:00Z",250,100,150]]}]}]}`
+
+var sampleWeatherForecast = []weather.Forecast{
+	{Date: time.Date(2020, time.November, 23, 12, 0, 0, 0, time.UTC), Period: 3 * time.Hour, CloudDensity: 90, Temperature: 4.5, Pressure: 1030, Humidity: 80, SkyDescription: "overcast clouds", WindSpeed: 2.1, RainAmount: 0.5, UVIndex: 0.8},
+	{Date: time.Date(2020, time.November, 23, 15, 0, 0, 0, time.UTC), Period: time.Hour, CloudDensity: 20, Temperature: 3.2, Pressure: 1031, Humidity: 85, WindSpeed: 1, SnowAmount: 1.5, Irradiance: 250, Direct: 100, Diffuse: 150, HasIrradiance: true},
+}
+
 var sampleWeather = weather.Data{
 	LocationName:   "XXX",
 	Date:           time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC),
@@ -45,6 +66,10 @@ var sampleWeather = weather.Data{
 	RainAmount:     2.0,
 	SnowAmount:     1.0,
 	Pressure:       1024,
+	RainLastHour:   0.5,
+	FeelsLike:      12.74,
+	Visibility:     10000,
+	UVIndex:        1.5,
 }
 
 func getSampleInverterData() inverter.Data {
@@ -107,16 +132,18 @@ func getSampleYieldForecastData() []yield_forecast.Data {
 	data[1].Date = time.Date(2010, time.November, 10, 23, 0, 0, 0, time.UTC)
 	data[1].CurrentProduction = inverter.WattHour(2)
 	data[1].CummulatedProduction = inverter.WattHour(3)
+	data[1].Power = inverter.Watt(4)
+	data[1].Period = 15 * time.Minute
 	return data[:]
 }
 
-func influxFromURL(url string) Influx {
+func influxFromURL(url string) *Influx {
 	var db Influx
 	db.URL = url
 	db.DatabaseName = "dbname"
 	db.User = "user"
 	db.Password = "pw"
-	return db
+	return &db
 }
 
 func TestInverterDataToInfluxData(t *testing.T) {
@@ -328,3 +355,524 @@ func TestRetrieveProduction(t *testing.T) {
 		}
 	}
 }
+
+func TestRetrievePowerFlow(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, validPowerFlow)
+	}))
+	defer ts.Close()
+
+	db := influxFromURL(ts.URL)
+	actual, err := db.GetTodaysPowerFlow()
+
+	if err != nil {
+		t.Fatalf("RetrievePowerFlow should not produce error %s", err)
+	}
+
+	d1, _ := time.Parse(time.RFC3339, "2020-11-21T12:32:00Z")
+	d2, _ := time.Parse(time.RFC3339, "2020-11-21T12:33:00Z")
+	expected := PowerFlow{
+		PV:      []ProductionStamps{{d1, 1000}, {d2, 1100}},
+		Load:    []ProductionStamps{{d1, -400}, {d2, -300}},
+		Grid:    []ProductionStamps{{d1, -600}, {d2, -800}},
+		Battery: []ProductionStamps{{d1, 0}, {d2, 0}},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Error actual = %v\n, and expected = %v\n.", actual, expected)
+	}
+}
+
+func TestRetrieveYieldForecast(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, validYieldForecast)
+	}))
+	defer ts.Close()
+
+	db := influxFromURL(ts.URL)
+	actual, err := db.GetTodaysYieldForecast()
+
+	if err != nil {
+		t.Fatalf("RetrieveYieldForecast should not produce error %s", err)
+	}
+
+	d1, _ := time.Parse(time.RFC3339, "2020-11-21T12:00:00Z")
+	d2, _ := time.Parse(time.RFC3339, "2020-11-21T13:00:00Z")
+	expected := []yield_forecast.Data{
+		{Date: d1, CurrentProduction: 500, CummulatedProduction: 1500},
+		{Date: d2, CurrentProduction: 175, CummulatedProduction: 2200, Power: 720, Period: 15 * time.Minute},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Error actual = %v\n, and expected = %v\n.", actual, expected)
+	}
+}
+
+func TestRetrieveEmptyAndErroneousResults(t *testing.T) {
+	var tests = []struct {
+		testName string
+		response string
+		errors   bool
+	}{
+		{"Empty", `{"results":[{"statement_id":0}]}`, false},
+		{"Influx Error", `{"results":[{"statement_id":0,"error":"database not found: dbname"}]}`, true},
+		{"Not JSON", `{not a json`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, tt.response)
+			}))
+			defer ts.Close()
+
+			db := influxFromURL(ts.URL)
+			ps, err := db.GetTodaysProduction()
+			if (err != nil) != tt.errors {
+				t.Fatalf("Unexpected error state %v", err)
+			}
+			if len(ps) != 0 {
+				t.Errorf("Expected no production stamps, got %v", ps)
+			}
+		})
+	}
+}
+
+func TestArchiveDataToInfluxData(t *testing.T) {
+	var first, second inverter.Data
+	first.Info.Date = time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	first.AC.Voltage = 1.0
+	first.AC.Current = 2.0
+	first.AC.Power = 3.0
+	first.PV.Voltage = 4.0
+	first.PV.Current = 5.0
+	first.PV.Power = 6.0
+	first.PV.String1.Voltage = 4.0
+	first.PV.String1.Current = 5.0
+	first.Service.Temperature = 7.0
+	second.Info.Date = first.Info.Date.Add(5 * time.Minute)
+
+	want := `AC Voltage=1.000000,Current=2.000000,Power=3.000000 1257894000
+PV Voltage=4.000000,Current=5.000000,Power=6.000000,Voltage_String_1=4.000000,Current_String_1=5.000000 1257894000
+Service Temperature=7.000000 1257894000
+Cummulations SumPowerPV=3.000000 1257894000
+AC Voltage=0.000000,Current=0.000000,Power=0.000000 1257894300
+PV Voltage=0.000000,Current=0.000000,Power=0.000000 1257894300
+Service Temperature=0.000000 1257894300
+Cummulations SumPowerPV=0.000000 1257894300
+`
+
+	if ans := archiveDataToInfluxData([]inverter.Data{first, second}); ans != want {
+		t.Errorf("got %s, want %s", ans, want)
+	}
+}
+
+func TestArchiveDataInPowerFlow(t *testing.T) {
+	//The server answers the query of the power flow with the written Cummulations
+	var values []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/write" {
+			body, _ := ioutil.ReadAll(r.Body)
+			for _, line := range strings.Split(string(body), "\n") {
+				var power float64
+				var stamp int64
+				if _, err := fmt.Sscanf(line, "Cummulations SumPowerPV=%f %d", &power, &stamp); err == nil {
+					values = append(values, fmt.Sprintf(`["%s",%g,null,null,null]`, time.Unix(stamp, 0).UTC().Format(time.RFC3339), power))
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprintf(w, `{"results":[{"statement_id":0,"series":[{"name":"Cummulations","columns":["time","SumPowerPV","SumPowerLoad","SumPowerGrid","SumPowerBattery"],"values":[%s]}]}]}`, strings.Join(values, ","))
+	}))
+	defer ts.Close()
+
+	start := time.Now().Truncate(time.Hour).UTC()
+	data := make([]inverter.Data, 2)
+	for i := range data {
+		data[i].Info.Date = start.Add(time.Duration(i) * 5 * time.Minute)
+		data[i].AC.Power = inverter.Watt(1000 * (i + 1))
+	}
+
+	db := influxFromURL(ts.URL)
+	if err := db.SendArchiveData(data); err != nil {
+		t.Fatalf("SendArchiveData should not produce error %s", err)
+	}
+	flow, err := db.GetTodaysPowerFlow()
+	if err != nil {
+		t.Fatalf("GetTodaysPowerFlow should not produce error %s", err)
+	}
+
+	want := []ProductionStamps{{data[0].Info.Date, 1000}, {data[1].Info.Date, 2000}}
+	if !reflect.DeepEqual(flow.PV, want) {
+		t.Errorf("got PV %v, want %v", flow.PV, want)
+	}
+	if len(flow.Load) != 2 || flow.Load[0].Value != 0 {
+		t.Errorf("got load %v, want no load", flow.Load)
+	}
+}
+
+func TestRetrieveProductionRange(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := `SELECT "Power" FROM "AC" WHERE time >= '2020-11-20T23:00:00Z' and time < '2020-11-21T23:00:00Z'`
+		if q := r.URL.Query().Get("q"); q != want {
+			t.Errorf("got query %s, want %s", q, want)
+		}
+		fmt.Fprintln(w, validProduction)
+	}))
+	defer ts.Close()
+
+	loc := time.FixedZone("CET", 3600)
+	db := influxFromURL(ts.URL)
+	actual, err := db.GetProduction(time.Date(2020, time.November, 21, 0, 0, 0, 0, loc), time.Date(2020, time.November, 22, 0, 0, 0, 0, loc))
+
+	if err != nil {
+		t.Fatalf("RetrieveProduction should not produce error %s", err)
+	}
+	if len(actual) != 4 {
+		t.Errorf("Expected 4 production stamps, got %v", actual)
+	}
+}
+
+func TestPendingWrites(t *testing.T) {
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bodyBytes, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(bodyBytes))
+		if len(bodies) == 1 {
+			panic("HTTP ERROR")
+		}
+	}))
+	defer ts.Close()
+
+	db := influxFromURL(ts.URL)
+	db.SendWeather(sampleWeather)
+	if len(db.pending) != 1 {
+		t.Fatalf("Failed write should be pending, got %v", db.pending)
+	}
+	at := db.pending[0].at.Unix()
+
+	db.SendYieldForecast(getSampleYieldForecastData())
+	if len(db.pending) != 0 {
+		t.Errorf("Pending writes should be flushed, got %v", db.pending)
+	}
+
+	want := strings.TrimRight(wantedWeatherString, "\n") + fmt.Sprintf(" %d\n", at)
+	if len(bodies) != 3 || bodies[2] != want {
+		t.Errorf("got %q, want %q", bodies, want)
+	}
+	if err := db.Flush(); err != nil {
+		t.Errorf("Flush without pending writes should not produce error %s", err)
+	}
+}
+
+func TestPendingWritesServerError(t *testing.T) {
+	status := http.StatusInternalServerError
+	writes := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writes++
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	db := influxFromURL(ts.URL)
+	db.SendWeather(sampleWeather)
+	if len(db.pending) != 1 {
+		t.Fatalf("Write with a server error should be pending, got %v", db.pending)
+	}
+	if err := db.Flush(); err == nil || len(db.pending) != 1 {
+		t.Errorf("Flush with a server error should fail and keep the write, got %v and %v", err, db.pending)
+	}
+
+	status = http.StatusNoContent
+	if err := db.Flush(); err != nil || len(db.pending) != 0 {
+		t.Errorf("Flush should write the pending data, got %v and %v", err, db.pending)
+	}
+	if writes != 3 {
+		t.Errorf("got %d writes, want 3", writes)
+	}
+}
+
+func TestPendingWritesLimit(t *testing.T) {
+	db := influxFromURL("http://127.0.0.1:0")
+	for i := 0; i < MaxPendingWrites+5; i++ {
+		db.queue(pendingWrite{fmt.Sprint(i), true, time.Now()})
+	}
+	if len(db.pending) != MaxPendingWrites || db.pending[0].data != "5" {
+		t.Errorf("Oldest writes should be dropped, got %d writes starting with %s", len(db.pending), db.pending[0].data)
+	}
+	if err := db.Flush(); err == nil || !strings.HasPrefix(err.Error(), fmt.Sprintf("Could not flush %d pending writes", MaxPendingWrites)) {
+		t.Errorf("Expected flush error, got %v", err)
+	}
+	if len(db.pending) != MaxPendingWrites {
+		t.Errorf("Failed flush should keep the writes, got %d", len(db.pending))
+	}
+}
+
+func TestSiteDataToInfluxData(t *testing.T) {
+	want := `Cummulations SumPowerGrid=26.000000,SumPowerLoad=27.000000,SumPowerBattery=28.000000,SumPowerPV=29.000000
+Service MeterLocation="unknown",Autonomy=16.000000,SelfConsumption=17.000000
+Meter Production=30.000000,ApparentPower=32.000000,BlindPower=33.000000,EnergyProduction=34.000000,EnergyUsed=35.000000,Feed=36.000000,Purchase=37.000000,Usage=38.000000
+`
+	if ans := siteDataToInfluxData(getSampleInverterData()); ans != want {
+		t.Errorf("got %s, want %s", ans, want)
+	}
+}
+
+func TestStartOfDay(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Fatalf("Could not load timezone: %s", err)
+	}
+
+	var tests = []struct {
+		testName string
+		t        time.Time
+		loc      *time.Location
+		want     string
+	}{
+		{"UTC", time.Date(2020, time.November, 21, 0, 30, 0, 0, time.UTC), time.UTC, "2020-11-21T00:00:00Z"},
+		{"Winter", time.Date(2020, time.November, 21, 12, 0, 0, 0, vienna), vienna, "2020-11-20T23:00:00Z"},
+		{"Summer", time.Date(2020, time.June, 21, 12, 0, 0, 0, vienna), vienna, "2020-06-20T22:00:00Z"},
+		{"Local day differs from UTC day", time.Date(2020, time.June, 20, 22, 30, 0, 0, time.UTC), vienna, "2020-06-20T22:00:00Z"},
+		{"DST starts", time.Date(2020, time.March, 29, 12, 0, 0, 0, vienna), vienna, "2020-03-28T23:00:00Z"},
+		{"DST ends", time.Date(2020, time.October, 25, 12, 0, 0, 0, vienna), vienna, "2020-10-24T22:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if ans := startOfDay(tt.t, tt.loc); ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestYieldToInfluxDataLocation(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Fatalf("Could not load timezone: %s", err)
+	}
+
+	//23:30 UTC is already the next day in Vienna
+	data := []yield_forecast.Data{{Date: time.Date(2020, time.June, 20, 23, 30, 0, 0, time.UTC)}}
+	want := "yieldforecast date=\"21.6.2020\",current_production=0.000000,cummulated_production=0.000000,power=0.000000,period=0 1592695800\n"
+	if ans := yieldToInfluxData(data, vienna); ans != want {
+		t.Errorf("got %s, want %s", ans, want)
+	}
+}
+
+func TestWeatherForecastToInfluxData(t *testing.T) {
+	if ans := weatherForecastToInfluxData(sampleWeatherForecast); ans != wantedWeatherForecastString {
+		t.Errorf("Error actual = %v, and expected = %v.", ans, wantedWeatherForecastString)
+	}
+}
+
+func TestRetrieveWeatherForecast(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Query().Get("q"), `SELECT "irradiance", "direct"`) {
+			fmt.Fprintln(w, validIrradiance)
+			return
+		}
+		fmt.Fprintln(w, validWeatherForecast)
+	}))
+	defer ts.Close()
+
+	db := influxFromURL(ts.URL)
+	actual, err := db.GetWeatherForecast(time.Date(2020, time.November, 23, 0, 0, 0, 0, time.UTC), time.Date(2020, time.November, 24, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetWeatherForecast should not produce error %s", err)
+	}
+
+	//The sky description is not read back
+	expected := make([]weather.Forecast, len(sampleWeatherForecast))
+	copy(expected, sampleWeatherForecast)
+	expected[0].SkyDescription = ""
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Error actual = %v\n, and expected = %v\n.", actual, expected)
+	}
+}
+
+func TestYieldSnapshotToInfluxData(t *testing.T) {
+	issued := time.Date(2020, time.June, 21, 10, 15, 0, 0, time.UTC)
+	data := []yield_forecast.Data{
+		{Date: time.Date(2020, time.June, 21, 11, 0, 0, 0, time.UTC), CurrentProduction: 500},
+		{Date: time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC), CurrentProduction: 600},
+		{Date: time.Date(2020, time.June, 22, 12, 0, 0, 0, time.UTC), CurrentProduction: 700, Period: 30 * time.Minute},
+		{Date: time.Date(2020, time.July, 22, 12, 0, 0, 0, time.UTC), CurrentProduction: 800},
+	}
+
+	//The period which started already and the one too far ahead are skipped
+	want := "yieldsnapshot,provider=solarprognose/own-v1,lead=6h current_production=600.000000,period=0,issued=1592734500 1592740800\n" +
+		"yieldsnapshot,provider=solarprognose/own-v1,lead=48h current_production=700.000000,period=1800,issued=1592734500 1592827200\n"
+	if ans := yieldSnapshotToInfluxData("solarprognose/own-v1", issued, data); ans != want {
+		t.Errorf("got %s, want %s", ans, want)
+	}
+
+	m := yield_forecast.Metrics{Samples: 12, MAE: 100, RMSE: 150, Bias: -20}
+	want = "forecastaccuracy,provider=my\\ model,lead=24h samples=12,mae=100.000000,rmse=150.000000,bias=-20.000000 1592690400\n"
+	if ans := forecastAccuracyToInfluxData("my model", "24h", time.Date(2020, time.June, 21, 0, 0, 0, 0, time.FixedZone("CEST", 2*3600)), m); ans != want {
+		t.Errorf("got %s, want %s", ans, want)
+	}
+}
+
+func TestRetrieveForecastAccuracy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		if !strings.Contains(q, `"provider" = 'forecast.solar' and "lead" = '24h' and time > '2020-06-20T00:00:00Z' and time <= '2020-06-21T00:00:00Z'`) {
+			t.Errorf("Unexpected query %s", q)
+		}
+		if strings.Contains(q, "yieldsnapshot") {
+			fmt.Fprintln(w, `{"results":[{"statement_id":0,"series":[{"name":"yieldsnapshot","columns":["time","current_production","period"],"values":[["2020-06-20T12:00:00Z",500,0],["2020-06-20T13:00:00Z",175,1800]]}]}]}`)
+			return
+		}
+		fmt.Fprintln(w, `{"results":[{"statement_id":0,"series":[{"name":"forecastaccuracy","columns":["time","samples","mae","rmse","bias"],"values":[["2020-06-20T00:00:00Z",12,100,150,-20]]}]}]}`)
+	}))
+	defer ts.Close()
+
+	db := influxFromURL(ts.URL)
+	from, to := time.Date(2020, time.June, 20, 0, 0, 0, 0, time.UTC), time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	snapshots, err := db.GetYieldSnapshots("forecast.solar", "24h", from, to)
+	if err != nil {
+		t.Fatalf("GetYieldSnapshots should not produce error %s", err)
+	}
+	wantSnapshots := []yield_forecast.Data{
+		{Date: time.Date(2020, time.June, 20, 12, 0, 0, 0, time.UTC), CurrentProduction: 500},
+		{Date: time.Date(2020, time.June, 20, 13, 0, 0, 0, time.UTC), CurrentProduction: 175, Period: 30 * time.Minute},
+	}
+	if !reflect.DeepEqual(snapshots, wantSnapshots) {
+		t.Errorf("got %v, want %v", snapshots, wantSnapshots)
+	}
+
+	metrics, err := db.GetForecastAccuracy("forecast.solar", "24h", from, to)
+	if err != nil {
+		t.Fatalf("GetForecastAccuracy should not produce error %s", err)
+	}
+	wantMetrics := []yield_forecast.Metrics{{Samples: 12, MAE: 100, RMSE: 150, Bias: -20}}
+	if !reflect.DeepEqual(metrics, wantMetrics) {
+		t.Errorf("got %v, want %v", metrics, wantMetrics)
+	}
+}
+
+func TestFinancialsToInfluxData(t *testing.T) {
+	day := tariff.Day{
+		Date:          time.Date(2020, time.June, 21, 0, 0, 0, 0, time.FixedZone("CEST", 2*3600)),
+		Purchased:     1200,
+		FedIn:         2000,
+		SelfConsumed:  1000,
+		ImportCost:    0.26,
+		FeedInRevenue: 0.16,
+		Savings:       0.3,
+		FixedCosts:    1,
+	}
+	want := "financials purchased=1200.000000,fed_in=2000.000000,self_consumed=1000.000000,import_cost=0.260000,feed_in_revenue=0.160000,savings=0.300000,fixed_costs=1.000000 1592690400\n"
+	if ans := financialsToInfluxData(day); ans != want {
+		t.Errorf("got %s, want %s", ans, want)
+	}
+
+	prices := []tariff.Price{{Start: time.Date(2020, time.June, 21, 10, 0, 0, 0, time.UTC), Price: 0.05}, {Start: time.Date(2020, time.June, 21, 11, 0, 0, 0, time.UTC), Price: -0.012}}
+	want = "price price=0.050000 1592733600\nprice price=-0.012000 1592737200\n"
+	if ans := pricesToInfluxData(prices); ans != want {
+		t.Errorf("got %s, want %s", ans, want)
+	}
+}
+
+func TestRetrieveMeterFinancialsAndPrices(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		if !strings.Contains(q, `time >= '2020-06-20T22:00:00Z' and time < '2020-06-21T22:00:00Z'`) {
+			t.Errorf("Unexpected query %s", q)
+		}
+		switch {
+		case strings.Contains(q, `FROM "Meter"`):
+			fmt.Fprintln(w, `{"results":[{"statement_id":0,"series":[{"name":"Meter","columns":["time","Feed","Purchase","Usage"],"values":[["2020-06-21T10:00:00Z",2000,0,500],["2020-06-21T20:00:00Z",0,300,300]]}]}]}`)
+		case strings.Contains(q, `FROM "financials"`):
+			fmt.Fprintln(w, `{"results":[{"statement_id":0,"series":[{"name":"financials","columns":["time","purchased","fed_in","self_consumed","import_cost","feed_in_revenue","savings","fixed_costs"],"values":[["2020-06-20T22:00:00Z",1200,2000,1000,0.26,0.16,0.3,1]]}]}]}`)
+		case strings.Contains(q, `FROM "emissionfactor"`):
+			fmt.Fprintln(w, `{"results":[{"statement_id":0,"series":[{"name":"emissionfactor","columns":["time","factor"],"values":[["2020-06-21T10:00:00Z",380]]}]}]}`)
+		default:
+			fmt.Fprintln(w, `{"results":[{"statement_id":0,"series":[{"name":"price","columns":["time","price"],"values":[["2020-06-21T10:00:00Z",0.05]]}]}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	db := influxFromURL(ts.URL)
+	db.Location = time.FixedZone("CEST", 2*3600)
+	from := time.Date(2020, time.June, 21, 0, 0, 0, 0, db.Location)
+	to := from.AddDate(0, 0, 1)
+
+	meter, err := db.GetMeter(from, to)
+	if err != nil {
+		t.Fatalf("GetMeter should not produce error %s", err)
+	}
+	noon, evening := time.Date(2020, time.June, 21, 10, 0, 0, 0, time.UTC), time.Date(2020, time.June, 21, 20, 0, 0, 0, time.UTC)
+	wantMeter := MeterFlow{
+		Feed:     []ProductionStamps{{noon, 2000}, {evening, 0}},
+		Purchase: []ProductionStamps{{noon, 0}, {evening, 300}},
+		Usage:    []ProductionStamps{{noon, 500}, {evening, 300}},
+	}
+	if !reflect.DeepEqual(meter, wantMeter) {
+		t.Errorf("got %v, want %v", meter, wantMeter)
+	}
+
+	days, err := db.GetFinancials(from, to)
+	if err != nil {
+		t.Fatalf("GetFinancials should not produce error %s", err)
+	}
+	if len(days) != 1 || !days[0].Date.Equal(from) || days[0].Date.Location() != db.Location || days[0].Purchased != 1200 || days[0].Savings != 0.3 || days[0].FixedCosts != 1 {
+		t.Errorf("got %+v", days)
+	}
+
+	prices, err := db.GetPrices(from, to)
+	if err != nil {
+		t.Fatalf("GetPrices should not produce error %s", err)
+	}
+	if want := []tariff.Price{{Start: noon, Price: 0.05}}; !reflect.DeepEqual(prices, want) {
+		t.Errorf("got %v, want %v", prices, want)
+	}
+
+	intensities, err := db.GetEmissionFactors(from, to)
+	if err != nil {
+		t.Fatalf("GetEmissionFactors should not produce error %s", err)
+	}
+	if want := []emissions.Intensity{{Start: noon, Factor: 380}}; !reflect.DeepEqual(intensities, want) {
+		t.Errorf("got %v, want %v", intensities, want)
+	}
+}
+
+func TestEnergyToInfluxData(t *testing.T) {
+	start := time.Date(2020, time.June, 21, 10, 0, 0, 0, time.UTC)
+	counters := []energy.Counters{
+		{Period: energy.PeriodHour, Start: start, End: start.Add(time.Hour), Production: 2500, Consumption: 800, SelfConsumption: 700, FeedIn: 1800, Import: 100, Covered: time.Hour},
+		{Period: energy.PeriodDay, Start: start.Add(-12 * time.Hour), Production: 20000, Covered: 90 * time.Minute},
+	}
+	want := "energy,period=hour production=2500.000000,consumption=800.000000,self_consumption=700.000000,feed_in=1800.000000,import=100.000000,covered=3600.000000 1592733600\n" +
+		"energy,period=day production=20000.000000,consumption=0.000000,self_consumption=0.000000,feed_in=0.000000,import=0.000000,covered=5400.000000 1592690400\n"
+	if ans := energyToInfluxData(counters); ans != want {
+		t.Errorf("got %s, want %s", ans, want)
+	}
+}
+
+func TestRetrieveEnergy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		if !strings.Contains(q, `FROM "energy" WHERE "period" = 'day' and time >= '2020-06-20T22:00:00Z' and time < '2020-06-21T22:00:00Z'`) {
+			t.Errorf("Unexpected query %s", q)
+		}
+		fmt.Fprintln(w, `{"results":[{"statement_id":0,"series":[{"name":"energy","columns":["time","production","consumption","self_consumption","feed_in","import","covered"],"values":[["2020-06-20T22:00:00Z",20000,9000,6000,14000,3000,86400]]}]}]}`)
+	}))
+	defer ts.Close()
+
+	db := influxFromURL(ts.URL)
+	db.Location = time.FixedZone("CEST", 2*3600)
+	from := time.Date(2020, time.June, 21, 0, 0, 0, 0, db.Location)
+
+	days, err := db.GetEnergy(energy.PeriodDay, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("GetEnergy should not produce error %s", err)
+	}
+	want := energy.Counters{Period: energy.PeriodDay, Start: from, End: from.AddDate(0, 0, 1), Production: 20000, Consumption: 9000, SelfConsumption: 6000, FeedIn: 14000, Import: 3000, Covered: 24 * time.Hour}
+	if len(days) != 1 || !reflect.DeepEqual(days[0], want) || !days[0].Complete() {
+		t.Errorf("got %+v, want %+v", days, want)
+	}
+}
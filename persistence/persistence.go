@@ -1,16 +1,37 @@
 package persistence
 
 import (
+	"solargo/emissions"
+	"solargo/energy"
 	"solargo/inverter"
+	"solargo/tariff"
 	"solargo/weather"
 	"solargo/yield_forecast"
 	"time"
 )
 
+//MinCoverage of an hour by the recorded production, see Hourly
+const MinCoverage = 45 * time.Minute
+
 //ProductionStamps contains a solar production at a given time
 type ProductionStamps struct {
 	Date  time.Time
-	Value inverter.WattHour
+	Value inverter.Watt
+}
+
+//PowerFlow of the whole site as reported by the inverter
+type PowerFlow struct {
+	PV      []ProductionStamps // Photovoltaic production
+	Load    []ProductionStamps // Negative if the household consumes power
+	Grid    []ProductionStamps // Negative if we direct power to the grid, positive if we consume power from the grid
+	Battery []ProductionStamps // Negative if charging, positive if discharging
+}
+
+//MeterFlow of the grid as measured by the smart meter, all values are positive
+type MeterFlow struct {
+	Feed     []ProductionStamps // Fed into the grid
+	Purchase []ProductionStamps // Purchased from the grid
+	Usage    []ProductionStamps // Used by the household
 }
 
 //GenericDatabase provides an abstraction over a specific database
@@ -18,12 +39,108 @@ type GenericDatabase interface {
 	//SendData of the inverter to the database
 	SendData(data inverter.Data)
 
+	//SendSiteData of the inverter, which contains only the power flow and meter values
+	SendSiteData(data inverter.Data)
+
+	//SendArchiveData of the inverter with their original timestamps to the database
+	SendArchiveData(data []inverter.Data) error
+
 	//SendWeather updates to the database
 	SendWeather(data weather.Data)
 
+	//SendWeatherForecast of the next days to the database
+	SendWeatherForecast(data []weather.Forecast)
+
 	//SendYieldForecast updates to the database
 	SendYieldForecast(data []yield_forecast.Data)
 
+	//Flush writes which could not be saved yet
+	Flush() error
+
 	//GetTodaysProduction from the database
 	GetTodaysProduction() ([]ProductionStamps, error)
+
+	//GetProduction between from and to from the database
+	GetProduction(from, to time.Time) ([]ProductionStamps, error)
+
+	//GetTodaysPowerFlow from the database
+	GetTodaysPowerFlow() (PowerFlow, error)
+
+	//GetTodaysYieldForecast from the database
+	GetTodaysYieldForecast() ([]yield_forecast.Data, error)
+
+	//GetWeatherForecast of the periods starting between from and to from the database
+	GetWeatherForecast(from, to time.Time) ([]weather.Forecast, error)
+
+	//SendYieldSnapshot of a forecast of the provider issued at the time, to score it later
+	SendYieldSnapshot(provider string, issued time.Time, data []yield_forecast.Data)
+
+	//GetYieldSnapshots of the provider and lead time with periods ending after from until to
+	GetYieldSnapshots(provider, lead string, from, to time.Time) ([]yield_forecast.Data, error)
+
+	//SendForecastAccuracy of the provider and lead time on the day
+	SendForecastAccuracy(provider, lead string, day time.Time, metrics yield_forecast.Metrics)
+
+	//GetForecastAccuracy of the provider and lead time of the days between from and to
+	GetForecastAccuracy(provider, lead string, from, to time.Time) ([]yield_forecast.Metrics, error)
+
+	//GetMeter values between from and to from the database
+	GetMeter(from, to time.Time) (MeterFlow, error)
+
+	//SendEnergy of the completed hours and days to the database
+	SendEnergy(counters []energy.Counters)
+
+	//GetEnergy of the hours or days starting between from and to from the database
+	GetEnergy(period string, from, to time.Time) ([]energy.Counters, error)
+
+	//SendFinancials of a day to the database
+	SendFinancials(day tariff.Day)
+
+	//GetFinancials of the days starting between from and to from the database
+	GetFinancials(from, to time.Time) ([]tariff.Day, error)
+
+	//SendPrices of the energy market to the database
+	SendPrices(prices []tariff.Price)
+
+	//GetPrices of the energy market of the hours starting between from and to from the database
+	GetPrices(from, to time.Time) ([]tariff.Price, error)
+
+	//GetEmissionFactors of the grid of the hours starting between from and to from the database
+	GetEmissionFactors(from, to time.Time) ([]emissions.Intensity, error)
+}
+
+//Hourly production, the mean power of an hour equals its energy.
+//Hours in which the production was recorded shorter than MinCoverage are skipped.
+func Hourly(stamps []ProductionStamps) map[time.Time]inverter.WattHour {
+	type hour struct {
+		sum         float64
+		count       int
+		first, last time.Time
+	}
+
+	hours := map[time.Time]*hour{}
+	for _, s := range stamps {
+		start := s.Date.Truncate(time.Hour)
+		h, ok := hours[start]
+		if !ok {
+			h = &hour{first: s.Date, last: s.Date}
+			hours[start] = h
+		}
+		h.sum += float64(s.Value)
+		h.count++
+		if s.Date.Before(h.first) {
+			h.first = s.Date
+		}
+		if s.Date.After(h.last) {
+			h.last = s.Date
+		}
+	}
+
+	energy := map[time.Time]inverter.WattHour{}
+	for start, h := range hours {
+		if h.last.Sub(h.first) >= MinCoverage {
+			energy[start] = inverter.Watt(h.sum / float64(h.count)).Over(time.Hour)
+		}
+	}
+	return energy
 }
@@ -0,0 +1,26 @@
+package persistence
+
+import (
+	"reflect"
+	"solargo/inverter"
+	"testing"
+	"time"
+)
+
+func TestHourly(t *testing.T) {
+	start := time.Date(2020, time.June, 21, 10, 0, 0, 0, time.UTC)
+	var stamps []ProductionStamps
+	for i := 0; i < 12; i++ {
+		stamps = append(stamps, ProductionStamps{Date: start.Add(time.Duration(i) * 5 * time.Minute), Value: inverter.Watt(1000 + i*100)})
+	}
+	//Only the last 10 minutes of the next hour were polled
+	stamps = append(stamps,
+		ProductionStamps{Date: start.Add(110 * time.Minute), Value: 3000},
+		ProductionStamps{Date: start.Add(115 * time.Minute), Value: 3000},
+	)
+
+	want := map[time.Time]inverter.WattHour{start: 1550}
+	if ans := Hourly(stamps); !reflect.DeepEqual(ans, want) {
+		t.Errorf("got %v, want %v", ans, want)
+	}
+}
@@ -0,0 +1,66 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"solargo/tariff"
+	"strings"
+	"time"
+)
+
+//Api endpoints of aWATTar for the Austrian and the German market
+const (
+	AWATTarAustriaURL = "https://api.awattar.at"
+	AWATTarGermanyURL = "https://api.awattar.de"
+)
+
+//AWATTar implementation of the GenericPrice interface, the API does not need a token
+type AWATTar struct {
+	URL      string
+	Location *time.Location // Of the days, the local time zone if nil
+}
+
+//aWATTarResult of the market data API, times are unix timestamps in milliseconds
+type aWATTarResult struct {
+	Data []struct {
+		Start       int64   `json:"start_timestamp"`
+		End         int64   `json:"end_timestamp"`
+		MarketPrice float64 `json:"marketprice"`
+		Unit        string  `json:"unit"`
+	} `json:"data"`
+}
+
+//RetrievePrices of today and tomorrow using the aWATTar API
+func (a *AWATTar) RetrievePrices(ctx context.Context) ([]tariff.Price, error) {
+	from, to := period(time.Now(), a.Location)
+	httpResult, err := get(ctx, fmt.Sprintf("%s/v1/marketdata?start=%d&end=%d", a.URL, millis(from), millis(to)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpResult.Body.Close()
+
+	if httpResult.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error while receiving market prices: %s", httpResult.Status)
+	}
+
+	var result aWATTarResult
+	if err := json.NewDecoder(httpResult.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("Error while receiving market prices: %s", err)
+	}
+
+	prices := make([]tariff.Price, 0, len(result.Data))
+	for _, d := range result.Data {
+		if !strings.EqualFold(d.Unit, "Eur/MWh") {
+			return nil, fmt.Errorf("Unknown unit %q of the market prices", d.Unit)
+		}
+		prices = append(prices, tariff.Price{Start: time.Unix(0, d.Start*int64(time.Millisecond)), Price: perKWh(d.MarketPrice)})
+	}
+	return hourly(prices), nil
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
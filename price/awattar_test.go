@@ -0,0 +1,61 @@
+package price
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAWATTar(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, errStart := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+		end, errEnd := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+		if r.URL.Path != "/v1/marketdata" || errStart != nil || errEnd != nil || end-start < 24*3600*1000 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, err := ioutil.ReadFile("testdata/awattar_marketdata.json")
+		if err != nil {
+			t.Fatalf("Could not read fixture: %s", err)
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	a := AWATTar{URL: ts.URL}
+	actual, err := a.RetrievePrices(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	assertPrices(t, actual, time.Date(2020, time.June, 21, 22, 0, 0, 0, time.UTC), 0.02505, 0.01886, -0.0035)
+}
+
+func TestAWATTarError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"Status", http.StatusTooManyRequests, `{"data":[]}`},
+		{"Invalid payload", http.StatusOK, `<html></html>`},
+		{"Unit", http.StatusOK, `{"data":[{"start_timestamp":1592776800000,"end_timestamp":1592780400000,"marketprice":2.5,"unit":"Eur/kWh"}]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer ts.Close()
+
+			a := AWATTar{URL: ts.URL}
+			if _, err := a.RetrievePrices(context.Background()); err == nil {
+				t.Errorf("Should produce Error")
+			}
+		})
+	}
+}
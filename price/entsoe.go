@@ -0,0 +1,140 @@
+package price
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"solargo/tariff"
+	"sort"
+	"strings"
+	"time"
+)
+
+//ENTSOEURL of the Transparency Platform Api
+const ENTSOEURL = "https://web-api.tp.entsoe.eu/api"
+
+//entsoePeriodFormat of the start and the end of the requested period in UTC
+const entsoePeriodFormat = "200601021504"
+
+//entsoeTimeFormat of the time intervals of the documents
+const entsoeTimeFormat = "2006-01-02T15:04Z"
+
+//entsoeDayAheadPrices is the document type of the day-ahead prices
+const entsoeDayAheadPrices = "A44"
+
+//ENTSOE implementation of the GenericPrice interface using the ENTSO-E Transparency Platform
+type ENTSOE struct {
+	URL   string
+	Token string
+	Area  string // EIC code of the bidding zone, e.g. 10YAT-APG------L for Austria
+
+	Location *time.Location // Of the days, the local time zone if nil
+}
+
+//entsoeDocument is either a publication of prices or an acknowledgement with the reason of the failure
+type entsoeDocument struct {
+	TimeSeries []struct {
+		Currency string `xml:"currency_Unit.name"`
+		Unit     string `xml:"price_Measure_Unit.name"`
+		Period   []struct {
+			Interval struct {
+				Start string `xml:"start"`
+				End   string `xml:"end"`
+			} `xml:"timeInterval"`
+			Resolution string `xml:"resolution"`
+			Points     []struct {
+				Position int     `xml:"position"`
+				Price    float64 `xml:"price.amount"`
+			} `xml:"Point"`
+		} `xml:"Period"`
+	} `xml:"TimeSeries"`
+	Reason []struct {
+		Code string `xml:"code"`
+		Text string `xml:"text"`
+	} `xml:"Reason"`
+}
+
+//RetrievePrices of today and tomorrow using the ENTSO-E API
+func (e *ENTSOE) RetrievePrices(ctx context.Context) ([]tariff.Price, error) {
+	from, to := period(time.Now(), e.Location)
+	query := url.Values{
+		"securityToken": {e.Token},
+		"documentType":  {entsoeDayAheadPrices},
+		"in_Domain":     {e.Area},
+		"out_Domain":    {e.Area},
+		"periodStart":   {from.UTC().Format(entsoePeriodFormat)},
+		"periodEnd":     {to.UTC().Format(entsoePeriodFormat)},
+	}
+	httpResult, err := get(ctx, fmt.Sprintf("%s?%s", e.URL, query.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpResult.Body.Close()
+
+	var document entsoeDocument
+	if err := xml.NewDecoder(httpResult.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("Error while receiving market prices: %s %s", httpResult.Status, err)
+	}
+	if len(document.Reason) > 0 && len(document.TimeSeries) == 0 {
+		return nil, fmt.Errorf("Error while receiving market prices: %s (code %s)", document.Reason[0].Text, document.Reason[0].Code)
+	}
+	if httpResult.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error while receiving market prices: %s", httpResult.Status)
+	}
+	return document.prices()
+}
+
+//prices of all periods, positions missing in a period repeat the price of the previous position
+func (d entsoeDocument) prices() ([]tariff.Price, error) {
+	var prices []tariff.Price
+	for _, series := range d.TimeSeries {
+		if !strings.EqualFold(series.Unit, "MWH") {
+			return nil, fmt.Errorf("Unknown unit %q of the market prices", series.Unit)
+		}
+		for _, p := range series.Period {
+			start, err := time.Parse(entsoeTimeFormat, p.Interval.Start)
+			if err != nil {
+				return nil, fmt.Errorf("Error trying to convert the start of the period: %s", err)
+			}
+			end, err := time.Parse(entsoeTimeFormat, p.Interval.End)
+			if err != nil {
+				return nil, fmt.Errorf("Error trying to convert the end of the period: %s", err)
+			}
+			resolution, err := parseResolution(p.Resolution)
+			if err != nil {
+				return nil, err
+			}
+			if len(p.Points) == 0 {
+				continue
+			}
+
+			points := p.Points
+			sort.Slice(points, func(i, j int) bool { return points[i].Position < points[j].Position })
+			next := 0
+			var price float64
+			for position := 1; start.Add(time.Duration(position-1) * resolution).Before(end); position++ {
+				for next < len(points) && points[next].Position <= position {
+					price = points[next].Price
+					next++
+				}
+				if position < points[0].Position {
+					continue
+				}
+				prices = append(prices, tariff.Price{Start: start.Add(time.Duration(position-1) * resolution), Price: perKWh(price)})
+			}
+		}
+	}
+	return hourly(prices), nil
+}
+
+//parseResolution of a period like PT60M or PT15M
+func parseResolution(value string) (time.Duration, error) {
+	var minutes int
+	if _, err := fmt.Sscanf(value, "PT%dM", &minutes); err != nil || minutes <= 0 {
+		return 0, fmt.Errorf("Unknown resolution %q of the market prices", value)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
@@ -0,0 +1,70 @@
+package price
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//entsoeServer answers with the recorded day-ahead prices of Austria and an acknowledgement for other areas
+func entsoeServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("securityToken") != "secret" || q.Get("documentType") != "A44" || len(q.Get("periodStart")) != 12 || len(q.Get("periodEnd")) != 12 {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("<html><body>Unauthorized</body></html>"))
+			return
+		}
+		file := "testdata/entsoe_day_ahead.xml"
+		if q.Get("in_Domain") != "10YAT-APG------L" || q.Get("out_Domain") != q.Get("in_Domain") {
+			file = "testdata/entsoe_no_data.xml"
+		}
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Could not read fixture: %s", err)
+		}
+		w.Write(body)
+	}))
+}
+
+func TestENTSOE(t *testing.T) {
+	ts := entsoeServer(t)
+	defer ts.Close()
+
+	e := ENTSOE{URL: ts.URL, Token: "secret", Area: "10YAT-APG------L"}
+	actual, err := e.RetrievePrices(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	//The missing second position repeats the first one, the quarter hours of the last hour are averaged
+	assertPrices(t, actual, time.Date(2020, time.June, 20, 22, 0, 0, 0, time.UTC), 0.02505, 0.02505, -0.0035, 0.025)
+}
+
+func TestENTSOEError(t *testing.T) {
+	ts := entsoeServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		e    ENTSOE
+		want string
+	}{
+		{"No data", ENTSOE{URL: ts.URL, Token: "secret", Area: "10Y1001A1001A82H"}, "No matching data found"},
+		{"Token", ENTSOE{URL: ts.URL, Token: "wrong", Area: "10YAT-APG------L"}, "401"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.e.RetrievePrices(context.Background()); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %v, want %q", err, test.want)
+			}
+		})
+	}
+
+	if _, err := parseResolution("P1D"); err == nil {
+		t.Errorf("A resolution of a day should not be supported")
+	}
+}
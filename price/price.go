@@ -0,0 +1,74 @@
+//Package price contains a generic interface for the day-ahead prices of the energy market and the aWATTar, Tibber and ENTSO-E implementations
+package price
+
+import (
+	"context"
+	"net/http"
+	"solargo/tariff"
+	"sort"
+	"time"
+)
+
+//Price providers which can be selected in the config
+const (
+	ProviderAWATTar = "awattar"
+	ProviderTibber  = "tibber"
+	ProviderENTSOE  = "entsoe"
+)
+
+//Days of prices requested starting today, the day-ahead market publishes tomorrow around noon
+const Days = 2
+
+//GenericPrice provides an abstraction over a specific source of market prices
+type GenericPrice interface {
+	//RetrievePrices of today and tomorrow as far as they are published, per kWh and hour
+	RetrievePrices(ctx context.Context) ([]tariff.Price, error)
+}
+
+//period of the request from the last midnight in the location for the days, the local time zone if nil
+func period(now time.Time, loc *time.Location) (time.Time, time.Time) {
+	if loc == nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	return from, from.AddDate(0, 0, Days)
+}
+
+//get the uri, the request is cancelled with the context
+func get(ctx context.Context, uri string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(request)
+}
+
+//perKWh converts a price per MWh
+func perKWh(perMWh float64) float64 {
+	return perMWh / 1000
+}
+
+//hourly prices sorted by their start. Markets with prices per quarter hour are averaged over the hour, of prices
+//with the same start the last one is used.
+func hourly(prices []tariff.Price) []tariff.Price {
+	byStart := map[time.Time]float64{}
+	for _, p := range prices {
+		byStart[p.Start.UTC()] = p.Price
+	}
+
+	sums := map[time.Time]float64{}
+	counts := map[time.Time]int{}
+	for start, p := range byStart {
+		hour := start.Truncate(time.Hour)
+		sums[hour] += p
+		counts[hour]++
+	}
+
+	result := make([]tariff.Price, 0, len(sums))
+	for hour, sum := range sums {
+		result = append(result, tariff.Price{Start: hour, Price: sum / float64(counts[hour])})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start.Before(result[j].Start) })
+	return result
+}
@@ -0,0 +1,52 @@
+package price
+
+import (
+	"math"
+	"solargo/tariff"
+	"testing"
+	"time"
+)
+
+//assertPrices compares the prices with the wanted prices per kWh starting at the hour
+func assertPrices(t *testing.T, actual []tariff.Price, start time.Time, want ...float64) {
+	t.Helper()
+	if len(actual) != len(want) {
+		t.Fatalf("got %d prices %v, want %d", len(actual), actual, len(want))
+	}
+	for i, p := range actual {
+		if !p.Start.Equal(start.Add(time.Duration(i)*time.Hour)) || math.Abs(p.Price-want[i]) > 1e-9 {
+			t.Errorf("Price %d: got %s %g, want %s %g", i, p.Start, p.Price, start.Add(time.Duration(i)*time.Hour), want[i])
+		}
+	}
+}
+
+func TestHourly(t *testing.T) {
+	start := time.Date(2020, time.June, 21, 10, 0, 0, 0, time.UTC)
+	prices := []tariff.Price{
+		{Start: start.Add(time.Hour), Price: 0.05},
+		{Start: start, Price: 0.10},
+		{Start: start.Add(15 * time.Minute), Price: 0.20},
+		{Start: start.Add(15 * time.Minute).In(time.FixedZone("CEST", 7200)), Price: 0.30},
+	}
+
+	//The quarter hour is given twice, the last one is used
+	assertPrices(t, hourly(prices), start, 0.20, 0.05)
+
+	if p := hourly(nil); len(p) != 0 {
+		t.Errorf("got %v", p)
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	vienna, _ := time.LoadLocation("Europe/Vienna")
+	from, to := period(time.Date(2020, time.June, 21, 14, 30, 0, 0, vienna), vienna)
+	if !from.Equal(time.Date(2020, time.June, 21, 0, 0, 0, 0, vienna)) || !to.Equal(time.Date(2020, time.June, 23, 0, 0, 0, 0, vienna)) {
+		t.Errorf("got %s - %s", from, to)
+	}
+
+	//Shortly before midnight in UTC it is already the next day in Vienna
+	from, _ = period(time.Date(2020, time.June, 21, 22, 30, 0, 0, time.UTC), vienna)
+	if !from.Equal(time.Date(2020, time.June, 22, 0, 0, 0, 0, vienna)) {
+		t.Errorf("got %s, want the start of the 22nd in Vienna", from)
+	}
+}
@@ -0,0 +1,101 @@
+package price
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"solargo/tariff"
+	"time"
+)
+
+//TibberURL of the GraphQL Api
+const TibberURL = "https://api.tibber.com/v1-beta/gql"
+
+//tibberQuery of the prices of today and tomorrow of all homes
+const tibberQuery = `{ viewer { homes { id currentSubscription { priceInfo { today { energy startsAt } tomorrow { energy startsAt } } } } } }`
+
+//Tibber implementation of the GenericPrice interface
+type Tibber struct {
+	URL   string
+	Token string
+	Home  string // ID of the home, the first home of the account if empty
+}
+
+//tibberPrice of the price info, the energy price is the spot price without taxes
+type tibberPrice struct {
+	Energy   float64   `json:"energy"`
+	StartsAt time.Time `json:"startsAt"`
+}
+
+//tibberResult of the GraphQL query
+type tibberResult struct {
+	Data struct {
+		Viewer struct {
+			Homes []struct {
+				ID                  string `json:"id"`
+				CurrentSubscription *struct {
+					PriceInfo struct {
+						Today    []tibberPrice `json:"today"`
+						Tomorrow []tibberPrice `json:"tomorrow"`
+					} `json:"priceInfo"`
+				} `json:"currentSubscription"`
+			} `json:"homes"`
+		} `json:"viewer"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+//RetrievePrices of today and tomorrow using the Tibber API
+func (t *Tibber) RetrievePrices(ctx context.Context) ([]tariff.Price, error) {
+	query, err := json.Marshal(map[string]string{"query": tibberQuery})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+t.Token)
+
+	httpResult, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpResult.Body.Close()
+
+	var result tibberResult
+	if err := json.NewDecoder(httpResult.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("Error while receiving market prices: %s %s", httpResult.Status, err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("Error while receiving market prices: %s", result.Errors[0].Message)
+	}
+	if httpResult.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error while receiving market prices: %s", httpResult.Status)
+	}
+
+	for _, home := range result.Data.Viewer.Homes {
+		if t.Home != "" && home.ID != t.Home {
+			continue
+		}
+		if home.CurrentSubscription == nil {
+			return nil, fmt.Errorf("The Tibber home %s has no subscription", home.ID)
+		}
+		info := home.CurrentSubscription.PriceInfo
+		var prices []tariff.Price
+		for _, p := range append(info.Today, info.Tomorrow...) {
+			prices = append(prices, tariff.Price{Start: p.StartsAt, Price: p.Energy})
+		}
+		return hourly(prices), nil
+	}
+	if t.Home != "" {
+		return nil, fmt.Errorf("Unknown Tibber home %s", t.Home)
+	}
+	return nil, fmt.Errorf("The Tibber account has no home")
+}
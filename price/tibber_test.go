@@ -0,0 +1,70 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//tibberServer answers with the recorded response if the token is valid
+func tibberServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var query map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil || !strings.Contains(query["query"], "priceInfo") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		file := "testdata/tibber_prices.json"
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			file = "testdata/tibber_error.json"
+		}
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Could not read fixture: %s", err)
+		}
+		w.Write(body)
+	}))
+}
+
+func TestTibber(t *testing.T) {
+	ts := tibberServer(t)
+	defer ts.Close()
+
+	tibber := Tibber{URL: ts.URL, Token: "secret"}
+	actual, err := tibber.RetrievePrices(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	start := time.Date(2020, time.June, 20, 22, 0, 0, 0, time.UTC)
+	if len(actual) != 3 || !actual[2].Start.Equal(start.Add(24*time.Hour)) || actual[2].Price != -0.0035 {
+		t.Fatalf("got %v", actual)
+	}
+	assertPrices(t, actual[:2], start, 0.0251, 0.0189)
+}
+
+func TestTibberError(t *testing.T) {
+	ts := tibberServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		tibber Tibber
+		want   string
+	}{
+		{"Token", Tibber{URL: ts.URL, Token: "wrong"}, "invalid token"},
+		{"Unknown home", Tibber{URL: ts.URL, Token: "secret", Home: "unknown"}, "Unknown Tibber home"},
+		{"Without subscription", Tibber{URL: ts.URL, Token: "secret", Home: "7f3c2d4e-8a9b-4c1d-9e2f-0a1b2c3d4e5f"}, "no subscription"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.tibber.RetrievePrices(context.Background()); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %v, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+//Package schedule parses the schedules of the jobs, including times relative to sunrise and sunset
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sunrise"
+	"gopkg.in/robfig/cron.v2"
+)
+
+//Default schedules, the daytime schedules reproduce the behaviour before schedules were configurable
+const (
+	DefaultPoll               = "30s"
+	DefaultPollFrom           = "sunrise"
+	DefaultPollUntil          = "sunset"
+	DefaultNightPoll          = "5m"
+	DefaultWeather            = "15,45 * * * *"
+	DefaultWeatherFrom        = "sunrise+30m"
+	DefaultWeatherUntil       = "sunset-30m"
+	DefaultSummary            = "sunset-30m"
+	DefaultYieldForecast      = "30m"
+	DefaultYieldForecastFrom  = "sunrise-30m"
+	DefaultYieldForecastUntil = "sunset-30m"
+	DefaultCalibration        = "03:00"
+	DefaultAccuracy           = "00:30"
+	DefaultAccuracyReport     = "0 19 * * 0"
+	DefaultFinancials         = "00:15"
+	DefaultPrices             = "5 * * * *"
+	DefaultEnergyReport       = "0 8 1 * *"
+)
+
+//Off disables a job
+const Off = "off"
+
+//Sun events a time can be relative to
+const (
+	Sunrise = "sunrise"
+	Sunset  = "sunset"
+)
+
+//Or returns the value or the fallback if the value is empty
+func Or(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+//Time of a day, either relative to sunrise or sunset or to midnight
+type Time struct {
+	Event  string // Sunrise, Sunset or empty for midnight
+	Offset time.Duration
+}
+
+//ParseTime parses "sunrise", "sunset-45m", "sunrise+1h" or a clock time like "06:30"
+func ParseTime(value string) (Time, error) {
+	value = strings.TrimSpace(value)
+	for _, event := range []string{Sunrise, Sunset} {
+		if !strings.HasPrefix(value, event) {
+			continue
+		}
+		offset := strings.TrimPrefix(value, event)
+		if offset == "" {
+			return Time{event, 0}, nil
+		}
+		if offset[0] != '+' && offset[0] != '-' {
+			return Time{}, fmt.Errorf("%q must be followed by +<duration> or -<duration>", value)
+		}
+		d, err := time.ParseDuration(offset)
+		if err != nil {
+			return Time{}, fmt.Errorf("%q has an invalid offset: %s", value, err)
+		}
+		return Time{event, d}, nil
+	}
+
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return Time{}, fmt.Errorf("%q is neither relative to sunrise or sunset nor a time like 06:30", value)
+	}
+	return Time{"", time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute}, nil
+}
+
+//String representation of the time
+func (t Time) String() string {
+	if t.Event == "" {
+		return fmt.Sprintf("%02d:%02d", int(t.Offset.Hours()), int(t.Offset.Minutes())%60)
+	}
+	if t.Offset == 0 {
+		return t.Event
+	}
+	if t.Offset > 0 {
+		return fmt.Sprintf("%s+%s", t.Event, t.Offset)
+	}
+	return fmt.Sprintf("%s%s", t.Event, t.Offset)
+}
+
+//On returns the time on the day of the given date, false if the sun does not rise or set on that day
+func (t Time) On(day time.Time, latitude, longitude float64) (time.Time, bool) {
+	year, month, date := day.Date()
+	if t.Event == "" {
+		//Wall clock time, adding the offset to midnight would be an hour off on days with a DST change
+		return time.Date(year, month, date, int(t.Offset.Hours()), int(t.Offset.Minutes())%60, 0, 0, day.Location()), true
+	}
+
+	rise, set := sunrise.SunriseSunset(latitude, longitude, year, month, date)
+	event := rise
+	if t.Event == Sunset {
+		event = set
+	}
+	if event.IsZero() {
+		return event, false
+	}
+	return event.In(day.Location()).Add(t.Offset), true
+}
+
+//Sun schedules a job once a day at a time, which may be relative to sunrise or sunset
+type Sun struct {
+	Time      Time
+	Latitude  float64
+	Longitude float64
+}
+
+//Next activation after t, the zero time if the sun does not rise or set within a year
+func (s Sun) Next(t time.Time) time.Time {
+	for i := -1; i <= 366; i++ {
+		next, ok := s.Time.On(t.AddDate(0, 0, i), s.Latitude, s.Longitude)
+		if ok && next.After(t) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+//InLocation evaluates a schedule in a timezone, independent of the timezone of the system
+type InLocation struct {
+	Schedule cron.Schedule
+	Location *time.Location
+}
+
+//Next activation after t
+func (s InLocation) Next(t time.Time) time.Time {
+	return s.Schedule.Next(t.In(s.Location))
+}
+
+//Parse a schedule, which is either a time of day (see ParseTime), an interval like "30s" or a cron expression.
+//Times of day and cron expressions are evaluated in the timezone.
+func Parse(spec string, latitude, longitude float64, loc *time.Location) (cron.Schedule, error) {
+	if t, err := ParseTime(spec); err == nil {
+		return InLocation{Sun{t, latitude, longitude}, loc}, nil
+	}
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d < time.Second {
+			return nil, fmt.Errorf("%q is shorter than a second", spec)
+		}
+		return cron.Every(d), nil
+	}
+	s, err := cron.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a time, an interval nor a cron expression: %s", spec, err)
+	}
+	//Cron expressions are evaluated in their own location, which defaults to the timezone of the system
+	if cs, ok := s.(*cron.SpecSchedule); ok && !strings.HasPrefix(strings.TrimSpace(spec), "TZ=") {
+		cs.Location = loc
+	}
+	return s, nil
+}
+
+//Window of a day in which a job runs
+type Window struct {
+	From  Time
+	Until Time
+}
+
+//ParseWindow parses the start and end of a window, see ParseTime
+func ParseWindow(from, until string) (Window, error) {
+	f, err := ParseTime(from)
+	if err != nil {
+		return Window{}, err
+	}
+	u, err := ParseTime(until)
+	if err != nil {
+		return Window{}, err
+	}
+	return Window{f, u}, nil
+}
+
+//Contains returns true if t is within the window on the day of t in the timezone,
+//a window ending before it starts spans midnight
+func (w Window) Contains(t time.Time, latitude, longitude float64, loc *time.Location) bool {
+	t = t.In(loc)
+	from, ok := w.From.On(t, latitude, longitude)
+	if !ok {
+		return false
+	}
+	until, ok := w.Until.On(t, latitude, longitude)
+	if !ok {
+		return false
+	}
+	if until.Before(from) {
+		return !t.Before(from) || t.Before(until)
+	}
+	return !t.Before(from) && t.Before(until)
+}
@@ -0,0 +1,221 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sunrise"
+	"gopkg.in/robfig/cron.v2"
+)
+
+const (
+	latitude  = 48.2
+	longitude = 16.37
+)
+
+func TestParseTime(t *testing.T) {
+	var tests = []struct {
+		value  string
+		want   Time
+		errors bool
+	}{
+		{"sunrise", Time{Sunrise, 0}, false},
+		{"sunset-45m", Time{Sunset, -45 * time.Minute}, false},
+		{" sunrise+1h30m ", Time{Sunrise, 90 * time.Minute}, false},
+		{"06:30", Time{"", 6*time.Hour + 30*time.Minute}, false},
+		{"sunset45m", Time{}, true},
+		{"sunrise+soon", Time{}, true},
+		{"25:00", Time{}, true},
+		{"noon", Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			ans, err := ParseTime(tt.value)
+			if (err != nil) != tt.errors {
+				t.Errorf("got error %v, want error %t", err, tt.errors)
+			}
+			if ans != tt.want {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeString(t *testing.T) {
+	var tests = []struct {
+		value string
+		want  string
+	}{
+		{"sunrise", "sunrise"},
+		{"sunset-45m", "sunset-45m0s"},
+		{"sunrise+1h", "sunrise+1h0m0s"},
+		{"06:05", "06:05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			ts, _ := ParseTime(tt.value)
+			if ans := ts.String(); ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeOn(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC)
+	rise, set := sunrise.SunriseSunset(latitude, longitude, 2020, time.June, 21)
+
+	var tests = []struct {
+		value string
+		want  time.Time
+	}{
+		{"sunrise", rise},
+		{"sunset-45m", set.Add(-45 * time.Minute)},
+		{"06:30", time.Date(2020, time.June, 21, 6, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			ts, _ := ParseTime(tt.value)
+			ans, ok := ts.On(day, latitude, longitude)
+			if !ok || !ans.Equal(tt.want) {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+
+	//The sun does not set in the arctic summer
+	if _, ok := (Time{Sunset, 0}).On(day, 80, 0); ok {
+		t.Errorf("Sunset should not exist in the arctic summer")
+	}
+}
+
+func TestSunNext(t *testing.T) {
+	_, set := sunrise.SunriseSunset(latitude, longitude, 2020, time.June, 21)
+	_, nextSet := sunrise.SunriseSunset(latitude, longitude, 2020, time.June, 22)
+	s := Sun{Time{Sunset, -45 * time.Minute}, latitude, longitude}
+
+	if ans := s.Next(set.Add(-time.Hour)); !ans.Equal(set.Add(-45 * time.Minute)) {
+		t.Errorf("got %s, want %s", ans, set.Add(-45*time.Minute))
+	}
+	if ans := s.Next(set.Add(-45 * time.Minute)); !ans.Equal(nextSet.Add(-45 * time.Minute)) {
+		t.Errorf("got %s, want %s", ans, nextSet.Add(-45*time.Minute))
+	}
+
+	arctic := Sun{Time{Sunset, 0}, 89, 0}
+	if ans := arctic.Next(time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)); ans.IsZero() || ans.Month() < time.September {
+		t.Errorf("Next sunset near the pole should be after summer, got %s", ans)
+	}
+}
+
+func TestParse(t *testing.T) {
+	now := time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		spec   string
+		want   time.Time
+		errors bool
+	}{
+		{"30s", now.Add(30 * time.Second), false},
+		{"15,45 * * * *", time.Date(2020, time.June, 21, 12, 15, 0, 0, time.UTC), false},
+		{"@every 0h30m0s", now.Add(30 * time.Minute), false},
+		{"13:00", time.Date(2020, time.June, 21, 13, 0, 0, 0, time.UTC), false},
+		{"500ms", time.Time{}, true},
+		{"every minute", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec, latitude, longitude, time.UTC)
+			if (err != nil) != tt.errors {
+				t.Fatalf("got error %v, want error %t", err, tt.errors)
+			}
+			if err != nil {
+				return
+			}
+			if ans := s.Next(now); !ans.Equal(tt.want) {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+
+	if s, _ := Parse("sunrise", latitude, longitude, time.UTC); s != cron.Schedule(InLocation{Sun{Time{Sunrise, 0}, latitude, longitude}, time.UTC}) {
+		t.Errorf("Sun relative spec should produce a sun schedule, got %v", s)
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	rise, set := sunrise.SunriseSunset(latitude, longitude, 2020, time.June, 21)
+	day, _ := ParseWindow("sunrise+30m", "sunset-30m")
+	night, _ := ParseWindow("22:00", "06:00")
+
+	var tests = []struct {
+		testName string
+		window   Window
+		t        time.Time
+		want     bool
+	}{
+		{"Before start", day, rise.Add(29 * time.Minute), false},
+		{"Start", day, rise.Add(30 * time.Minute), true},
+		{"Before end", day, set.Add(-31 * time.Minute), true},
+		{"End", day, set.Add(-30 * time.Minute), false},
+		{"Night evening", night, time.Date(2020, time.June, 21, 23, 0, 0, 0, time.UTC), true},
+		{"Night morning", night, time.Date(2020, time.June, 21, 5, 0, 0, 0, time.UTC), true},
+		{"Night noon", night, time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if ans := tt.window.Contains(tt.t, latitude, longitude, time.UTC); ans != tt.want {
+				t.Errorf("got %t, want %t", ans, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseWindow("sunrise", "dusk"); err == nil {
+		t.Errorf("Invalid window should produce an error")
+	}
+}
+
+func TestDaylightSavingTime(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Fatalf("Can not load timezone: %s", err)
+	}
+
+	var tests = []struct {
+		testName string
+		spec     string
+		now      time.Time
+		want     time.Time
+	}{
+		{"Clock before spring forward", "06:30", time.Date(2020, time.March, 28, 12, 0, 0, 0, vienna), time.Date(2020, time.March, 29, 4, 30, 0, 0, time.UTC)},
+		{"Clock before fall back", "06:30", time.Date(2020, time.October, 24, 12, 0, 0, 0, vienna), time.Date(2020, time.October, 25, 5, 30, 0, 0, time.UTC)},
+		{"Cron before spring forward", "0 12 * * *", time.Date(2020, time.March, 29, 0, 0, 0, 0, vienna), time.Date(2020, time.March, 29, 10, 0, 0, 0, time.UTC)},
+		{"Cron in UTC evening", "0 1 * * *", time.Date(2020, time.June, 21, 22, 30, 0, 0, time.UTC), time.Date(2020, time.June, 21, 23, 0, 0, 0, time.UTC)},
+		{"Cron with own timezone", "TZ=UTC 0 1 * * *", time.Date(2020, time.June, 21, 22, 30, 0, 0, time.UTC), time.Date(2020, time.June, 22, 1, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			s, err := Parse(tt.spec, latitude, longitude, vienna)
+			if err != nil {
+				t.Fatalf("Should not produce Error: %s", err)
+			}
+			if ans := s.Next(tt.now); !ans.Equal(tt.want) {
+				t.Errorf("got %s, want %s", ans, tt.want.In(vienna))
+			}
+		})
+	}
+
+	//Shortly after midnight in Vienna is still the previous day in UTC
+	night, _ := ParseWindow("00:00", "06:00")
+	if !night.Contains(time.Date(2020, time.October, 24, 22, 30, 0, 0, time.UTC), latitude, longitude, vienna) {
+		t.Errorf("00:30 in Vienna should be within the window")
+	}
+	if night.Contains(time.Date(2020, time.October, 24, 22, 30, 0, 0, time.UTC), latitude, longitude, time.UTC) {
+		t.Errorf("22:30 in UTC should not be within the window")
+	}
+}
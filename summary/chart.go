@@ -0,0 +1,223 @@
+package summary
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"solargo/persistence"
+	"solargo/yield_forecast"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+	"gonum.org/v1/plot/vg/vgsvg"
+)
+
+//Supported chart formats
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+//Supported chart themes
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+//ChartOptions define how the daily chart is rendered
+type ChartOptions struct {
+	Width    int            // Width in pixels
+	Height   int            // Height in pixels
+	Theme    string         // Either ThemeLight or ThemeDark
+	Format   string         // Either FormatPNG or FormatSVG
+	Location *time.Location // Timezone of the time axis
+}
+
+//ChartData contains all series shown in the daily chart
+type ChartData struct {
+	Flow     persistence.PowerFlow
+	Forecast []yield_forecast.Data
+}
+
+type theme struct {
+	background color.Color
+	foreground color.Color
+	grid       color.Color
+}
+
+var themes = map[string]theme{
+	ThemeLight: {color.White, color.Black, color.Gray{Y: 200}},
+	ThemeDark:  {color.RGBA{R: 30, G: 30, B: 30, A: 255}, color.Gray{Y: 220}, color.Gray{Y: 80}},
+}
+
+//Series colors
+var (
+	colorPV       = color.RGBA{R: 255, G: 190, A: 255}
+	colorLoad     = color.RGBA{R: 220, G: 50, B: 50, A: 255}
+	colorImport   = color.RGBA{R: 150, G: 80, B: 200, A: 255}
+	colorExport   = color.RGBA{R: 50, G: 160, B: 80, A: 255}
+	colorBattery  = color.RGBA{R: 40, G: 120, B: 220, A: 255}
+	colorForecast = color.RGBA{R: 255, G: 140, A: 255}
+)
+
+//withDefaults fills all unset options
+func (o ChartOptions) withDefaults() ChartOptions {
+	if o.Width <= 0 {
+		o.Width = 1024
+	}
+	if o.Height <= 0 {
+		o.Height = 640
+	}
+	if _, ok := themes[o.Theme]; !ok {
+		o.Theme = ThemeLight
+	}
+	if o.Format != FormatSVG {
+		o.Format = FormatPNG
+	}
+	if o.Location == nil {
+		o.Location = time.Local
+	}
+	return o
+}
+
+//FileName of the rendered chart
+func (o ChartOptions) FileName() string {
+	return fmt.Sprintf("production.%s", o.withDefaults().Format)
+}
+
+//RenderChart draws the daily power chart into the writer
+func RenderChart(w io.Writer, data ChartData, options ChartOptions) error {
+	options = options.withDefaults()
+	t := themes[options.Theme]
+
+	p, err := plot.New()
+	if err != nil {
+		return fmt.Errorf("Could not create plot: %s", err)
+	}
+
+	p.Title.Text = "Todays Power"
+	p.X.Label.Text = fmt.Sprintf("Time (%s)", options.Location)
+	p.Y.Label.Text = "Power (kW)"
+	p.X.Tick.Marker = plot.TimeTicks{Format: "15:04", Time: plot.UnixTimeIn(options.Location)}
+	p.Y.Min = 0
+	p.Legend.Top = true
+	p.Legend.Left = true
+	applyTheme(p, t)
+
+	grid := plotter.NewGrid()
+	grid.Vertical.Color = t.grid
+	grid.Horizontal.Color = t.grid
+	p.Add(grid)
+
+	flow := data.Flow
+	load := make([]persistence.ProductionStamps, len(flow.Load))
+	for i, s := range flow.Load {
+		load[i] = persistence.ProductionStamps{Date: s.Date, Value: -s.Value}
+	}
+	gridImport := make([]persistence.ProductionStamps, len(flow.Grid))
+	gridExport := make([]persistence.ProductionStamps, len(flow.Grid))
+	for i, s := range flow.Grid {
+		gridImport[i].Date, gridExport[i].Date = s.Date, s.Date
+		if s.Value > 0 {
+			gridImport[i].Value = s.Value
+		} else {
+			gridExport[i].Value = -s.Value
+		}
+	}
+	forecast := forecastStamps(data.Forecast)
+
+	series := []struct {
+		name   string
+		stamps []persistence.ProductionStamps
+		color  color.Color
+		dashed bool
+	}{
+		{"PV", flow.PV, colorPV, false},
+		{"Load", load, colorLoad, false},
+		{"Grid import", gridImport, colorImport, false},
+		{"Grid export", gridExport, colorExport, false},
+		{"Battery", flow.Battery, colorBattery, false},
+		{"Forecast", forecast, colorForecast, true},
+	}
+
+	for _, s := range series {
+		if len(s.stamps) == 0 {
+			continue
+		}
+		line, err := plotter.NewLine(parseProductionToPlotter(s.stamps))
+		if err != nil {
+			return fmt.Errorf("Could not create line %s: %s", s.name, err)
+		}
+		line.Color = s.color
+		line.Width = vg.Points(1.5)
+		if s.dashed {
+			line.Dashes = []vg.Length{vg.Points(6), vg.Points(3)}
+		}
+		p.Add(line)
+		p.Legend.Add(s.name, line)
+
+		//The battery is negative while charging
+		for _, v := range s.stamps {
+			if kw := float64(v.Value) / 1000; kw < p.Y.Min {
+				p.Y.Min = kw
+			}
+		}
+	}
+
+	width := vg.Length(options.Width) * vg.Inch / 96
+	height := vg.Length(options.Height) * vg.Inch / 96
+
+	if options.Format == FormatSVG {
+		c := vgsvg.New(width, height)
+		p.Draw(draw.New(c))
+		_, err = c.WriteTo(w)
+		return err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, options.Width, options.Height))
+	c := vgimg.NewWith(vgimg.UseImage(img))
+	p.Draw(draw.New(c))
+	return png.Encode(w, c.Image())
+}
+
+//applyTheme colors all plot elements
+func applyTheme(p *plot.Plot, t theme) {
+	p.BackgroundColor = t.background
+	p.Title.Color = t.foreground
+	p.Legend.Color = t.foreground
+	for _, a := range []*plot.Axis{&p.X, &p.Y} {
+		a.Color = t.foreground
+		a.Label.Color = t.foreground
+		a.Tick.Color = t.foreground
+		a.Tick.Label.Color = t.foreground
+	}
+}
+
+//forecastStamps of the mean power of the forecast periods at their middle, the periods end at their dates
+func forecastStamps(data []yield_forecast.Data) []persistence.ProductionStamps {
+	stamps := make([]persistence.ProductionStamps, len(data))
+	for i, f := range data {
+		period := f.Period
+		if period <= 0 {
+			period = time.Hour
+		}
+		stamps[i] = persistence.ProductionStamps{Date: f.Date.Add(-period / 2), Value: f.MeanPower()}
+	}
+	return stamps
+}
+
+//parseProductionToPlotter converts the stamps into points in kW
+func parseProductionToPlotter(ps []persistence.ProductionStamps) plotter.XYs {
+	pts := make(plotter.XYs, len(ps))
+	for i := range pts {
+		pts[i].X = float64(ps[i].Date.Unix())
+		pts[i].Y = float64(ps[i].Value) / 1000
+	}
+	return pts
+}
@@ -0,0 +1,202 @@
+package summary
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"solargo/inverter"
+	"solargo/persistence"
+	"solargo/yield_forecast"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func getSampleChartData() ChartData {
+	var data ChartData
+	loc := time.FixedZone("CET", 3600)
+	start := time.Date(2020, time.June, 21, 5, 0, 0, 0, loc)
+
+	for i := 0; i <= 30; i++ {
+		t := start.Add(time.Duration(i) * 30 * time.Minute)
+		pv := 6000 * math.Max(0, math.Sin(math.Pi*float64(i)/30))
+		load := 400 + 300*math.Cos(float64(i)/3)
+		battery := 0.0
+		if i > 8 && i < 16 {
+			battery = -1500
+		} else if i > 24 {
+			battery = 800
+		}
+		grid := load - pv - battery
+
+		data.Flow.PV = append(data.Flow.PV, persistence.ProductionStamps{Date: t, Value: inverter.Watt(pv)})
+		data.Flow.Load = append(data.Flow.Load, persistence.ProductionStamps{Date: t, Value: inverter.Watt(-load)})
+		data.Flow.Grid = append(data.Flow.Grid, persistence.ProductionStamps{Date: t, Value: inverter.Watt(grid)})
+		data.Flow.Battery = append(data.Flow.Battery, persistence.ProductionStamps{Date: t, Value: inverter.Watt(battery)})
+	}
+
+	for i := 0; i <= 15; i++ {
+		t := start.Add(time.Duration(i) * time.Hour)
+		production := 5500 * math.Max(0, math.Sin(math.Pi*float64(i)/15))
+		data.Forecast = append(data.Forecast, yield_forecast.Data{Date: t, CurrentProduction: inverter.WattHour(production)})
+	}
+	return data
+}
+
+func golden(t *testing.T, name string, actual []byte) []byte {
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatalf("Could not update golden file: %s", err)
+		}
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read golden file: %s", err)
+	}
+	return expected
+}
+
+func decodePNG(t *testing.T, data []byte) *image.NRGBA {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Could not decode png: %s", err)
+	}
+	bounds := img.Bounds()
+	res := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			res.Set(x, y, img.At(x, y))
+		}
+	}
+	return res
+}
+
+//maxDifference of the share of the drawn pixels, which may differ from the golden file
+const maxDifference = 0.02
+
+//difference of the images of the same size as the share of the drawn pixels with a clearly different color,
+//the background is the color of the top left corner
+func difference(a, b *image.NRGBA) float64 {
+	differs := func(p, q []uint8) bool {
+		for c := range p {
+			if d := int(p[c]) - int(q[c]); d > 32 || d < -32 {
+				return true
+			}
+		}
+		return false
+	}
+
+	background := a.Pix[:4]
+	drawn, differing := 0, 0
+	for i := 0; i < len(a.Pix); i += 4 {
+		p, q := a.Pix[i:i+4], b.Pix[i:i+4]
+		if !differs(p, background) && !differs(q, background) {
+			continue
+		}
+		drawn++
+		if differs(p, q) {
+			differing++
+		}
+	}
+	if drawn == 0 {
+		return 0
+	}
+	return float64(differing) / float64(drawn)
+}
+
+func TestRenderChartPNG(t *testing.T) {
+	var tests = []struct {
+		golden  string
+		options ChartOptions
+	}{
+		{"chart_light.png", ChartOptions{Width: 800, Height: 500, Theme: ThemeLight, Location: time.FixedZone("CET", 3600)}},
+		{"chart_dark.png", ChartOptions{Width: 640, Height: 400, Theme: ThemeDark, Location: time.FixedZone("CET", 3600)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := RenderChart(&buf, getSampleChartData(), tt.options); err != nil {
+				t.Fatalf("Should not produce Error: %s", err)
+			}
+
+			actual := decodePNG(t, buf.Bytes())
+			expected := decodePNG(t, golden(t, tt.golden, buf.Bytes()))
+
+			if actual.Bounds() != image.Rect(0, 0, tt.options.Width, tt.options.Height) {
+				t.Errorf("Wrong image size %v", actual.Bounds())
+			}
+			if actual.Bounds() != expected.Bounds() {
+				t.Fatalf("Size %v differs from %v of %s", actual.Bounds(), expected.Bounds(), tt.golden)
+			}
+			//Font rendering and anti-aliasing vary slightly between platforms
+			if d := difference(actual, expected); d > maxDifference {
+				t.Errorf("Rendered chart differs in %.1f %% of the pixels from %s, run with -update to inspect the difference", d*100, tt.golden)
+			}
+		})
+	}
+}
+
+func TestRenderChartSVG(t *testing.T) {
+	var buf bytes.Buffer
+	options := ChartOptions{Format: FormatSVG, Location: time.FixedZone("CET", 3600)}
+	if err := RenderChart(&buf, getSampleChartData(), options); err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), golden(t, "chart.svg", buf.Bytes())) {
+		t.Errorf("Rendered chart differs from chart.svg, run with -update to inspect the difference")
+	}
+}
+
+func TestRenderChartEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderChart(&buf, ChartData{}, ChartOptions{}); err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if _, err := png.Decode(&buf); err != nil {
+		t.Errorf("Should render a valid png: %s", err)
+	}
+}
+
+func TestForecastStamps(t *testing.T) {
+	end := time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC)
+	data := []yield_forecast.Data{
+		{Date: end, CurrentProduction: 500},
+		{Date: end.Add(30 * time.Minute), CurrentProduction: 500, Period: 30 * time.Minute},
+	}
+	want := []persistence.ProductionStamps{
+		{Date: end.Add(-30 * time.Minute), Value: 500},
+		{Date: end.Add(15 * time.Minute), Value: 1000},
+	}
+	if ans := forecastStamps(data); !reflect.DeepEqual(ans, want) {
+		t.Errorf("got %v, want %v", ans, want)
+	}
+}
+
+func TestChartFileName(t *testing.T) {
+	var tests = []struct {
+		options ChartOptions
+		want    string
+	}{
+		{ChartOptions{}, "production.png"},
+		{ChartOptions{Format: FormatPNG}, "production.png"},
+		{ChartOptions{Format: FormatSVG}, "production.svg"},
+		{ChartOptions{Format: "gif"}, "production.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if ans := tt.options.FileName(); ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
@@ -2,31 +2,33 @@ package summary
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"image"
-	"image/color"
-	"image/png"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"solargo/accuracy"
+	"solargo/analytics"
 	"solargo/config"
+	"solargo/emissions"
+	"solargo/financials"
 	"solargo/inverter"
 	"solargo/persistence"
+	"solargo/weather"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
-	"gonum.org/v1/plot"
-	"gonum.org/v1/plot/plotter"
-	"gonum.org/v1/plot/vg/draw"
-	"gonum.org/v1/plot/vg/vgimg"
 )
 
 //SendSummary sends the daily summary to the specified telegram bot
-func SendSummary(config *config.Config, inverter inverter.GenericInverter, database persistence.GenericDatabase) {
+func SendSummary(ctx context.Context, config *config.Config, inverter inverter.GenericInverter, database persistence.GenericDatabase) {
 	var message string
 	summary := config.Summary
 
 	if summary.SendStatistics {
-		statistics, err := inverter.GetInverterStatistics()
+		statistics, err := inverter.GetInverterStatistics(ctx)
 		if err != nil {
 			log.Warn("Could not receive daily statistics. Produced Error: ", err)
 			message = "Solaranlage hat Fehler! Bitte überprüfen."
@@ -34,82 +36,217 @@ func SendSummary(config *config.Config, inverter inverter.GenericInverter, datab
 			message = fmt.Sprintf("Solaranlage Statistik Today:\n%s", statistics.String())
 		}
 
-		message = url.QueryEscape(message)
+		if config.Tariff.Enabled {
+			money, err := financials.Summary(database, config.GetTariff(), time.Now(), config.Energy.Enabled)
+			if err != nil {
+				log.Warn("Could not compute the financials: ", err)
+			} else {
+				message += "\n\n" + money
+			}
+		}
 
-		uri := fmt.Sprintf("%s%s/sendmessage?chat_id=%s&text=%s", summary.TelegramURL, summary.BotToken, summary.ChatID, message)
-		_, _ = http.Get(uri)
+		if config.Energy.Enabled {
+			today, err := analytics.Today(database, time.Now(), config.Location())
+			if err != nil {
+				log.Warn("Could not compute the self-consumption: ", err)
+			} else if today.Production > 0 || today.Consumption > 0 {
+				message += "\n\nEnergie heute: " + today.String()
+			}
+		}
 
-		ps, err := database.GetTodaysProduction()
-		if err != nil {
-			log.Warn("Could not receive todays production. Produced Error: ", err)
-			return
+		if factor := config.GetEmissionFactor(); factor != nil && config.Energy.Enabled {
+			now := time.Now().In(config.Location())
+			savings, err := analytics.Emissions(database, factor, analytics.Day, analytics.PeriodStart(analytics.Day, now), now, config.Location())
+			if err != nil {
+				log.Warn("Could not compute the avoided emissions: ", err)
+			} else if len(savings) > 0 {
+				message += "\n\nCO2 heute: " + savings[0].String()
+			}
 		}
 
-		p, err := plot.New()
-		if err != nil {
-			log.Warn("Could not create plot: ", err)
-			return
+		if forecast := tomorrowsWeather(config, database); forecast != "" {
+			message += "\n\n" + forecast
 		}
 
-		p.X.Tick.Marker = plot.TimeTicks{Format: "15:04"}
-		p.Title.Text = "Todays Production"
-		p.X.Label.Text = "Time"
-		p.Y.Label.Text = "Production (kWh)"
-		p.Y.Min = 0
-		p.Add(plotter.NewGrid())
+		sendMessage(ctx, config, message)
 
-		line, points, err := plotter.NewLinePoints(parseProductionToPlotter(ps))
-		if err != nil {
-			log.Warn("Could not create plot: ", err)
-			return
+		if err := sendChart(ctx, config, database); err != nil {
+			log.Warn("Could not send the daily chart: ", err)
 		}
-		line.Color = color.RGBA{R: 255, G: 214, A: 255}
-		points.Shape = draw.CircleGlyph{}
-		points.Color = color.RGBA{R: 255, G: 214, A: 255}
+	}
+}
 
-		p.Add(line, points)
+//SendAccuracyReport sends the accuracy of the yield forecasts of the last week to the specified telegram bot
+func SendAccuracyReport(ctx context.Context, config *config.Config, database persistence.GenericDatabase) {
+	if !config.Summary.SendStatistics || !config.Summary.AccuracyReport {
+		return
+	}
 
-		// Draw the plot to an in-memory image.
-		img := image.NewRGBA(image.Rect(0, 0, 512, 512))
-		c := vgimg.NewWith(vgimg.UseImage(img))
-		p.Draw(draw.New(c))
+	to := time.Now().In(config.Location())
+	report, err := accuracy.Report(database, config.TrackedYieldProviders(), to.AddDate(0, 0, -accuracy.ReportDays), to)
+	if err != nil {
+		log.Warn("Could not create the accuracy report: ", err)
+		return
+	}
+	if report == "" {
+		log.Info("No forecast accuracy to report")
+		return
+	}
+	sendMessage(ctx, config, fmt.Sprintf("Wochenbericht der Ertragsprognose:\n%s", report))
+}
 
-		body := &bytes.Buffer{}
-		writer := multipart.NewWriter(body)
-		part, err := writer.CreateFormFile("photo", "test.png")
+//SendEnergyReport sends the self-consumption, autarky and avoided emissions of the last month to the specified
+//telegram bot, at the start of a year also of the last year
+func SendEnergyReport(ctx context.Context, config *config.Config, database persistence.GenericDatabase) {
+	if !config.Summary.SendStatistics || !config.Summary.EnergyReport {
+		return
+	}
 
+	message, err := energyReport(database, config.GetEmissionFactor(), time.Now().In(config.Location()), config.Location())
+	if err != nil {
+		log.Warn("Could not create the energy report: ", err)
+		return
+	}
+	if message == "" {
+		log.Info("No accounted energy to report")
+		return
+	}
+	sendMessage(ctx, config, message)
+}
+
+//energyReport of the month before now, in January with the year before. The emissions are skipped without factor.
+func energyReport(database persistence.GenericDatabase, factor *emissions.Factor, now time.Time, loc *time.Location) (string, error) {
+	end := analytics.PeriodStart(analytics.Month, now)
+	resolutions := []string{analytics.Month}
+	if end.Month() == time.January {
+		resolutions = append(resolutions, analytics.Year)
+	}
+
+	var parts []string
+	for _, resolution := range resolutions {
+		start := analytics.PeriodStart(resolution, end.AddDate(0, 0, -1))
+		report, err := analytics.NewReport(database, resolution, start, end, loc)
 		if err != nil {
-			log.Warn("Could not create formfile: ", err)
-			return
+			return "", err
 		}
-
-		// Save the image in the multipart
-		if err := png.Encode(part, c.Image()); err != nil {
-			log.Warn("Could not write the image into the multipart: ", err)
-			return
+		if len(report.Periods) > 0 {
+			parts = append(parts, report.String())
 		}
-		writer.Close()
-		uri = fmt.Sprintf("%s%s/sendPhoto?chat_id=%s", summary.TelegramURL, summary.BotToken, summary.ChatID)
-
-		r, err := http.NewRequest("POST", uri, body)
-		
+		if factor == nil {
+			continue
+		}
+		savings, err := analytics.Emissions(database, factor, resolution, start, end, loc)
 		if err != nil {
-			log.Warn("Could not create new request", err)
-			return
+			return "", err
+		}
+		if len(savings) > 0 {
+			parts = append(parts, analytics.EmissionsString(resolution, savings))
 		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
 
-		r.Header.Add("Content-Type", writer.FormDataContentType())
-		client := &http.Client{}
-		_, _ = client.Do(r)
+//sendMessage to the specified telegram bot
+func sendMessage(ctx context.Context, config *config.Config, message string) {
+	summary := config.Summary
+	uri := fmt.Sprintf("%s%s/sendmessage?chat_id=%s&text=%s", summary.TelegramURL, summary.BotToken, summary.ChatID, url.QueryEscape(message))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		log.Warn("Could not send the message: ", err)
+		return
 	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		log.Warn("Could not send the message: ", err)
+		return
+	}
+	response.Body.Close()
+}
+
+//tomorrowsWeather summarizes the weather forecast of tomorrow, empty if there is none
+func tomorrowsWeather(config *config.Config, database persistence.GenericDatabase) string {
+	year, month, day := time.Now().In(config.Location()).Date()
+	from := time.Date(year, month, day+1, 0, 0, 0, 0, config.Location())
+	forecast, err := database.GetWeatherForecast(from, from.AddDate(0, 0, 1))
+	if err != nil {
+		log.Info("Could not receive the weather forecast: ", err)
+		return ""
+	}
+	return weatherSummary(forecast)
+}
+
+//weatherSummary of the forecast periods of a day
+func weatherSummary(forecast []weather.Forecast) string {
+	if len(forecast) == 0 {
+		return ""
+	}
+
+	min, max := forecast[0].Temperature, forecast[0].Temperature
+	var clouds, precipitation float64
+	for _, f := range forecast {
+		min = math.Min(min, f.Temperature)
+		max = math.Max(max, f.Temperature)
+		clouds += f.CloudDensity
+		precipitation += f.RainAmount + f.SnowAmount
+	}
+	return fmt.Sprintf("Wetter morgen: %.0f bis %.0f °C, %.0f%% Bewölkung, %.1f mm Niederschlag",
+		min, max, clouds/float64(len(forecast)), precipitation)
 }
 
-// randomPoints returns some random x, y points.
-func parseProductionToPlotter(ps []persistence.ProductionStamps) plotter.XYs {
-	pts := make(plotter.XYs, len(ps))
-	for i := range pts {
-		pts[i].X = float64(ps[i].Date.Unix())
-		pts[i].Y = float64(ps[i].Value.ToKWh())
+//sendChart renders todays power chart and sends it to the specified telegram bot
+func sendChart(ctx context.Context, config *config.Config, database persistence.GenericDatabase) error {
+	summary := config.Summary
+	options := ChartOptions{
+		Width:    summary.Chart.Width,
+		Height:   summary.Chart.Height,
+		Theme:    summary.Chart.Theme,
+		Format:   summary.Chart.Format,
+		Location: config.Location(),
+	}
+
+	var data ChartData
+	var err error
+	data.Flow, err = database.GetTodaysPowerFlow()
+	if err != nil {
+		return fmt.Errorf("Could not receive todays power flow: %s", err)
+	}
+
+	//The forecast is optional
+	data.Forecast, err = database.GetTodaysYieldForecast()
+	if err != nil {
+		log.Info("Could not receive todays yield forecast: ", err)
+	}
+
+	//Telegram only accepts raster images as photo
+	method, field := "sendPhoto", "photo"
+	if options.Format == FormatSVG {
+		method, field = "sendDocument", "document"
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(field, options.FileName())
+	if err != nil {
+		return fmt.Errorf("Could not create formfile: %s", err)
+	}
+
+	if err := RenderChart(part, data, options); err != nil {
+		return err
+	}
+	writer.Close()
+
+	uri := fmt.Sprintf("%s%s/%s?chat_id=%s", summary.TelegramURL, summary.BotToken, method, summary.ChatID)
+	r, err := http.NewRequestWithContext(ctx, "POST", uri, body)
+	if err != nil {
+		return fmt.Errorf("Could not create new request: %s", err)
+	}
+
+	r.Header.Add("Content-Type", writer.FormDataContentType())
+	client := &http.Client{}
+	resp, err := client.Do(r)
+	if err != nil {
+		return err
 	}
-	return pts
+	resp.Body.Close()
+	return nil
 }
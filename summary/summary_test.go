@@ -1,11 +1,19 @@
 package summary
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"solargo/config"
+	"solargo/emissions"
+	"solargo/energy"
 	"solargo/testutils"
+	"solargo/weather"
+	"solargo/yield_forecast"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSendSummarySuccess(t *testing.T) {
@@ -28,10 +36,34 @@ func TestSendSummarySuccess(t *testing.T) {
 	config.Summary.SendStatistics = true
 	config.Summary.TelegramURL = ts.URL
 
-	SendSummary(&config, &iv, &db)
+	SendSummary(context.Background(), &config, &iv, &db)
 
 }
 
+func TestSendSummaryFinancials(t *testing.T) {
+	var message string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.RequestURI, "/sendmessage") {
+			message = r.URL.Query().Get("text")
+		}
+	}))
+	defer ts.Close()
+
+	var iv testutils.SuccessInverter
+	var db testutils.SuccessDatabase
+	var c config.Config
+	c.Summary.SendStatistics = true
+	c.Summary.TelegramURL = ts.URL
+	c.Tariff.Enabled = true
+	c.Tariff.Import.Price = 0.3
+
+	SendSummary(context.Background(), &c, &iv, &db)
+	want := "\n\nFinanzen heute:\nErsparnis: 0.00 EUR\nEinspeisevergütung: 0.00 EUR\nNetzbezug: 0.00 EUR"
+	if !strings.HasSuffix(message, want) {
+		t.Errorf("got %q, want suffix %q", message, want)
+	}
+}
+
 func TestSendSummaryError(t *testing.T) {
 	seen := false
 	expected := "/sendmessage?chat_id=&text=Solaranlage+hat+Fehler%21+Bitte+%C3%BCberpr%C3%BCfen."
@@ -51,5 +83,240 @@ func TestSendSummaryError(t *testing.T) {
 	config.Summary.SendStatistics = true
 	config.Summary.TelegramURL = ts.URL
 
-	SendSummary(&config, &iv, &db)
+	SendSummary(context.Background(), &config, &iv, &db)
+}
+
+func TestSendSummaryChart(t *testing.T) {
+	var tests = []struct {
+		format   string
+		endpoint string
+		field    string
+		fileName string
+	}{
+		{"", "/sendPhoto", "photo", "production.png"},
+		{"svg", "/sendDocument", "document", "production.svg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			seen := false
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasPrefix(r.RequestURI, tt.endpoint) {
+					return
+				}
+				seen = true
+				_, header, err := r.FormFile(tt.field)
+				if err != nil {
+					t.Errorf("Should contain the chart: %s", err)
+					return
+				}
+				if header.Filename != tt.fileName {
+					t.Errorf("Error actual = %v, and expected = %v.", header.Filename, tt.fileName)
+				}
+			}))
+			defer ts.Close()
+
+			var iv testutils.SuccessInverter
+			var db testutils.SuccessDatabase
+			var config config.Config
+			config.Summary.SendStatistics = true
+			config.Summary.TelegramURL = ts.URL
+			config.Summary.Chart.Format = tt.format
+
+			SendSummary(context.Background(), &config, &iv, &db)
+
+			if !seen {
+				t.Errorf("Chart was not sent to %s", tt.endpoint)
+			}
+		})
+	}
+}
+
+func TestWeatherSummary(t *testing.T) {
+	day := time.Date(2020, time.November, 24, 0, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		testName string
+		forecast []weather.Forecast
+		want     string
+	}{
+		{"No forecast", nil, ""},
+		{"Forecast", []weather.Forecast{
+			{Date: day.Add(9 * time.Hour), Temperature: 2.4, CloudDensity: 100, RainAmount: 0.5},
+			{Date: day.Add(12 * time.Hour), Temperature: 7.6, CloudDensity: 50, SnowAmount: 1},
+			{Date: day.Add(15 * time.Hour), Temperature: 5, CloudDensity: 0},
+		}, "Wetter morgen: 2 bis 8 °C, 50% Bewölkung, 1.5 mm Niederschlag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if ans := weatherSummary(tt.forecast); ans != tt.want {
+				t.Errorf("got %q, want %q", ans, tt.want)
+			}
+		})
+	}
+}
+
+//accuracyDatabase returns the same score for every provider and lead time
+type accuracyDatabase struct {
+	testutils.SuccessDatabase
+}
+
+func (db *accuracyDatabase) GetForecastAccuracy(provider, lead string, from, to time.Time) ([]yield_forecast.Metrics, error) {
+	if lead != "6h" {
+		return nil, nil
+	}
+	return []yield_forecast.Metrics{{Samples: 10, MAE: 120, RMSE: 180, Bias: 40}}, nil
+}
+
+func TestSendAccuracyReport(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query().Get("text"))
+	}))
+	defer ts.Close()
+
+	var c config.Config
+	c.Summary.SendStatistics = true
+	c.Summary.TelegramURL = ts.URL
+	c.Yield.Compare = []string{"model"}
+
+	//Disabled by default
+	SendAccuracyReport(context.Background(), &c, &accuracyDatabase{})
+	c.Summary.AccuracyReport = true
+	SendAccuracyReport(context.Background(), &c, &testutils.SuccessDatabase{})
+	SendAccuracyReport(context.Background(), &c, &accuracyDatabase{})
+
+	want := []string{"Wochenbericht der Ertragsprognose:\nPrognosegenauigkeit pro Stunde:\n" +
+		"solarprognose (6h): MAE 120 Wh, RMSE 180 Wh, Bias +40 Wh, 10 h\n" +
+		"model (6h): MAE 120 Wh, RMSE 180 Wh, Bias +40 Wh, 10 h"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("got %q, want %q", requests, want)
+	}
+}
+
+//energyDatabase returns the same energy for every hour and day
+type energyDatabase struct {
+	testutils.SuccessDatabase
+}
+
+func (db *energyDatabase) GetEnergy(period string, from, to time.Time) ([]energy.Counters, error) {
+	var counters []energy.Counters
+	step := func(t time.Time) time.Time { return t.Add(time.Hour) }
+	if period == energy.PeriodDay {
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	}
+	for t := from; t.Before(to); t = step(t) {
+		counters = append(counters, energy.Counters{Period: period, Start: t, End: step(t), Production: 10000, Consumption: 8000, SelfConsumption: 4000, FeedIn: 6000, Import: 4000})
+	}
+	return counters, nil
+}
+
+func TestEnergyReport(t *testing.T) {
+	loc := time.UTC
+	tests := []struct {
+		testName string
+		now      time.Time
+		want     string
+	}{
+		{"Month", time.Date(2020, 7, 1, 8, 0, 0, 0, loc), "Eigenverbrauch und Autarkie pro Monat:\n" +
+			"2020-06: Eigenverbrauch 40 %, Autarkie 50 %, Erzeugung 300.0 kWh, Verbrauch 240.0 kWh, Einspeisung 180.0 kWh, Netzbezug 120.0 kWh"},
+		{"Year", time.Date(2021, 1, 1, 8, 0, 0, 0, loc), "Eigenverbrauch und Autarkie pro Monat:\n" +
+			"2020-12: Eigenverbrauch 40 %, Autarkie 50 %, Erzeugung 310.0 kWh, Verbrauch 248.0 kWh, Einspeisung 186.0 kWh, Netzbezug 124.0 kWh\n\n" +
+			"Eigenverbrauch und Autarkie pro Jahr:\n" +
+			"2020: Eigenverbrauch 40 %, Autarkie 50 %, Erzeugung 3660.0 kWh, Verbrauch 2928.0 kWh, Einspeisung 2196.0 kWh, Netzbezug 1464.0 kWh"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			ans, err := energyReport(&energyDatabase{}, nil, tt.now, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ans != tt.want {
+				t.Errorf("got %q, want %q", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendEnergyReport(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query().Get("text"))
+	}))
+	defer ts.Close()
+
+	var c config.Config
+	c.Summary.SendStatistics = true
+	c.Summary.TelegramURL = ts.URL
+
+	//Disabled by default, nothing is sent without accounted energy
+	SendEnergyReport(context.Background(), &c, &energyDatabase{})
+	c.Summary.EnergyReport = true
+	SendEnergyReport(context.Background(), &c, &testutils.SuccessDatabase{})
+	if len(requests) != 0 {
+		t.Errorf("got %q, want no message", requests)
+	}
+
+	SendEnergyReport(context.Background(), &c, &energyDatabase{})
+	if len(requests) != 1 || !strings.HasPrefix(requests[0], "Eigenverbrauch und Autarkie pro Monat:\n") {
+		t.Errorf("got %q, want the report of the last month", requests)
+	}
+}
+
+func TestSendSummaryEnergy(t *testing.T) {
+	var message string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.RequestURI, "/sendmessage") {
+			message = r.URL.Query().Get("text")
+		}
+	}))
+	defer ts.Close()
+
+	var iv testutils.SuccessInverter
+	var c config.Config
+	c.Summary.SendStatistics = true
+	c.Summary.TelegramURL = ts.URL
+	c.Energy.Enabled = true
+
+	SendSummary(context.Background(), &c, &iv, &energyDatabase{})
+	if !strings.Contains(message, "\n\nEnergie heute: Eigenverbrauch 40 %, Autarkie 50 %") {
+		t.Errorf("got %q, want the self-consumption of today", message)
+	}
+}
+
+func TestSendSummaryEmissions(t *testing.T) {
+	var message string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.RequestURI, "/sendmessage") {
+			message = r.URL.Query().Get("text")
+		}
+	}))
+	defer ts.Close()
+
+	var iv testutils.SuccessInverter
+	var c config.Config
+	c.Summary.SendStatistics = true
+	c.Summary.TelegramURL = ts.URL
+	c.Energy.Enabled = true
+	c.Emissions.Enabled = true
+	c.Emissions.Factor = 400
+
+	SendSummary(context.Background(), &c, &iv, &energyDatabase{})
+	if !strings.Contains(message, "\n\nCO2 heute: vermieden ") {
+		t.Errorf("got %q, want the avoided emissions of today", message)
+	}
+}
+
+func TestEnergyReportEmissions(t *testing.T) {
+	ans, err := energyReport(&energyDatabase{}, &emissions.Factor{Static: 400}, time.Date(2020, 7, 1, 8, 0, 0, 0, time.UTC), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//30 days of 24 hours with 10 kWh each
+	want := "\n\nCO2-Einsparung pro Monat:\n2020-06: vermieden 2880.0 kg CO2, davon 1152.0 kg durch Eigenverbrauch, entspricht 19200 km Autofahrt oder 230.4 Bäumen pro Jahr"
+	if !strings.HasSuffix(ans, want) {
+		t.Errorf("got %q, want suffix %q", ans, want)
+	}
 }
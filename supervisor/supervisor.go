@@ -0,0 +1,148 @@
+//Package supervisor runs the periodic jobs of the daemon and stops them gracefully
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/robfig/cron.v2"
+)
+
+//Job run by the supervisor, long running jobs should return early once the context is cancelled
+type Job func(ctx context.Context)
+
+//Supervisor runs jobs on a cron schedule, recovers their panics and never runs the same job twice at a time
+type Supervisor struct {
+	cron    *cron.Cron
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	started bool
+	running map[string]bool
+}
+
+//New supervisor without jobs
+func New() *Supervisor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Supervisor{
+		cron:    cron.New(),
+		ctx:     ctx,
+		cancel:  cancel,
+		running: map[string]bool{},
+	}
+}
+
+//Schedule a job which runs on the schedule
+func (s *Supervisor) Schedule(name string, schedule cron.Schedule, job Job) {
+	s.cron.Schedule(schedule, cron.FuncJob(func() { s.Run(name, job) }))
+}
+
+//Start the cron schedule
+func (s *Supervisor) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started && s.ctx.Err() == nil {
+		s.started = true
+		s.cron.Start()
+	}
+}
+
+//Run the job now and wait for it, the run is skipped if the job is still running or the supervisor is stopped
+func (s *Supervisor) Run(name string, job Job) {
+	if !s.acquire(name) {
+		return
+	}
+	defer s.release(name)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Job ", name, " panicked: ", r, "\n", string(debug.Stack()))
+		}
+	}()
+
+	job(s.ctx)
+}
+
+//Go runs the job in the background, see Run
+func (s *Supervisor) Go(name string, job Job) {
+	go s.Run(name, job)
+}
+
+func (s *Supervisor) acquire(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return false
+	}
+	if s.running[name] {
+		log.Warn("Job ", name, " is still running, skipping this run")
+		return false
+	}
+	s.running[name] = true
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Supervisor) release(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, name)
+	s.wg.Done()
+}
+
+//Stop the schedule, cancel the running jobs and wait at most timeout for them to return
+func (s *Supervisor) Stop(timeout time.Duration) error {
+	s.mu.Lock()
+	//Stopping a cron which never started blocks forever
+	if s.started {
+		s.cron.Stop()
+		s.started = false
+	}
+	s.cancel()
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		names := make([]string, 0, len(s.running))
+		for name := range s.running {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("Jobs still running after %s: %s", timeout, strings.Join(names, ", "))
+	}
+}
+
+//ShutdownSignal returns a channel which is closed on SIGINT or SIGTERM
+func ShutdownSignal() <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		sig := <-signals
+		log.Info("Received ", sig, ", shutting down")
+		signal.Stop(signals)
+		close(done)
+	}()
+	return done
+}
@@ -0,0 +1,103 @@
+package supervisor
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/robfig/cron.v2"
+)
+
+func TestRunRecoversPanics(t *testing.T) {
+	s := New()
+	s.Run("panic", func(ctx context.Context) { panic("Error") })
+
+	ran := false
+	s.Run("panic", func(ctx context.Context) { ran = true })
+	if !ran {
+		t.Errorf("Job should run again after a panic")
+	}
+}
+
+func TestRunSkipsOverlappingRuns(t *testing.T) {
+	s := New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.Go("poll", func(ctx context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	ran := false
+	s.Run("poll", func(ctx context.Context) { ran = true })
+	if ran {
+		t.Errorf("Job should not run while the previous run is still running")
+	}
+	s.Run("other", func(ctx context.Context) { ran = true })
+	if !ran {
+		t.Errorf("Other jobs should run")
+	}
+	close(release)
+
+	if err := s.Stop(time.Second); err != nil {
+		t.Errorf("Should not produce Error: %s", err)
+	}
+}
+
+func TestStopCancelsJobs(t *testing.T) {
+	s := New()
+	started := make(chan struct{})
+	var cancelled int32
+	s.Go("backfill", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		atomic.StoreInt32(&cancelled, 1)
+	})
+	<-started
+
+	if err := s.Stop(time.Second); err != nil {
+		t.Errorf("Should not produce Error: %s", err)
+	}
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Errorf("Job should be cancelled and waited for")
+	}
+
+	ran := false
+	s.Run("backfill", func(ctx context.Context) { ran = true })
+	if ran {
+		t.Errorf("Stopped supervisor should not run jobs")
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	s := New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	s.Go("stuck", func(ctx context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	if err := s.Stop(10 * time.Millisecond); err == nil || !strings.HasSuffix(err.Error(), "stuck") {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	s := New()
+	runs := make(chan struct{}, 10)
+	s.Schedule("fast", cron.Every(time.Second), func(ctx context.Context) { runs <- struct{}{} })
+	s.Start()
+	defer s.Stop(time.Second)
+
+	select {
+	case <-runs:
+	case <-time.After(3 * time.Second):
+		t.Errorf("Scheduled job did not run")
+	}
+}
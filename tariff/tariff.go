@@ -0,0 +1,291 @@
+//Package tariff prices the energy purchased from and fed into the grid
+package tariff
+
+import (
+	"fmt"
+	"solargo/inverter"
+	"strings"
+	"time"
+)
+
+//DateFormat of the validity of feed-in compensations and the installation date
+const DateFormat = "2006-01-02"
+
+//ImportPrice of the energy purchased from the grid
+type ImportPrice interface {
+	//Price per kWh at the time
+	Price(t time.Time) float64
+}
+
+//Flat price which is always the same
+type Flat float64
+
+//Price per kWh at any time
+func (f Flat) Price(t time.Time) float64 {
+	return float64(f)
+}
+
+//Window of a day with its own price, e.g. a cheaper night rate
+type Window struct {
+	From  time.Duration  // Since midnight
+	Until time.Duration  // Since midnight, windows until before from last over midnight
+	Days  []time.Weekday // Days on which the window starts, all days if empty
+	Price float64
+}
+
+//contains returns true if the time of day on the weekday is within the window
+func (w Window) contains(weekday time.Weekday, clock time.Duration) bool {
+	if w.Until <= w.From {
+		//Over midnight, the morning belongs to the window of the previous day
+		if clock >= w.From {
+			return w.onDay(weekday)
+		}
+		return clock < w.Until && w.onDay((weekday+6)%7)
+	}
+	return clock >= w.From && clock < w.Until && w.onDay(weekday)
+}
+
+func (w Window) onDay(weekday time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+//TimeOfUse prices, the first window containing a time sets its price and the default is used outside of all windows
+type TimeOfUse struct {
+	Default  float64
+	Windows  []Window
+	Location *time.Location // Of the clock times, the local time zone if nil
+}
+
+//Price per kWh at the time
+func (t *TimeOfUse) Price(at time.Time) float64 {
+	loc := t.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	local := at.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	clock := local.Sub(midnight)
+	for _, w := range t.Windows {
+		if w.contains(local.Weekday(), clock) {
+			return w.Price
+		}
+	}
+	return t.Default
+}
+
+//Price of an hour at the energy market, without fees and taxes
+type Price struct {
+	Start time.Time
+	Price float64 // per kWh
+}
+
+//Dynamic prices follow the energy market hour by hour, hours without a market price use the fallback
+type Dynamic struct {
+	Markup   float64 // Fees and taxes added to the market price per kWh
+	Fallback ImportPrice
+	prices   map[time.Time]float64
+}
+
+//Load the market prices, which replace the loaded prices of the same hours
+func (d *Dynamic) Load(prices []Price) {
+	if d.prices == nil {
+		d.prices = map[time.Time]float64{}
+	}
+	for _, p := range prices {
+		d.prices[p.Start.Truncate(time.Hour).UTC()] = p.Price
+	}
+}
+
+//Price per kWh at the time
+func (d *Dynamic) Price(t time.Time) float64 {
+	if p, ok := d.prices[t.Truncate(time.Hour).UTC()]; ok {
+		return p + d.Markup
+	}
+	if d.Fallback == nil {
+		return 0
+	}
+	return d.Fallback.Price(t)
+}
+
+//Until returns the end of the last hour with a market price, zero without prices
+func (d *Dynamic) Until() time.Time {
+	var until time.Time
+	for start := range d.prices {
+		if end := start.Add(time.Hour); end.After(until) {
+			until = end
+		}
+	}
+	return until
+}
+
+//FeedIn compensation per kWh, which is paid between from and until
+type FeedIn struct {
+	From  time.Time // Inclusive, zero if it is paid since ever
+	Until time.Time // Exclusive, zero if it is paid forever
+	Price float64
+}
+
+//Tariff of the site and the costs of the installation
+type Tariff struct {
+	Currency         string
+	Import           ImportPrice
+	FeedIn           []FeedIn  // The first one valid at a time is paid
+	FixedCosts       float64   // Per year, e.g. insurance and maintenance of the installation
+	InstallationCost float64   // To compute the payback
+	InstallationDate time.Time // Zero if the payback is computed since ever
+	Location         *time.Location
+}
+
+//FeedInPrice per kWh at the time, 0 if no compensation is valid
+func (t *Tariff) FeedInPrice(at time.Time) float64 {
+	for _, f := range t.FeedIn {
+		if (f.From.IsZero() || !at.Before(f.From)) && (f.Until.IsZero() || at.Before(f.Until)) {
+			return f.Price
+		}
+	}
+	return 0
+}
+
+//ImportPrice per kWh at the time, 0 without import price
+func (t *Tariff) ImportPrice(at time.Time) float64 {
+	if t.Import == nil {
+		return 0
+	}
+	return t.Import.Price(at)
+}
+
+//Day of the financial tracking
+type Day struct {
+	Date          time.Time // Midnight of the day
+	Purchased     inverter.WattHour
+	FedIn         inverter.WattHour
+	SelfConsumed  inverter.WattHour
+	ImportCost    float64 // Paid for the purchased energy
+	FeedInRevenue float64 // Paid for the energy fed into the grid
+	Savings       float64 // Not paid for the self consumed energy
+	FixedCosts    float64 // Share of the day of the yearly fixed costs
+}
+
+//Benefit of the installation on the day
+func (d Day) Benefit() float64 {
+	return d.Savings + d.FeedInRevenue - d.FixedCosts
+}
+
+//Compute the financials of the day from the energy of every hour, the keys are the starts of the hours in the
+//location of all maps. The self consumption of an hour is the usage, which was not purchased.
+func (t *Tariff) Compute(day time.Time, purchased, fedIn, used map[time.Time]inverter.WattHour) Day {
+	loc := t.location()
+	local := day.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+
+	d := Day{Date: start}
+	for _, h := range hours(start, end, purchased, fedIn, used) {
+		middle := h.Add(30 * time.Minute)
+		self := used[h] - purchased[h]
+		if self < 0 {
+			self = 0
+		}
+		d.Purchased += purchased[h]
+		d.FedIn += fedIn[h]
+		d.SelfConsumed += self
+		d.ImportCost += kwh(purchased[h]) * t.ImportPrice(middle)
+		d.FeedInRevenue += kwh(fedIn[h]) * t.FeedInPrice(middle)
+		d.Savings += kwh(self) * t.ImportPrice(middle)
+	}
+	yearStart := time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, loc)
+	daysOfYear := yearStart.AddDate(1, 0, 0).Sub(yearStart).Round(24*time.Hour).Hours() / 24
+	d.FixedCosts = t.FixedCosts / daysOfYear
+	return d
+}
+
+//hours starting between start and end in any of the maps
+func hours(start, end time.Time, maps ...map[time.Time]inverter.WattHour) []time.Time {
+	var keys []time.Time
+	seen := map[time.Time]bool{}
+	for _, m := range maps {
+		for h := range m {
+			if !seen[h] && !h.Before(start) && h.Before(end) {
+				seen[h] = true
+				keys = append(keys, h)
+			}
+		}
+	}
+	return keys
+}
+
+func kwh(w inverter.WattHour) float64 {
+	return float64(w.ToKWh())
+}
+
+//Payback of the installation cost by the benefit of the days between 0 and 1 or more, 0 without installation cost
+func (t *Tariff) Payback(days []Day) float64 {
+	if t.InstallationCost <= 0 {
+		return 0
+	}
+	var benefit float64
+	for _, d := range days {
+		benefit += d.Benefit()
+	}
+	return benefit / t.InstallationCost
+}
+
+//Format an amount of money in the currency
+func (t *Tariff) Format(amount float64) string {
+	return strings.TrimSpace(fmt.Sprintf("%.2f %s", amount, t.Currency))
+}
+
+func (t *Tariff) location() *time.Location {
+	if t.Location == nil {
+		return time.Local
+	}
+	return t.Location
+}
+
+//ParseClock parses a time of day like "06:30" into the duration since midnight, "24:00" is the end of the day
+func ParseClock(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time like 06:30", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+//ParseWeekday parses the English name of a weekday or its first three letters, e.g. "monday" or "Mon"
+func ParseWeekday(value string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || (len(name) == 3 && name == full[:3]) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a weekday like monday", value)
+}
+
+//ParseDate parses a date like "2020-06-01" at midnight in the location, an empty date is zero
+func ParseDate(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	t, err := time.ParseInLocation(DateFormat, strings.TrimSpace(value), loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date like 2020-06-01", value)
+	}
+	return t, nil
+}
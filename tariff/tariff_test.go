@@ -0,0 +1,196 @@
+package tariff
+
+import (
+	"math"
+	"solargo/inverter"
+	"testing"
+	"time"
+)
+
+var vienna, _ = time.LoadLocation("Europe/Vienna")
+
+func TestTimeOfUse(t *testing.T) {
+	tou := TimeOfUse{
+		Default: 0.30,
+		Windows: []Window{
+			{From: 22 * time.Hour, Until: 6 * time.Hour, Price: 0.20},
+			{From: 0, Until: 24 * time.Hour, Days: []time.Weekday{time.Saturday, time.Sunday}, Price: 0.25},
+		},
+		Location: vienna,
+	}
+
+	//2020-06-19 is a Friday
+	tests := []struct {
+		name string
+		at   time.Time
+		want float64
+	}{
+		{"Friday noon", time.Date(2020, time.June, 19, 12, 0, 0, 0, vienna), 0.30},
+		{"Friday night", time.Date(2020, time.June, 19, 22, 0, 0, 0, vienna), 0.20},
+		{"Saturday morning", time.Date(2020, time.June, 20, 5, 59, 0, 0, vienna), 0.20},
+		{"Saturday noon", time.Date(2020, time.June, 20, 12, 0, 0, 0, vienna), 0.25},
+		{"Monday morning", time.Date(2020, time.June, 22, 5, 0, 0, 0, vienna), 0.20},
+		{"Monday at six", time.Date(2020, time.June, 22, 6, 0, 0, 0, vienna), 0.30},
+		{"In UTC", time.Date(2020, time.June, 19, 20, 30, 0, 0, time.UTC), 0.20},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := tou.Price(test.at); actual != test.want {
+				t.Errorf("got %g, want %g", actual, test.want)
+			}
+		})
+	}
+
+	//The night window of Sunday belongs to Sunday, the morning of Monday to the window of Sunday
+	weekend := TimeOfUse{Default: 0.30, Windows: []Window{{From: 22 * time.Hour, Until: 6 * time.Hour, Days: []time.Weekday{time.Sunday}, Price: 0.10}}, Location: vienna}
+	if p := weekend.Price(time.Date(2020, time.June, 22, 5, 0, 0, 0, vienna)); p != 0.10 {
+		t.Errorf("Monday morning: got %g, want 0.10", p)
+	}
+	if p := weekend.Price(time.Date(2020, time.June, 21, 5, 0, 0, 0, vienna)); p != 0.30 {
+		t.Errorf("Sunday morning: got %g, want 0.30", p)
+	}
+}
+
+func TestDynamic(t *testing.T) {
+	start := time.Date(2020, time.June, 21, 10, 0, 0, 0, time.UTC)
+	d := Dynamic{Markup: 0.15, Fallback: Flat(0.30)}
+	d.Load([]Price{{Start: start, Price: 0.05}, {Start: start.Add(time.Hour), Price: -0.02}})
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want float64
+	}{
+		{"Market price", start.Add(30 * time.Minute), 0.20},
+		{"Negative market price", start.Add(time.Hour).In(vienna), 0.13},
+		{"Fallback", start.Add(2 * time.Hour), 0.30},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := d.Price(test.at); math.Abs(actual-test.want) > 1e-9 {
+				t.Errorf("got %g, want %g", actual, test.want)
+			}
+		})
+	}
+
+	if u := d.Until(); !u.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("got until %s", u)
+	}
+	if u := (&Dynamic{}).Until(); !u.IsZero() {
+		t.Errorf("Without prices until should be zero, got %s", u)
+	}
+	if p := (&Dynamic{}).Price(start); p != 0 {
+		t.Errorf("Without prices and fallback: got %g", p)
+	}
+}
+
+func TestFeedInPrice(t *testing.T) {
+	tariff := Tariff{FeedIn: []FeedIn{
+		{Until: time.Date(2021, time.January, 1, 0, 0, 0, 0, vienna), Price: 0.08},
+		{From: time.Date(2021, time.July, 1, 0, 0, 0, 0, vienna), Price: 0.06},
+	}}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want float64
+	}{
+		{"First", time.Date(2020, time.December, 31, 23, 59, 0, 0, vienna), 0.08},
+		{"Between", time.Date(2021, time.January, 1, 0, 0, 0, 0, vienna), 0},
+		{"Second", time.Date(2021, time.July, 1, 0, 0, 0, 0, vienna), 0.06},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := tariff.FeedInPrice(test.at); actual != test.want {
+				t.Errorf("got %g, want %g", actual, test.want)
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, vienna)
+	tariff := Tariff{
+		Import:     &TimeOfUse{Default: 0.30, Windows: []Window{{From: 22 * time.Hour, Until: 6 * time.Hour, Price: 0.20}}, Location: vienna},
+		FeedIn:     []FeedIn{{Price: 0.08}},
+		FixedCosts: 366,
+		Location:   vienna,
+	}
+
+	//The hours are keyed in UTC like the hourly values of the database
+	hour := func(h int) time.Time { return day.Add(time.Duration(h) * time.Hour).UTC() }
+	purchased := map[time.Time]inverter.WattHour{hour(3): 1000, hour(12): 200, hour(30): 5000}
+	fedIn := map[time.Time]inverter.WattHour{hour(12): 2000}
+	used := map[time.Time]inverter.WattHour{hour(3): 1000, hour(12): 1200, hour(-1): 3000}
+
+	d := tariff.Compute(day.Add(15*time.Hour), purchased, fedIn, used)
+	want := Day{
+		Date:          day,
+		Purchased:     1200,
+		FedIn:         2000,
+		SelfConsumed:  1000,
+		ImportCost:    0.20 + 0.06,
+		FeedInRevenue: 0.16,
+		Savings:       0.30,
+		FixedCosts:    1,
+	}
+	if !d.Date.Equal(want.Date) || d.Purchased != want.Purchased || d.FedIn != want.FedIn || d.SelfConsumed != want.SelfConsumed ||
+		math.Abs(d.ImportCost-want.ImportCost) > 1e-9 || math.Abs(d.FeedInRevenue-want.FeedInRevenue) > 1e-9 ||
+		math.Abs(d.Savings-want.Savings) > 1e-9 || math.Abs(d.FixedCosts-want.FixedCosts) > 1e-9 {
+		t.Errorf("got %+v, want %+v", d, want)
+	}
+	if b := d.Benefit(); math.Abs(b-(0.30+0.16-1)) > 1e-9 {
+		t.Errorf("got benefit %g", b)
+	}
+}
+
+func TestPayback(t *testing.T) {
+	days := []Day{{Savings: 3, FeedInRevenue: 2}, {Savings: 4, FixedCosts: 1}}
+	if p := (&Tariff{InstallationCost: 80}).Payback(days); p != 0.1 {
+		t.Errorf("got %g, want 0.1", p)
+	}
+	if p := (&Tariff{}).Payback(days); p != 0 {
+		t.Errorf("Without installation cost: got %g, want 0", p)
+	}
+	if s := (&Tariff{Currency: "EUR"}).Format(12.345); s != "12.35 EUR" {
+		t.Errorf("got %q", s)
+	}
+}
+
+func TestParse(t *testing.T) {
+	clocks := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"06:30", 6*time.Hour + 30*time.Minute, true},
+		{"24:00", 24 * time.Hour, true},
+		{"25:00", 0, false},
+		{"sunrise", 0, false},
+	}
+	for _, c := range clocks {
+		d, err := ParseClock(c.value)
+		if d != c.want || (err == nil) != c.ok {
+			t.Errorf("ParseClock(%q): got %s %v", c.value, d, err)
+		}
+	}
+
+	for value, want := range map[string]time.Weekday{"monday": time.Monday, "Sun": time.Sunday, " SATURDAY ": time.Saturday} {
+		if d, err := ParseWeekday(value); d != want || err != nil {
+			t.Errorf("ParseWeekday(%q): got %s %v", value, d, err)
+		}
+	}
+	if _, err := ParseWeekday("mo"); err == nil {
+		t.Errorf("ParseWeekday should fail for an abbreviation with two letters")
+	}
+
+	if d, err := ParseDate("2020-06-01", vienna); err != nil || !d.Equal(time.Date(2020, time.June, 1, 0, 0, 0, 0, vienna)) {
+		t.Errorf("ParseDate: got %s %v", d, err)
+	}
+	if d, err := ParseDate("", vienna); err != nil || !d.IsZero() {
+		t.Errorf("An empty date should be zero, got %s %v", d, err)
+	}
+	if _, err := ParseDate("01.06.2020", vienna); err == nil {
+		t.Errorf("ParseDate should fail for a German date")
+	}
+}
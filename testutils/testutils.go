@@ -1,12 +1,17 @@
 package testutils
 
 import (
+	"context"
 	"fmt"
+	"solargo/emissions"
+	"solargo/energy"
 	"solargo/inverter"
 	"solargo/persistence"
+	"solargo/tariff"
 	"solargo/weather"
 	"solargo/yield_forecast"
 	"testing"
+	"time"
 )
 
 //AssertPanic tests if the provided function panics
@@ -24,14 +29,14 @@ type SuccessInverter struct {
 }
 
 //GetInverterStatistics always returns the standard statistics
-func (f *SuccessInverter) GetInverterStatistics() (inverter.DailyStatistics, error) {
+func (f *SuccessInverter) GetInverterStatistics(ctx context.Context) (inverter.DailyStatistics, error) {
 	var statistics inverter.DailyStatistics
 	return statistics, nil
 
 }
 
 //RetrieveData always returns the standard data
-func (f *SuccessInverter) RetrieveData() (inverter.Data, error) {
+func (f *SuccessInverter) RetrieveData(ctx context.Context) (inverter.Data, error) {
 	var data inverter.Data
 
 	return data, nil
@@ -42,14 +47,14 @@ type ErrorInverter struct {
 }
 
 //GetInverterStatistics always produces an error
-func (f *ErrorInverter) GetInverterStatistics() (inverter.DailyStatistics, error) {
+func (f *ErrorInverter) GetInverterStatistics(ctx context.Context) (inverter.DailyStatistics, error) {
 	var statistics inverter.DailyStatistics
 	return statistics, fmt.Errorf("Error")
 
 }
 
 //RetrieveData always produces an error
-func (f *ErrorInverter) RetrieveData() (inverter.Data, error) {
+func (f *ErrorInverter) RetrieveData(ctx context.Context) (inverter.Data, error) {
 	var data inverter.Data
 
 	return data, fmt.Errorf("Error")
@@ -61,14 +66,112 @@ type SuccessDatabase struct{}
 //SendData of the inverter to nowhere
 func (db *SuccessDatabase) SendData(data inverter.Data) {}
 
+//SendSiteData of the inverter to nowhere
+func (db *SuccessDatabase) SendSiteData(data inverter.Data) {}
+
+//SendArchiveData to nowhere
+func (db *SuccessDatabase) SendArchiveData(data []inverter.Data) error { return nil }
+
 //SendWeather updates nothing
 func (db *SuccessDatabase) SendWeather(data weather.Data) {}
 
+//SendWeatherForecast updates nothing
+func (db *SuccessDatabase) SendWeatherForecast(data []weather.Forecast) {}
+
 //SendYieldForecast updates nothing
 func (db *SuccessDatabase) SendYieldForecast(data []yield_forecast.Data) {}
 
+//Flush nothing
+func (db *SuccessDatabase) Flush() error { return nil }
+
 //GetTodaysProduction from nothing
 func (db *SuccessDatabase) GetTodaysProduction() ([]persistence.ProductionStamps, error) {
 	var ps []persistence.ProductionStamps
 	return ps, nil
 }
+
+//GetProduction from nothing
+func (db *SuccessDatabase) GetProduction(from, to time.Time) ([]persistence.ProductionStamps, error) {
+	var ps []persistence.ProductionStamps
+	return ps, nil
+}
+
+//GetTodaysPowerFlow from nothing
+func (db *SuccessDatabase) GetTodaysPowerFlow() (persistence.PowerFlow, error) {
+	var flow persistence.PowerFlow
+	return flow, nil
+}
+
+//GetTodaysYieldForecast from nothing
+func (db *SuccessDatabase) GetTodaysYieldForecast() ([]yield_forecast.Data, error) {
+	var data []yield_forecast.Data
+	return data, nil
+}
+
+//GetWeatherForecast from nothing
+func (db *SuccessDatabase) GetWeatherForecast(from, to time.Time) ([]weather.Forecast, error) {
+	var data []weather.Forecast
+	return data, nil
+}
+
+//SendYieldSnapshot to nowhere
+func (db *SuccessDatabase) SendYieldSnapshot(provider string, issued time.Time, data []yield_forecast.Data) {
+}
+
+//GetYieldSnapshots from nothing
+func (db *SuccessDatabase) GetYieldSnapshots(provider, lead string, from, to time.Time) ([]yield_forecast.Data, error) {
+	var data []yield_forecast.Data
+	return data, nil
+}
+
+//SendForecastAccuracy to nowhere
+func (db *SuccessDatabase) SendForecastAccuracy(provider, lead string, day time.Time, metrics yield_forecast.Metrics) {
+}
+
+//GetForecastAccuracy from nothing
+func (db *SuccessDatabase) GetForecastAccuracy(provider, lead string, from, to time.Time) ([]yield_forecast.Metrics, error) {
+	var metrics []yield_forecast.Metrics
+	return metrics, nil
+}
+
+//GetMeter from nothing
+func (db *SuccessDatabase) GetMeter(from, to time.Time) (persistence.MeterFlow, error) {
+	var flow persistence.MeterFlow
+	return flow, nil
+}
+
+//SendEnergy to nowhere
+func (db *SuccessDatabase) SendEnergy(counters []energy.Counters) {
+}
+
+//GetEnergy from nothing
+func (db *SuccessDatabase) GetEnergy(period string, from, to time.Time) ([]energy.Counters, error) {
+	var counters []energy.Counters
+	return counters, nil
+}
+
+//SendFinancials to nowhere
+func (db *SuccessDatabase) SendFinancials(day tariff.Day) {
+}
+
+//GetFinancials from nothing
+func (db *SuccessDatabase) GetFinancials(from, to time.Time) ([]tariff.Day, error) {
+	var days []tariff.Day
+	return days, nil
+}
+
+//SendPrices to nowhere
+func (db *SuccessDatabase) SendPrices(prices []tariff.Price) {
+}
+
+//GetPrices from nothing
+func (db *SuccessDatabase) GetPrices(from, to time.Time) ([]tariff.Price, error) {
+	var prices []tariff.Price
+	return prices, nil
+}
+
+//GetEmissionFactors from nothing
+func (db *SuccessDatabase) GetEmissionFactors(from, to time.Time) ([]emissions.Intensity, error) {
+	var intensities []emissions.Intensity
+	return intensities, nil
+}
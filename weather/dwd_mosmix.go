@@ -0,0 +1,385 @@
+package weather
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nathan-osman/go-sunrise"
+)
+
+//DWDURL of the open data server of the Deutscher Wetterdienst
+const DWDURL = "https://opendata.dwd.de"
+
+//DWDStationsURL of the MOSMIX station catalogue
+const DWDStationsURL = "https://www.dwd.de/DE/leistungen/met_verfahren_mosmix/mosmix_stationskatalog.cfg?view=nasPublication"
+
+//DWDMosmix implementation of the GenericWeather interface using the MOSMIX_L forecast of the nearest station
+type DWDMosmix struct {
+	Latitude    float64
+	Longitude   float64
+	Station     string // MOSMIX station ID, the nearest station is searched if empty
+	URL         string
+	StationsURL string
+
+	mu sync.Mutex
+}
+
+//mosmixStation of the station catalogue or a forecast file
+type mosmixStation struct {
+	ID        string
+	Name      string
+	Latitude  float64
+	Longitude float64
+}
+
+//mosmixForecast of a station, every element has one value per time step
+type mosmixForecast struct {
+	Station  mosmixStation
+	Times    []time.Time
+	Elements map[string][]float64 // NaN if a value is missing
+}
+
+//RetrieveForecast of the current hour from the latest MOSMIX forecast
+func (d *DWDMosmix) RetrieveForecast(ctx context.Context) (Data, error) {
+	var data Data
+	forecast, err := d.retrieve(ctx)
+	if err != nil {
+		return data, err
+	}
+
+	now := time.Now()
+	periods := forecast.periods()
+	if len(periods) == 0 {
+		return data, fmt.Errorf("Forecast of station %s is empty", forecast.Station.ID)
+	}
+	current := periods[0]
+	for _, p := range periods {
+		if p.Date.After(now) {
+			break
+		}
+		current = p
+	}
+
+	rise, set := sunrise.SunriseSunset(d.Latitude, d.Longitude, now.Year(), now.Month(), now.Day())
+	data.Date = current.Date
+	data.LocationName = forecast.Station.Name
+	data.Sunrise = rise
+	data.Sunset = set
+	data.CloudDensity = current.CloudDensity
+	data.Temperature = current.Temperature
+	data.Pressure = current.Pressure
+	data.Humidity = current.Humidity
+	data.WindSpeed = current.WindSpeed
+	data.WindDirection = forecast.value("DD", current.Date.Add(time.Hour))
+	data.RainAmount = current.RainAmount
+	return data, nil
+}
+
+//RetrieveHourlyForecast of the next 10 days including the global irradiance from the latest MOSMIX forecast
+func (d *DWDMosmix) RetrieveHourlyForecast(ctx context.Context) ([]Forecast, error) {
+	forecast, err := d.retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return forecast.periods(), nil
+}
+
+//retrieve the latest forecast of the station
+func (d *DWDMosmix) retrieve(ctx context.Context) (mosmixForecast, error) {
+	station, err := d.station(ctx)
+	if err != nil {
+		return mosmixForecast{}, err
+	}
+
+	uri := fmt.Sprintf("%s/weather/local_forecasts/mos/MOSMIX_L/single_stations/%s/kml/MOSMIX_L_LATEST_%s.kmz", d.URL, station, station)
+	body, err := get(ctx, uri)
+	if err != nil {
+		return mosmixForecast{}, err
+	}
+
+	forecasts, err := parseMosmixKMZ(body)
+	if err != nil {
+		return mosmixForecast{}, err
+	}
+	return nearestForecast(forecasts, d.Latitude, d.Longitude)
+}
+
+//station which is configured or the nearest one of the station catalogue, which is only read once
+func (d *DWDMosmix) station(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.Station != "" {
+		return d.Station, nil
+	}
+
+	body, err := get(ctx, d.StationsURL)
+	if err != nil {
+		return "", err
+	}
+	stations, err := parseMosmixStations(bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	nearest, ok := nearestStation(stations, d.Latitude, d.Longitude)
+	if !ok {
+		return "", fmt.Errorf("MOSMIX station catalogue is empty")
+	}
+	d.Station = nearest.ID
+	return d.Station, nil
+}
+
+//get the body of the uri, the request is cancelled with the context
+func get(ctx context.Context, uri string) ([]byte, error) {
+	httpResult, err := httpGet(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpResult.Body.Close()
+
+	if httpResult.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error while receiving %s: %s", uri, httpResult.Status)
+	}
+	return ioutil.ReadAll(httpResult.Body)
+}
+
+//periods of the forecast, radiation and precipitation are the values of the hour before a time step
+func (f mosmixForecast) periods() []Forecast {
+	forecasts := make([]Forecast, len(f.Times))
+	for i, t := range f.Times {
+		p := &forecasts[i]
+		p.Date = t.Add(-time.Hour)
+		p.Period = time.Hour
+		p.CloudDensity = orZero(f.at("N", i))
+		p.Temperature = orZero(kelvinToCelsius(f.at("TTT", i)))
+		p.Pressure = orZero(f.at("PPPP", i) / 100)
+		p.Humidity = orZero(relativeHumidity(f.at("TTT", i), f.at("Td", i)))
+		p.WindSpeed = orZero(f.at("FF", i))
+		p.RainAmount = orZero(f.at("RR1c", i))
+		if rad := f.at("Rad1h", i); !math.IsNaN(rad) {
+			//kJ/m² within the hour as mean W/m²
+			p.Irradiance = rad * 1000 / 3600
+			p.HasIrradiance = true
+		}
+	}
+	return forecasts
+}
+
+//at returns the value of the element at the time step, NaN if it is missing
+func (f mosmixForecast) at(element string, i int) float64 {
+	values := f.Elements[element]
+	if i >= len(values) {
+		return math.NaN()
+	}
+	return values[i]
+}
+
+//value of the element at the time step t, 0 if it is missing
+func (f mosmixForecast) value(element string, t time.Time) float64 {
+	for i, ts := range f.Times {
+		if ts.Equal(t) {
+			return orZero(f.at(element, i))
+		}
+	}
+	return 0
+}
+
+func orZero(value float64) float64 {
+	if math.IsNaN(value) {
+		return 0
+	}
+	return value
+}
+
+func kelvinToCelsius(k float64) float64 {
+	return k - 273.15
+}
+
+//relativeHumidity in percent from the temperature and dew point in Kelvin using the Magnus formula
+func relativeHumidity(temperature, dewPoint float64) float64 {
+	magnus := func(k float64) float64 {
+		c := kelvinToCelsius(k)
+		return math.Exp(17.625 * c / (243.04 + c))
+	}
+	return 100 * magnus(dewPoint) / magnus(temperature)
+}
+
+//parseMosmixKMZ parses the KML file within the zipped KMZ file
+func parseMosmixKMZ(kmz []byte) ([]mosmixForecast, error) {
+	archive, err := zip.NewReader(bytes.NewReader(kmz), int64(len(kmz)))
+	if err != nil {
+		return nil, fmt.Errorf("Can not open KMZ file: %s", err)
+	}
+	for _, file := range archive.File {
+		if !strings.HasSuffix(strings.ToLower(file.Name), ".kml") {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Can not open %s: %s", file.Name, err)
+		}
+		defer r.Close()
+		return parseMosmixKML(r)
+	}
+	return nil, fmt.Errorf("KMZ file does not contain a KML file")
+}
+
+//parseMosmixKML parses the forecasts of all stations of a MOSMIX KML file
+func parseMosmixKML(r io.Reader) ([]mosmixForecast, error) {
+	type Document struct {
+		TimeSteps []string `xml:"Document>ExtendedData>ProductDefinition>ForecastTimeSteps>TimeStep"`
+		Placemark []struct {
+			Name        string `xml:"name"`
+			Description string `xml:"description"`
+			Coordinates string `xml:"Point>coordinates"`
+			Forecast    []struct {
+				Element string `xml:"elementName,attr"`
+				Value   string `xml:"value"`
+			} `xml:"ExtendedData>Forecast"`
+		} `xml:"Document>Placemark"`
+	}
+
+	var doc Document
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charsetReader
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Can not parse KML file: %s", err)
+	}
+
+	times := make([]time.Time, len(doc.TimeSteps))
+	for i, step := range doc.TimeSteps {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(step))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid time step %q: %s", step, err)
+		}
+		times[i] = t
+	}
+
+	forecasts := make([]mosmixForecast, len(doc.Placemark))
+	for i, p := range doc.Placemark {
+		f := &forecasts[i]
+		f.Station.ID = strings.TrimSpace(p.Name)
+		f.Station.Name = strings.TrimSpace(p.Description)
+		coordinates := strings.Split(strings.TrimSpace(p.Coordinates), ",")
+		if len(coordinates) < 2 {
+			return nil, fmt.Errorf("Station %s has invalid coordinates %q", f.Station.ID, p.Coordinates)
+		}
+		f.Station.Longitude, _ = strconv.ParseFloat(coordinates[0], 64)
+		f.Station.Latitude, _ = strconv.ParseFloat(coordinates[1], 64)
+		f.Times = times
+		f.Elements = map[string][]float64{}
+		for _, e := range p.Forecast {
+			fields := strings.Fields(e.Value)
+			values := make([]float64, len(fields))
+			for j, field := range fields {
+				v, err := strconv.ParseFloat(field, 64)
+				if err != nil {
+					//Missing values are "-"
+					v = math.NaN()
+				}
+				values[j] = v
+			}
+			f.Elements[e.Element] = values
+		}
+	}
+	return forecasts, nil
+}
+
+//charsetReader converts the ISO-8859-1 encoding of the KML files into UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	if !strings.EqualFold(charset, "ISO-8859-1") {
+		return nil, fmt.Errorf("Unsupported charset %s", charset)
+	}
+	latin1, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	//Every byte of ISO-8859-1 is the code point of the character
+	runes := make([]rune, len(latin1))
+	for i, b := range latin1 {
+		runes[i] = rune(b)
+	}
+	return strings.NewReader(string(runes)), nil
+}
+
+//parseMosmixStations parses the station catalogue, whose coordinates are given in degrees and minutes
+func parseMosmixStations(r io.Reader) ([]mosmixStation, error) {
+	var stations []mosmixStation
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		//ID, ICAO code or "----", name, latitude, longitude and elevation
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[0] == "ID" || strings.HasPrefix(fields[0], "-") {
+			continue
+		}
+		lat, errLat := strconv.ParseFloat(fields[len(fields)-3], 64)
+		lon, errLon := strconv.ParseFloat(fields[len(fields)-2], 64)
+		if errLat != nil || errLon != nil {
+			continue
+		}
+		name := strings.Join(fields[2:len(fields)-3], " ")
+		stations = append(stations, mosmixStation{fields[0], name, degreesMinutes(lat), degreesMinutes(lon)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Can not read station catalogue: %s", err)
+	}
+	return stations, nil
+}
+
+//degreesMinutes converts 48.12 meaning 48°12' into decimal degrees
+func degreesMinutes(value float64) float64 {
+	degrees := math.Trunc(value)
+	return degrees + (value-degrees)*100/60
+}
+
+//distance between two coordinates in kilometers
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+//nearestStation to the coordinates, false if there are no stations
+func nearestStation(stations []mosmixStation, latitude, longitude float64) (mosmixStation, bool) {
+	var nearest mosmixStation
+	min := math.Inf(1)
+	for _, s := range stations {
+		if d := distance(latitude, longitude, s.Latitude, s.Longitude); d < min {
+			nearest, min = s, d
+		}
+	}
+	return nearest, !math.IsInf(min, 1)
+}
+
+//nearestForecast of the station nearest to the coordinates
+func nearestForecast(forecasts []mosmixForecast, latitude, longitude float64) (mosmixForecast, error) {
+	stations := make([]mosmixStation, len(forecasts))
+	for i, f := range forecasts {
+		stations[i] = f.Station
+	}
+	nearest, ok := nearestStation(stations, latitude, longitude)
+	if !ok {
+		return mosmixForecast{}, fmt.Errorf("MOSMIX file contains no station")
+	}
+	for _, f := range forecasts {
+		if f.Station.ID == nearest.ID {
+			return f, nil
+		}
+	}
+	return mosmixForecast{}, fmt.Errorf("MOSMIX file contains no station")
+}
@@ -0,0 +1,177 @@
+package weather
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseMosmixKML(t *testing.T) {
+	file, err := os.Open("testdata/MOSMIX_L_LATEST_11035.kml")
+	if err != nil {
+		t.Fatalf("Could not open sample: %s", err)
+	}
+	defer file.Close()
+
+	forecasts, err := parseMosmixKML(file)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if len(forecasts) != 1 {
+		t.Fatalf("got %d stations, want 1", len(forecasts))
+	}
+
+	f := forecasts[0]
+	if f.Station != (mosmixStation{"11035", "WIEN/HOHE WARTE", 48.25, 16.36}) {
+		t.Errorf("Unexpected station %+v", f.Station)
+	}
+	if len(f.Times) != 4 || !f.Times[0].Equal(time.Date(2020, time.November, 23, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected time steps %v", f.Times)
+	}
+	if rad := f.Elements["Rad1h"]; len(rad) != 4 || rad[2] != 540 || !math.IsNaN(rad[3]) {
+		t.Errorf("Unexpected irradiance %v", rad)
+	}
+}
+
+func TestMosmixPeriods(t *testing.T) {
+	kmz, err := ioutil.ReadFile("testdata/MOSMIX_L_LATEST_11035.kmz")
+	if err != nil {
+		t.Fatalf("Could not read sample: %s", err)
+	}
+	forecasts, err := parseMosmixKMZ(kmz)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	periods := forecasts[0].periods()
+	if len(periods) != 4 {
+		t.Fatalf("got %d periods, want 4", len(periods))
+	}
+
+	var tests = []struct {
+		index         int
+		date          time.Time
+		irradiance    float64
+		hasIrradiance bool
+		temperature   float64
+		rain          float64
+	}{
+		{0, time.Date(2020, time.November, 23, 9, 0, 0, 0, time.UTC), 50, true, 3.9, 0},
+		{2, time.Date(2020, time.November, 23, 11, 0, 0, 0, time.UTC), 150, true, 5.3, 0.1},
+		{3, time.Date(2020, time.November, 23, 12, 0, 0, 0, time.UTC), 0, false, 5.5, 0},
+	}
+
+	for _, tt := range tests {
+		p := periods[tt.index]
+		if !p.Date.Equal(tt.date) || p.Period != time.Hour || p.HasIrradiance != tt.hasIrradiance ||
+			math.Abs(p.Irradiance-tt.irradiance) > 1e-9 || math.Abs(p.Temperature-tt.temperature) > 1e-9 || p.RainAmount != tt.rain {
+			t.Errorf("Unexpected period %d: %+v", tt.index, p)
+		}
+	}
+	if p := periods[0]; p.Pressure != 1029.8 || p.CloudDensity != 98 || p.WindSpeed != 2.06 || math.Abs(p.Humidity-83.7) > 0.1 {
+		t.Errorf("Unexpected period %+v", p)
+	}
+}
+
+func TestParseMosmixKMZErrors(t *testing.T) {
+	if _, err := parseMosmixKMZ([]byte("not a zip")); err == nil {
+		t.Errorf("Should produce Error")
+	}
+}
+
+func TestNearestMosmixStation(t *testing.T) {
+	file, err := os.Open("testdata/mosmix_stationskatalog.cfg")
+	if err != nil {
+		t.Fatalf("Could not open sample: %s", err)
+	}
+	defer file.Close()
+
+	stations, err := parseMosmixStations(file)
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if len(stations) != 6 {
+		t.Fatalf("got %d stations, want 6", len(stations))
+	}
+	if s := stations[3]; s.ID != "11034" || s.Name != "WIEN/INNERE STADT" || math.Abs(s.Latitude-48.2) > 1e-9 || math.Abs(s.Longitude-(16+22.0/60)) > 1e-9 {
+		t.Errorf("Unexpected station %+v", s)
+	}
+	if s := stations[0]; math.Abs(s.Longitude-(-8-40.0/60)) > 1e-9 {
+		t.Errorf("Negative coordinates should be converted, got %+v", s)
+	}
+
+	var tests = []struct {
+		testName  string
+		latitude  float64
+		longitude float64
+		want      string
+	}{
+		{"Vienna", 48.2, 16.37, "11034"},
+		{"Airport", 48.1, 16.6, "11036"},
+		{"Munich", 48.1, 11.6, "10865"},
+		{"Berlin", 52.5, 13.4, "10382"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if s, _ := nearestStation(stations, tt.latitude, tt.longitude); s.ID != tt.want {
+				t.Errorf("got %s, want %s", s.ID, tt.want)
+			}
+		})
+	}
+
+	if _, ok := nearestStation(nil, 48.2, 16.37); ok {
+		t.Errorf("Without stations there is no nearest one")
+	}
+}
+
+func TestDWDMosmix(t *testing.T) {
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		file := ""
+		switch r.URL.Path {
+		case "/stations":
+			file = "testdata/mosmix_stationskatalog.cfg"
+		case "/weather/local_forecasts/mos/MOSMIX_L/single_stations/11035/kml/MOSMIX_L_LATEST_11035.kmz":
+			file = "testdata/MOSMIX_L_LATEST_11035.kmz"
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := ioutil.ReadFile(file)
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	d := DWDMosmix{Latitude: 48.25, Longitude: 16.36, URL: ts.URL, StationsURL: ts.URL + "/stations"}
+	forecast, err := d.RetrieveHourlyForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if len(forecast) != 4 || !forecast[1].HasIrradiance || forecast[1].Irradiance != 100 {
+		t.Errorf("Unexpected forecast %+v", forecast)
+	}
+
+	data, err := d.RetrieveForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if data.LocationName != "WIEN/HOHE WARTE" || data.WindDirection != 140 {
+		t.Errorf("Unexpected weather %+v", data)
+	}
+
+	if d.Station != "11035" || requests["/stations"] != 1 {
+		t.Errorf("The nearest station should be searched once, got station %s and %d requests", d.Station, requests["/stations"])
+	}
+
+	d.Station = "99999"
+	if _, err := d.RetrieveHourlyForecast(context.Background()); err == nil {
+		t.Errorf("Unknown station should produce Error")
+	}
+}
@@ -0,0 +1,193 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nathan-osman/go-sunrise"
+)
+
+//OpenMeteoURL Api endpoint
+const OpenMeteoURL = "https://api.open-meteo.com"
+
+//OpenMeteoForecastDays requested from the API
+const OpenMeteoForecastDays = 3
+
+//openMeteoCurrent are the variables of the current conditions
+const openMeteoCurrent = "temperature_2m,relative_humidity_2m,pressure_msl,cloud_cover,wind_speed_10m,wind_direction_10m,rain,snowfall,weather_code"
+
+//openMeteoHourly are the variables of the hourly forecast
+const openMeteoHourly = "temperature_2m,relative_humidity_2m,pressure_msl,cloud_cover,wind_speed_10m,rain,snowfall,weather_code,shortwave_radiation,direct_radiation,diffuse_radiation"
+
+//OpenMeteo implementation of the GenericWeather interface, the API does not need a token
+type OpenMeteo struct {
+	Latitude  float64
+	Longitude float64
+	URL       string
+}
+
+//openMeteoResult of the forecast API, times are unix timestamps
+type openMeteoResult struct {
+	Current struct {
+		Time          int64   `json:"time"`
+		Temperature   float64 `json:"temperature_2m"`
+		Humidity      float64 `json:"relative_humidity_2m"`
+		Pressure      float64 `json:"pressure_msl"`
+		CloudCover    float64 `json:"cloud_cover"`
+		WindSpeed     float64 `json:"wind_speed_10m"`
+		WindDirection float64 `json:"wind_direction_10m"`
+		Rain          float64 `json:"rain"`
+		Snowfall      float64 `json:"snowfall"`
+		WeatherCode   int     `json:"weather_code"`
+	} `json:"current"`
+	Hourly struct {
+		Time        []int64   `json:"time"`
+		Temperature []float64 `json:"temperature_2m"`
+		Humidity    []float64 `json:"relative_humidity_2m"`
+		Pressure    []float64 `json:"pressure_msl"`
+		CloudCover  []float64 `json:"cloud_cover"`
+		WindSpeed   []float64 `json:"wind_speed_10m"`
+		Rain        []float64 `json:"rain"`
+		Snowfall    []float64 `json:"snowfall"`
+		WeatherCode []int     `json:"weather_code"`
+		Shortwave   []float64 `json:"shortwave_radiation"`
+		Direct      []float64 `json:"direct_radiation"`
+		Diffuse     []float64 `json:"diffuse_radiation"`
+	} `json:"hourly"`
+	Reason string `json:"reason"`
+}
+
+//RetrieveForecast of the current conditions using the Open-Meteo API
+func (o *OpenMeteo) RetrieveForecast(ctx context.Context) (Data, error) {
+	var data Data
+	result, err := o.request(ctx, url.Values{"current": {openMeteoCurrent}})
+	if err != nil {
+		return data, err
+	}
+
+	now := time.Now()
+	rise, set := sunrise.SunriseSunset(o.Latitude, o.Longitude, now.Year(), now.Month(), now.Day())
+
+	c := result.Current
+	data.Date = time.Unix(c.Time, 0)
+	data.LocationName = fmt.Sprintf("%.4f,%.4f", o.Latitude, o.Longitude)
+	data.Sunrise = rise
+	data.Sunset = set
+	data.CloudDensity = c.CloudCover
+	data.Temperature = c.Temperature
+	data.Pressure = c.Pressure
+	data.Humidity = c.Humidity
+	data.SkyDescription = wmoDescription(c.WeatherCode)
+	data.WindSpeed = c.WindSpeed
+	data.WindDirection = c.WindDirection
+	data.RainAmount = c.Rain
+	data.SnowAmount = c.Snowfall * 10
+	return data, nil
+}
+
+//RetrieveHourlyForecast of the next days including the irradiance using the Open-Meteo API
+func (o *OpenMeteo) RetrieveHourlyForecast(ctx context.Context) ([]Forecast, error) {
+	result, err := o.request(ctx, url.Values{
+		"hourly":        {openMeteoHourly},
+		"forecast_days": {fmt.Sprint(OpenMeteoForecastDays)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := result.Hourly
+	for _, values := range [][]float64{h.Temperature, h.Humidity, h.Pressure, h.CloudCover, h.WindSpeed, h.Rain, h.Snowfall, h.Shortwave, h.Direct, h.Diffuse} {
+		if len(values) != len(h.Time) {
+			return nil, fmt.Errorf("Error while receiving weather forecast: %d times but %d values", len(h.Time), len(values))
+		}
+	}
+
+	forecasts := make([]Forecast, len(h.Time))
+	for i, ts := range h.Time {
+		f := &forecasts[i]
+		//Radiation, rain and snow are the values of the preceding hour
+		f.Date = time.Unix(ts, 0).Add(-time.Hour)
+		f.Period = time.Hour
+		f.CloudDensity = h.CloudCover[i]
+		f.Temperature = h.Temperature[i]
+		f.Pressure = h.Pressure[i]
+		f.Humidity = h.Humidity[i]
+		f.WindSpeed = h.WindSpeed[i]
+		f.RainAmount = h.Rain[i]
+		f.SnowAmount = h.Snowfall[i] * 10
+		if i < len(h.WeatherCode) {
+			f.SkyDescription = wmoDescription(h.WeatherCode[i])
+		}
+		f.Irradiance = h.Shortwave[i]
+		f.Direct = h.Direct[i]
+		f.Diffuse = h.Diffuse[i]
+		f.HasIrradiance = true
+	}
+	return forecasts, nil
+}
+
+//request the forecast API with the coordinates, metric units and unix timestamps
+func (o *OpenMeteo) request(ctx context.Context, query url.Values) (openMeteoResult, error) {
+	var result openMeteoResult
+	query.Set("latitude", fmt.Sprint(o.Latitude))
+	query.Set("longitude", fmt.Sprint(o.Longitude))
+	query.Set("wind_speed_unit", "ms")
+	query.Set("timeformat", "unixtime")
+	query.Set("timezone", "UTC")
+
+	httpResult, err := httpGet(ctx, fmt.Sprintf("%s/v1/forecast?%s", o.URL, query.Encode()))
+	if err != nil {
+		return result, err
+	}
+
+	defer httpResult.Body.Close()
+
+	err = json.NewDecoder(httpResult.Body).Decode(&result)
+	if err != nil {
+		return result, fmt.Errorf("Error while receiving weather data: %s", err)
+	}
+	if httpResult.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("Error while receiving weather data: %s %s", httpResult.Status, result.Reason)
+	}
+	return result, nil
+}
+
+//wmoDescriptions of the WMO weather interpretation codes used by Open-Meteo
+var wmoDescriptions = map[int]string{
+	0:  "clear sky",
+	1:  "mainly clear",
+	2:  "partly cloudy",
+	3:  "overcast",
+	45: "fog",
+	48: "depositing rime fog",
+	51: "light drizzle",
+	53: "moderate drizzle",
+	55: "dense drizzle",
+	56: "light freezing drizzle",
+	57: "dense freezing drizzle",
+	61: "slight rain",
+	63: "moderate rain",
+	65: "heavy rain",
+	66: "light freezing rain",
+	67: "heavy freezing rain",
+	71: "slight snow fall",
+	73: "moderate snow fall",
+	75: "heavy snow fall",
+	77: "snow grains",
+	80: "slight rain showers",
+	81: "moderate rain showers",
+	82: "violent rain showers",
+	85: "slight snow showers",
+	86: "heavy snow showers",
+	95: "thunderstorm",
+	96: "thunderstorm with slight hail",
+	99: "thunderstorm with heavy hail",
+}
+
+func wmoDescription(code int) string {
+	return wmoDescriptions[code]
+}
@@ -0,0 +1,112 @@
+package weather
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//openMeteoServer answers with the recorded responses of the Open-Meteo API
+func openMeteoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/forecast" || q.Get("latitude") != "48.2" || q.Get("longitude") != "16.37" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":true,"reason":"Invalid coordinates"}`))
+			return
+		}
+		file := "testdata/open_meteo_current.json"
+		if q.Get("hourly") != "" {
+			file = "testdata/open_meteo_hourly.json"
+		}
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Could not read fixture: %s", err)
+		}
+		w.Write(body)
+	}))
+}
+
+func TestOpenMeteoCurrent(t *testing.T) {
+	ts := openMeteoServer(t)
+	defer ts.Close()
+
+	o := OpenMeteo{Latitude: 48.2, Longitude: 16.37, URL: ts.URL}
+	actual, err := o.RetrieveForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	if !actual.Date.Equal(time.Unix(1606132800, 0)) || actual.Temperature != 4.6 || actual.CloudDensity != 88 ||
+		actual.Humidity != 81 || actual.Pressure != 1029.8 || actual.WindSpeed != 2.4 || actual.WindDirection != 124 ||
+		actual.RainAmount != 0.2 || math.Abs(actual.SnowAmount-0.7) > 1e-9 || actual.SkyDescription != "slight rain" {
+		t.Errorf("Unexpected current weather %+v", actual)
+	}
+	if actual.Sunrise.IsZero() || !actual.Sunset.After(actual.Sunrise) {
+		t.Errorf("Sunrise and sunset should be computed for the coordinates, got %s and %s", actual.Sunrise, actual.Sunset)
+	}
+}
+
+func TestOpenMeteoHourly(t *testing.T) {
+	ts := openMeteoServer(t)
+	defer ts.Close()
+
+	o := OpenMeteo{Latitude: 48.2, Longitude: 16.37, URL: ts.URL}
+	actual, err := o.RetrieveHourlyForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if len(actual) != 3 {
+		t.Fatalf("got %d forecasts, want 3", len(actual))
+	}
+
+	var tests = []struct {
+		index      int
+		date       time.Time
+		irradiance float64
+		direct     float64
+		diffuse    float64
+		clouds     float64
+	}{
+		{0, time.Date(2020, time.November, 23, 9, 0, 0, 0, time.UTC), 48, 2, 46, 100},
+		{2, time.Date(2020, time.November, 23, 11, 0, 0, 0, time.UTC), 160, 41, 119, 88},
+	}
+
+	for _, tt := range tests {
+		f := actual[tt.index]
+		if !f.Date.Equal(tt.date) || f.Period != time.Hour || !f.HasIrradiance || f.Irradiance != tt.irradiance ||
+			f.Direct != tt.direct || f.Diffuse != tt.diffuse || f.CloudDensity != tt.clouds {
+			t.Errorf("Unexpected forecast %d: %+v", tt.index, f)
+		}
+	}
+	if actual[2].SkyDescription != "slight rain" || actual[2].RainAmount != 0.2 {
+		t.Errorf("Unexpected precipitation %+v", actual[2])
+	}
+}
+
+func TestOpenMeteoErrors(t *testing.T) {
+	ts := openMeteoServer(t)
+	defer ts.Close()
+
+	o := OpenMeteo{Latitude: 91, Longitude: 16.37, URL: ts.URL}
+	if _, err := o.RetrieveForecast(context.Background()); err == nil {
+		t.Errorf("Should produce Error")
+	}
+	if _, err := o.RetrieveHourlyForecast(context.Background()); err == nil {
+		t.Errorf("Should produce Error")
+	}
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"hourly":{"time":[1606125600,1606129200],"temperature_2m":[2.9]}}`))
+	}))
+	defer broken.Close()
+
+	o.URL = broken.URL
+	if _, err := o.RetrieveHourlyForecast(context.Background()); err == nil {
+		t.Errorf("Forecast with missing values should produce Error")
+	}
+}
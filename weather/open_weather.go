@@ -1,6 +1,7 @@
 package weather
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -15,76 +16,277 @@ const OpenWeatherURL = "https://api.openweathermap.org"
 //OpenWeather implementation of the GenericWeather interface
 type OpenWeather struct {
 	Token        string
-	City         string
+	City         string // City ID, the coordinates are used if empty
 	LanguageCode string
 	Latitude     float64
 	Longitude    float64
+	OneCall      bool // Use the One Call API, which forecasts hourly and the UV index, but requires a subscription
 	URL          string
 }
 
-//RetrieveForecast using the open weather map API
-func (o *OpenWeather) RetrieveForecast() (Data, error) {
-	var data Data
-	uri := fmt.Sprintf("%s/data/2.5/weather?id=%s&APPID=%s&lang=%s&units=metric", o.URL, o.City, o.Token, o.LanguageCode)
-	httpResult, err := http.Get(uri)
+//openWeatherPrecipitation within the last hour or three hours
+type openWeatherPrecipitation struct {
+	OneHour    float64 `json:"1h"`
+	ThreeHours float64 `json:"3h"`
+}
 
+//openWeatherDescription of the sky
+type openWeatherDescription []struct {
+	Description string `json:"description"`
+}
+
+func (d openWeatherDescription) String() string {
+	if len(d) == 0 {
+		return ""
+	}
+	return d[0].Description
+}
+
+//location selects the city or the coordinates
+func (o *OpenWeather) location() string {
+	if o.City != "" {
+		return fmt.Sprintf("id=%s", o.City)
+	}
+	return fmt.Sprintf("lat=%g&lon=%g", o.Latitude, o.Longitude)
+}
+
+//get the API endpoint and decode the result
+func (o *OpenWeather) get(ctx context.Context, endpoint string, result interface{}) error {
+	uri := fmt.Sprintf("%s%s&APPID=%s&lang=%s&units=metric", o.URL, endpoint, o.Token, o.LanguageCode)
+	httpResult, err := httpGet(ctx, uri)
 	if err != nil {
-		return data, err
+		return err
 	}
 
 	defer httpResult.Body.Close()
 
+	err = json.NewDecoder(httpResult.Body).Decode(result)
+	if err != nil || httpResult.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error while receiving weather data: %s %v", httpResult.Status, err)
+	}
+	return nil
+}
+
+//sun returns the sunrise and sunset of the API or computes them if the API does not provide them
+func (o *OpenWeather) sun(rise, set int64) (time.Time, time.Time) {
+	if rise != 0 && set != 0 {
+		return time.Unix(rise, 0), time.Unix(set, 0)
+	}
+	now := time.Now()
+	return sunrise.SunriseSunset(o.Latitude, o.Longitude, now.Year(), now.Month(), now.Day())
+}
+
+//RetrieveForecast of the current weather using the open weather map API
+func (o *OpenWeather) RetrieveForecast(ctx context.Context) (Data, error) {
+	if o.OneCall {
+		return o.retrieveOneCall(ctx)
+	}
+
+	var data Data
 	type Result struct {
+		Date     int64  `json:"dt"`
 		Location string `json:"name"`
 		Clouds   struct {
 			Density float64 `json:"all"`
 		} `json:"clouds"`
 		Main struct {
 			Temperature float64 `json:"temp"`
+			FeelsLike   float64 `json:"feels_like"`
 			Pressure    float64 `json:"pressure"`
 			Humidity    float64 `json:"humidity"`
 		} `json:"main"`
-		Wind struct {
+		Visibility float64 `json:"visibility"`
+		Wind       struct {
 			Speed     float64 `json:"speed"`
 			Direction float64 `json:"deg"`
 		} `json:"wind"`
-		Rain struct {
-			ThreeHours float64 `json:"3h"`
-		} `json:"rain"`
-		Snow struct {
-			ThreeHours float64 `json:"3h"`
-		} `json:"snow"`
-		Weather []struct {
-			Description string `json:"description"`
-		} `json:"weather"`
+		Rain    openWeatherPrecipitation `json:"rain"`
+		Snow    openWeatherPrecipitation `json:"snow"`
+		Weather openWeatherDescription   `json:"weather"`
+		Sys     struct {
+			Sunrise int64 `json:"sunrise"`
+			Sunset  int64 `json:"sunset"`
+		} `json:"sys"`
 	}
 
 	var result Result
-	err = json.NewDecoder(httpResult.Body).Decode(&result)
-	if err != nil || httpResult.StatusCode != http.StatusOK {
-		return data, fmt.Errorf("Error while receiving weather data: %s", err)
+	if err := o.get(ctx, "/data/2.5/weather?"+o.location(), &result); err != nil {
+		return data, err
 	}
 
-	rise, set := sunrise.SunriseSunset(
-		o.Latitude, o.Longitude,
-		time.Now().Year(), time.Now().Month(), time.Now().Day(),
-	)
-
 	data.Date = time.Now()
 	data.LocationName = result.Location
-	data.Sunrise = rise
-	data.Sunset = set
+	data.Sunrise, data.Sunset = o.sun(result.Sys.Sunrise, result.Sys.Sunset)
 	data.Humidity = result.Main.Humidity
 	data.Temperature = result.Main.Temperature
-	if len(result.Weather) > 0 {
-		data.SkyDescription = result.Weather[0].Description
-	}
+	data.FeelsLike = result.Main.FeelsLike
+	data.SkyDescription = result.Weather.String()
 	data.WindSpeed = result.Wind.Speed
 	data.CloudDensity = result.Clouds.Density
 	data.WindDirection = result.Wind.Direction
 	data.RainAmount = result.Rain.ThreeHours
+	data.RainLastHour = result.Rain.OneHour
 	data.SnowAmount = result.Snow.ThreeHours
 	data.Pressure = result.Main.Pressure
-
+	data.Visibility = result.Visibility
 	return data, nil
 }
+
+//oneCallHour of the One Call API, used for the current weather and the hourly forecast
+type oneCallHour struct {
+	Date          int64                    `json:"dt"`
+	Sunrise       int64                    `json:"sunrise"`
+	Sunset        int64                    `json:"sunset"`
+	Temperature   float64                  `json:"temp"`
+	FeelsLike     float64                  `json:"feels_like"`
+	Pressure      float64                  `json:"pressure"`
+	Humidity      float64                  `json:"humidity"`
+	UVIndex       float64                  `json:"uvi"`
+	Clouds        float64                  `json:"clouds"`
+	Visibility    float64                  `json:"visibility"`
+	WindSpeed     float64                  `json:"wind_speed"`
+	WindDirection float64                  `json:"wind_deg"`
+	Rain          openWeatherPrecipitation `json:"rain"`
+	Snow          openWeatherPrecipitation `json:"snow"`
+	Weather       openWeatherDescription   `json:"weather"`
+}
+
+//oneCall requests the current weather and the hourly forecast of the next 48 hours
+func (o *OpenWeather) oneCall(ctx context.Context) (oneCallHour, []oneCallHour, error) {
+	type Result struct {
+		Current oneCallHour   `json:"current"`
+		Hourly  []oneCallHour `json:"hourly"`
+	}
+
+	var result Result
+	endpoint := fmt.Sprintf("/data/3.0/onecall?lat=%g&lon=%g&exclude=minutely,daily,alerts", o.Latitude, o.Longitude)
+	err := o.get(ctx, endpoint, &result)
+	return result.Current, result.Hourly, err
+}
+
+func (o *OpenWeather) retrieveOneCall(ctx context.Context) (Data, error) {
+	c, _, err := o.oneCall(ctx)
+	if err != nil {
+		return Data{}, err
+	}
+	return o.oneCallData(c), nil
+}
+
+//oneCallData of the current weather of the One Call API
+func (o *OpenWeather) oneCallData(c oneCallHour) Data {
+	var data Data
+	data.Date = time.Unix(c.Date, 0)
+	data.LocationName = fmt.Sprintf("%g,%g", o.Latitude, o.Longitude)
+	data.Sunrise, data.Sunset = o.sun(c.Sunrise, c.Sunset)
+	data.Humidity = c.Humidity
+	data.Temperature = c.Temperature
+	data.FeelsLike = c.FeelsLike
+	data.SkyDescription = c.Weather.String()
+	data.WindSpeed = c.WindSpeed
+	data.CloudDensity = c.Clouds
+	data.WindDirection = c.WindDirection
+	data.RainLastHour = c.Rain.OneHour
+	data.SnowAmount = c.Snow.OneHour
+	data.Pressure = c.Pressure
+	data.Visibility = c.Visibility
+	data.UVIndex = c.UVIndex
+	return data
+}
+
+//openWeatherForecastPeriod of the 5 day forecast
+const openWeatherForecastPeriod = 3 * time.Hour
+
+//RetrieveHourlyForecast of the next 5 days in periods of three hours using the open weather map API,
+//or of the next 48 hours in periods of one hour using the One Call API
+func (o *OpenWeather) RetrieveHourlyForecast(ctx context.Context) ([]Forecast, error) {
+	if o.OneCall {
+		return o.retrieveOneCallForecast(ctx)
+	}
+
+	type Result struct {
+		List []struct {
+			Date   int64 `json:"dt"`
+			Clouds struct {
+				Density float64 `json:"all"`
+			} `json:"clouds"`
+			Main struct {
+				Temperature float64 `json:"temp"`
+				Pressure    float64 `json:"pressure"`
+				Humidity    float64 `json:"humidity"`
+			} `json:"main"`
+			Wind struct {
+				Speed float64 `json:"speed"`
+			} `json:"wind"`
+			Rain    openWeatherPrecipitation `json:"rain"`
+			Snow    openWeatherPrecipitation `json:"snow"`
+			Weather openWeatherDescription   `json:"weather"`
+		} `json:"list"`
+	}
+
+	var result Result
+	if err := o.get(ctx, "/data/2.5/forecast?"+o.location(), &result); err != nil {
+		return nil, fmt.Errorf("Error while receiving weather forecast: %s", err)
+	}
+
+	forecasts := make([]Forecast, len(result.List))
+	for i, r := range result.List {
+		f := &forecasts[i]
+		f.Date = time.Unix(r.Date, 0)
+		f.Period = openWeatherForecastPeriod
+		f.CloudDensity = r.Clouds.Density
+		f.Temperature = r.Main.Temperature
+		f.Pressure = r.Main.Pressure
+		f.Humidity = r.Main.Humidity
+		f.WindSpeed = r.Wind.Speed
+		f.RainAmount = r.Rain.ThreeHours
+		f.SnowAmount = r.Snow.ThreeHours
+		f.SkyDescription = r.Weather.String()
+	}
+	return forecasts, nil
+}
+
+func (o *OpenWeather) retrieveOneCallForecast(ctx context.Context) ([]Forecast, error) {
+	_, hourly, err := o.oneCall(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error while receiving weather forecast: %s", err)
+	}
+	return oneCallForecast(hourly), nil
+}
+
+//oneCallForecast of the hours of the One Call API
+func oneCallForecast(hourly []oneCallHour) []Forecast {
+	forecasts := make([]Forecast, len(hourly))
+	for i, h := range hourly {
+		f := &forecasts[i]
+		f.Date = time.Unix(h.Date, 0)
+		f.Period = time.Hour
+		f.CloudDensity = h.Clouds
+		f.Temperature = h.Temperature
+		f.Pressure = h.Pressure
+		f.Humidity = h.Humidity
+		f.WindSpeed = h.WindSpeed
+		f.RainAmount = h.Rain.OneHour
+		f.SnowAmount = h.Snow.OneHour
+		f.SkyDescription = h.Weather.String()
+		f.UVIndex = h.UVIndex
+	}
+	return forecasts
+}
+
+//RetrieveWeather of the current weather and the forecast, the One Call API provides both with a single request
+func (o *OpenWeather) RetrieveWeather(ctx context.Context) (Data, []Forecast, error) {
+	if !o.OneCall {
+		data, err := o.RetrieveForecast(ctx)
+		if err != nil {
+			return data, nil, err
+		}
+		forecast, err := o.RetrieveHourlyForecast(ctx)
+		return data, forecast, err
+	}
+
+	c, hourly, err := o.oneCall(ctx)
+	if err != nil {
+		return Data{}, nil, err
+	}
+	return o.oneCallData(c), oneCallForecast(hourly), nil
+}
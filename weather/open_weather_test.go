@@ -1,9 +1,12 @@
 package weather
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -38,6 +41,7 @@ const ValidResponse = `{
 	  "deg": 3
 	},
 	"rain": {
+	  "1h": 0.5,
 	  "3h": 2.0
 	},
 	"snow": {
@@ -51,8 +55,8 @@ const ValidResponse = `{
 	  "type": 3,
 	  "id": 34534,
 	  "country": "AT",
-	  "sunrise": 456456456,
-	  "sunset": 456456456
+	  "sunrise": 1606111763,
+	  "sunset": 1606144478
 	},
 	"timezone": 45645,
 	"id": 4564564564,
@@ -70,22 +74,20 @@ func TestValidResponse(t *testing.T) {
 
 	o.URL = ts.URL
 
-	actual, err := o.RetrieveForecast()
+	actual, err := o.RetrieveForecast(context.Background())
 
 	if err != nil {
 		t.Fatalf("Should not produce Error: %s", err)
 	}
 
-	rise, set := sunrise.SunriseSunset(
-		o.Latitude, o.Longitude,
-		time.Now().Year(), time.Now().Month(), time.Now().Day(),
-	)
-
 	var expected Data
 	expected.LocationName = "XXX"
 	expected.Date = actual.Date
-	expected.Sunrise = rise
-	expected.Sunset = set
+	expected.Sunrise = time.Unix(1606111763, 0)
+	expected.Sunset = time.Unix(1606144478, 0)
+	expected.FeelsLike = 12.74
+	expected.Visibility = 10000
+	expected.RainLastHour = 0.5
 	expected.Humidity = 66
 	expected.Temperature = 14.17
 	expected.SkyDescription = "Really nice sky. Color is blue?!?"
@@ -111,7 +113,7 @@ func TestInvalidStatusCode(t *testing.T) {
 
 	o.URL = ts.URL
 
-	_, err := o.RetrieveForecast()
+	_, err := o.RetrieveForecast(context.Background())
 
 	if err == nil {
 		t.Fatalf("Should produce Error")
@@ -128,9 +130,196 @@ func TestHTTPError(t *testing.T) {
 
 	o.URL = ts.URL
 
-	_, err := o.RetrieveForecast()
+	_, err := o.RetrieveForecast(context.Background())
 
 	if err == nil {
 		t.Fatalf("Should produce Error")
 	}
 }
+
+const validForecastResponse = `{
+	"cod": "200",
+	"cnt": 2,
+	"list": [
+	  {
+		"dt": 1606132800,
+		"main": {"temp": 4.5, "pressure": 1030, "humidity": 80},
+		"weather": [{"id": 804, "main": "Clouds", "description": "overcast clouds"}],
+		"clouds": {"all": 90},
+		"wind": {"speed": 2.1, "deg": 120},
+		"rain": {"3h": 0.5},
+		"dt_txt": "2020-11-23 12:00:00"
+	  },
+	  {
+		"dt": 1606143600,
+		"main": {"temp": 3.2, "pressure": 1031, "humidity": 85},
+		"weather": [],
+		"clouds": {"all": 20},
+		"wind": {"speed": 1.0, "deg": 100},
+		"snow": {"3h": 1.5},
+		"dt_txt": "2020-11-23 15:00:00"
+	  }
+	],
+	"city": {"id": 2761369, "name": "Vienna"}
+  }`
+
+func TestValidForecastResponse(t *testing.T) {
+	var o OpenWeather
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data/2.5/forecast" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintln(w, validForecastResponse)
+	}))
+	defer ts.Close()
+
+	o.URL = ts.URL
+
+	actual, err := o.RetrieveHourlyForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	expected := []Forecast{
+		{Date: time.Unix(1606132800, 0), Period: 3 * time.Hour, CloudDensity: 90, Temperature: 4.5, Pressure: 1030, Humidity: 80, SkyDescription: "overcast clouds", WindSpeed: 2.1, RainAmount: 0.5},
+		{Date: time.Unix(1606143600, 0), Period: 3 * time.Hour, CloudDensity: 20, Temperature: 3.2, Pressure: 1031, Humidity: 85, WindSpeed: 1.0, SnowAmount: 1.5},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Error actual = %v, and expected = %v.", actual, expected)
+	}
+}
+
+func TestForecastInvalidStatusCode(t *testing.T) {
+	var o OpenWeather
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(401)
+		fmt.Fprintln(w, `{"cod": 401, "message": "Invalid API key"}`)
+	}))
+	defer ts.Close()
+
+	o.URL = ts.URL
+
+	if _, err := o.RetrieveHourlyForecast(context.Background()); err == nil {
+		t.Fatalf("Should produce Error")
+	}
+}
+
+func TestLocationQuery(t *testing.T) {
+	var tests = []struct {
+		testName string
+		weather  OpenWeather
+		want     string
+	}{
+		{"City", OpenWeather{City: "2761369", Latitude: 48.2, Longitude: 16.37}, "id=2761369"},
+		{"Coordinates", OpenWeather{Latitude: 48.2, Longitude: 16.37}, "lat=48.2&lon=16.37"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var query string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.RawQuery
+				fmt.Fprintln(w, `{"name": "Vienna"}`)
+			}))
+			defer ts.Close()
+
+			tt.weather.URL = ts.URL
+			data, err := tt.weather.RetrieveForecast(context.Background())
+			if err != nil {
+				t.Fatalf("Should not produce Error: %s", err)
+			}
+			if !strings.HasPrefix(query, tt.want+"&") {
+				t.Errorf("got query %s, want it to start with %s", query, tt.want)
+			}
+
+			//Without sunrise and sunset of the API, they are computed for the coordinates
+			now := time.Now()
+			rise, set := sunrise.SunriseSunset(48.2, 16.37, now.Year(), now.Month(), now.Day())
+			if !data.Sunrise.Equal(rise) || !data.Sunset.Equal(set) {
+				t.Errorf("got %s and %s, want %s and %s", data.Sunrise, data.Sunset, rise, set)
+			}
+		})
+	}
+}
+
+const validOneCallResponse = `{
+	"lat": 48.2,
+	"lon": 16.37,
+	"timezone": "Europe/Vienna",
+	"current": {
+	  "dt": 1606132800,
+	  "sunrise": 1606111763,
+	  "sunset": 1606144478,
+	  "temp": 4.6,
+	  "feels_like": 1.2,
+	  "pressure": 1030,
+	  "humidity": 81,
+	  "uvi": 0.8,
+	  "clouds": 88,
+	  "visibility": 9000,
+	  "wind_speed": 2.4,
+	  "wind_deg": 124,
+	  "rain": {"1h": 0.2},
+	  "weather": [{"id": 500, "main": "Rain", "description": "light rain"}]
+	},
+	"hourly": [
+	  {"dt": 1606132800, "temp": 4.6, "pressure": 1030, "humidity": 81, "uvi": 0.8, "clouds": 88, "wind_speed": 2.4, "rain": {"1h": 0.2}, "weather": [{"description": "light rain"}]},
+	  {"dt": 1606136400, "temp": 5.1, "pressure": 1029, "humidity": 78, "uvi": 0.6, "clouds": 75, "wind_speed": 2.8, "snow": {"1h": 0.4}, "weather": []}
+	]
+  }`
+
+func TestOneCall(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/data/3.0/onecall" || r.URL.Query().Get("lat") != "48.2" || r.URL.Query().Get("lon") != "16.37" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, `{"cod": 404}`)
+			return
+		}
+		fmt.Fprintln(w, validOneCallResponse)
+	}))
+	defer ts.Close()
+
+	o := OpenWeather{City: "2761369", Latitude: 48.2, Longitude: 16.37, OneCall: true, URL: ts.URL}
+
+	data, err := o.RetrieveForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if !data.Date.Equal(time.Unix(1606132800, 0)) || !data.Sunrise.Equal(time.Unix(1606111763, 0)) || data.UVIndex != 0.8 ||
+		data.FeelsLike != 1.2 || data.Visibility != 9000 || data.RainLastHour != 0.2 || data.SkyDescription != "light rain" {
+		t.Errorf("Unexpected weather %+v", data)
+	}
+
+	forecast, err := o.RetrieveHourlyForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	expected := []Forecast{
+		{Date: time.Unix(1606132800, 0), Period: time.Hour, CloudDensity: 88, Temperature: 4.6, Pressure: 1030, Humidity: 81, SkyDescription: "light rain", WindSpeed: 2.4, RainAmount: 0.2, UVIndex: 0.8},
+		{Date: time.Unix(1606136400, 0), Period: time.Hour, CloudDensity: 75, Temperature: 5.1, Pressure: 1029, Humidity: 78, WindSpeed: 2.8, SnowAmount: 0.4, UVIndex: 0.6},
+	}
+	if !reflect.DeepEqual(forecast, expected) {
+		t.Errorf("Error actual = %v, and expected = %v.", forecast, expected)
+	}
+
+	//Every update requests the weather and the forecast together, also with the same context of the job
+	requests = 0
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		current, hourly, err := o.RetrieveWeather(ctx)
+		if err != nil {
+			t.Fatalf("Should not produce Error: %s", err)
+		}
+		if !current.Date.Equal(data.Date) || !reflect.DeepEqual(hourly, expected) {
+			t.Errorf("got %+v and %v, want %+v and %v", current, hourly, data, expected)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected one request per update, got %d", requests)
+	}
+}
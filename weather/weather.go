@@ -1,7 +1,9 @@
-//Package weather contains a generic weather interface and an OpenWeathermap implementation
+//Package weather contains a generic weather interface for current conditions and forecasts and the OpenWeathermap, Open-Meteo and DWD MOSMIX implementations
 package weather
 
 import (
+	"context"
+	"net/http"
 	"time"
 )
 
@@ -20,10 +22,65 @@ type Data struct {
 	Sunset         time.Time
 	RainAmount     float64
 	SnowAmount     float64
+	RainLastHour   float64 // Millimeters within the last hour
+	FeelsLike      float64 // Perceived temperature
+	Visibility     float64 // Meters
+	UVIndex        float64
 }
 
+//Forecast of the weather for a period starting at Date
+type Forecast struct {
+	Date           time.Time
+	Period         time.Duration // Length of the period, e.g. one or three hours
+	CloudDensity   float64       // Percent of the sky covered by clouds
+	Temperature    float64
+	Pressure       float64
+	Humidity       float64
+	SkyDescription string
+	WindSpeed      float64
+	RainAmount     float64 // Millimeters within the period
+	SnowAmount     float64 // Millimeters within the period
+	UVIndex        float64
+	Irradiance     float64 // Mean global horizontal irradiance in W/m²
+	Direct         float64 // Mean direct irradiance on a horizontal plane in W/m², 0 if only the global irradiance is known
+	Diffuse        float64 // Mean diffuse irradiance in W/m², 0 if only the global irradiance is known
+	HasIrradiance  bool    // Not every source forecasts the irradiance
+}
+
+//Weather providers which can be selected in the config
+const (
+	ProviderOpenWeather = "openweathermap"
+	ProviderOpenMeteo   = "open-meteo"
+	ProviderDWDMosmix   = "dwd-mosmix"
+)
+
 //GenericWeather provides an abstraction over a specific Weather source
 type GenericWeather interface {
 	//RetrieveForecast
-	RetrieveForecast() (Data, error)
+	RetrieveForecast(ctx context.Context) (Data, error)
+}
+
+//ForecastWeather is a weather source which also forecasts the next days
+type ForecastWeather interface {
+	GenericWeather
+
+	//RetrieveHourlyForecast for the next days sorted by date, the periods may be longer than an hour
+	RetrieveHourlyForecast(ctx context.Context) ([]Forecast, error)
+}
+
+//UpdateWeather is a weather source which retrieves the current weather and the forecast together
+type UpdateWeather interface {
+	ForecastWeather
+
+	//RetrieveWeather of the current weather and the forecast of the next days
+	RetrieveWeather(ctx context.Context) (Data, []Forecast, error)
+}
+
+//httpGet the uri, the request is cancelled with the context
+func httpGet(ctx context.Context, uri string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(request)
 }
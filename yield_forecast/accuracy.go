@@ -0,0 +1,87 @@
+package yield_forecast
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+//LeadTime of a forecast, the time between issuing the forecast and the end of the forecast period
+type LeadTime struct {
+	Name string
+	Max  time.Duration
+}
+
+//LeadTimes in which the accuracy of the forecasts is tracked
+var LeadTimes = []LeadTime{
+	{"6h", 6 * time.Hour},
+	{"24h", 24 * time.Hour},
+	{"48h", 48 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+//Lead returns the name of the lead time of a period ending at date, false if the period has started or is too far ahead
+func Lead(issued, date time.Time, period time.Duration) (string, bool) {
+	if period <= 0 {
+		period = time.Hour
+	}
+	if date.Add(-period).Before(issued) {
+		return "", false
+	}
+	for _, l := range LeadTimes {
+		if date.Sub(issued) <= l.Max {
+			return l.Name, true
+		}
+	}
+	return "", false
+}
+
+//Metrics of the hourly error in Wh, a positive bias means that the forecast is too high
+type Metrics struct {
+	Samples int     `json:"samples"`
+	MAE     float64 `json:"mae"`
+	RMSE    float64 `json:"rmse"`
+	Bias    float64 `json:"bias"`
+}
+
+//String representation of the metrics
+func (m Metrics) String() string {
+	return fmt.Sprintf("MAE %.0f Wh, RMSE %.0f Wh, bias %+.0f Wh over %d hours", m.MAE, m.RMSE, m.Bias, m.Samples)
+}
+
+//Errors computes the metrics of the differences between forecast and actual values
+func Errors(errors []float64) Metrics {
+	var m Metrics
+	for _, e := range errors {
+		m.MAE += math.Abs(e)
+		m.RMSE += e * e
+		m.Bias += e
+	}
+	m.Samples = len(errors)
+	if m.Samples > 0 {
+		n := float64(m.Samples)
+		m.MAE /= n
+		m.RMSE = math.Sqrt(m.RMSE / n)
+		m.Bias /= n
+	}
+	return m
+}
+
+//Combine the metrics of several periods, weighted by their samples
+func Combine(metrics ...Metrics) Metrics {
+	var c Metrics
+	for _, m := range metrics {
+		n := float64(m.Samples)
+		c.MAE += m.MAE * n
+		c.RMSE += m.RMSE * m.RMSE * n
+		c.Bias += m.Bias * n
+		c.Samples += m.Samples
+	}
+	if c.Samples > 0 {
+		n := float64(c.Samples)
+		c.MAE /= n
+		c.RMSE = math.Sqrt(c.RMSE / n)
+		c.Bias /= n
+	}
+	return c
+}
@@ -0,0 +1,55 @@
+package yield_forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLead(t *testing.T) {
+	issued := time.Date(2020, time.June, 21, 10, 15, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		date   time.Time
+		period time.Duration
+		lead   string
+		ok     bool
+	}{
+		{"Started", issued.Add(30 * time.Minute), 0, "", false},
+		{"Next hour", issued.Add(105 * time.Minute), 0, "6h", true},
+		{"Short period", issued.Add(30 * time.Minute), 15 * time.Minute, "6h", true},
+		{"Tomorrow", issued.Add(20 * time.Hour), time.Hour, "24h", true},
+		{"Day after tomorrow", issued.Add(47 * time.Hour), time.Hour, "48h", true},
+		{"Next week", issued.Add(100 * time.Hour), time.Hour, "7d", true},
+		{"Too far", issued.Add(200 * time.Hour), time.Hour, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lead, ok := Lead(issued, test.date, test.period)
+			if lead != test.lead || ok != test.ok {
+				t.Errorf("got %q %t, want %q %t", lead, ok, test.lead, test.ok)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	m := Errors([]float64{-10, 30, 0, 20})
+	if m.Samples != 4 || m.MAE != 15 || math.Abs(m.RMSE-math.Sqrt(350)) > 1e-9 || m.Bias != 10 {
+		t.Errorf("got %v", m)
+	}
+	if m := Errors(nil); m != (Metrics{}) {
+		t.Errorf("Without errors: got %v", m)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	first := Errors([]float64{-10, 30})
+	second := Errors([]float64{0, 20})
+	want := Errors([]float64{-10, 30, 0, 20})
+	actual := Combine(first, second, Metrics{})
+	if actual.Samples != want.Samples || math.Abs(actual.MAE-want.MAE) > 1e-9 || math.Abs(actual.RMSE-want.RMSE) > 1e-9 || math.Abs(actual.Bias-want.Bias) > 1e-9 {
+		t.Errorf("got %v, want %v", actual, want)
+	}
+}
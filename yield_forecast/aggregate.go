@@ -0,0 +1,254 @@
+package yield_forecast
+
+import (
+	"context"
+	"fmt"
+	"solargo/inverter"
+	"time"
+)
+
+//OutlookDays covered by the week of the outlook, starting with today
+const OutlookDays = 7
+
+//Hour of the forecast with its energy
+type Hour struct {
+	Start  time.Time
+	Energy inverter.WattHour
+}
+
+//Outlook of the expected energy of the forecast
+type Outlook struct {
+	Today          inverter.WattHour
+	RemainingToday inverter.WattHour // From now until the end of today
+	Tomorrow       inverter.WattHour
+	Week           inverter.WattHour // Of the next OutlookDays days including today
+	PeakToday      Hour              // Zero if there is no production left today
+	PeakTomorrow   Hour
+	Until          time.Time // End of the forecast, the energy after it is unknown
+}
+
+//Appliance which runs for the duration at a constant power
+type Appliance struct {
+	Power    inverter.Watt
+	Duration time.Duration
+}
+
+//Need of energy to run the appliance once
+func (a Appliance) Need() inverter.WattHour {
+	return a.Power.Over(a.Duration)
+}
+
+//Window to run an appliance with the energy of the forecast, which it can use
+type Window struct {
+	Start time.Time
+	End   time.Time
+	Solar inverter.WattHour // Energy of the appliance covered by the forecast production
+	Need  inverter.WattHour
+	Cost  float64 // Of the energy by the prices, only computed by CheapestWindow
+}
+
+//Prices of the energy purchased from and fed into the grid per kWh, e.g. a tariff
+type Prices interface {
+	ImportPrice(t time.Time) float64
+	FeedInPrice(t time.Time) float64
+}
+
+//Coverage of the need by the production between 0 and 1
+func (w Window) Coverage() float64 {
+	if w.Need <= 0 {
+		return 0
+	}
+	return float64(w.Solar / w.Need)
+}
+
+//interval of the period ending at the date of the forecast, the power is assumed to be constant within it
+func (d Data) interval() (time.Time, time.Time) {
+	period := d.Period
+	if period <= 0 {
+		period = time.Hour
+	}
+	return d.Date.Add(-period), d.Date
+}
+
+//overlap of the intervals
+func overlap(start, end, from, to time.Time) time.Duration {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+//Energy expected between from and to, periods partially within are prorated
+func Energy(data []Data, from, to time.Time) inverter.WattHour {
+	var energy inverter.WattHour
+	for _, d := range data {
+		start, end := d.interval()
+		if o := overlap(start, end, from, to); o > 0 {
+			energy += d.CurrentProduction * inverter.WattHour(float64(o)/float64(end.Sub(start)))
+		}
+	}
+	return energy
+}
+
+//Peak returns the full hour between from and to with the most energy, false if there is no production.
+//An hour which started before from is skipped.
+func Peak(data []Data, from, to time.Time) (Hour, bool) {
+	var peak Hour
+	start := from.Truncate(time.Hour)
+	if start.Before(from) {
+		start = start.Add(time.Hour)
+	}
+	for ; start.Before(to); start = start.Add(time.Hour) {
+		if e := Energy(data, start, start.Add(time.Hour)); e > peak.Energy {
+			peak = Hour{Start: start, Energy: e}
+		}
+	}
+	return peak, peak.Energy > 0
+}
+
+//NewOutlook of the forecast at now, the days start at midnight in the location
+func NewOutlook(data []Data, now time.Time, loc *time.Location) Outlook {
+	if loc == nil {
+		loc = time.Local
+	}
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	tomorrow := today.AddDate(0, 0, 1)
+	dayAfter := today.AddDate(0, 0, 2)
+
+	o := Outlook{
+		Today:          Energy(data, today, tomorrow),
+		RemainingToday: Energy(data, now, tomorrow),
+		Tomorrow:       Energy(data, tomorrow, dayAfter),
+		Week:           Energy(data, today, today.AddDate(0, 0, OutlookDays)),
+	}
+	o.PeakToday, _ = Peak(data, now, tomorrow)
+	o.PeakTomorrow, _ = Peak(data, tomorrow, dayAfter)
+	for _, d := range data {
+		if d.Date.After(o.Until) {
+			o.Until = d.Date
+		}
+	}
+	return o
+}
+
+//covered energy of the appliance running between from and to, the power above the forecast production is drawn from the grid
+func covered(data []Data, power inverter.Watt, from, to time.Time) inverter.WattHour {
+	var solar inverter.WattHour
+	for _, d := range data {
+		start, end := d.interval()
+		if o := overlap(start, end, from, to); o > 0 {
+			p := d.MeanPower()
+			if p > power {
+				p = power
+			}
+			solar += p.Over(o)
+		}
+	}
+	return solar
+}
+
+//BestWindow to run the appliance between from and to, which covers most of its need with the forecast production.
+//The windows start at full quarter hours, of equally good ones the earliest is returned. It returns false if
+//the appliance does not fit between from and to or no production is expected.
+func BestWindow(data []Data, appliance Appliance, from, to time.Time) (Window, bool) {
+	var best Window
+	if appliance.Duration <= 0 || appliance.Power <= 0 {
+		return best, false
+	}
+	start := from.Truncate(15 * time.Minute)
+	if start.Before(from) {
+		start = start.Add(15 * time.Minute)
+	}
+	for ; !start.Add(appliance.Duration).After(to); start = start.Add(15 * time.Minute) {
+		end := start.Add(appliance.Duration)
+		if solar := covered(data, appliance.Power, start, end); solar > best.Solar {
+			best = Window{Start: start, End: end, Solar: solar}
+		}
+	}
+	best.Need = appliance.Need()
+	return best, best.Solar > 0
+}
+
+//cost of running the appliance between from and to quarter hour by quarter hour. The energy drawn from the grid is
+//paid at the import price, the used production loses its feed-in compensation.
+func cost(data []Data, prices Prices, power inverter.Watt, from, to time.Time) (float64, inverter.WattHour) {
+	var total float64
+	var solar inverter.WattHour
+	for start := from; start.Before(to); start = start.Add(15 * time.Minute) {
+		end := start.Add(15 * time.Minute)
+		if end.After(to) {
+			end = to
+		}
+		middle := start.Add(end.Sub(start) / 2)
+		covered := covered(data, power, start, end)
+		grid := power.Over(end.Sub(start)) - covered
+		total += float64(grid.ToKWh())*prices.ImportPrice(middle) + float64(covered.ToKWh())*prices.FeedInPrice(middle)
+		solar += covered
+	}
+	return total, solar
+}
+
+//CheapestWindow to run the appliance between from and to, which costs the least by the prices and the forecast
+//production. The windows start at full quarter hours, of equally cheap ones the earliest is returned. It returns
+//false if the appliance does not fit between from and to.
+func CheapestWindow(data []Data, prices Prices, appliance Appliance, from, to time.Time) (Window, bool) {
+	var best Window
+	found := false
+	if appliance.Duration <= 0 || appliance.Power <= 0 {
+		return best, false
+	}
+	start := from.Truncate(15 * time.Minute)
+	if start.Before(from) {
+		start = start.Add(15 * time.Minute)
+	}
+	for ; !start.Add(appliance.Duration).After(to); start = start.Add(15 * time.Minute) {
+		end := start.Add(appliance.Duration)
+		if c, solar := cost(data, prices, appliance.Power, start, end); !found || c < best.Cost-1e-9 {
+			best = Window{Start: start, End: end, Solar: solar, Cost: c}
+			found = true
+		}
+	}
+	best.Need = appliance.Need()
+	return best, found
+}
+
+//Aggregator of the forecast of any provider
+type Aggregator struct {
+	Forecast GenericYieldForecast
+	Location *time.Location // Of the days, the local time zone if nil
+}
+
+//Outlook of the current forecast at now
+func (a *Aggregator) Outlook(ctx context.Context, now time.Time) (Outlook, error) {
+	data, err := a.Forecast.RetrieveForecast(ctx)
+	if err != nil {
+		return Outlook{}, err
+	}
+	return NewOutlook(data, now, a.Location), nil
+}
+
+//BestWindow to run the appliance from now until the end of the forecast
+func (a *Aggregator) BestWindow(ctx context.Context, now time.Time, appliance Appliance) (Window, error) {
+	data, err := a.Forecast.RetrieveForecast(ctx)
+	if err != nil {
+		return Window{}, err
+	}
+	var until time.Time
+	for _, d := range data {
+		if d.Date.After(until) {
+			until = d.Date
+		}
+	}
+	w, ok := BestWindow(data, appliance, now, until)
+	if !ok {
+		return w, fmt.Errorf("No production expected to run the appliance")
+	}
+	return w, nil
+}
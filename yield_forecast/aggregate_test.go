@@ -0,0 +1,192 @@
+package yield_forecast
+
+import (
+	"context"
+	"math"
+	"solargo/inverter"
+	"testing"
+	"time"
+)
+
+//staticForecast returns the same forecast on every request
+type staticForecast struct {
+	data []Data
+}
+
+func (f *staticForecast) RetrieveForecast(ctx context.Context) ([]Data, error) { return f.data, nil }
+
+//days of hourly forecasts between 8:00 and 16:00, the production rises by 100 Wh until noon and falls afterwards
+func days(start time.Time, n int) []Data {
+	var data []Data
+	for d := 0; d < n; d++ {
+		day := start.AddDate(0, 0, d)
+		for h := 9; h <= 16; h++ {
+			energy := inverter.WattHour(100 * (4 - math.Abs(float64(h)-12.5) + 0.5))
+			data = append(data, Data{Date: day.Add(time.Duration(h) * time.Hour), CurrentProduction: energy * inverter.WattHour(d+1)})
+		}
+	}
+	return data
+}
+
+func TestEnergy(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	data := []Data{
+		{Date: day.Add(11 * time.Hour), CurrentProduction: 600},
+		{Date: day.Add(11*time.Hour + 15*time.Minute), CurrentProduction: 200, Period: 15 * time.Minute},
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     inverter.WattHour
+	}{
+		{"Everything", day, day.AddDate(0, 0, 1), 800},
+		{"Half hour", day.Add(10 * time.Hour), day.Add(10*time.Hour + 30*time.Minute), 300},
+		{"Across periods", day.Add(10*time.Hour + 50*time.Minute), day.Add(11*time.Hour + 5*time.Minute), 100 + 200.0/3},
+		{"Period end is excluded", day.Add(11*time.Hour + 15*time.Minute), day.Add(12 * time.Hour), 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := Energy(data, test.from, test.to); math.Abs(float64(actual-test.want)) > 1e-9 {
+				t.Errorf("got %f, want %f", actual, test.want)
+			}
+		})
+	}
+}
+
+func TestNewOutlook(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Vienna")
+	today := time.Date(2020, time.June, 21, 0, 0, 0, 0, loc)
+	data := days(today, 3)
+	now := today.Add(12*time.Hour + 30*time.Minute)
+
+	o := NewOutlook(data, now, loc)
+	//100+200+300+400+400+300+200+100 Wh on the first day, twice and three times as much on the next days
+	if o.Today != 2000 || o.Tomorrow != 4000 || o.Week != 12000 {
+		t.Errorf("got today %f, tomorrow %f and week %f", o.Today, o.Tomorrow, o.Week)
+	}
+	//Half of the hour until 13:00 and the afternoon
+	if o.RemainingToday != 200+300+200+100 {
+		t.Errorf("got remaining %f", o.RemainingToday)
+	}
+	//The hour from 12:00 has already started
+	if want := (Hour{Start: today.Add(13 * time.Hour), Energy: 300}); o.PeakToday != want {
+		t.Errorf("got peak today %v, want %v", o.PeakToday, want)
+	}
+	if want := (Hour{Start: today.Add(35 * time.Hour), Energy: 800}); !o.PeakTomorrow.Start.Equal(want.Start) || o.PeakTomorrow.Energy != want.Energy {
+		t.Errorf("got peak tomorrow %v, want %v", o.PeakTomorrow, want)
+	}
+	if !o.Until.Equal(today.AddDate(0, 0, 2).Add(16 * time.Hour)) {
+		t.Errorf("got until %s", o.Until)
+	}
+
+	evening := NewOutlook(data, today.Add(20*time.Hour), loc)
+	if evening.RemainingToday != 0 || evening.PeakToday != (Hour{}) || evening.Today != 2000 {
+		t.Errorf("Nothing should remain in the evening, got %+v", evening)
+	}
+}
+
+func TestBestWindow(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	data := days(day, 1)
+
+	tests := []struct {
+		name      string
+		appliance Appliance
+		from, to  time.Time
+		start     time.Time
+		solar     inverter.WattHour
+		ok        bool
+	}{
+		{"Around noon", Appliance{Power: 2000, Duration: 2 * time.Hour}, day, day.AddDate(0, 0, 1), day.Add(11 * time.Hour), 800, true},
+		{"Low power", Appliance{Power: 150, Duration: 2 * time.Hour}, day, day.AddDate(0, 0, 1), day.Add(9 * time.Hour), 300, true},
+		{"After from", Appliance{Power: 2000, Duration: time.Hour}, day.Add(12*time.Hour + 5*time.Minute), day.AddDate(0, 0, 1), day.Add(12*time.Hour + 15*time.Minute), 375, true},
+		{"Until to", Appliance{Power: 2000, Duration: 2 * time.Hour}, day, day.Add(12 * time.Hour), day.Add(10 * time.Hour), 700, true},
+		{"Too long", Appliance{Power: 2000, Duration: 3 * time.Hour}, day.Add(20 * time.Hour), day.AddDate(0, 0, 1), time.Time{}, 0, false},
+		{"At night", Appliance{Power: 2000, Duration: time.Hour}, day.Add(20 * time.Hour), day.AddDate(0, 0, 1), time.Time{}, 0, false},
+		{"No power", Appliance{Duration: time.Hour}, day, day.AddDate(0, 0, 1), time.Time{}, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w, ok := BestWindow(data, test.appliance, test.from, test.to)
+			if ok != test.ok || !w.Start.Equal(test.start) || math.Abs(float64(w.Solar-test.solar)) > 1e-9 {
+				t.Errorf("got %v %t, want start %s with %f Wh", w, ok, test.start, test.solar)
+			}
+			if ok && (!w.End.Equal(w.Start.Add(test.appliance.Duration)) || w.Need != test.appliance.Need()) {
+				t.Errorf("got %v for %v", w, test.appliance)
+			}
+		})
+	}
+
+	if c := (Window{Solar: 800, Need: 4000}).Coverage(); c != 0.2 {
+		t.Errorf("got coverage %f", c)
+	}
+}
+
+//nightPrices are cheaper between 2:00 and 4:00 UTC
+type nightPrices struct {
+	night, day, feedIn float64
+}
+
+func (p nightPrices) ImportPrice(t time.Time) float64 {
+	if h := t.UTC().Hour(); h >= 2 && h < 4 {
+		return p.night
+	}
+	return p.day
+}
+
+func (p nightPrices) FeedInPrice(t time.Time) float64 { return p.feedIn }
+
+func TestCheapestWindow(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	data := days(day, 1)
+	tariff := nightPrices{night: 0.10, day: 0.30, feedIn: 0.05}
+
+	tests := []struct {
+		name      string
+		prices    Prices
+		appliance Appliance
+		from, to  time.Time
+		start     time.Time
+		solar     inverter.WattHour
+		cost      float64
+		ok        bool
+	}{
+		{"Cheap night", tariff, Appliance{Power: 1000, Duration: time.Hour}, day, day.AddDate(0, 0, 1), day.Add(2 * time.Hour), 0, 0.10, true},
+		{"Flat price", nightPrices{night: 0.30, day: 0.30}, Appliance{Power: 1000, Duration: time.Hour}, day, day.AddDate(0, 0, 1), day.Add(11 * time.Hour), 400, 0.18, true},
+		{"Covered by the production", tariff, Appliance{Power: 200, Duration: time.Hour}, day, day.AddDate(0, 0, 1), day.Add(9 * time.Hour), 200, 0.01, true},
+		{"After the night", tariff, Appliance{Power: 1000, Duration: time.Hour}, day.Add(4 * time.Hour), day.Add(8 * time.Hour), day.Add(4 * time.Hour), 0, 0.30, true},
+		{"Too long", tariff, Appliance{Power: 1000, Duration: 3 * time.Hour}, day, day.Add(2 * time.Hour), time.Time{}, 0, 0, false},
+		{"No power", tariff, Appliance{Duration: time.Hour}, day, day.AddDate(0, 0, 1), time.Time{}, 0, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w, ok := CheapestWindow(data, test.prices, test.appliance, test.from, test.to)
+			if ok != test.ok || !w.Start.Equal(test.start) || math.Abs(float64(w.Solar-test.solar)) > 1e-9 || math.Abs(w.Cost-test.cost) > 1e-9 {
+				t.Errorf("got %v %t, want start %s with %f Wh for %f", w, ok, test.start, test.solar, test.cost)
+			}
+			if ok && (!w.End.Equal(w.Start.Add(test.appliance.Duration)) || w.Need != test.appliance.Need()) {
+				t.Errorf("got %v for %v", w, test.appliance)
+			}
+		})
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC)
+	a := Aggregator{Forecast: &staticForecast{days(day, 2)}, Location: time.UTC}
+
+	o, err := a.Outlook(context.Background(), day.Add(6*time.Hour))
+	if err != nil || o.Today != 2000 || o.RemainingToday != 2000 || o.Tomorrow != 4000 {
+		t.Errorf("got %+v %v", o, err)
+	}
+
+	w, err := a.BestWindow(context.Background(), day.Add(14*time.Hour), Appliance{Power: 1000, Duration: 2 * time.Hour})
+	if err != nil || !w.Start.Equal(day.Add(35*time.Hour)) {
+		t.Errorf("The best window should be tomorrow, got %v %v", w, err)
+	}
+
+	if _, err := a.BestWindow(context.Background(), day.Add(47*time.Hour), Appliance{Power: 1000, Duration: time.Hour}); err == nil {
+		t.Errorf("Should produce an error after the forecast")
+	}
+}
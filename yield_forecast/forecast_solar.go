@@ -0,0 +1,134 @@
+package yield_forecast
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"solargo/inverter"
+	"sort"
+	"time"
+
+	"github.com/nathan-osman/go-sunrise"
+)
+
+//ForecastSolarURL Api endpoint
+const ForecastSolarURL = "https://api.forecast.solar"
+
+//ForecastSolar implementation of the GenericYieldForecast interface, the forecasts of all planes are summed up
+type ForecastSolar struct {
+	Token     string // API key of a paid plan, the public API is used if empty
+	Latitude  float64
+	Longitude float64
+	Planes    []Plane
+	URL       string
+	Location  *time.Location // Of the days, the local time zone if nil
+}
+
+//forecastSolarResult of the estimate API, the keys are ISO 8601 times
+type forecastSolarResult struct {
+	Result struct {
+		Watts           map[string]float64 `json:"watts"`
+		WattHoursPeriod map[string]float64 `json:"watt_hours_period"`
+		WattHours       map[string]float64 `json:"watt_hours"`
+	} `json:"result"`
+	Message struct {
+		Code int    `json:"code"`
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+//RetrieveForecast of all planes using the Forecast.Solar API
+func (o *ForecastSolar) RetrieveForecast(ctx context.Context) ([]Data, error) {
+	if len(o.Planes) == 0 {
+		return nil, fmt.Errorf("Error while receiving yield forecast data: no planes configured")
+	}
+
+	sums := map[int64]*Data{}
+	for _, plane := range o.Planes {
+		result, err := o.estimate(ctx, plane)
+		if err != nil {
+			return nil, err
+		}
+		for key, wh := range result.Result.WattHoursPeriod {
+			t, err := time.Parse(time.RFC3339, key)
+			if err != nil {
+				return nil, fmt.Errorf("Error trying to convert yield forecast data: %s", err)
+			}
+			d, ok := sums[t.Unix()]
+			if !ok {
+				d = &Data{Date: t}
+				sums[t.Unix()] = d
+			}
+			d.CurrentProduction += inverter.WattHour(wh)
+			d.CummulatedProduction += inverter.WattHour(result.Result.WattHours[key])
+			d.Power += inverter.Watt(result.Result.Watts[key])
+		}
+	}
+
+	data := make([]Data, 0, len(sums))
+	for _, d := range sums {
+		data = append(data, *d)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Date.Before(data[j].Date) })
+
+	//The periods end at the times of the forecast and are not of equal length, the first one of a day starts at sunrise
+	loc := o.location()
+	for i := range data {
+		y, m, d := data[i].Date.In(loc).Date()
+		if i > 0 {
+			y1, m1, d1 := data[i-1].Date.In(loc).Date()
+			if y == y1 && m == m1 && d == d1 {
+				data[i].Period = data[i].Date.Sub(data[i-1].Date)
+				continue
+			}
+		}
+
+		rise, _ := sunrise.SunriseSunset(o.Latitude, o.Longitude, y, m, d)
+		if rise.Before(data[i].Date) {
+			data[i].Period = data[i].Date.Sub(rise)
+			continue
+		}
+		//The forecast starts before the computed sunrise, so the period is as long as the next one of the day
+		if i+1 < len(data) {
+			y2, m2, d2 := data[i+1].Date.In(loc).Date()
+			if y == y2 && m == m2 && d == d2 {
+				data[i].Period = data[i+1].Date.Sub(data[i].Date)
+			}
+		}
+	}
+	return data, nil
+}
+
+func (o *ForecastSolar) location() *time.Location {
+	if o.Location == nil {
+		return time.Local
+	}
+	return o.Location
+}
+
+//estimate of a single plane
+func (o *ForecastSolar) estimate(ctx context.Context, plane Plane) (forecastSolarResult, error) {
+	var result forecastSolarResult
+	base := o.URL
+	if o.Token != "" {
+		base = fmt.Sprintf("%s/%s", o.URL, o.Token)
+	}
+	uri := fmt.Sprintf("%s/estimate/%g/%g/%g/%g/%g?time=iso8601", base, o.Latitude, o.Longitude, plane.Declination, plane.Azimuth, plane.KWP)
+
+	httpResult, err := get(ctx, uri)
+	if err != nil {
+		return result, err
+	}
+	defer httpResult.Body.Close()
+
+	err = json.NewDecoder(httpResult.Body).Decode(&result)
+	if err != nil {
+		return result, fmt.Errorf("Error while receiving yield forecast data: %s", err)
+	}
+	if httpResult.StatusCode != http.StatusOK || result.Message.Code != 0 {
+		return result, fmt.Errorf("Error while receiving yield forecast data: %s (code %d)", result.Message.Text, result.Message.Code)
+	}
+	return result, nil
+}
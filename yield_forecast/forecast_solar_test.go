@@ -0,0 +1,143 @@
+package yield_forecast
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//forecastSolarServer answers with the fixture of the plane, the azimuth selects the fixture
+func forecastSolarServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("time") != "iso8601" {
+			t.Errorf("Times should be requested in ISO 8601, got %s", r.URL.RawQuery)
+		}
+		file := ""
+		switch r.URL.Path {
+		case "/estimate/48.2/16.37/30/-90/4.5", "/secret/estimate/48.2/16.37/30/-90/4.5":
+			file = "testdata/forecast_solar_east.json"
+		case "/estimate/48.2/16.37/30/90/1.2":
+			file = "testdata/forecast_solar_west.json"
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+			file = "testdata/forecast_solar_ratelimit.json"
+		}
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Could not read fixture: %s", err)
+		}
+		w.Write(body)
+	}))
+}
+
+func TestForecastSolar(t *testing.T) {
+	ts := forecastSolarServer(t)
+	defer ts.Close()
+
+	cet := time.FixedZone("CET", 3600)
+	s := ForecastSolar{Latitude: 48.2, Longitude: 16.37, URL: ts.URL, Planes: []Plane{{30, -90, 4.5}, {30, 90, 1.2}}, Location: cet}
+	actual, err := s.RetrieveForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	//The first period of a day starts at the sunrise at 7:13:50, or is as long as the next one if the forecast starts before it
+	expected := []Data{
+		{Date: time.Date(2020, time.November, 23, 7, 14, 0, 0, cet), Period: 10 * time.Second},
+		{Date: time.Date(2020, time.November, 23, 8, 0, 0, 0, cet), CurrentProduction: 192, CummulatedProduction: 192, Power: 500, Period: 46 * time.Minute},
+		{Date: time.Date(2020, time.November, 23, 9, 0, 0, 0, cet), CurrentProduction: 850, CummulatedProduction: 1042, Power: 1200, Period: time.Hour},
+		{Date: time.Date(2020, time.November, 24, 7, 15, 0, 0, cet), Period: 45 * time.Minute},
+		{Date: time.Date(2020, time.November, 24, 8, 0, 0, 0, cet), CurrentProduction: 173, CummulatedProduction: 173, Power: 460, Period: 45 * time.Minute},
+	}
+
+	if len(actual) != len(expected) {
+		t.Fatalf("got %d values, want %d", len(actual), len(expected))
+	}
+	for i, want := range expected {
+		got := actual[i]
+		if !got.Date.Equal(want.Date) || got.CurrentProduction != want.CurrentProduction || got.CummulatedProduction != want.CummulatedProduction ||
+			got.Power != want.Power || got.Period != want.Period {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+	if mean := actual[1].MeanPower(); mean < 250 || mean > 251 {
+		t.Errorf("192 Wh within 46 minutes should be about 250 W, got %f", mean)
+	}
+}
+
+func TestForecastSolarLocation(t *testing.T) {
+	ts := forecastSolarServer(t)
+	defer ts.Close()
+
+	//At UTC-8 the forecast of 9:00 CET starts the 23rd, which ends with the first values of the 24th in CET
+	s := ForecastSolar{Latitude: 48.2, Longitude: 16.37, URL: ts.URL, Planes: []Plane{{30, -90, 4.5}}, Location: time.FixedZone("PST", -8*3600)}
+	actual, err := s.RetrieveForecast(context.Background())
+	if err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+
+	if len(actual) != 5 {
+		t.Fatalf("got %d values, want 5", len(actual))
+	}
+	if want := 22*time.Hour + 15*time.Minute; actual[3].Period != want {
+		t.Errorf("got period %s of %s, want %s", actual[3].Period, actual[3].Date, want)
+	}
+}
+
+func TestForecastSolarToken(t *testing.T) {
+	ts := forecastSolarServer(t)
+	defer ts.Close()
+
+	s := ForecastSolar{Token: "secret", Latitude: 48.2, Longitude: 16.37, URL: ts.URL, Planes: []Plane{{30, -90, 4.5}}}
+	if _, err := s.RetrieveForecast(context.Background()); err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+}
+
+func TestForecastSolarErrors(t *testing.T) {
+	ts := forecastSolarServer(t)
+	defer ts.Close()
+
+	var tests = []struct {
+		testName string
+		planes   []Plane
+		want     string
+	}{
+		{"No planes", nil, "Error while receiving yield forecast data: no planes configured"},
+		{"Rate limit", []Plane{{30, -90, 4.5}, {45, 0, 10}}, "Error while receiving yield forecast data: Rate limit for API calls reached. (code 429)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			s := ForecastSolar{Latitude: 48.2, Longitude: 16.37, URL: ts.URL, Planes: tt.planes}
+			_, err := s.RetrieveForecast(context.Background())
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeanPower(t *testing.T) {
+	var tests = []struct {
+		testName string
+		data     Data
+		want     float64
+	}{
+		{"Hourly", Data{CurrentProduction: 600}, 600},
+		{"Quarter hour", Data{CurrentProduction: 150, Period: 15 * time.Minute}, 600},
+		{"Two hours", Data{CurrentProduction: 600, Period: 2 * time.Hour}, 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if ans := float64(tt.data.MeanPower()); ans != tt.want {
+				t.Errorf("got %f, want %f", ans, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package yield_forecast
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"solargo/inverter"
+	"solargo/weather"
+	"time"
+)
+
+//ModelDays is the number of days forecast by the model, starting today
+const ModelDays = 2
+
+//DefaultLosses of the system in percent, e.g. wiring, soiling, mismatch and inverter efficiency
+const DefaultLosses = 14.0
+
+//DefaultTemperature of the air in °C if no weather forecast is available
+const DefaultTemperature = 20.0
+
+//modelSamples per hour used to integrate the power to energy
+const modelSamples = 4
+
+//Model implementation of the GenericYieldForecast interface, which computes the yield offline from the
+//geometry of the PV array. Without a weather source the yield of a cloudless sky is forecast.
+type Model struct {
+	Latitude      float64
+	Longitude     float64
+	Planes        []Plane
+	Losses        float64           // System losses in percent
+	InverterLimit inverter.Watt     // Maximum AC power of the inverter, 0 if unlimited
+	Location      *time.Location    // Timezone of the day boundaries
+	Weather       weather.ForecastWeather
+}
+
+//RetrieveForecast of today and tomorrow in hourly periods
+func (m *Model) RetrieveForecast(ctx context.Context) ([]Data, error) {
+	if len(m.Planes) == 0 {
+		return nil, fmt.Errorf("Error while computing yield forecast: no planes configured")
+	}
+
+	var forecasts []weather.Forecast
+	if m.Weather != nil {
+		var err error
+		forecasts, err = m.Weather.RetrieveHourlyForecast(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error while computing yield forecast: %s", err)
+		}
+	}
+	return m.Forecast(time.Now(), forecasts), nil
+}
+
+//Forecast the days starting with the one of now in hourly periods, only periods with production are returned
+func (m *Model) Forecast(now time.Time, forecasts []weather.Forecast) []Data {
+	loc := m.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+
+	var data []Data
+	for day := 0; day < ModelDays; day++ {
+		start := time.Date(now.Year(), now.Month(), now.Day()+day, 0, 0, 0, 0, loc)
+		end := time.Date(now.Year(), now.Month(), now.Day()+day+1, 0, 0, 0, 0, loc)
+		var cummulated inverter.WattHour
+		for t := start; t.Before(end); t = t.Add(time.Hour) {
+			energy := m.Energy(t, forecasts)
+			if energy <= 0 {
+				continue
+			}
+			cummulated += energy
+			date := t.Add(time.Hour)
+			data = append(data, Data{
+				Date:                 date,
+				CurrentProduction:    energy,
+				CummulatedProduction: cummulated,
+				Power:                m.Power(date, FindForecast(forecasts, date)),
+			})
+		}
+	}
+	return data
+}
+
+//Energy of the hour starting at start in Wh, the weather forecasts may be empty for a cloudless sky
+func (m *Model) Energy(start time.Time, forecasts []weather.Forecast) inverter.WattHour {
+	var energy inverter.WattHour
+	for i := 0; i < modelSamples; i++ {
+		sample := start.Add(time.Duration(2*i+1) * time.Hour / (2 * modelSamples))
+		energy += m.Power(sample, FindForecast(forecasts, sample)).Over(time.Hour / modelSamples)
+	}
+	return energy
+}
+
+//Power of all planes at time t, the weather forecast may be nil for a cloudless sky
+func (m *Model) Power(t time.Time, forecast *weather.Forecast) inverter.Watt {
+	sun := SolarPosition(t, m.Latitude, m.Longitude)
+	global := ClearSkyIrradiance(sun.Zenith)
+	temperature := DefaultTemperature
+	if forecast != nil {
+		global *= m.cloudFactor(*forecast)
+		temperature = forecast.Temperature
+	}
+	irradiance := Decompose(global, sun, t.YearDay())
+
+	var power float64
+	for _, plane := range m.Planes {
+		poa := PlaneOfArray(plane, sun, irradiance)
+		cell := CellTemperature(temperature, poa)
+		power += plane.KWP * poa * TemperatureFactor(cell)
+	}
+	power *= 1 - m.Losses/100
+	if m.InverterLimit > 0 {
+		power = math.Min(power, float64(m.InverterLimit))
+	}
+	return inverter.Watt(math.Max(0, power))
+}
+
+//cloudFactor reduces the clear sky irradiance, the forecast irradiance is preferred over the cloud cover
+func (m *Model) cloudFactor(f weather.Forecast) float64 {
+	if !f.HasIrradiance {
+		return CloudCoverFactor(f.CloudDensity)
+	}
+
+	//The irradiance is the mean of the period, compare it to the mean of the cloudless sky
+	var clear float64
+	for i := 0; i < modelSamples; i++ {
+		sample := f.Date.Add(time.Duration(2*i+1) * f.Period / (2 * modelSamples))
+		clear += ClearSkyIrradiance(SolarPosition(sample, m.Latitude, m.Longitude).Zenith) / modelSamples
+	}
+	if clear <= 0 {
+		return 0
+	}
+	return math.Min(1.2, f.Irradiance/clear)
+}
+
+//FindForecast of the period containing t, nil if there is none
+func FindForecast(forecasts []weather.Forecast, t time.Time) *weather.Forecast {
+	for i := range forecasts {
+		f := &forecasts[i]
+		if !t.Before(f.Date) && t.Before(f.Date.Add(f.Period)) {
+			return f
+		}
+	}
+	return nil
+}
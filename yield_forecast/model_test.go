@@ -0,0 +1,135 @@
+package yield_forecast
+
+import (
+	"context"
+	"errors"
+	"solargo/inverter"
+	"solargo/weather"
+	"testing"
+	"time"
+)
+
+//modelWeather returns a fixed forecast
+type modelWeather struct {
+	forecasts []weather.Forecast
+	err       error
+}
+
+func (w modelWeather) RetrieveForecast(ctx context.Context) (weather.Data, error) {
+	return weather.Data{}, w.err
+}
+
+func (w modelWeather) RetrieveHourlyForecast(ctx context.Context) ([]weather.Forecast, error) {
+	return w.forecasts, w.err
+}
+
+func dailyYield(data []Data, day time.Time) float64 {
+	var sum float64
+	for _, d := range data {
+		y1, m1, d1 := d.Date.Add(-time.Hour).Date()
+		y2, m2, d2 := day.Date()
+		if y1 == y2 && m1 == m2 && d1 == d2 {
+			sum += float64(d.CurrentProduction)
+		}
+	}
+	return sum
+}
+
+func TestModelDailyYield(t *testing.T) {
+	vienna, _ := time.LoadLocation("Europe/Vienna")
+	tests := []struct {
+		name     string
+		day      time.Time
+		planes   []Plane
+		clouds   float64
+		min, max float64
+	}{
+		//PVGIS estimates about 6 to 7 kWh per kWp on clear days in june and 2 kWh in december for Vienna
+		{"Summer clear sky", time.Date(2020, time.June, 21, 0, 0, 0, 0, vienna), []Plane{{30, 0, 1}}, 0, 6000, 7500},
+		{"Winter clear sky", time.Date(2020, time.December, 21, 0, 0, 0, 0, vienna), []Plane{{30, 0, 1}}, 0, 1500, 2800},
+		{"Summer overcast", time.Date(2020, time.June, 21, 0, 0, 0, 0, vienna), []Plane{{30, 0, 1}}, 100, 1200, 2200},
+		{"East west", time.Date(2020, time.June, 21, 0, 0, 0, 0, vienna), []Plane{{15, -90, 0.5}, {15, 90, 0.5}}, 0, 5500, 7000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := Model{Latitude: 48.2, Longitude: 16.37, Planes: test.planes, Losses: DefaultLosses, Location: vienna}
+			forecasts := []weather.Forecast{{Date: test.day, Period: 48 * time.Hour, CloudDensity: test.clouds, Temperature: 20}}
+			data := m.Forecast(test.day.Add(10*time.Hour), forecasts)
+			if actual := dailyYield(data, test.day); actual < test.min || actual > test.max {
+				t.Errorf("got %.0f Wh, want between %.0f and %.0f", actual, test.min, test.max)
+			}
+		})
+	}
+}
+
+func TestModelForecast(t *testing.T) {
+	vienna, _ := time.LoadLocation("Europe/Vienna")
+	day := time.Date(2020, time.June, 21, 0, 0, 0, 0, vienna)
+	m := Model{Latitude: 48.2, Longitude: 16.37, Planes: []Plane{{30, 0, 10}}, InverterLimit: 5000, Location: vienna}
+	data := m.Forecast(day.Add(10*time.Hour), nil)
+
+	if len(data) == 0 {
+		t.Fatalf("Should forecast production")
+	}
+	var cummulated float64
+	for i, d := range data {
+		if d.Date.Minute() != 0 || d.Period != 0 {
+			t.Errorf("%d: should be an hourly period, got %s %s", i, d.Date, d.Period)
+		}
+		if d.Date.Hour() < 5 || d.Date.Hour() > 22 {
+			t.Errorf("%d: should not produce at night, got %s", i, d.Date)
+		}
+		if d.Power > m.InverterLimit || d.CurrentProduction > m.InverterLimit.Over(time.Hour) {
+			t.Errorf("%d: should be limited by the inverter, got %f W and %f Wh", i, d.Power, d.CurrentProduction)
+		}
+		if i > 0 && data[i-1].Date.Day() != d.Date.Day() {
+			cummulated = 0
+		}
+		cummulated += float64(d.CurrentProduction)
+		if d.CummulatedProduction != inverter.WattHour(cummulated) {
+			t.Errorf("%d: got cummulated %f, want %f", i, d.CummulatedProduction, cummulated)
+		}
+	}
+	if first, last := data[0].Date, data[len(data)-1].Date; !sameDay(first, day) || !sameDay(last, day.AddDate(0, 0, 1)) {
+		t.Errorf("Should forecast today and tomorrow, got %s until %s", first, last)
+	}
+}
+
+func TestModelIrradiance(t *testing.T) {
+	m := Model{Latitude: 48.2, Longitude: 16.37, Planes: []Plane{{30, 0, 1}}}
+	noon := time.Date(2020, time.June, 21, 11, 0, 0, 0, time.UTC)
+	clear := float64(m.Power(noon, nil))
+
+	//A forecast irradiance of half the cloudless sky halves the power, the cloud cover is ignored
+	half := ClearSkyIrradiance(SolarPosition(noon.Add(30*time.Minute), 48.2, 16.37).Zenith) / 2
+	forecast := weather.Forecast{Date: noon.Add(-30 * time.Minute), Period: 2 * time.Hour, Irradiance: half, HasIrradiance: true, CloudDensity: 100, Temperature: DefaultTemperature}
+	actual := float64(m.Power(noon, &forecast))
+	if actual < clear*0.4 || actual > clear*0.6 {
+		t.Errorf("got %f W, want about half of %f W", actual, clear)
+	}
+}
+
+func TestModelErrors(t *testing.T) {
+	m := Model{Latitude: 48.2, Longitude: 16.37}
+	if _, err := m.RetrieveForecast(context.Background()); err == nil {
+		t.Errorf("Should produce an error without planes")
+	}
+
+	m.Planes = []Plane{{30, 0, 1}}
+	m.Weather = modelWeather{err: errors.New("offline")}
+	if _, err := m.RetrieveForecast(context.Background()); err == nil {
+		t.Errorf("Should produce an error if the weather forecast fails")
+	}
+
+	m.Weather = nil
+	if _, err := m.RetrieveForecast(context.Background()); err != nil {
+		t.Errorf("Should not produce an error without weather: %s", err)
+	}
+}
+
+func sameDay(a, b time.Time) bool {
+	y1, m1, d1 := a.Date()
+	y2, m2, d2 := b.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
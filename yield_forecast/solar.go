@@ -0,0 +1,162 @@
+package yield_forecast
+
+import (
+	"math"
+	"time"
+)
+
+//SolarConstant is the extraterrestrial irradiance at the mean distance of the earth to the sun in W/m²
+const SolarConstant = 1367.0
+
+//Albedo of the ground in front of the PV array, typical for grass
+const Albedo = 0.2
+
+//SunPosition in degrees, the azimuth is measured from south like the azimuth of a Plane
+type SunPosition struct {
+	Zenith  float64
+	Azimuth float64
+}
+
+//Elevation of the sun above the horizon in degrees
+func (s SunPosition) Elevation() float64 {
+	return 90 - s.Zenith
+}
+
+func rad(deg float64) float64 { return deg * math.Pi / 180 }
+
+func deg(rad float64) float64 { return rad * 180 / math.Pi }
+
+//SolarPosition at time t using the NOAA solar calculator, which is accurate to about 0.01° between 1800 and 2100
+func SolarPosition(t time.Time, latitude, longitude float64) SunPosition {
+	t = t.UTC()
+	julianDay := float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+	jc := (julianDay - 2451545) / 36525
+
+	meanLongitude := math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	meanAnomaly := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	eccentricity := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	center := math.Sin(rad(meanAnomaly))*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(rad(2*meanAnomaly))*(0.019993-0.000101*jc) +
+		math.Sin(rad(3*meanAnomaly))*0.000289
+	apparentLongitude := meanLongitude + center - 0.00569 - 0.00478*math.Sin(rad(125.04-1934.136*jc))
+	meanObliquity := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliquity := meanObliquity + 0.00256*math.Cos(rad(125.04-1934.136*jc))
+	declination := deg(math.Asin(math.Sin(rad(obliquity)) * math.Sin(rad(apparentLongitude))))
+
+	y := math.Pow(math.Tan(rad(obliquity/2)), 2)
+	equationOfTime := 4 * deg(y*math.Sin(2*rad(meanLongitude))-
+		2*eccentricity*math.Sin(rad(meanAnomaly))+
+		4*eccentricity*y*math.Sin(rad(meanAnomaly))*math.Cos(2*rad(meanLongitude))-
+		0.5*y*y*math.Sin(4*rad(meanLongitude))-
+		1.25*eccentricity*eccentricity*math.Sin(2*rad(meanAnomaly)))
+
+	minutes := float64(t.Hour()*60+t.Minute()) + float64(t.Second())/60 + float64(t.Nanosecond())/6e10
+	trueSolarTime := math.Mod(minutes+equationOfTime+4*longitude, 1440)
+	if trueSolarTime < 0 {
+		trueSolarTime += 1440
+	}
+	hourAngle := trueSolarTime/4 - 180
+
+	cosZenith := math.Sin(rad(latitude))*math.Sin(rad(declination)) +
+		math.Cos(rad(latitude))*math.Cos(rad(declination))*math.Cos(rad(hourAngle))
+	zenith := deg(math.Acos(math.Max(-1, math.Min(1, cosZenith))))
+
+	//Azimuth from north, clockwise
+	azimuth := 180.0
+	if denominator := math.Cos(rad(latitude)) * math.Sin(rad(zenith)); denominator != 0 {
+		cosAzimuth := (math.Sin(rad(latitude))*math.Cos(rad(zenith)) - math.Sin(rad(declination))) / denominator
+		a := deg(math.Acos(math.Max(-1, math.Min(1, cosAzimuth))))
+		if hourAngle > 0 {
+			azimuth = math.Mod(a+180, 360)
+		} else {
+			azimuth = math.Mod(540-a, 360)
+		}
+	}
+	return SunPosition{Zenith: zenith, Azimuth: azimuth - 180}
+}
+
+//ExtraterrestrialIrradiance normal to the sun on the day of the year in W/m²
+func ExtraterrestrialIrradiance(dayOfYear int) float64 {
+	return SolarConstant * (1 + 0.033*math.Cos(2*math.Pi*float64(dayOfYear)/365))
+}
+
+//ClearSkyIrradiance is the global horizontal irradiance of a cloudless sky in W/m² using the Haurwitz model
+func ClearSkyIrradiance(zenith float64) float64 {
+	cosZenith := math.Cos(rad(zenith))
+	if cosZenith <= 0 {
+		return 0
+	}
+	return 1098 * cosZenith * math.Exp(-0.059/cosZenith)
+}
+
+//CloudCoverFactor reduces the clear sky irradiance by the cloud cover in percent using the Kasten-Czeplak model
+func CloudCoverFactor(cloudCover float64) float64 {
+	c := math.Max(0, math.Min(100, cloudCover)) / 100
+	return 1 - 0.75*math.Pow(c, 3.4)
+}
+
+//DiffuseFraction of the global horizontal irradiance using the Erbs model of the clearness index
+func DiffuseFraction(clearness float64) float64 {
+	switch {
+	case clearness <= 0.22:
+		return 1 - 0.09*clearness
+	case clearness <= 0.8:
+		return 0.9511 - 0.1604*clearness + 4.388*math.Pow(clearness, 2) - 16.638*math.Pow(clearness, 3) + 12.336*math.Pow(clearness, 4)
+	default:
+		return 0.165
+	}
+}
+
+//Irradiance on a horizontal plane in W/m², the direct part is measured on the horizontal plane too
+type Irradiance struct {
+	Global  float64
+	Direct  float64
+	Diffuse float64
+}
+
+//Decompose the global horizontal irradiance into its direct and diffuse parts
+func Decompose(global float64, sun SunPosition, dayOfYear int) Irradiance {
+	cosZenith := math.Cos(rad(sun.Zenith))
+	if global <= 0 || cosZenith <= 0 {
+		return Irradiance{}
+	}
+	clearness := math.Min(1, global/(ExtraterrestrialIrradiance(dayOfYear)*cosZenith))
+	diffuse := global * DiffuseFraction(clearness)
+	return Irradiance{Global: global, Direct: global - diffuse, Diffuse: diffuse}
+}
+
+//AngleOfIncidence of the sun on the plane in degrees
+func AngleOfIncidence(plane Plane, sun SunPosition) float64 {
+	cos := math.Cos(rad(sun.Zenith))*math.Cos(rad(plane.Declination)) +
+		math.Sin(rad(sun.Zenith))*math.Sin(rad(plane.Declination))*math.Cos(rad(sun.Azimuth-plane.Azimuth))
+	return deg(math.Acos(math.Max(-1, math.Min(1, cos))))
+}
+
+//PlaneOfArray transposes the horizontal irradiance onto the plane using the isotropic sky model of Liu and Jordan
+func PlaneOfArray(plane Plane, sun SunPosition, irradiance Irradiance) float64 {
+	cosZenith := math.Cos(rad(sun.Zenith))
+	if cosZenith <= 0 {
+		return 0
+	}
+
+	//Avoid huge direct irradiance normal to the sun close to the horizon
+	directNormal := irradiance.Direct / math.Max(cosZenith, 0.065)
+	direct := directNormal * math.Max(0, math.Cos(rad(AngleOfIncidence(plane, sun))))
+	tilt := rad(plane.Declination)
+	diffuse := irradiance.Diffuse * (1 + math.Cos(tilt)) / 2
+	reflected := irradiance.Global * Albedo * (1 - math.Cos(tilt)) / 2
+	return direct + diffuse + reflected
+}
+
+//CellTemperature of the modules in °C for an air temperature and the irradiance on the plane,
+//using a nominal operating cell temperature of 45°C
+func CellTemperature(air, planeOfArray float64) float64 {
+	const noct = 45.0
+	return air + (noct-20)/800*planeOfArray
+}
+
+//TemperatureFactor of the module power, crystalline modules lose 0.4% per °C above 25°C
+func TemperatureFactor(cell float64) float64 {
+	const coefficient = -0.004
+	return 1 + coefficient*(cell-25)
+}
@@ -0,0 +1,126 @@
+package yield_forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSolarPosition(t *testing.T) {
+	tests := []struct {
+		name      string
+		time      time.Time
+		latitude  float64
+		longitude float64
+		zenith    float64
+		azimuth   float64
+	}{
+		//Example of the NREL solar position algorithm, the azimuth is 194.34° from north
+		{"NREL SPA", time.Date(2003, time.October, 17, 12, 30, 30, 0, time.FixedZone("MST", -7*3600)), 39.742476, -105.1786, 50.11162, 14.34024},
+		//The sun is at the zenith of the tropic of cancer on the june solstice at solar noon
+		{"Solstice", time.Date(2020, time.June, 20, 12, 1, 30, 0, time.UTC), 23.44, 0, 0, 0},
+		{"Vienna noon", time.Date(2020, time.June, 21, 12, 56, 20, 0, time.FixedZone("CEST", 2*3600)), 48.2, 16.37, 24.77, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := SolarPosition(test.time, test.latitude, test.longitude)
+			if math.Abs(actual.Zenith-test.zenith) > 0.05 {
+				t.Errorf("Zenith: got %f, want %f", actual.Zenith, test.zenith)
+			}
+			//The azimuth is undefined with the sun at the zenith
+			if test.zenith > 1 && math.Abs(actual.Azimuth-test.azimuth) > 0.5 {
+				t.Errorf("Azimuth: got %f, want %f", actual.Azimuth, test.azimuth)
+			}
+		})
+	}
+}
+
+func TestClearSkyIrradiance(t *testing.T) {
+	tests := []struct {
+		zenith   float64
+		expected float64
+	}{
+		{0, 1035.09},
+		{60, 487.89},
+		{90, 0},
+		{120, 0},
+	}
+
+	for _, test := range tests {
+		if actual := ClearSkyIrradiance(test.zenith); math.Abs(actual-test.expected) > 0.01 {
+			t.Errorf("Zenith %g: got %f, want %f", test.zenith, actual, test.expected)
+		}
+	}
+}
+
+func TestCloudCoverFactor(t *testing.T) {
+	tests := []struct {
+		cloudCover float64
+		expected   float64
+	}{
+		{0, 1},
+		{50, 0.92895},
+		{100, 0.25},
+		{150, 0.25},
+	}
+
+	for _, test := range tests {
+		if actual := CloudCoverFactor(test.cloudCover); math.Abs(actual-test.expected) > 0.0001 {
+			t.Errorf("Cloud cover %g: got %f, want %f", test.cloudCover, actual, test.expected)
+		}
+	}
+}
+
+func TestDiffuseFraction(t *testing.T) {
+	tests := []struct {
+		clearness float64
+		expected  float64
+	}{
+		{0.1, 0.991},
+		{0.5, 0.65915},
+		{0.7, 0.24398},
+		{0.9, 0.165},
+	}
+
+	for _, test := range tests {
+		if actual := DiffuseFraction(test.clearness); math.Abs(actual-test.expected) > 0.0001 {
+			t.Errorf("Clearness %g: got %f, want %f", test.clearness, actual, test.expected)
+		}
+	}
+}
+
+func TestPlaneOfArray(t *testing.T) {
+	sun := SunPosition{Zenith: 30, Azimuth: 0}
+	irradiance := Irradiance{Global: 800, Direct: 600, Diffuse: 200}
+	tests := []struct {
+		name     string
+		plane    Plane
+		expected float64
+	}{
+		//A horizontal plane receives the global irradiance
+		{"Horizontal", Plane{0, 0, 1}, 800},
+		//A plane facing the sun receives the direct normal irradiance
+		{"Facing the sun", Plane{30, 0, 1}, 600/math.Cos(math.Pi/6) + 200*(1+math.Cos(math.Pi/6))/2 + 800*Albedo*(1-math.Cos(math.Pi/6))/2},
+		//A vertical plane facing north is in the shade
+		{"Vertical north", Plane{90, 180, 1}, 200*0.5 + 800*Albedo*0.5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := PlaneOfArray(test.plane, sun, irradiance); math.Abs(actual-test.expected) > 0.01 {
+				t.Errorf("got %f, want %f", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestTemperatureDerating(t *testing.T) {
+	cell := CellTemperature(25, 800)
+	if math.Abs(cell-50) > 0.001 {
+		t.Errorf("Cell temperature: got %f, want 50", cell)
+	}
+	if factor := TemperatureFactor(cell); math.Abs(factor-0.9) > 0.001 {
+		t.Errorf("Temperature factor: got %f, want 0.9", factor)
+	}
+}
@@ -1,10 +1,12 @@
 package yield_forecast
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"solargo/inverter"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -12,6 +14,34 @@ import (
 //SolarPrognoseURL Api endpoint
 const SolarPrognoseURL = "https://www.solarprognose.de"
 
+//Status codes of the solarprognose.de API, all codes except StatusOK are errors
+const (
+	StatusOK                     = 0
+	StatusInvalidAccessToken     = -2
+	StatusAccessDenied           = -8
+	StatusDailyQuotaExceeded     = -19
+	StatusAccessDeniedDueToLimit = -25
+	StatusInvalidAlgorithm       = -28
+)
+
+//solarPrognoseValues per time, the energy of the hour and of the day
+const solarPrognoseValues = 2
+
+//SolarPrognoseError is returned if the API answers with a status other than StatusOK
+type SolarPrognoseError struct {
+	Status  int
+	Message string
+}
+
+func (e *SolarPrognoseError) Error() string {
+	return fmt.Sprintf("Error while receiving yield forecast data: %s (status %d)", e.Message, e.Status)
+}
+
+//RateLimited returns true if the request was refused because of the quota of the account
+func (e *SolarPrognoseError) RateLimited() bool {
+	return e.Status == StatusDailyQuotaExceeded || e.Status == StatusAccessDeniedDueToLimit
+}
+
 //SolarPrognose implementation of the GenericYieldForecast interface
 type SolarPrognose struct {
 	Token     string
@@ -19,40 +49,79 @@ type SolarPrognose struct {
 	ID        string
 	Algorithm string
 	URL       string
+	next      time.Time // Suggested time of the next request
 }
 
-//RetrieveForecast using the solarprognose.de API
-func (o *SolarPrognose) RetrieveForecast() ([]Data, error) {
-	var data []Data
+//solarPrognoseResult of the API, the keys of the data are Unix times and the values the energy of the hour and of the day in kWh
+type solarPrognoseResult struct {
+	Status                    int    `json:"status"`
+	Message                   string `json:"message"`
+	PreferredNextApiRequestAt struct {
+		EpochTimeUtc int64 `json:"epochTimeUtc"`
+	} `json:"preferredNextApiRequestAt"`
+	Data json.RawMessage `json:"data"` // An empty array instead of an object with errors
+}
+
+//NextRequest suggested by the API with the last answer, zero before the first request
+func (o *SolarPrognose) NextRequest() time.Time {
+	return o.next
+}
+
+//RetrieveForecast using the solarprognose.de API, the forecast is sorted by time
+func (o *SolarPrognose) RetrieveForecast(ctx context.Context) ([]Data, error) {
 	uri := fmt.Sprintf("%s/web/solarprediction/api/v1?access-token=%s&item=%s&id=%s&type=hourly&_format=json&algorithm=%s", o.URL, o.Token, o.Type, o.ID, o.Algorithm)
-	httpResult, err := http.Get(uri)
+	httpResult, err := get(ctx, uri)
 
 	if err != nil {
-		return data[:], err
+		return nil, err
 	}
 	defer httpResult.Body.Close()
 
-	type Result struct {
-		Data map[string][]float64 `json:"data"`
+	var result solarPrognoseResult
+	err = json.NewDecoder(httpResult.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("Error while receiving yield forecast data: %s", err)
 	}
 
-	var result Result
-	err = json.NewDecoder(httpResult.Body).Decode(&result)
-	if err != nil || httpResult.StatusCode != http.StatusOK {
-		return data[:], fmt.Errorf("Error while receiving yield forecast data: %s", err)
+	//The suggestion is also sent with errors, e.g. if the quota is exceeded
+	if result.PreferredNextApiRequestAt.EpochTimeUtc > 0 {
+		o.next = time.Unix(result.PreferredNextApiRequestAt.EpochTimeUtc, 0)
+	}
+	if result.Status != StatusOK {
+		return nil, &SolarPrognoseError{Status: result.Status, Message: result.Message}
+	}
+	if httpResult.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error while receiving yield forecast data: %s", httpResult.Status)
+	}
+	var values map[string][]float64
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("Error while receiving yield forecast data: no forecast")
+	}
+	if err := json.Unmarshal(result.Data, &values); err != nil {
+		return nil, fmt.Errorf("Error trying to convert yield forecast data: %s", err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("Error while receiving yield forecast data: no forecast")
 	}
 
-	data = make([]Data, len(result.Data))
-	cnt := 0
-	for k, v := range result.Data {
+	data := make([]Data, 0, len(values))
+	for k, v := range values {
 		i, err := strconv.ParseInt(k, 10, 64)
 		if err != nil {
-			return data, fmt.Errorf("Error trying to convert yield forecast data: %s", err)
+			return nil, fmt.Errorf("Error trying to convert yield forecast data: %s", err)
+		}
+		if len(v) < solarPrognoseValues {
+			return nil, fmt.Errorf("Error trying to convert yield forecast data: %d values at %s, want %d", len(v), k, solarPrognoseValues)
+		}
+		if v[0] < 0 || v[1] < 0 {
+			return nil, fmt.Errorf("Error trying to convert yield forecast data: negative energy at %s", k)
 		}
-		data[cnt].Date = time.Unix(i, 0)
-		data[cnt].CurrentProduction = inverter.WattHour(v[0] * 1000.0)
-		data[cnt].CummulatedProduction = inverter.WattHour(v[1] * 1000.0)
-		cnt++
+		data = append(data, Data{
+			Date:                 time.Unix(i, 0),
+			CurrentProduction:    inverter.WattHour(v[0] * 1000.0),
+			CummulatedProduction: inverter.WattHour(v[1] * 1000.0),
+		})
 	}
-	return data[:], nil
+	sort.Slice(data, func(i, j int) bool { return data[i].Date.Before(data[j].Date) })
+	return data, nil
 }
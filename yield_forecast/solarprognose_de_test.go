@@ -1,6 +1,8 @@
 package yield_forecast
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -14,7 +16,9 @@ const (
 	errorNotJSON    = `{not a json`
 	errorJSONFormat = `{"data":{"XX1576735200":[0,0]}}`
 	//Taken from https://www.solarprognose.de/web/de/solarprediction/page/api
-	validResponse = `{"data":{"1576735200":[0,0],"1576738800":[0.064,0.064],"1576742400":[0.606,0.67]}}`
+	validResponse = `{"preferredNextApiRequestAt":{"secondOfHour":2345,"epochTimeUtc":1576737945},"status":0,"iLastPredictionGenerationEpochTime":1576735512,` +
+		`"data":{"1576742400":[0.606,0.67],"1576735200":[0,0],"1576738800":[0.064,0.064]}}`
+	quotaResponse = `{"preferredNextApiRequestAt":{"secondOfHour":2345,"epochTimeUtc":1576741545},"status":-19,"message":"DAILY QUOTA EXCEEDED","data":[]}`
 )
 
 func TestSolarPrognoseJSONParsingError(t *testing.T) {
@@ -30,7 +34,7 @@ func TestSolarPrognoseJSONParsingError(t *testing.T) {
 		name string
 		f    func() error
 	}{
-		{"RetrieveForecast", func() error { _, err := s.RetrieveForecast(); return err }},
+		{"RetrieveForecast", func() error { _, err := s.RetrieveForecast(context.Background()); return err }},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -55,7 +59,7 @@ func TestSolarPrognoseInvalidJSONFormat(t *testing.T) {
 		name string
 		f    func() error
 	}{
-		{"RetrieveForecast", func() error { _, err := s.RetrieveForecast(); return err }},
+		{"RetrieveForecast", func() error { _, err := s.RetrieveForecast(context.Background()); return err }},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -75,7 +79,7 @@ func TestSolarPrognoseInvalidURLError(t *testing.T) {
 		name string
 		f    func() error
 	}{
-		{"RetrieveForecast", func() error { _, err := s.RetrieveForecast(); return err }},
+		{"RetrieveForecast", func() error { _, err := s.RetrieveForecast(context.Background()); return err }},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -96,7 +100,7 @@ func TestSolarPrognoseSuccess(t *testing.T) {
 	var s SolarPrognose
 	s.URL = ts.URL
 
-	actual, err := s.RetrieveForecast()
+	actual, err := s.RetrieveForecast(context.Background())
 
 	if err != nil {
 		t.Fatalf("Should not produce Error: %s", err)
@@ -120,3 +124,81 @@ func TestSolarPrognoseSuccess(t *testing.T) {
 		}
 	}
 }
+
+func TestSolarPrognoseInvalidPayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"Short array", http.StatusOK, `{"status":0,"data":{"1576735200":[0.1]}}`, "Error trying to convert yield forecast data: 1 values at 1576735200, want 2"},
+		{"Negative energy", http.StatusOK, `{"status":0,"data":{"1576735200":[-0.1,0]}}`, "Error trying to convert yield forecast data: negative energy at 1576735200"},
+		{"No data", http.StatusOK, `{"status":0,"data":{}}`, "Error while receiving yield forecast data: no forecast"},
+		{"Missing data", http.StatusOK, `{"status":0}`, "Error while receiving yield forecast data: no forecast"},
+		{"HTTP error", http.StatusInternalServerError, `{"status":0}`, "Error while receiving yield forecast data: 500 Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprintln(w, tt.body)
+			}))
+			defer ts.Close()
+
+			s := SolarPrognose{URL: ts.URL}
+			data, err := s.RetrieveForecast(context.Background())
+			if err == nil || err.Error() != tt.want || data != nil {
+				t.Errorf("SolarPrognose.de error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSolarPrognoseStatusError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, quotaResponse)
+	}))
+	defer ts.Close()
+
+	s := SolarPrognose{URL: ts.URL}
+	_, err := s.RetrieveForecast(context.Background())
+
+	var apiError *SolarPrognoseError
+	if !errors.As(err, &apiError) {
+		t.Fatalf("Should return a SolarPrognoseError, got %v", err)
+	}
+	if apiError.Status != StatusDailyQuotaExceeded || apiError.Message != "DAILY QUOTA EXCEEDED" || !apiError.RateLimited() {
+		t.Errorf("got %+v", apiError)
+	}
+	if err.Error() != "Error while receiving yield forecast data: DAILY QUOTA EXCEEDED (status -19)" {
+		t.Errorf("got %s", err)
+	}
+	if !s.NextRequest().Equal(time.Unix(1576741545, 0)) {
+		t.Errorf("The next request should be suggested with errors, got %s", s.NextRequest())
+	}
+}
+
+func TestSolarPrognoseNextRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, validResponse)
+	}))
+	defer ts.Close()
+
+	s := SolarPrognose{URL: ts.URL}
+	if !s.NextRequest().IsZero() {
+		t.Errorf("Without request nothing should be suggested, got %s", s.NextRequest())
+	}
+	if _, err := s.RetrieveForecast(context.Background()); err != nil {
+		t.Fatalf("Should not produce Error: %s", err)
+	}
+	if !s.NextRequest().Equal(time.Unix(1576737945, 0)) {
+		t.Errorf("got %s, want %s", s.NextRequest(), time.Unix(1576737945, 0))
+	}
+
+	var throttled interface{} = &s
+	if _, ok := throttled.(Throttled); !ok {
+		t.Errorf("SolarPrognose should be Throttled")
+	}
+}
@@ -1,7 +1,10 @@
-//Package yield_forecast contains a generic yield forecast interface and an solarprognose.de implementation
+//Package yield_forecast contains a generic yield forecast interface and the solarprognose.de and Forecast.Solar implementations
+//as well as an offline model of the PV array and the aggregation of forecasts into daily energy and appliance windows
 package yield_forecast
 
 import (
+	"context"
+	"net/http"
 	"solargo/inverter"
 	"time"
 )
@@ -9,12 +12,51 @@ import (
 //Data of a yield forecast
 type Data struct {
 	Date                 time.Time
-	CurrentProduction    inverter.WattHour
-	CummulatedProduction inverter.WattHour
+	CurrentProduction    inverter.WattHour // Energy of the period ending at Date
+	CummulatedProduction inverter.WattHour // Energy of the day until Date
+	Power                inverter.Watt     // Power at Date, 0 if only the energy is forecast
+	Period               time.Duration     // Length of the period, 0 for hourly periods
+}
+
+//MeanPower during the period, the energy of an hourly period equals its mean power
+func (d Data) MeanPower() inverter.Watt {
+	if d.Period <= 0 {
+		return inverter.Watt(d.CurrentProduction)
+	}
+	return inverter.Watt(float64(d.CurrentProduction) * float64(time.Hour) / float64(d.Period))
+}
+
+//Yield forecast providers which can be selected in the config
+const (
+	ProviderSolarPrognose = "solarprognose"
+	ProviderForecastSolar = "forecast.solar"
+	ProviderModel         = "model"
+)
+
+//Plane of a PV array
+type Plane struct {
+	Declination float64 // Tilt in degrees, 0 is horizontal and 90 vertical
+	Azimuth     float64 // Degrees from south, -90 is east and 90 west
+	KWP         float64 // Installed peak power in kW
 }
 
 //GenericYieldForecast provides an abstraction over a specific forecast source
 type GenericYieldForecast interface {
 	//RetrieveForecast
-	RetrieveForecast() ([]Data, error)
+	RetrieveForecast(ctx context.Context) ([]Data, error)
+}
+
+//Throttled is implemented by providers which suggest when the next forecast should be requested
+type Throttled interface {
+	//NextRequest is the earliest time of the next request, zero if there is no suggestion
+	NextRequest() time.Time
+}
+
+//get the uri, the request is cancelled with the context
+func get(ctx context.Context, uri string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(request)
 }